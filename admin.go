@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// runAdmin implements `server admin bootstrap` and
+// `server admin promote <email> [role]`.
+func runAdmin(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: server admin bootstrap | promote <email> [customer|staff|admin]")
+		os.Exit(2)
+	}
+
+	connectDB()
+	st := store.NewPostgres(db)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "bootstrap":
+		// promote the first user who signed up, but only on a fresh install
+		u, err := st.BootstrapAdmin(ctx)
+		switch {
+		case errors.Is(err, store.ErrAdminExists):
+			log.Fatal("admin bootstrap: an admin already exists; use `admin promote` instead")
+		case errors.Is(err, store.ErrNotFound):
+			log.Fatal("admin bootstrap: no users yet; sign up first")
+		case err != nil:
+			log.Fatalf("admin bootstrap: %v", err)
+		}
+		fmt.Printf("promoted %s (id %d) to admin\n", u.Email, u.ID)
+	case "promote":
+		if len(args) < 2 {
+			log.Fatal("usage: server admin promote <email> [role]")
+		}
+		role := models.RoleAdmin
+		if len(args) > 2 {
+			role = models.Role(args[2])
+		}
+		if !role.Valid() {
+			log.Fatalf("admin promote: unknown role %q", role)
+		}
+		u, err := st.SetUserRole(ctx, args[1], role)
+		if errors.Is(err, store.ErrNotFound) {
+			log.Fatalf("admin promote: no user with email %q", args[1])
+		}
+		if err != nil {
+			log.Fatalf("admin promote: %v", err)
+		}
+		fmt.Printf("set role of %s (id %d) to %s\n", u.Email, u.ID, u.Role)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown admin command %q\n", args[0])
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/jobs"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// newCartExpiry builds the idle cart job. CART_IDLE_AFTER, GUEST_CART_TTL
+// and CART_EXPIRY_INTERVAL (durations such as 24h) override its defaults.
+func newCartExpiry(s store.CartExpiryStore) (*jobs.CartExpiry, error) {
+	j := jobs.NewCartExpiry(s)
+	for env, d := range map[string]*time.Duration{
+		"CART_IDLE_AFTER":      &j.IdleAfter,
+		"GUEST_CART_TTL":       &j.GuestTTL,
+		"CART_EXPIRY_INTERVAL": &j.Interval,
+	} {
+		v := os.Getenv(env)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", env, err)
+		}
+		*d = parsed
+	}
+	return j, nil
+}
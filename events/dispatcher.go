@@ -0,0 +1,163 @@
+// Package events delivers the domain events recorded in the outbox to
+// in-process subscribers.
+package events
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// AllEvents subscribes a handler to every event type.
+const AllEvents = "*"
+
+// Handler processes one event. Delivery is at least once: a handler may
+// see the same event again if it or another subscriber failed, so it
+// should be idempotent on event.ID.
+type Handler func(ctx context.Context, event models.OutboxEvent) error
+
+// Dispatcher polls the outbox and publishes due events to subscribers. An
+// event is marked published only once every subscriber has accepted it;
+// otherwise it is retried with exponential backoff.
+type Dispatcher struct {
+	Store store.OutboxStore
+	// Interval is how long to sleep when the outbox is empty.
+	Interval time.Duration
+	// BatchSize caps the events claimed per poll.
+	BatchSize int
+	// Lease is how long a claimed event is hidden from other dispatchers.
+	Lease time.Duration
+	// EventTimeout bounds the time subscribers may take over one event.
+	// Events are published one after another, so a poll claims no more
+	// than fit in Lease at this pace; otherwise the tail of a slow batch
+	// would be claimed and delivered again by another dispatcher.
+	EventTimeout time.Duration
+	// MaxBackoff caps the delay between retries of a failing event.
+	MaxBackoff time.Duration
+
+	mu   sync.RWMutex
+	subs map[string][]Handler
+}
+
+// NewDispatcher returns a Dispatcher with sensible defaults.
+func NewDispatcher(s store.OutboxStore) *Dispatcher {
+	return &Dispatcher{
+		Store:        s,
+		Interval:     time.Second,
+		BatchSize:    50,
+		Lease:        5 * time.Minute,
+		EventTimeout: 30 * time.Second,
+		MaxBackoff:   time.Hour,
+		subs:         map[string][]Handler{},
+	}
+}
+
+// Subscribe registers h for events of eventType, or AllEvents.
+func (d *Dispatcher) Subscribe(eventType string, h Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subs[eventType] = append(d.subs[eventType], h)
+}
+
+// Run dispatches until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil {
+			log.Printf("events: dispatch failed: %v", err)
+		}
+		if n > 0 && err == nil {
+			continue // there may be more waiting
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.Interval):
+		}
+	}
+}
+
+// DispatchOnce claims one batch of due events and publishes it, returning
+// how many events were claimed.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	batch, err := d.Store.ClaimOutboxEvents(ctx, d.batchSize(), d.Lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, ev := range batch {
+		if err := d.publishWithin(ctx, ev); err != nil {
+			retryAt := time.Now().Add(d.backoff(ev.Attempts + 1))
+			if err := d.Store.MarkOutboxFailed(ctx, ev.ID, err.Error(), retryAt); err != nil {
+				return len(batch), err
+			}
+			continue
+		}
+		if err := d.Store.MarkOutboxPublished(ctx, ev.ID); err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+// batchSize returns how many events to claim: BatchSize, capped so that
+// publishing every one for up to EventTimeout still ends within Lease.
+func (d *Dispatcher) batchSize() int {
+	n := d.BatchSize
+	if d.EventTimeout > 0 {
+		if fit := int(d.Lease / d.EventTimeout); fit < n {
+			n = fit
+		}
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// publishWithin publishes ev, giving up once EventTimeout has passed.
+func (d *Dispatcher) publishWithin(ctx context.Context, ev models.OutboxEvent) error {
+	if d.EventTimeout <= 0 {
+		return d.publish(ctx, ev)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.EventTimeout)
+	defer cancel()
+	return d.publish(ctx, ev)
+}
+
+// publish hands ev to each subscriber, stopping at the first failure.
+func (d *Dispatcher) publish(ctx context.Context, ev models.OutboxEvent) (err error) {
+	d.mu.RLock()
+	handlers := append(append([]Handler(nil), d.subs[ev.Type]...), d.subs[AllEvents]...)
+	d.mu.RUnlock()
+
+	defer func() {
+		// a panicking subscriber must not take the dispatcher down
+		if r := recover(); r != nil {
+			err = fmt.Errorf("subscriber panicked: %v", r)
+		}
+	}()
+	for _, h := range handlers {
+		if err := h(ctx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backoff returns the delay before the given delivery attempt: one second,
+// doubling each time, up to MaxBackoff.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := time.Second
+	for i := 1; i < attempt && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.MaxBackoff {
+		delay = d.MaxBackoff
+	}
+	return delay
+}
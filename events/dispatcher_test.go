@@ -0,0 +1,109 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func TestDispatcherDeliversAndRetries(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	st.CreateUser(ctx, &models.User{Email: "a@example.com"})
+	p := models.Product{Name: "Mug", Price: 500, Currency: "USD"}
+	st.CreateProduct(ctx, &p)
+	p.Price = 600
+	st.UpdateProduct(ctx, &p)
+
+	d := NewDispatcher(st)
+	var seen []string
+	fail := true
+	d.Subscribe(AllEvents, func(_ context.Context, ev models.OutboxEvent) error {
+		seen = append(seen, ev.Type)
+		return nil
+	})
+	d.Subscribe(models.EventProductPriceChanged, func(context.Context, models.OutboxEvent) error {
+		if fail {
+			fail = false
+			return errors.New("subscriber down")
+		}
+		return nil
+	})
+
+	if n, err := d.DispatchOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("DispatchOnce = %d, %v; want 2, nil", n, err)
+	}
+	evs := st.OutboxEvents()
+	if evs[0].PublishedAt == nil {
+		t.Errorf("signup event not published")
+	}
+	if evs[1].PublishedAt != nil || evs[1].Attempts != 1 || evs[1].LastError != "subscriber down" {
+		t.Fatalf("failed event = %+v; want unpublished with one attempt", evs[1])
+	}
+
+	// not due again until the backoff elapses
+	if n, _ := d.DispatchOnce(ctx); n != 0 {
+		t.Fatalf("redelivered %d events before backoff", n)
+	}
+	st.MarkOutboxFailed(ctx, evs[1].ID, "subscriber down", time.Now())
+	if n, err := d.DispatchOnce(ctx); n != 1 || err != nil {
+		t.Fatalf("retry = %d, %v; want 1, nil", n, err)
+	}
+	if st.OutboxEvents()[1].PublishedAt == nil {
+		t.Errorf("price change not published after retry")
+	}
+	// type-specific subscribers run first, so the catch-all only saw the
+	// price change once it went through
+	want := []string{models.EventUserSignedUp, models.EventProductPriceChanged}
+	if len(seen) != len(want) || seen[0] != want[0] || seen[1] != want[1] {
+		t.Fatalf("seen = %v; want %v", seen, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(store.NewMemory())
+	d.MaxBackoff = 10 * time.Second
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 9: 10 * time.Second} {
+		if got := d.backoff(attempt); got != want {
+			t.Errorf("backoff(%d) = %v; want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestDispatcherBatchFitsLease(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		st.CreateUser(ctx, &models.User{Email: email})
+	}
+
+	d := NewDispatcher(st)
+	d.Lease = 100 * time.Millisecond
+	d.EventTimeout = 40 * time.Millisecond
+	d.Subscribe(AllEvents, func(ctx context.Context, _ models.OutboxEvent) error {
+		<-ctx.Done() // a subscriber that never answers
+		return ctx.Err()
+	})
+
+	// only two events can be published within the lease
+	start := time.Now()
+	if n, err := d.DispatchOnce(ctx); n != 2 || err != nil {
+		t.Fatalf("DispatchOnce = %d, %v; want 2, nil", n, err)
+	}
+	if took := time.Since(start); took >= d.Lease {
+		t.Fatalf("batch took %v; want less than the %v lease", took, d.Lease)
+	}
+	evs := st.OutboxEvents()
+	for _, ev := range evs[:2] {
+		if ev.PublishedAt != nil || ev.Attempts != 1 || ev.LastError != context.DeadlineExceeded.Error() {
+			t.Errorf("timed out event = %+v; want one failed attempt", ev)
+		}
+	}
+	if evs[2].Attempts != 0 {
+		t.Errorf("third event attempted in the first batch: %+v", evs[2])
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// AddressHandler manages the caller's address book
+type AddressHandler struct {
+	Store store.AddressStore
+}
+
+// NewAddressHandler constructs an AddressHandler
+func NewAddressHandler(s store.AddressStore) *AddressHandler {
+	return &AddressHandler{Store: s}
+}
+
+var countryCode = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// addressInput is the body of create and update requests.
+type addressInput struct {
+	Name       string `json:"name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone"`
+	IsDefault  bool   `json:"is_default"`
+}
+
+// decode reads and validates an addressInput, writing the error and
+// returning false if it is unacceptable.
+func (in *addressInput) decode(w http.ResponseWriter, r *http.Request) bool {
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return false
+	}
+	for _, f := range []*string{&in.Name, &in.Line1, &in.Line2, &in.City, &in.Region, &in.PostalCode, &in.Phone} {
+		*f = strings.TrimSpace(*f)
+	}
+	in.Country = strings.ToUpper(strings.TrimSpace(in.Country))
+	in.Region = strings.ToUpper(in.Region)
+	switch {
+	case in.Name == "" || in.Line1 == "" || in.City == "":
+		http.Error(w, "name, line1 and city are required", http.StatusBadRequest)
+		return false
+	case !countryCode.MatchString(in.Country):
+		http.Error(w, "country must be a two-letter ISO 3166 code", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// apply copies in onto a.
+func (in addressInput) apply(a *models.Address) {
+	a.Name, a.Line1, a.Line2, a.City = in.Name, in.Line1, in.Line2, in.City
+	a.Region, a.PostalCode, a.Country, a.Phone = in.Region, in.PostalCode, in.Country, in.Phone
+	a.IsDefault = in.IsDefault
+}
+
+// Create handles POST /users/me/addresses. The caller's first address
+// becomes their default.
+func (h *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in addressInput
+	if !in.decode(w, r) {
+		return
+	}
+	a := models.Address{UserID: r.Context().Value(ContextUserID).(int)}
+	in.apply(&a)
+	if err := h.Store.CreateAddress(r.Context(), &a); err != nil {
+		http.Error(w, "failed to create address", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+// List handles GET /users/me/addresses
+func (h *AddressHandler) List(w http.ResponseWriter, r *http.Request) {
+	addrs, err := h.Store.ListAddresses(r.Context(), r.Context().Value(ContextUserID).(int))
+	if err != nil {
+		http.Error(w, "failed to fetch addresses", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(addrs)
+}
+
+// Get handles GET /users/me/addresses/{addressID}
+func (h *AddressHandler) Get(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.address(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+// Update handles PUT /users/me/addresses/{addressID}
+func (h *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.address(w, r)
+	if !ok {
+		return
+	}
+	var in addressInput
+	if !in.decode(w, r) {
+		return
+	}
+	in.apply(&a)
+	err := h.Store.UpdateAddress(r.Context(), &a)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update address", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+// Delete handles DELETE /users/me/addresses/{addressID}. Orders already
+// shipped there keep their copy of the address.
+func (h *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.address(w, r)
+	if !ok {
+		return
+	}
+	err := h.Store.DeleteAddress(r.Context(), a.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete address", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// address loads the caller's address named by the addressID URL parameter,
+// writing an error and returning false if there is none.
+func (h *AddressHandler) address(w http.ResponseWriter, r *http.Request) (models.Address, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "addressID"))
+	if err != nil {
+		http.Error(w, "invalid address ID", http.StatusBadRequest)
+		return models.Address{}, false
+	}
+	return userAddress(w, r, h.Store, id)
+}
+
+// userAddress loads address id, writing an error and returning false if it
+// does not exist or belongs to someone other than the caller.
+func userAddress(w http.ResponseWriter, r *http.Request, addrs store.AddressStore, id int) (models.Address, bool) {
+	a, err := addrs.GetAddress(r.Context(), id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "failed to fetch address", http.StatusInternalServerError)
+		return models.Address{}, false
+	}
+	userID, _ := r.Context().Value(ContextUserID).(int)
+	if err != nil || a.UserID != userID {
+		http.Error(w, "address not found", http.StatusNotFound)
+		return models.Address{}, false
+	}
+	return a, true
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func TestCreateAddress(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"name":"Ada","line1":"1 Main St","city":"Springfield","region":"il","country":"us"}`, http.StatusCreated},
+		{"missing city", `{"name":"Ada","line1":"1 Main St","country":"US"}`, http.StatusBadRequest},
+		{"bad country", `{"name":"Ada","line1":"1 Main St","city":"Springfield","country":"USA"}`, http.StatusBadRequest},
+		{"invalid JSON", `{"name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewAddressHandler(store.NewMemory())
+			w := httptest.NewRecorder()
+			h.Create(w, withUser(httptest.NewRequest("POST", "/users/me/addresses", bytes.NewBufferString(tt.body)), 42))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+// seedAddress stores a US address for userID.
+func seedAddress(t *testing.T, st *store.Memory, userID int, region string) models.Address {
+	t.Helper()
+	a := models.Address{UserID: userID, Name: "Ada", Line1: "1 Main St", City: "Springfield", Region: region, Country: "US"}
+	if err := st.CreateAddress(context.Background(), &a); err != nil {
+		t.Fatalf("seed address: %v", err)
+	}
+	return a
+}
+
+func TestAddresses_Default(t *testing.T) {
+	st := store.NewMemory()
+	first := seedAddress(t, st, 42, "IL")
+	if !first.IsDefault {
+		t.Fatalf("first address is not the default")
+	}
+
+	h := NewAddressHandler(st)
+	w := httptest.NewRecorder()
+	h.Create(w, withUser(httptest.NewRequest("POST", "/users/me/addresses", bytes.NewBufferString(
+		`{"name":"Ada","line1":"2 Side St","city":"Sacramento","region":"CA","country":"US","is_default":true}`)), 42))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create status = %d; want %d", w.Code, http.StatusCreated)
+	}
+
+	w = httptest.NewRecorder()
+	h.List(w, withUser(httptest.NewRequest("GET", "/users/me/addresses", nil), 42))
+	var got []models.Address
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(got) != 2 || got[0].City != "Sacramento" || !got[0].IsDefault || got[1].IsDefault {
+		t.Errorf("got %+v; want the new address first and the only default", got)
+	}
+}
+
+func TestAddresses_OtherUser(t *testing.T) {
+	st := store.NewMemory()
+	a := seedAddress(t, st, 42, "IL")
+	h := NewAddressHandler(st)
+
+	for name, call := range map[string]func(http.ResponseWriter, *http.Request){
+		"Get": h.Get, "Update": h.Update, "Delete": h.Delete,
+	} {
+		req := withURLParams(withUser(httptest.NewRequest("PUT", "/users/me/addresses/1", bytes.NewBufferString(
+			`{"name":"Eve","line1":"Elsewhere","city":"Nowhere","country":"US"}`)), 7), "addressID", "1")
+		w := httptest.NewRecorder()
+		call(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d; want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+	if got, _ := st.GetAddress(context.Background(), a.ID); got.Name != "Ada" {
+		t.Errorf("address changed to %+v", got)
+	}
+}
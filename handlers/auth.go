@@ -1,28 +1,53 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
 )
 
-// AuthHandler holds DB and JWT config
+// Default token lifetimes. Access tokens are short-lived; clients renew
+// them with the refresh token returned alongside.
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
+// AuthHandler holds the user, token and cart stores, JWT config and the
+// limits a guest cart merged at login is held to
 type AuthHandler struct {
-	DB        *sqlx.DB
-	JWTSecret string
+	Users      store.UserStore
+	Tokens     store.TokenStore
+	Carts      store.CartStore
+	JWTSecret  string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	CartLimits store.CartLimits
 }
 
-// NewAuthHandler constructs an AuthHandler
-func NewAuthHandler(db *sqlx.DB, secret string) *AuthHandler {
+// NewAuthHandler constructs an AuthHandler with the default token lifetimes
+func NewAuthHandler(users store.UserStore, tokens store.TokenStore, carts store.CartStore, secret string) *AuthHandler {
 	return &AuthHandler{
-		DB:        db,
-		JWTSecret: secret,
+		Users:      users,
+		Tokens:     tokens,
+		Carts:      carts,
+		JWTSecret:  secret,
+		AccessTTL:  DefaultAccessTTL,
+		RefreshTTL: DefaultRefreshTTL,
+		CartLimits: store.CartLimits{MaxLine: DefaultMaxLineQuantity, MaxTotal: DefaultMaxCartQuantity},
 	}
 }
 
@@ -43,12 +68,12 @@ func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// insert user
-	var user models.User
-	insert := `INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id, email, created_at`
-	if err := h.DB.QueryRowx(insert, inp.Email, string(hash)).StructScan(&user); err != nil {
+	user := models.User{Email: inp.Email, PasswordHash: string(hash)}
+	if err := h.Users.CreateUser(r.Context(), &user); err != nil {
 		http.Error(w, "failed to create user", http.StatusInternalServerError)
 		return
 	}
+	h.mergeGuestCart(r, user.ID)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
@@ -65,8 +90,8 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// fetch user
-	var user models.User
-	if err := h.DB.Get(&user, "SELECT * FROM users WHERE email=$1", inp.Email); err != nil {
+	user, err := h.Users.GetUserByEmail(r.Context(), inp.Email)
+	if err != nil {
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
@@ -75,10 +100,140 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
 		return
 	}
-	// create JWT
+	h.mergeGuestCart(r, user.ID)
+	// start a new refresh token family for this session
+	family, err := randomToken(16)
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	refresh, rt, err := h.newRefreshToken()
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	rt.UserID = user.ID
+	rt.FamilyID = family
+	if err := h.Tokens.CreateRefreshToken(r.Context(), &rt); err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	h.writeTokens(w, user, refresh)
+}
+
+// Refresh handles POST /users/refresh. The presented refresh token is
+// rotated: it stops working and a new one is returned with the access token.
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var inp struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&inp); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if inp.RefreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+	refresh, next, err := h.newRefreshToken()
+	if err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	old, err := h.Tokens.RotateRefreshToken(r.Context(), hashToken(inp.RefreshToken), &next)
+	switch {
+	case errors.Is(err, store.ErrRefreshTokenReused):
+		http.Error(w, "refresh token reused; please log in again", http.StatusUnauthorized)
+		return
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrRefreshTokenExpired):
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+	// re-read the user so role changes take effect on refresh
+	user, err := h.Users.GetUser(r.Context(), old.UserID)
+	if err != nil {
+		http.Error(w, "invalid refresh token", http.StatusUnauthorized)
+		return
+	}
+	h.writeTokens(w, user, refresh)
+}
+
+// Logout handles POST /users/logout. It revokes the caller's access token
+// and, if one is given, the session behind the refresh token.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var inp struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// the body is optional: without it only the access token is revoked
+	if err := json.NewDecoder(r.Body).Decode(&inp); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := r.Context().Value(ContextUserID).(int)
+	if inp.RefreshToken != "" {
+		err := h.Tokens.RevokeRefreshFamily(r.Context(), userID, hashToken(inp.RefreshToken))
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "invalid refresh token", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	jti, _ := r.Context().Value(ContextTokenID).(string)
+	exp, _ := r.Context().Value(ContextTokenExpiry).(time.Time)
+	if err := h.Tokens.RevokeAccessToken(r.Context(), jti, exp); err != nil {
+		http.Error(w, "failed to log out", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mergeGuestCart moves the guest cart named by the cart token header, if
+// any, into the user's active cart; see store.CartStore.MergeCart for how
+// quantities combine. Invalid tokens and carts that are gone or already
+// merged are ignored. Other failures are only logged: the user has signed
+// up or logged in regardless, and the guest cart stays active for them to
+// retry with.
+func (h *AuthHandler) mergeGuestCart(r *http.Request, userID int) {
+	cartID, ok := parseCartToken(h.JWTSecret, r.Header.Get(CartTokenHeader))
+	if !ok {
+		return
+	}
+	if _, err := h.Carts.MergeCart(r.Context(), cartID, userID, h.CartLimits); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("auth: merging guest cart %d into user %d: %v", cartID, userID, err)
+	}
+}
+
+// newRefreshToken returns a fresh opaque refresh token and the record to
+// store for it. The caller fills in the user and family.
+func (h *AuthHandler) newRefreshToken() (string, models.RefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+	return raw, models.RefreshToken{
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(h.RefreshTTL),
+	}, nil
+}
+
+// writeTokens signs an access token for user and writes it with refresh.
+func (h *AuthHandler) writeTokens(w http.ResponseWriter, user models.User, refresh string) {
+	jti, err := randomToken(16)
+	if err != nil {
+		http.Error(w, "failed to sign token", http.StatusInternalServerError)
+		return
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 72).Unix(),
+		"sub":  user.ID,
+		"role": user.Role,
+		"jti":  jti,
+		"exp":  time.Now().Add(h.AccessTTL).Unix(),
 	})
 	signed, err := token.SignedString([]byte(h.JWTSecret))
 	if err != nil {
@@ -86,7 +241,25 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": signed})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":         signed,
+		"refresh_token": refresh,
+		"expires_in":    int(h.AccessTTL.Seconds()),
+	})
 }
 
-// Logout or other auth endpoints can be added later
+// randomToken returns n random bytes, URL-safe base64 encoded.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a refresh token, which is what the
+// store keeps instead of the token itself.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,74 +2,56 @@ package handlers
 
 import (
 	"bytes"
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/jmoiron/sqlx"
-	"github.com/lib/pq"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-)
 
-// setupMockDB gives you a sqlx.DB backed by sqlmock
-func setupMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to open sqlmock: %v", err)
-	}
-	return sqlx.NewDb(db, "sqlmock"), mock
-}
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
 
 func TestSignup(t *testing.T) {
 	tests := []struct {
 		name       string
 		body       string
-		mockSetup  func(sqlmock.Sqlmock)
+		seed       func(*store.Memory)
 		wantStatus int
 	}{
 		{
 			name:       "invalid JSON",
 			body:       `{"email":`,
-			mockSetup:  func(m sqlmock.Sqlmock) {},
+			seed:       func(*store.Memory) {},
 			wantStatus: http.StatusBadRequest,
 		},
 		{
 			name: "duplicate email",
 			body: `{"email":"dupe@example.com","password":"pass"}`,
-			mockSetup: func(m sqlmock.Sqlmock) {
-				// INSERT ... RETURNING returns a unique violation
-				m.ExpectQuery(`INSERT INTO users`).
-					WithArgs("dupe@example.com", sqlmock.AnyArg()).
-					WillReturnError(&pq.Error{Code: "23505"})
+			seed: func(st *store.Memory) {
+				st.CreateUser(context.Background(), &models.User{Email: "dupe@example.com"})
 			},
 			wantStatus: http.StatusInternalServerError, // handler maps all errors to 500
 		},
 		{
-			name: "successful signup",
-			body: `{"email":"new@example.com","password":"pass"}`,
-			mockSetup: func(m sqlmock.Sqlmock) {
-				now := time.Now()
-				m.ExpectQuery(`INSERT INTO users .*RETURNING id, email, created_at`).
-					WithArgs("new@example.com", sqlmock.AnyArg()).
-					WillReturnRows(sqlmock.
-						NewRows([]string{"id", "email", "created_at"}).
-						AddRow(1, "new@example.com", now),
-					)
-			},
+			name:       "successful signup",
+			body:       `{"email":"new@example.com","password":"pass"}`,
+			seed:       func(*store.Memory) {},
 			wantStatus: http.StatusCreated,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db, mock := setupMockDB(t)
-			tt.mockSetup(mock)
+			st := store.NewMemory()
+			tt.seed(st)
 
-			ah := NewAuthHandler(db, "secret")
+			ah := NewAuthHandler(st, st, st, "secret")
 			req := httptest.NewRequest("POST", "/users/signup", bytes.NewBufferString(tt.body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
@@ -78,9 +60,6 @@ func TestSignup(t *testing.T) {
 			if w.Code != tt.wantStatus {
 				t.Errorf("signup status = %d; want %d", w.Code, tt.wantStatus)
 			}
-			if err := mock.ExpectationsWereMet(); err != nil {
-				t.Errorf("unmet expectations: %v", err)
-			}
 		})
 	}
 }
@@ -92,37 +71,27 @@ func TestLogin(t *testing.T) {
 	tests := []struct {
 		name       string
 		body       string
-		mockSetup  func(sqlmock.Sqlmock)
+		seed       func(*store.Memory)
 		wantStatus int
 		wantToken  bool
 	}{
 		{
 			name:       "invalid JSON",
 			body:       `{"email":`,
-			mockSetup:  func(m sqlmock.Sqlmock) {},
+			seed:       func(*store.Memory) {},
 			wantStatus: http.StatusBadRequest,
 		},
 		{
-			name: "wrong credentials",
-			body: `{"email":"noone@example.com","password":"bad"}`,
-			mockSetup: func(m sqlmock.Sqlmock) {
-				m.ExpectQuery(`SELECT \* FROM users WHERE email=\$1`).
-					WithArgs("noone@example.com").
-					WillReturnError(sql.ErrNoRows)
-			},
+			name:       "wrong credentials",
+			body:       `{"email":"noone@example.com","password":"bad"}`,
+			seed:       func(*store.Memory) {},
 			wantStatus: http.StatusUnauthorized,
 		},
 		{
 			name: "successful login",
 			body: `{"email":"user@example.com","password":"` + pw + `"}`,
-			mockSetup: func(m sqlmock.Sqlmock) {
-				now := time.Now()
-				m.ExpectQuery(`SELECT \* FROM users WHERE email=\$1`).
-					WithArgs("user@example.com").
-					WillReturnRows(sqlmock.
-						NewRows([]string{"id", "email", "password_hash", "created_at"}).
-						AddRow(42, "user@example.com", string(hash), now),
-					)
+			seed: func(st *store.Memory) {
+				st.CreateUser(context.Background(), &models.User{Email: "user@example.com", PasswordHash: string(hash)})
 			},
 			wantStatus: http.StatusOK,
 			wantToken:  true,
@@ -131,10 +100,10 @@ func TestLogin(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db, mock := setupMockDB(t)
-			tt.mockSetup(mock)
+			st := store.NewMemory()
+			tt.seed(st)
 
-			ah := NewAuthHandler(db, "secret")
+			ah := NewAuthHandler(st, st, st, "secret")
 			req := httptest.NewRequest("POST", "/users/login", bytes.NewBufferString(tt.body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
@@ -145,18 +114,190 @@ func TestLogin(t *testing.T) {
 			}
 			if tt.wantToken {
 				var resp struct {
-					Token string `json:"token"`
+					Token        string `json:"token"`
+					RefreshToken string `json:"refresh_token"`
 				}
 				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
 					t.Fatalf("failed to parse JSON: %v", err)
 				}
-				if resp.Token == "" {
-					t.Errorf("expected non-empty token")
+				if resp.Token == "" || resp.RefreshToken == "" {
+					t.Errorf("expected non-empty access and refresh tokens")
+				}
+				claims := jwt.MapClaims{}
+				if _, err := jwt.ParseWithClaims(resp.Token, claims, func(*jwt.Token) (interface{}, error) {
+					return []byte("secret"), nil
+				}); err != nil {
+					t.Fatalf("parse token: %v", err)
+				}
+				if claims["role"] != "customer" {
+					t.Errorf("role claim = %v; want customer", claims["role"])
 				}
-			}
-			if err := mock.ExpectationsWereMet(); err != nil {
-				t.Errorf("unmet expectations: %v", err)
 			}
 		})
 	}
 }
+
+// loginTokens signs up a user in st and logs in, returning the access and
+// refresh tokens.
+func loginTokens(t *testing.T, ah *AuthHandler) (access, refresh string) {
+	t.Helper()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	ah.Users.CreateUser(context.Background(), &models.User{Email: "u@example.com", PasswordHash: string(hash)})
+	w := httptest.NewRecorder()
+	ah.Login(w, httptest.NewRequest("POST", "/users/login",
+		bytes.NewBufferString(`{"email":"u@example.com","password":"pw"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d; want %d", w.Code, http.StatusOK)
+	}
+	return decodeTokens(t, w)
+}
+
+func decodeTokens(t *testing.T, w *httptest.ResponseRecorder) (access, refresh string) {
+	t.Helper()
+	var resp struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	return resp.Token, resp.RefreshToken
+}
+
+func refresh(ah *AuthHandler, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	ah.Refresh(w, httptest.NewRequest("POST", "/users/refresh",
+		bytes.NewBufferString(`{"refresh_token":"`+token+`"}`)))
+	return w
+}
+
+func TestRefresh_RotatesAndDetectsReuse(t *testing.T) {
+	st := store.NewMemory()
+	ah := NewAuthHandler(st, st, st, "secret")
+	_, first := loginTokens(t, ah)
+
+	w := refresh(ah, first)
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh status = %d; want %d", w.Code, http.StatusOK)
+	}
+	_, second := decodeTokens(t, w)
+	if second == "" || second == first {
+		t.Fatalf("refresh did not rotate the token")
+	}
+
+	// replaying the rotated token revokes the whole family
+	if w := refresh(ah, first); w.Code != http.StatusUnauthorized {
+		t.Errorf("reused token status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := refresh(ah, second); w.Code != http.StatusUnauthorized {
+		t.Errorf("token after reuse status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	st := store.NewMemory()
+	ah := NewAuthHandler(st, st, st, "secret")
+	ah.RefreshTTL = -time.Minute
+	_, token := loginTokens(t, ah)
+
+	if w := refresh(ah, token); w.Code != http.StatusUnauthorized {
+		t.Errorf("expired token status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	st := store.NewMemory()
+	ah := NewAuthHandler(st, st, st, "secret")
+	access, refreshToken := loginTokens(t, ah)
+
+	protected := AuthMiddleware("secret", st)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	call := func(h http.Handler, body string) int {
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		req.Header.Set("Authorization", "Bearer "+access)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := call(AuthMiddleware("secret", st)(http.HandlerFunc(ah.Logout)),
+		`{"refresh_token":"`+refreshToken+`"}`); code != http.StatusNoContent {
+		t.Fatalf("logout status = %d; want %d", code, http.StatusNoContent)
+	}
+	if code := call(protected, ""); code != http.StatusUnauthorized {
+		t.Errorf("revoked access token status = %d; want %d", code, http.StatusUnauthorized)
+	}
+	if w := refresh(ah, refreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogin_MergesGuestCart(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	user := models.User{Email: "u@example.com", PasswordHash: string(hash)}
+	st.CreateUser(ctx, &user)
+	a := models.Product{Name: "A", Price: 100, Currency: "USD", StockQuantity: 10}
+	b := models.Product{Name: "B", Price: 200, Currency: "USD", StockQuantity: 10}
+	st.CreateProduct(ctx, &a)
+	st.CreateProduct(ctx, &b)
+
+	own, _, _ := st.ActiveCart(ctx, user.ID)
+	st.AddCartItem(ctx, own.ID, a.ID, nil, 2)
+	guest, _ := st.CreateGuestCart(ctx)
+	st.AddCartItem(ctx, guest.ID, a.ID, nil, 5)
+	st.AddCartItem(ctx, guest.ID, b.ID, nil, 1)
+
+	ah := NewAuthHandler(st, st, st, "secret")
+	req := httptest.NewRequest("POST", "/users/login",
+		bytes.NewBufferString(`{"email":"u@example.com","password":"pw"}`))
+	req.Header.Set(CartTokenHeader, signCartToken("secret", guest.ID))
+	w := httptest.NewRecorder()
+	ah.Login(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d; want %d", w.Code, http.StatusOK)
+	}
+	lines, _ := st.CartLines(ctx, own.ID)
+	if len(lines) != 2 || lines[0].Quantity != 5 || lines[1].Quantity != 1 {
+		t.Errorf("got lines %+v; want A×5 (larger quantity wins) and B×1", lines)
+	}
+	if g, _ := st.GetCart(ctx, guest.ID); g.Status != models.CartMerged {
+		t.Errorf("guest cart status = %q; want %q", g.Status, models.CartMerged)
+	}
+
+	// logging in again with the stale token changes nothing
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/users/login",
+		bytes.NewBufferString(`{"email":"u@example.com","password":"pw"}`))
+	req.Header.Set(CartTokenHeader, signCartToken("secret", guest.ID))
+	ah.Login(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("second login status = %d; want %d", w.Code, http.StatusOK)
+	}
+}
+
+// failingMerge is a cart store whose MergeCart always fails.
+type failingMerge struct{ *store.Memory }
+
+func (failingMerge) MergeCart(context.Context, int, int, store.CartLimits) (models.Cart, error) {
+	return models.Cart{}, errors.New("boom")
+}
+
+func TestSignup_MergeFailureIsNotFatal(t *testing.T) {
+	st := store.NewMemory()
+	guest, _ := st.CreateGuestCart(context.Background())
+	ah := NewAuthHandler(st, st, failingMerge{st}, "secret")
+	req := httptest.NewRequest("POST", "/users/signup",
+		bytes.NewBufferString(`{"email":"new@example.com","password":"pw"}`))
+	req.Header.Set(CartTokenHeader, signCartToken("secret", guest.ID))
+	w := httptest.NewRecorder()
+	ah.Signup(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("signup status = %d; want %d (%s)", w.Code, http.StatusCreated, w.Body)
+	}
+	if g, _ := st.GetCart(context.Background(), guest.ID); g.Status != models.CartActive {
+		t.Errorf("guest cart status = %q; want it still %q", g.Status, models.CartActive)
+	}
+}
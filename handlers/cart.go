@@ -1,116 +1,589 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/shipping"
+	"github.com/Heisenberg270/ecommerce-go/store"
+	"github.com/Heisenberg270/ecommerce-go/tax"
 )
 
-// CartHandler holds the DB reference.
+// Default cart limits: how many units of one product a cart may hold, and
+// how many units in total.
+const (
+	DefaultMaxLineQuantity = 99
+	DefaultMaxCartQuantity = 500
+)
+
+// CartHandler holds the cart, product, variant, promotion and address
+// stores, the shipping rates, cart limits and the secret guest cart tokens
+// are signed with. Tax works out the tax shown in cart totals, for the
+// region the cart is headed to, else TaxRegion.
 type CartHandler struct {
-	DB *sqlx.DB
+	Carts           store.CartStore
+	Products        store.ProductStore
+	Variants        store.VariantStore
+	Promotions      store.PromotionStore
+	Addresses       store.AddressStore
+	Shipping        shipping.RateProvider
+	Tax             tax.Calculator
+	TaxRegion       string
+	TokenSecret     string
+	MaxLineQuantity int
+	MaxCartQuantity int
 }
 
-// NewCartHandler constructs a CartHandler.
-func NewCartHandler(db *sqlx.DB) *CartHandler {
-	return &CartHandler{DB: db}
+// NewCartHandler constructs a CartHandler with the default limits and
+// shipping rates that charges no tax.
+func NewCartHandler(carts store.CartStore, products store.ProductStore, variants store.VariantStore, promos store.PromotionStore, addrs store.AddressStore, secret string) *CartHandler {
+	return &CartHandler{
+		Carts:           carts,
+		Products:        products,
+		Variants:        variants,
+		Promotions:      promos,
+		Addresses:       addrs,
+		Shipping:        shipping.DefaultTable(),
+		Tax:             &tax.Table{},
+		TokenSecret:     secret,
+		MaxLineQuantity: DefaultMaxLineQuantity,
+		MaxCartQuantity: DefaultMaxCartQuantity,
+	}
 }
 
-// CreateCart creates a new cart for the authenticated user.
+// CreateCart returns the caller's active cart, creating it if needed. It
+// answers 201 for a new cart and 200 for an existing one.
 func (h *CartHandler) CreateCart(w http.ResponseWriter, r *http.Request) {
-	userID := r.Context().Value(ContextUserID).(int)
-	var cart models.Cart
-	err := h.DB.Get(&cart,
-		`INSERT INTO carts (user_id) VALUES ($1) RETURNING id, user_id, created_at`,
-		userID)
+	cart, created, err := h.callerCart(r)
 	if err != nil {
 		http.Error(w, "failed to create cart", http.StatusInternalServerError)
 		return
 	}
+	h.setCartToken(w, cart)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
 	json.NewEncoder(w).Encode(cart)
 }
 
-// AddItem adds or updates an item in the cart.
+// CurrentCart handles GET /carts/current, returning the caller's active
+// cart and its items, creating an empty cart if they have none.
+func (h *CartHandler) CurrentCart(w http.ResponseWriter, r *http.Request) {
+	cart, _, err := h.callerCart(r)
+	if err != nil {
+		http.Error(w, "failed to fetch cart", http.StatusInternalServerError)
+		return
+	}
+	h.setCartToken(w, cart)
+	h.writeCart(w, r, cart)
+}
+
+// callerCart returns the caller's active cart: the user's own when
+// authenticated, otherwise the guest cart named by the cart token, creating
+// a cart if there is none.
+func (h *CartHandler) callerCart(r *http.Request) (models.Cart, bool, error) {
+	if userID, ok := r.Context().Value(ContextUserID).(int); ok {
+		return h.Carts.ActiveCart(r.Context(), userID)
+	}
+	if cartID, ok := parseCartToken(h.TokenSecret, r.Header.Get(CartTokenHeader)); ok {
+		cart, err := h.Carts.GetCart(r.Context(), cartID)
+		if err == nil && cart.UserID == nil && cart.Status == models.CartActive {
+			return cart, false, nil
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return models.Cart{}, false, err
+		}
+	}
+	cart, err := h.Carts.CreateGuestCart(r.Context())
+	return cart, err == nil, err
+}
+
+// setCartToken hands a guest the token for their cart.
+func (h *CartHandler) setCartToken(w http.ResponseWriter, cart models.Cart) {
+	if cart.UserID == nil {
+		w.Header().Set(CartTokenHeader, signCartToken(h.TokenSecret, cart.ID))
+	}
+}
+
+// AddItem handles POST /carts/{cartID}/items, adding quantity units of a
+// product to the cart on top of any already there. Products with variants
+// need a variant_id naming which one.
 func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
-	cartID, _ := strconv.Atoi(chi.URLParam(r, "cartID"))
 	var in struct {
-		ProductID int `json:"product_id"`
-		Quantity  int `json:"quantity"`
+		ProductID int  `json:"product_id"`
+		VariantID *int `json:"variant_id"`
+		Quantity  int  `json:"quantity"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		http.Error(w, "invalid payload", http.StatusBadRequest)
 		return
 	}
-	_, err := h.DB.Exec(`
-    INSERT INTO cart_items (cart_id, product_id, quantity)
-    VALUES ($1, $2, $3)
-    ON CONFLICT (cart_id, product_id) DO UPDATE
-      SET quantity = cart_items.quantity + EXCLUDED.quantity
-  `, cartID, in.ProductID, in.Quantity)
+	if in.Quantity <= 0 {
+		http.Error(w, "quantity must be positive", http.StatusBadRequest)
+		return
+	}
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+
+	lines, err := h.Carts.CartLines(r.Context(), cart.ID)
 	if err != nil {
+		http.Error(w, "failed to fetch items", http.StatusInternalServerError)
+		return
+	}
+	want := in.Quantity
+	for _, l := range lines {
+		if l.ProductID == in.ProductID && models.SameVariant(l.VariantID, in.VariantID) {
+			want += l.Quantity
+		}
+	}
+	if !h.checkLine(w, r, lines, in.ProductID, in.VariantID, want) {
+		return
+	}
+
+	if err := h.Carts.AddCartItem(r.Context(), cart.ID, in.ProductID, in.VariantID, in.Quantity); err != nil {
 		http.Error(w, "failed to add item", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// SetItem handles PUT /carts/{cartID}/items/{productID}, setting the line's
+// quantity outright. A quantity of 0 removes the line. The variant_id query
+// parameter picks out a variant's line.
+func (h *CartHandler) SetItem(w http.ResponseWriter, r *http.Request) {
+	productID, variantID, ok := cartLine(w, r)
+	if !ok {
+		return
+	}
+	var in struct {
+		Quantity *int `json:"quantity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.Quantity == nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if *in.Quantity < 0 {
+		http.Error(w, "quantity must not be negative", http.StatusBadRequest)
+		return
+	}
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+
+	// removing a line needs no checks, even if the product is gone
+	if *in.Quantity > 0 {
+		lines, err := h.Carts.CartLines(r.Context(), cart.ID)
+		if err != nil {
+			http.Error(w, "failed to fetch items", http.StatusInternalServerError)
+			return
+		}
+		if !h.checkLine(w, r, lines, productID, variantID, *in.Quantity) {
+			return
+		}
+	}
+
+	if err := h.Carts.SetCartItem(r.Context(), cart.ID, productID, variantID, *in.Quantity); err != nil {
+		http.Error(w, "failed to update item", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cartLine parses the productID URL parameter and optional variant_id
+// query parameter naming a cart line, writing an error and returning false
+// if either is malformed.
+func cartLine(w http.ResponseWriter, r *http.Request) (int, *int, bool) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "productID"))
+	if err != nil {
+		http.Error(w, "invalid product ID", http.StatusBadRequest)
+		return 0, nil, false
+	}
+	v := r.URL.Query().Get("variant_id")
+	if v == "" {
+		return productID, nil, true
+	}
+	variantID, err := strconv.Atoi(v)
+	if err != nil {
+		http.Error(w, "invalid variant ID", http.StatusBadRequest)
+		return 0, nil, false
+	}
+	return productID, &variantID, true
+}
+
+// checkLine reports whether the cart may hold qty units of productID, or of
+// its variant variantID, given its current lines, writing the error if not.
+// Stock is checked here as a courtesy; CreateOrder re-checks under row
+// locks.
+func (h *CartHandler) checkLine(w http.ResponseWriter, r *http.Request, lines []models.CartLine, productID int, variantID *int, qty int) bool {
+	product, err := h.Products.GetProduct(r.Context(), productID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "product not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch product", http.StatusInternalServerError)
+		return false
+	}
+	variants, err := h.Variants.ListVariants(r.Context(), productID)
+	if err != nil {
+		http.Error(w, "failed to fetch variants", http.StatusInternalServerError)
+		return false
+	}
+	stock := product.StockQuantity
+	if variantID == nil && len(variants) > 0 {
+		http.Error(w, "variant_id is required for this product", http.StatusBadRequest)
+		return false
+	}
+	if variantID != nil {
+		found := false
+		for _, v := range variants {
+			if v.ID == *variantID {
+				stock, found = v.StockQuantity, true
+			}
+		}
+		if !found {
+			http.Error(w, "variant not found", http.StatusNotFound)
+			return false
+		}
+	}
+	if qty > h.MaxLineQuantity {
+		http.Error(w, fmt.Sprintf("at most %d of a product per cart", h.MaxLineQuantity), http.StatusBadRequest)
+		return false
+	}
+	total := qty
+	for _, l := range lines {
+		if l.Currency != product.Currency {
+			http.Error(w, "cart cannot mix currencies", http.StatusConflict)
+			return false
+		}
+		if l.ProductID != productID || !models.SameVariant(l.VariantID, variantID) {
+			total += l.Quantity
+		}
+	}
+	if total > h.MaxCartQuantity {
+		http.Error(w, fmt.Sprintf("at most %d items per cart", h.MaxCartQuantity), http.StatusBadRequest)
+		return false
+	}
+	if qty > stock {
+		writeStockConflict(w, []store.StockShortage{{
+			ProductID: product.ID,
+			VariantID: variantID,
+			Requested: qty,
+			Available: stock,
+		}})
+		return false
+	}
+	return true
+}
+
 // GetCart returns the cart and its items.
 func (h *CartHandler) GetCart(w http.ResponseWriter, r *http.Request) {
-	cartID, _ := strconv.Atoi(chi.URLParam(r, "cartID"))
-	var cart models.Cart
-	if err := h.DB.Get(&cart,
-		`SELECT id, user_id, created_at FROM carts WHERE id=$1`, cartID); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "cart not found", http.StatusNotFound)
+	cart, ok := h.ownedCart(w, r)
+	if !ok {
+		return
+	}
+	h.writeCart(w, r, cart)
+}
+
+// AcceptPrices handles POST /carts/{cartID}/accept-prices. Orders are
+// refused while any line's price differs from the one the customer saw;
+// this acknowledges the current prices and returns the updated cart.
+func (h *CartHandler) AcceptPrices(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Carts.AcceptCartPrices(r.Context(), cart.ID); err != nil {
+		http.Error(w, "failed to accept prices", http.StatusInternalServerError)
+		return
+	}
+	h.writeCart(w, r, cart)
+}
+
+// ApplyCoupon handles POST /carts/{cartID}/coupon, applying a coupon code
+// to the cart in place of any other. The code must apply to the cart as it
+// stands; it is re-checked whenever the cart is priced.
+func (h *CartHandler) ApplyCoupon(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || strings.TrimSpace(in.Code) == "" {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+	promo, err := h.Promotions.GetPromotionByCode(r.Context(), strings.ToUpper(strings.TrimSpace(in.Code)))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "coupon not found", http.StatusNotFound)
 		} else {
-			http.Error(w, "failed to fetch cart", http.StatusInternalServerError)
+			http.Error(w, "failed to fetch coupon", http.StatusInternalServerError)
 		}
 		return
 	}
+	lines, err := h.Carts.CartLines(r.Context(), cart.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch items", http.StatusInternalServerError)
+		return
+	}
+	userID, _ := r.Context().Value(ContextUserID).(int)
+	_, reason, err := couponDiscount(r.Context(), h.Promotions, promo, lines, userID)
+	if err != nil {
+		http.Error(w, "failed to check coupon", http.StatusInternalServerError)
+		return
+	}
+	if reason != nil {
+		http.Error(w, reason.Error(), http.StatusConflict)
+		return
+	}
+	if err := h.Carts.SetCartPromotion(r.Context(), cart.ID, &promo.ID); err != nil {
+		http.Error(w, "failed to apply coupon", http.StatusInternalServerError)
+		return
+	}
+	cart.PromotionID = &promo.ID
+	h.writeCart(w, r, cart)
+}
+
+// RemoveCoupon handles DELETE /carts/{cartID}/coupon.
+func (h *CartHandler) RemoveCoupon(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Carts.SetCartPromotion(r.Context(), cart.ID, nil); err != nil {
+		http.Error(w, "failed to remove coupon", http.StatusInternalServerError)
+		return
+	}
+	cart.PromotionID = nil
+	h.writeCart(w, r, cart)
+}
+
+// SetShippingMethod handles PUT /carts/{cartID}/shipping-method, choosing
+// how the order will be shipped. An empty method clears the choice.
+func (h *CartHandler) SetShippingMethod(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Method string `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	in.Method = strings.TrimSpace(in.Method)
+	if in.Method != "" && !h.Shipping.Offers(in.Method) {
+		http.Error(w, "unknown shipping method", http.StatusBadRequest)
+		return
+	}
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Carts.SetCartShippingMethod(r.Context(), cart.ID, in.Method); err != nil {
+		http.Error(w, "failed to set shipping method", http.StatusInternalServerError)
+		return
+	}
+	cart.ShippingMethod = in.Method
+	h.writeCart(w, r, cart)
+}
+
+// ShippingRates handles GET /carts/{cartID}/shipping-rates, quoting every
+// method that can ship the cart. Users name one of their addresses with
+// address_id, defaulting to their default address; guests give country and
+// optionally region.
+func (h *CartHandler) ShippingRates(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.ownedCart(w, r)
+	if !ok {
+		return
+	}
+	dest, ok := h.destination(w, r)
+	if !ok {
+		return
+	}
+	lines, err := h.Carts.CartLines(r.Context(), cart.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch items", http.StatusInternalServerError)
+		return
+	}
+	quotes, err := h.Shipping.Quote(r.Context(), shippingRequest(dest, lines))
+	if err != nil {
+		http.Error(w, "failed to quote shipping", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quotes)
+}
+
+// destination works out where ShippingRates should quote for, writing an
+// error and returning false if it cannot.
+func (h *CartHandler) destination(w http.ResponseWriter, r *http.Request) (models.Address, bool) {
+	dest, ok := h.cartDestination(w, r)
+	if !ok {
+		return models.Address{}, false
+	}
+	if dest == nil {
+		http.Error(w, "address_id or country is required", http.StatusBadRequest)
+		return models.Address{}, false
+	}
+	return *dest, true
+}
 
-	var items []struct {
-		models.CartItem
-		ProductName string  `db:"name" json:"product_name"`
-		Price       float64 `db:"price" json:"unit_price"`
+// cartDestination works out where the cart is headed: the address named
+// by address_id, else the country and optional region query parameters,
+// else the user's default address. It is nil when none of these is known;
+// an error is written and false returned if the request names a bad one.
+func (h *CartHandler) cartDestination(w http.ResponseWriter, r *http.Request) (*models.Address, bool) {
+	q := r.URL.Query()
+	if v := q.Get("address_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "invalid address ID", http.StatusBadRequest)
+			return nil, false
+		}
+		a, ok := userAddress(w, r, h.Addresses, id)
+		return &a, ok
 	}
-	query := `
-    SELECT ci.cart_id, ci.product_id, ci.quantity,
-           p.name, p.price
-    FROM cart_items ci
-    JOIN products p ON p.id=ci.product_id
-    WHERE ci.cart_id=$1`
-	if err := h.DB.Select(&items, query, cartID); err != nil {
+	if v := q.Get("country"); v != "" {
+		dest := models.Address{Country: strings.ToUpper(v), Region: strings.ToUpper(q.Get("region"))}
+		if !countryCode.MatchString(dest.Country) {
+			http.Error(w, "country must be a two-letter ISO 3166 code", http.StatusBadRequest)
+			return nil, false
+		}
+		return &dest, true
+	}
+	if userID, ok := r.Context().Value(ContextUserID).(int); ok {
+		a, err := h.Addresses.DefaultAddress(r.Context(), userID)
+		if err == nil {
+			return &a, true
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "failed to fetch address", http.StatusInternalServerError)
+			return nil, false
+		}
+	}
+	return nil, true
+}
+
+// writeCart responds with cart, its items, totals and coupon. Shipping
+// and tax are worked out for where the cart is headed, as cartDestination
+// finds it.
+func (h *CartHandler) writeCart(w http.ResponseWriter, r *http.Request, cart models.Cart) {
+	items, err := h.Carts.CartLines(r.Context(), cart.ID)
+	if err != nil {
 		http.Error(w, "failed to fetch items", http.StatusInternalServerError)
 		return
 	}
+	dest, ok := h.cartDestination(w, r)
+	if !ok {
+		return
+	}
 
+	userID, _ := r.Context().Value(ContextUserID).(int)
+	totals, coupon, err := h.priceCart(r.Context(), cart, items, dest, userID)
+	if err != nil {
+		http.Error(w, "failed to price cart", http.StatusInternalServerError)
+		return
+	}
 	resp := struct {
-		Cart  models.Cart `json:"cart"`
-		Items interface{} `json:"items"`
-	}{Cart: cart, Items: items}
+		Cart   models.Cart       `json:"cart"`
+		Items  []models.CartLine `json:"items"`
+		Totals models.CartTotals `json:"totals"`
+		Coupon *cartCoupon       `json:"coupon,omitempty"`
+	}{Cart: cart, Items: items, Totals: totals, Coupon: coupon}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-// RemoveItem deletes an item from the cart.
+// RemoveItem deletes an item from the cart. The variant_id query parameter
+// picks out a variant's line.
 func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
-	cartID, _ := strconv.Atoi(chi.URLParam(r, "cartID"))
-	productID, _ := strconv.Atoi(chi.URLParam(r, "productID"))
-	if _, err := h.DB.Exec(
-		`DELETE FROM cart_items WHERE cart_id=$1 AND product_id=$2`,
-		cartID, productID,
-	); err != nil {
-		http.Error(w, "failed to remove item", http.StatusInternalServerError)
+	productID, variantID, ok := cartLine(w, r)
+	if !ok {
+		return
+	}
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Carts.RemoveCartItem(r.Context(), cart.ID, productID, variantID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "item not in cart", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to remove item", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EmptyCart handles DELETE /carts/{cartID}, removing every item. The cart
+// itself stays active.
+func (h *CartHandler) EmptyCart(w http.ResponseWriter, r *http.Request) {
+	cart, ok := h.activeCart(w, r)
+	if !ok {
+		return
+	}
+	if err := h.Carts.ClearCart(r.Context(), cart.ID); err != nil {
+		http.Error(w, "failed to empty cart", http.StatusInternalServerError)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// ownedCart loads the cart named by the cartID URL parameter, writing an
+// error and returning false if it does not exist or belongs to someone
+// else. Foreign carts are reported as not found so IDs cannot be probed. A
+// guest cart belongs to whoever presents its cart token.
+func (h *CartHandler) ownedCart(w http.ResponseWriter, r *http.Request) (models.Cart, bool) {
+	cartID, err := strconv.Atoi(chi.URLParam(r, "cartID"))
+	if err != nil {
+		http.Error(w, "invalid cart ID", http.StatusBadRequest)
+		return models.Cart{}, false
+	}
+	cart, err := h.Carts.GetCart(r.Context(), cartID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "cart not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch cart", http.StatusInternalServerError)
+		}
+		return models.Cart{}, false
+	}
+	var owner bool
+	if cart.UserID == nil {
+		tokenID, ok := parseCartToken(h.TokenSecret, r.Header.Get(CartTokenHeader))
+		owner = ok && tokenID == cart.ID
+	} else {
+		userID, ok := r.Context().Value(ContextUserID).(int)
+		owner = ok && cart.OwnedBy(userID)
+	}
+	if !owner {
+		http.Error(w, "cart not found", http.StatusNotFound)
+		return models.Cart{}, false
+	}
+	return cart, true
+}
+
+// activeCart is ownedCart for requests that modify the cart, which is only
+// allowed until it has been checked out.
+func (h *CartHandler) activeCart(w http.ResponseWriter, r *http.Request) (models.Cart, bool) {
+	cart, ok := h.ownedCart(w, r)
+	if ok && cart.Status != models.CartActive {
+		http.Error(w, "cart is no longer active", http.StatusConflict)
+		return models.Cart{}, false
+	}
+	return cart, ok
+}
@@ -3,42 +3,53 @@ package handlers
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
-	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/shipping"
+	"github.com/Heisenberg270/ecommerce-go/store"
+	"github.com/Heisenberg270/ecommerce-go/tax"
 )
 
-func setupCartMock(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
-	db, mock, err := sqlmock.New()
+// withURLParams injects chi route params given as key/value pairs.
+func withURLParams(req *http.Request, kv ...string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for i := 0; i+1 < len(kv); i += 2 {
+		rctx.URLParams.Add(kv[i], kv[i+1])
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// withUser injects an authenticated user ID, as AuthMiddleware would.
+func withUser(req *http.Request, userID int) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), ContextUserID, userID))
+}
+
+// seedCart creates a product with 10 units in stock and a cart for userID in st.
+func seedCart(t *testing.T, st *store.Memory, userID int) (models.Product, models.Cart) {
+	t.Helper()
+	ctx := context.Background()
+	p := models.Product{Name: "Widget", Price: 500, Currency: "USD", StockQuantity: 10}
+	if err := st.CreateProduct(ctx, &p); err != nil {
+		t.Fatalf("seed product: %v", err)
+	}
+	cart, _, err := st.ActiveCart(ctx, userID)
 	if err != nil {
-		t.Fatalf("failed to open sqlmock: %v", err)
+		t.Fatalf("seed cart: %v", err)
 	}
-	return sqlx.NewDb(db, "sqlmock"), mock
+	return p, cart
 }
 
 func TestCreateCart(t *testing.T) {
-	db, mock := setupCartMock(t)
-	// Expect INSERT ... RETURNING
-	now := time.Now()
-	mock.ExpectQuery(`INSERT INTO carts .*RETURNING id, user_id, created_at`).
-		WithArgs(99).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "created_at"}).
-			AddRow(123, 99, now),
-		)
-
-	ch := NewCartHandler(db)
-	req := httptest.NewRequest("POST", "/carts", nil)
-	// inject authenticated user ID
-	req = req.WithContext(context.WithValue(req.Context(), ContextUserID, 99))
+	st := store.NewMemory()
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(httptest.NewRequest("POST", "/carts", nil), 99)
 	w := httptest.NewRecorder()
 
 	ch.CreateCart(w, req)
@@ -50,17 +61,102 @@ func TestCreateCart(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &cart); err != nil {
 		t.Fatalf("failed to parse JSON: %v", err)
 	}
-	if cart.ID != 123 || cart.UserID != 99 {
-		t.Errorf("got cart %+v; want ID=123, UserID=99", cart)
+	if cart.ID == 0 || !cart.OwnedBy(99) || cart.Status != models.CartActive {
+		t.Errorf("got cart %+v; want non-zero active cart for UserID=99", cart)
+	}
+
+	// a second POST returns the same cart instead of another empty one
+	w = httptest.NewRecorder()
+	ch.CreateCart(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("second CreateCart status = %d; want %d", w.Code, http.StatusOK)
+	}
+	var again models.Cart
+	if err := json.Unmarshal(w.Body.Bytes(), &again); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if again.ID != cart.ID {
+		t.Errorf("second CreateCart returned cart %d; want %d", again.ID, cart.ID)
+	}
+}
+
+func TestCurrentCart(t *testing.T) {
+	st := store.NewMemory()
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+
+	var ids []int
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		ch.CurrentCart(w, withUser(httptest.NewRequest("GET", "/carts/current", nil), 5))
+		if w.Code != http.StatusOK {
+			t.Fatalf("CurrentCart status = %d; want %d", w.Code, http.StatusOK)
+		}
+		var resp struct {
+			Cart  models.Cart       `json:"cart"`
+			Items []models.CartLine `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse JSON: %v", err)
+		}
+		if !resp.Cart.OwnedBy(5) || resp.Items == nil {
+			t.Errorf("got %+v; want user 5's cart with an empty item list", resp)
+		}
+		ids = append(ids, resp.Cart.ID)
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("CurrentCart returned carts %v; want the same cart twice", ids)
+	}
+}
+
+func TestCart_ForeignCartNotFound(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{"get", ch.GetCart, httptest.NewRequest("GET", "/carts/1", nil)},
+		{"add", ch.AddItem, httptest.NewRequest("POST", "/carts/1/items",
+			bytes.NewBufferString(`{"product_id":1,"quantity":1}`))},
+		{"remove", ch.RemoveItem, httptest.NewRequest("DELETE", "/carts/1/items/1", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withUser(withURLParams(tt.req, "cartID", "1", "productID", "1"), 2)
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d; want %d", w.Code, http.StatusNotFound)
+			}
+		})
 	}
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("unfulfilled expectations: %v", err)
+	if lines, _ := st.CartLines(context.Background(), cart.ID); len(lines) != 1 || lines[0].Quantity != 2 {
+		t.Errorf("got lines %+v; want the owner's cart untouched", lines)
+	}
+}
+
+func TestAddItem_CheckedOutCart(t *testing.T) {
+	st := store.NewMemory()
+	placeOrder(t, st, 1)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+		bytes.NewBufferString(`{"product_id":1,"quantity":1}`)), "cartID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.AddItem(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("AddItem status = %d; want %d", w.Code, http.StatusConflict)
 	}
 }
 
 func TestAddItem_BadJSON(t *testing.T) {
-	db, _ := setupCartMock(t)
-	ch := NewCartHandler(db)
+	st := store.NewMemory()
+	ch := NewCartHandler(st, st, st, st, st, "secret")
 	req := httptest.NewRequest("POST", "/carts/1/items", bytes.NewBufferString(`{"product_id":`))
 	w := httptest.NewRecorder()
 
@@ -71,75 +167,488 @@ func TestAddItem_BadJSON(t *testing.T) {
 }
 
 func TestAddItem_Success(t *testing.T) {
-	db, mock := setupCartMock(t)
-	mock.ExpectExec(`INSERT INTO cart_items`).
-		WithArgs(5, 10, 3).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	ch := NewCartHandler(db)
-	req := httptest.NewRequest("POST", "/carts/5/items",
-		bytes.NewBufferString(`{"product_id":10,"quantity":3}`))
-	// inject cartID=5 into chi route context
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("cartID", "5")
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
 
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	for i := 0; i < 2; i++ {
+		req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+			bytes.NewBufferString(`{"product_id":1,"quantity":3}`)), "cartID", "1"), 1)
+		w := httptest.NewRecorder()
+		ch.AddItem(w, req)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("AddItem status = %d; want %d", w.Code, http.StatusNoContent)
+		}
+	}
+
+	lines, _ := st.CartLines(context.Background(), cart.ID)
+	if len(lines) != 1 || lines[0].ProductID != p.ID || lines[0].Quantity != 6 {
+		t.Errorf("got lines %+v; want one line with quantity 6", lines)
+	}
+}
+
+func TestAddItem_ExceedsStock(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 8)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+		bytes.NewBufferString(`{"product_id":1,"quantity":3}`)), "cartID", "1"), 1)
 	w := httptest.NewRecorder()
 	ch.AddItem(w, req)
 
-	if w.Code != http.StatusNoContent {
-		t.Errorf("AddItem status = %d; want %d", w.Code, http.StatusNoContent)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("AddItem status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	var resp struct {
+		Items []store.StockShortage `json:"items"`
 	}
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("unfulfilled expectations: %v", err)
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].Requested != 11 || resp.Items[0].Available != 10 {
+		t.Errorf("got shortages %+v; want requested 11, available 10", resp.Items)
 	}
 }
 
-func TestGetCart_NotFound(t *testing.T) {
-	db, mock := setupCartMock(t)
-	mock.ExpectQuery(`SELECT id, user_id, created_at FROM carts`).
-		WithArgs(7).
-		WillReturnError(sql.ErrNoRows)
-
-	ch := NewCartHandler(db)
-	req := httptest.NewRequest("GET", "/carts/7", nil)
-	// inject cartID=7 into chi context
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("cartID", "7")
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+func TestAddItem_MixedCurrency(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 1)
+	eur := models.Product{Name: "Euro widget", Price: 400, Currency: "EUR", StockQuantity: 5}
+	st.CreateProduct(context.Background(), &eur)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+		bytes.NewBufferString(`{"product_id":2,"quantity":1}`)), "cartID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.AddItem(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("AddItem status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestAddItem_UnknownProduct(t *testing.T) {
+	st := store.NewMemory()
+	seedCart(t, st, 1)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+		bytes.NewBufferString(`{"product_id":99,"quantity":1}`)), "cartID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.AddItem(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("AddItem status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetCart(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("GET", "/carts/1", nil), "cartID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.GetCart(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetCart status = %d; want %d", w.Code, http.StatusOK)
+	}
+	var resp struct {
+		Cart   models.Cart       `json:"cart"`
+		Items  []models.CartLine `json:"items"`
+		Totals models.CartTotals `json:"totals"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].ProductName != "Widget" || resp.Items[0].LineTotal != 1000 {
+		t.Errorf("got items %+v; want one Widget line totalling 10.00", resp.Items)
+	}
+	if resp.Totals.Subtotal != 1000 || resp.Totals.Total != 1000 || resp.Totals.Currency != "USD" {
+		t.Errorf("got totals %+v; want 10.00 USD", resp.Totals)
+	}
+}
 
+func TestGetCart_FlagsPriceChange(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(ctx, cart.ID, p.ID, nil, 1)
+	p.Price = 450
+	st.UpdateProduct(ctx, &p)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	w := httptest.NewRecorder()
+	ch.GetCart(w, withUser(withURLParams(httptest.NewRequest("GET", "/carts/1", nil), "cartID", "1"), 1))
+
+	var resp struct {
+		Items []models.CartLine `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if len(resp.Items) != 1 || !resp.Items[0].PriceChanged || resp.Items[0].AddedPrice != 500 {
+		t.Errorf("got items %+v; want the line flagged as changed from 5.00", resp.Items)
+	}
+}
+
+func TestGetCart_NotFound(t *testing.T) {
+	st := store.NewMemory()
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("GET", "/carts/7", nil), "cartID", "7"), 1)
 	w := httptest.NewRecorder()
 	ch.GetCart(w, req)
 
 	if w.Code != http.StatusNotFound {
 		t.Errorf("GetCart not found status = %d; want %d", w.Code, http.StatusNotFound)
 	}
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("unfulfilled expectations: %v", err)
-	}
 }
 
 func TestRemoveItem(t *testing.T) {
-	db, mock := setupCartMock(t)
-	mock.ExpectExec(`DELETE FROM cart_items`).
-		WithArgs(8, 20).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	ch := NewCartHandler(db)
-	req := httptest.NewRequest("DELETE", "/carts/8/items/20", nil)
-	// inject cartID=8 & productID=20 into chi context
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("cartID", "8")
-	rctx.URLParams.Add("productID", "20")
-	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
 
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("DELETE", "/carts/1/items/1", nil),
+		"cartID", "1", "productID", "1"), 1)
 	w := httptest.NewRecorder()
 	ch.RemoveItem(w, req)
 
 	if w.Code != http.StatusNoContent {
 		t.Errorf("RemoveItem status = %d; want %d", w.Code, http.StatusNoContent)
 	}
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("unfulfilled expectations: %v", err)
+	if lines, _ := st.CartLines(context.Background(), cart.ID); len(lines) != 0 {
+		t.Errorf("got lines %+v; want empty cart", lines)
+	}
+}
+
+func TestAddItem_NonPositiveQuantity(t *testing.T) {
+	st := store.NewMemory()
+	seedCart(t, st, 1)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	for _, body := range []string{`{"product_id":1,"quantity":0}`, `{"product_id":1,"quantity":-2}`} {
+		req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+			bytes.NewBufferString(body)), "cartID", "1"), 1)
+		w := httptest.NewRecorder()
+		ch.AddItem(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("AddItem %s status = %d; want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSetItem(t *testing.T) {
+	tests := []struct {
+		name       string
+		productID  string
+		body       string
+		maxLine    int // overrides MaxLineQuantity when non-zero
+		wantStatus int
+		wantQty    int // quantity of product 1 afterwards; 0 if absent
+	}{
+		{"set absolute", "1", `{"quantity":5}`, 0, http.StatusNoContent, 5},
+		{"zero removes", "1", `{"quantity":0}`, 0, http.StatusNoContent, 0},
+		{"negative", "1", `{"quantity":-1}`, 0, http.StatusBadRequest, 2},
+		{"missing quantity", "1", `{}`, 0, http.StatusBadRequest, 2},
+		{"bad product ID", "x", `{"quantity":1}`, 0, http.StatusBadRequest, 2},
+		{"unknown product", "99", `{"quantity":1}`, 0, http.StatusNotFound, 2},
+		{"exceeds stock", "1", `{"quantity":11}`, 0, http.StatusConflict, 2},
+		{"exceeds line limit", "1", `{"quantity":4}`, 3, http.StatusBadRequest, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			p, cart := seedCart(t, st, 1)
+			st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+			ch := NewCartHandler(st, st, st, st, st, "secret")
+			if tt.maxLine != 0 {
+				ch.MaxLineQuantity = tt.maxLine
+			}
+			req := withUser(withURLParams(httptest.NewRequest("PUT", "/carts/1/items/"+tt.productID,
+				bytes.NewBufferString(tt.body)), "cartID", "1", "productID", tt.productID), 1)
+			w := httptest.NewRecorder()
+			ch.SetItem(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("SetItem status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			qty := 0
+			lines, _ := st.CartLines(context.Background(), cart.ID)
+			for _, l := range lines {
+				if l.ProductID == p.ID {
+					qty = l.Quantity
+				}
+			}
+			if qty != tt.wantQty {
+				t.Errorf("quantity = %d; want %d", qty, tt.wantQty)
+			}
+		})
+	}
+}
+
+func TestAddItem_ExceedsCartLimit(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	other := models.Product{Name: "Gadget", Price: 300, Currency: "USD", StockQuantity: 10}
+	st.CreateProduct(context.Background(), &other)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 4)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	ch.MaxCartQuantity = 6
+	req := withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+		bytes.NewBufferString(`{"product_id":2,"quantity":3}`)), "cartID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.AddItem(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("AddItem status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRemoveItem_NotInCart(t *testing.T) {
+	st := store.NewMemory()
+	seedCart(t, st, 1)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("DELETE", "/carts/1/items/1", nil),
+		"cartID", "1", "productID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.RemoveItem(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("RemoveItem status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestEmptyCart(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := withUser(withURLParams(httptest.NewRequest("DELETE", "/carts/1", nil), "cartID", "1"), 1)
+	w := httptest.NewRecorder()
+	ch.EmptyCart(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("EmptyCart status = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	if lines, _ := st.CartLines(context.Background(), cart.ID); len(lines) != 0 {
+		t.Errorf("got lines %+v; want empty cart", lines)
+	}
+	if got, _ := st.GetCart(context.Background(), cart.ID); got.Status != models.CartActive {
+		t.Errorf("cart status = %q; want it to stay active", got.Status)
+	}
+}
+
+func TestGuestCart(t *testing.T) {
+	st := store.NewMemory()
+	seedCart(t, st, 1) // product 1 and user 1's cart
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+
+	w := httptest.NewRecorder()
+	ch.CreateCart(w, httptest.NewRequest("POST", "/carts", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateCart status = %d; want %d", w.Code, http.StatusCreated)
+	}
+	token := w.Header().Get(CartTokenHeader)
+	var cart models.Cart
+	if err := json.Unmarshal(w.Body.Bytes(), &cart); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if token == "" || cart.UserID != nil {
+		t.Fatalf("got cart %+v, token %q; want a guest cart and its token", cart, token)
+	}
+	cartID := strconv.Itoa(cart.ID)
+
+	// the token grants access to the guest cart
+	req := withURLParams(httptest.NewRequest("POST", "/carts/"+cartID+"/items",
+		bytes.NewBufferString(`{"product_id":1,"quantity":2}`)), "cartID", cartID)
+	req.Header.Set(CartTokenHeader, token)
+	w = httptest.NewRecorder()
+	ch.AddItem(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("AddItem status = %d; want %d", w.Code, http.StatusNoContent)
+	}
+
+	// and presenting it again returns the same cart
+	req = httptest.NewRequest("GET", "/carts/current", nil)
+	req.Header.Set(CartTokenHeader, token)
+	w = httptest.NewRecorder()
+	ch.CurrentCart(w, req)
+	var resp struct {
+		Cart  models.Cart       `json:"cart"`
+		Items []models.CartLine `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse JSON: %v", err)
+	}
+	if resp.Cart.ID != cart.ID || len(resp.Items) != 1 {
+		t.Errorf("got %+v; want guest cart %d with one item", resp, cart.ID)
+	}
+
+	// without the token, or as a logged-in user, the guest cart is hidden
+	for name, req := range map[string]*http.Request{
+		"anonymous": httptest.NewRequest("GET", "/carts/"+cartID, nil),
+		"user":      withUser(httptest.NewRequest("GET", "/carts/"+cartID, nil), 1),
+	} {
+		w = httptest.NewRecorder()
+		ch.GetCart(w, withURLParams(req, "cartID", cartID))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s GetCart status = %d; want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+
+	// nor can a guest token open a user's cart
+	req = withURLParams(httptest.NewRequest("GET", "/carts/1", nil), "cartID", "1")
+	req.Header.Set(CartTokenHeader, signCartToken("secret", 1))
+	w = httptest.NewRecorder()
+	ch.GetCart(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("guest GetCart of user cart status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestShippingMethod(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+
+	set := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ch.SetShippingMethod(w, withURLParams(withUser(httptest.NewRequest("PUT", "/carts/1/shipping-method",
+			bytes.NewBufferString(body)), 42), "cartID", "1"))
+		return w
+	}
+	if w := set(`{"method":"teleport"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown method status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := set(`{"method":"express"}`); w.Code != http.StatusOK {
+		t.Fatalf("SetShippingMethod status = %d; want %d", w.Code, http.StatusOK)
+	}
+	if got, _ := st.GetCart(context.Background(), cart.ID); got.ShippingMethod != "express" {
+		t.Errorf("shipping method = %q; want express", got.ShippingMethod)
+	}
+}
+
+func TestShippingRates(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	p.WeightGrams = 1500
+	st.UpdateProduct(context.Background(), &p)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+	seedAddress(t, st, 42, "IL")
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+
+	rates := func(target string, userID int) ([]shipping.Quote, int) {
+		w := httptest.NewRecorder()
+		ch.ShippingRates(w, withURLParams(withUser(httptest.NewRequest("GET", target, nil), userID), "cartID", "1"))
+		var got []shipping.Quote
+		json.Unmarshal(w.Body.Bytes(), &got)
+		return got, w.Code
+	}
+	got, code := rates("/carts/1/shipping-rates", 42)
+	if code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", code, http.StatusOK)
+	}
+	if len(got) != 2 || got[0].Method != "standard" || got[1].Method != "express" || got[1].Amount != 2000 {
+		t.Errorf("got %+v; want standard then express at 20.00 for 3kg", got)
+	}
+	if _, code := rates("/carts/1/shipping-rates?country=usa", 42); code != http.StatusBadRequest {
+		t.Errorf("bad country status = %d; want %d", code, http.StatusBadRequest)
+	}
+	if _, code := rates("/carts/1/shipping-rates?address_id=99", 42); code != http.StatusNotFound {
+		t.Errorf("unknown address status = %d; want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestGetCart_Tax(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      tax.Mode
+		target    string
+		wantTax   models.Money
+		wantTotal models.Money
+	}{
+		{"exclusive, default region", tax.ModeExclusive, "/carts/1", 80, 1080},
+		{"exclusive, named country", tax.ModeExclusive, "/carts/1?country=gb", 200, 1200},
+		{"inclusive", tax.ModeInclusive, "/carts/1?country=GB", 167, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			p, cart := seedCart(t, st, 42)
+			st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+			ch := NewCartHandler(st, st, st, st, st, "secret")
+			ch.Tax = &tax.Table{Mode: tt.mode, Rates: []tax.Rate{
+				{Region: "US", BasisPoints: 800},
+				{Region: "GB", BasisPoints: 2000},
+			}}
+			ch.TaxRegion = "US-TX"
+			w := httptest.NewRecorder()
+			ch.GetCart(w, withUser(withURLParams(httptest.NewRequest("GET", tt.target, nil), "cartID", "1"), 42))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+			}
+			var resp struct {
+				Totals models.CartTotals `json:"totals"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Totals.Tax != tt.wantTax || resp.Totals.Total != tt.wantTotal {
+				t.Errorf("got tax %s, total %s; want %s, %s", resp.Totals.Tax, resp.Totals.Total, tt.wantTax, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestGetCart_Shipping(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		wantShipping models.Money
+	}{
+		{"no method", "", "/carts/1?country=US", 0},
+		{"no destination", "standard", "/carts/1", 0},
+		{"method and destination", "standard", "/carts/1?country=US", 500},
+		{"method cannot ship there", "local", "/carts/1?country=FR", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			ctx := context.Background()
+			p, cart := seedCart(t, st, 42)
+			st.AddCartItem(ctx, cart.ID, p.ID, nil, 2)
+			st.SetCartShippingMethod(ctx, cart.ID, tt.method)
+
+			ch := NewCartHandler(st, st, st, st, st, "secret")
+			ch.Shipping = &shipping.Table{Methods: []shipping.Method{
+				{Code: "standard", Name: "Standard", Currency: "USD", Flat: 500},
+				{Code: "local", Name: "Local", Currency: "USD", Flat: 200, Countries: []string{"US"}},
+			}}
+			w := httptest.NewRecorder()
+			ch.GetCart(w, withUser(withURLParams(httptest.NewRequest("GET", tt.target, nil), "cartID", "1"), 42))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+			}
+			var resp struct {
+				Totals models.CartTotals `json:"totals"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Totals.Shipping != tt.wantShipping || resp.Totals.Total != 1000+tt.wantShipping {
+				t.Errorf("got shipping %s, total %s; want %s on top of 10.00",
+					resp.Totals.Shipping, resp.Totals.Total, tt.wantShipping)
+			}
+		})
 	}
 }
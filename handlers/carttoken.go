@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// CartTokenHeader carries a guest's cart token. Responses that create or
+// return a guest cart set it; the client sends it back on later cart
+// requests and when logging in or signing up, so the cart can be merged.
+const CartTokenHeader = "X-Cart-Token"
+
+// signCartToken returns an opaque token naming a guest cart, signed with
+// secret so visitors cannot guess other carts' tokens.
+func signCartToken(secret string, cartID int) string {
+	id := strconv.Itoa(cartID)
+	return base64.RawURLEncoding.EncodeToString([]byte(id)) + "." + cartTokenMAC(secret, id)
+}
+
+// parseCartToken returns the cart ID in token, reporting false if the token
+// is malformed or its signature does not match.
+func parseCartToken(secret, token string) (int, bool) {
+	enc, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(mac), []byte(cartTokenMAC(secret, string(raw)))) {
+		return 0, false
+	}
+	id, err := strconv.Atoi(string(raw))
+	return id, err == nil
+}
+
+func cartTokenMAC(secret, id string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("cart:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
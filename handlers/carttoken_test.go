@@ -0,0 +1,28 @@
+package handlers
+
+import "testing"
+
+func TestParseCartToken(t *testing.T) {
+	valid := signCartToken("secret", 42)
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		wantID int
+		wantOK bool
+	}{
+		{"valid", "secret", valid, 42, true},
+		{"wrong secret", "other", valid, 0, false},
+		{"tampered ID", "secret", signCartToken("secret", 43)[:3] + valid[3:], 0, false},
+		{"no signature", "secret", "NDI", 0, false},
+		{"empty", "secret", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := parseCartToken(tt.secret, tt.token)
+			if id != tt.wantID || ok != tt.wantOK {
+				t.Errorf("parseCartToken = %d, %v; want %d, %v", id, ok, tt.wantID, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// CategoryHandler manages the category tree and product assignments
+type CategoryHandler struct {
+	Store    store.CategoryStore
+	Products store.ProductStore
+}
+
+// NewCategoryHandler constructs a CategoryHandler
+func NewCategoryHandler(cats store.CategoryStore, products store.ProductStore) *CategoryHandler {
+	return &CategoryHandler{Store: cats, Products: products}
+}
+
+var (
+	categorySlug = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	slugBreaks   = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// categoryInput is the body of create and update requests. Slug defaults
+// to one derived from the name.
+type categoryInput struct {
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// decode reads and validates a categoryInput, writing the error and
+// returning false if it is unacceptable.
+func (in *categoryInput) decode(w http.ResponseWriter, r *http.Request) bool {
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return false
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.Slug = strings.ToLower(strings.TrimSpace(in.Slug))
+	if in.Slug == "" {
+		in.Slug = strings.Trim(slugBreaks.ReplaceAllString(strings.ToLower(in.Name), "-"), "-")
+	}
+	switch {
+	case in.Name == "":
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return false
+	case len(in.Slug) > 64 || !categorySlug.MatchString(in.Slug):
+		http.Error(w, "slug must be up to 64 lower-case letters and digits separated by dashes", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// apply copies in onto c.
+func (in categoryInput) apply(c *models.Category) {
+	c.Name, c.Slug, c.ParentID = in.Name, in.Slug, in.ParentID
+}
+
+// Tree handles GET /categories. It returns the top-level categories with
+// their subcategories nested under children, each level ordered by name.
+func (h *CategoryHandler) Tree(w http.ResponseWriter, r *http.Request) {
+	cats, err := h.Store.ListCategories(r.Context())
+	if err != nil {
+		http.Error(w, "failed to fetch categories", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.CategoryTree(cats))
+}
+
+// Create handles POST /categories
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in categoryInput
+	if !in.decode(w, r) {
+		return
+	}
+	var c models.Category
+	in.apply(&c)
+	if !categorySaved(w, h.Store.CreateCategory(r.Context(), &c), "failed to create category") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// Get handles GET /categories/{categoryID}
+func (h *CategoryHandler) Get(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.category(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// Update handles PUT /categories/{categoryID}. Moving a category moves
+// its whole subtree; it cannot be moved under itself or a descendant.
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.category(w, r)
+	if !ok {
+		return
+	}
+	var in categoryInput
+	if !in.decode(w, r) {
+		return
+	}
+	in.apply(&c)
+	if !categorySaved(w, h.Store.UpdateCategory(r.Context(), &c), "failed to update category") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// categorySaved writes the error for a failed create or update and
+// reports whether err was nil. The category itself was loaded beforehand,
+// so ErrNotFound refers to its parent.
+func categorySaved(w http.ResponseWriter, err error, failure string) bool {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "parent category not found", http.StatusBadRequest)
+	case errors.Is(err, store.ErrCategoryCycle):
+		http.Error(w, "a category cannot be moved under itself or its descendants", http.StatusBadRequest)
+	case errors.Is(err, store.ErrDuplicateCategorySlug):
+		http.Error(w, "slug already in use", http.StatusConflict)
+	case err != nil:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+	return err == nil
+}
+
+// Delete handles DELETE /categories/{categoryID}. Products in the category
+// are unassigned from it but otherwise untouched.
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.category(w, r)
+	if !ok {
+		return
+	}
+	err := h.Store.DeleteCategory(r.Context(), c.ID)
+	if errors.Is(err, store.ErrCategoryHasChildren) {
+		http.Error(w, "category has subcategories", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete category", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// category loads the category named by the categoryID URL parameter,
+// writing an error and returning false if there is none.
+func (h *CategoryHandler) category(w http.ResponseWriter, r *http.Request) (models.Category, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "categoryID"))
+	if err != nil {
+		http.Error(w, "invalid category ID", http.StatusBadRequest)
+		return models.Category{}, false
+	}
+	c, err := h.Store.GetCategory(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return models.Category{}, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch category", http.StatusInternalServerError)
+		return models.Category{}, false
+	}
+	return c, true
+}
+
+// ProductCategories handles GET /products/{id}/categories
+func (h *CategoryHandler) ProductCategories(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.product(w, r)
+	if !ok {
+		return
+	}
+	h.writeProductCategories(w, r, p.ID)
+}
+
+// SetProductCategories handles PUT /products/{id}/categories. The body's
+// category_ids replace the product's current categories.
+func (h *CategoryHandler) SetProductCategories(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.product(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		CategoryIDs []int `json:"category_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	err := h.Store.SetProductCategories(r.Context(), p.ID, body.CategoryIDs)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "category not found", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update product categories", http.StatusInternalServerError)
+		return
+	}
+	h.writeProductCategories(w, r, p.ID)
+}
+
+func (h *CategoryHandler) writeProductCategories(w http.ResponseWriter, r *http.Request, productID int) {
+	cats, err := h.Store.ProductCategories(r.Context(), productID)
+	if err != nil {
+		http.Error(w, "failed to fetch product categories", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cats)
+}
+
+// product loads the product named by the id URL parameter, writing an
+// error and returning false if there is none.
+func (h *CategoryHandler) product(w http.ResponseWriter, r *http.Request) (models.Product, bool) {
+	return productParam(w, r, h.Products)
+}
+
+// productParam loads the product named by the id URL parameter from
+// products, writing an error and returning false if there is none.
+func productParam(w http.ResponseWriter, r *http.Request, products store.ProductStore) (models.Product, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return models.Product{}, false
+	}
+	p, err := products.GetProduct(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return models.Product{}, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch product", http.StatusInternalServerError)
+		return models.Product{}, false
+	}
+	return p, true
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// createCategory posts body to h.Create and decodes the new category.
+func createCategory(t *testing.T, h *CategoryHandler, body string) models.Category {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.Create(w, httptest.NewRequest("POST", "/categories", bytes.NewBufferString(body)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create %s: status = %d (%s)", body, w.Code, w.Body)
+	}
+	var c models.Category
+	json.Unmarshal(w.Body.Bytes(), &c)
+	return c
+}
+
+func TestCreateCategory(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantSlug   string
+	}{
+		{"derived slug", `{"name":"  Kitchen & Dining "}`, http.StatusCreated, "kitchen-dining"},
+		{"explicit slug", `{"name":"Mugs","slug":"Coffee-Mugs"}`, http.StatusCreated, "coffee-mugs"},
+		{"missing name", `{"slug":"mugs"}`, http.StatusBadRequest, ""},
+		{"bad slug", `{"name":"Mugs","slug":"mugs!"}`, http.StatusBadRequest, ""},
+		{"unknown parent", `{"name":"Mugs","parent_id":42}`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewCategoryHandler(store.NewMemory(), nil)
+			w := httptest.NewRecorder()
+			h.Create(w, httptest.NewRequest("POST", "/categories", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			var c models.Category
+			json.Unmarshal(w.Body.Bytes(), &c)
+			if c.Slug != tt.wantSlug {
+				t.Errorf("slug = %q; want %q", c.Slug, tt.wantSlug)
+			}
+		})
+	}
+}
+
+func TestCreateCategory_DuplicateSlug(t *testing.T) {
+	h := NewCategoryHandler(store.NewMemory(), nil)
+	createCategory(t, h, `{"name":"Mugs"}`)
+
+	w := httptest.NewRecorder()
+	h.Create(w, httptest.NewRequest("POST", "/categories", bytes.NewBufferString(`{"name":"MUGS"}`)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	h := NewCategoryHandler(store.NewMemory(), nil)
+	home := createCategory(t, h, `{"name":"Home"}`)
+	kitchen := createCategory(t, h, `{"name":"Kitchen","parent_id":`+strconv.Itoa(home.ID)+`}`)
+	createCategory(t, h, `{"name":"Mugs","parent_id":`+strconv.Itoa(kitchen.ID)+`}`)
+	createCategory(t, h, `{"name":"Garden"}`)
+
+	w := httptest.NewRecorder()
+	h.Tree(w, httptest.NewRequest("GET", "/categories", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", w.Code, http.StatusOK)
+	}
+	var tree []models.Category
+	if err := json.Unmarshal(w.Body.Bytes(), &tree); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(tree) != 2 || tree[0].Name != "Garden" || tree[1].Name != "Home" {
+		t.Fatalf("roots = %+v; want Garden, Home", tree)
+	}
+	if k := tree[1].Children; len(k) != 1 || len(k[0].Children) != 1 || k[0].Children[0].Name != "Mugs" {
+		t.Errorf("Home subtree = %+v; want Kitchen > Mugs", k)
+	}
+}
+
+func TestUpdateCategory_Cycle(t *testing.T) {
+	h := NewCategoryHandler(store.NewMemory(), nil)
+	home := createCategory(t, h, `{"name":"Home"}`)
+	kitchen := createCategory(t, h, `{"name":"Kitchen","parent_id":`+strconv.Itoa(home.ID)+`}`)
+
+	for _, parent := range []int{home.ID, kitchen.ID} {
+		body := `{"name":"Home","parent_id":` + strconv.Itoa(parent) + `}`
+		req := withURLParams(httptest.NewRequest("PUT", "/categories/1", bytes.NewBufferString(body)),
+			"categoryID", strconv.Itoa(home.ID))
+		w := httptest.NewRecorder()
+		h.Update(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("parent %d: status = %d; want %d", parent, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestDeleteCategory_HasChildren(t *testing.T) {
+	h := NewCategoryHandler(store.NewMemory(), nil)
+	home := createCategory(t, h, `{"name":"Home"}`)
+	kitchen := createCategory(t, h, `{"name":"Kitchen","parent_id":`+strconv.Itoa(home.ID)+`}`)
+
+	del := func(id int) int {
+		w := httptest.NewRecorder()
+		h.Delete(w, withURLParams(httptest.NewRequest("DELETE", "/categories/"+strconv.Itoa(id), nil), "categoryID", strconv.Itoa(id)))
+		return w.Code
+	}
+	if code := del(home.ID); code != http.StatusConflict {
+		t.Fatalf("delete parent: status = %d; want %d", code, http.StatusConflict)
+	}
+	if code := del(kitchen.ID); code != http.StatusNoContent {
+		t.Fatalf("delete child: status = %d; want %d", code, http.StatusNoContent)
+	}
+	if code := del(home.ID); code != http.StatusNoContent {
+		t.Errorf("delete emptied parent: status = %d; want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestListProducts_CategoryIncludesDescendants(t *testing.T) {
+	st := store.NewMemory()
+	ctx := context.Background()
+	h := NewCategoryHandler(st, st)
+	home := createCategory(t, h, `{"name":"Home"}`)
+	kitchen := createCategory(t, h, `{"name":"Kitchen","parent_id":`+strconv.Itoa(home.ID)+`}`)
+	garden := createCategory(t, h, `{"name":"Garden"}`)
+	for _, seed := range []struct {
+		name     string
+		category int
+	}{{"Rug", home.ID}, {"Mug", kitchen.ID}, {"Rake", garden.ID}} {
+		p := models.Product{Name: seed.name, Price: 100}
+		st.CreateProduct(ctx, &p)
+		body := `{"category_ids":[` + strconv.Itoa(seed.category) + `]}`
+		w := httptest.NewRecorder()
+		h.SetProductCategories(w, withURLParams(
+			httptest.NewRequest("PUT", "/products/"+strconv.Itoa(p.ID)+"/categories", bytes.NewBufferString(body)),
+			"id", strconv.Itoa(p.ID)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("assign %s: status = %d (%s)", seed.name, w.Code, w.Body)
+		}
+	}
+
+	list := func(target string) []models.Product {
+		w := httptest.NewRecorder()
+		NewProductHandler(st, st).List(w, httptest.NewRequest("GET", target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d (%s)", target, w.Code, w.Body)
+		}
+		var got []models.Product
+		json.Unmarshal(w.Body.Bytes(), &got)
+		return got
+	}
+	if got := list("/products?category=" + strconv.Itoa(home.ID)); len(got) != 2 || got[0].Name != "Rug" || got[1].Name != "Mug" {
+		t.Errorf("home = %+v; want Rug, Mug", got)
+	}
+	if got := list("/products?category=" + strconv.Itoa(kitchen.ID)); len(got) != 1 || got[0].Name != "Mug" {
+		t.Errorf("kitchen = %+v; want Mug", got)
+	}
+	w := httptest.NewRecorder()
+	NewProductHandler(st, st).List(w, httptest.NewRequest("GET", "/products?category=home", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("non-numeric category: status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestSetProductCategories_UnknownCategory(t *testing.T) {
+	st := store.NewMemory()
+	p := models.Product{Name: "Mug", Price: 100}
+	st.CreateProduct(context.Background(), &p)
+	h := NewCategoryHandler(st, st)
+
+	w := httptest.NewRecorder()
+	h.SetProductCategories(w, withURLParams(
+		httptest.NewRequest("PUT", "/products/1/categories", bytes.NewBufferString(`{"category_ids":[9]}`)),
+		"id", strconv.Itoa(p.ID)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+}
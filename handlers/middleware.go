@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
 )
 
 type contextKey string
@@ -13,8 +16,19 @@ type contextKey string
 // ContextUserID is the key we use to store the user ID in request contexts.
 const ContextUserID = contextKey("userID")
 
-// AuthMiddleware parses a Bearer JWT and stores the user ID in the context.
-func AuthMiddleware(secret string) func(http.Handler) http.Handler {
+// ContextUserRole is the key we use to store the user's role in request contexts.
+const ContextUserRole = contextKey("userRole")
+
+// ContextTokenID and ContextTokenExpiry hold the access token's jti and
+// expiry, so the token can be revoked on logout.
+const (
+	ContextTokenID     = contextKey("tokenID")
+	ContextTokenExpiry = contextKey("tokenExpiry")
+)
+
+// AuthMiddleware parses a Bearer JWT, rejects it if it has been revoked, and
+// stores the user ID and role in the context.
+func AuthMiddleware(secret string, tokens store.TokenStore) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			auth := r.Header.Get("Authorization")
@@ -39,8 +53,84 @@ func AuthMiddleware(secret string) func(http.Handler) http.Handler {
 				http.Error(w, "invalid token claims", http.StatusUnauthorized)
 				return
 			}
+			// tokens without a jti predate revocation and cannot be logged out
+			jti, _ := claims["jti"].(string)
+			if jti == "" {
+				http.Error(w, "invalid token claims", http.StatusUnauthorized)
+				return
+			}
+			revoked, err := tokens.AccessTokenRevoked(r.Context(), jti)
+			if err != nil {
+				http.Error(w, "failed to check token", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "token revoked", http.StatusUnauthorized)
+				return
+			}
+			exp, err := claims.GetExpirationTime()
+			if err != nil || exp == nil {
+				http.Error(w, "invalid token claims", http.StatusUnauthorized)
+				return
+			}
+			// tokens issued before roles existed carry no role claim
+			role := models.RoleCustomer
+			if claim, ok := claims["role"].(string); ok && models.Role(claim).Valid() {
+				role = models.Role(claim)
+			}
 			ctx := context.WithValue(r.Context(), ContextUserID, int(sub))
+			ctx = context.WithValue(ctx, ContextUserRole, role)
+			ctx = context.WithValue(ctx, ContextTokenID, jti)
+			ctx = context.WithValue(ctx, ContextTokenExpiry, exp.Time)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// OptionalAuth is AuthMiddleware for routes that also serve anonymous
+// visitors: requests without an Authorization header pass through with no
+// user in the context, while a bad token is still rejected.
+func OptionalAuth(secret string, tokens store.TokenStore) func(http.Handler) http.Handler {
+	auth := AuthMiddleware(secret, tokens)
+	return func(next http.Handler) http.Handler {
+		authed := auth(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			authed.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireRole rejects requests whose authenticated user has none of the
+// given roles. It must run after AuthMiddleware.
+func RequireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := userRole(r)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			http.Error(w, "forbidden", http.StatusForbidden)
+		})
+	}
+}
+
+// userRole returns the role AuthMiddleware stored, defaulting to customer.
+func userRole(r *http.Request) models.Role {
+	if role, ok := r.Context().Value(ContextUserRole).(models.Role); ok {
+		return role
+	}
+	return models.RoleCustomer
+}
+
+// isStaff reports whether the request was made by staff or an admin.
+func isStaff(r *http.Request) bool {
+	role := userRole(r)
+	return role == models.RoleStaff || role == models.RoleAdmin
+}
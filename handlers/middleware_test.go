@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func signedToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func TestAuthMiddleware_Role(t *testing.T) {
+	tests := []struct {
+		name     string
+		claims   jwt.MapClaims
+		wantRole models.Role
+	}{
+		{
+			name:     "staff claim",
+			claims:   jwt.MapClaims{"sub": 1, "jti": "a", "role": "staff", "exp": time.Now().Add(time.Hour).Unix()},
+			wantRole: models.RoleStaff,
+		},
+		{
+			name:     "token without role",
+			claims:   jwt.MapClaims{"sub": 1, "jti": "b", "exp": time.Now().Add(time.Hour).Unix()},
+			wantRole: models.RoleCustomer,
+		},
+		{
+			name:     "unknown role",
+			claims:   jwt.MapClaims{"sub": 1, "jti": "c", "role": "root", "exp": time.Now().Add(time.Hour).Unix()},
+			wantRole: models.RoleCustomer,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.Role
+			h := AuthMiddleware("secret", store.NewMemory())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = userRole(r)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signedToken(t, tt.claims))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.wantRole {
+				t.Errorf("role = %q; want %q", got, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		role       models.Role
+		wantStatus int
+	}{
+		{models.RoleAdmin, http.StatusOK},
+		{models.RoleStaff, http.StatusOK},
+		{models.RoleCustomer, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.role), func(t *testing.T) {
+			ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			h := AuthMiddleware("secret", store.NewMemory())(RequireRole(models.RoleAdmin, models.RoleStaff)(ok))
+			req := httptest.NewRequest("POST", "/products", nil)
+			req.Header.Set("Authorization", "Bearer "+signedToken(t, jwt.MapClaims{
+				"sub": 1, "jti": "d", "role": string(tt.role), "exp": time.Now().Add(time.Hour).Unix(),
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_RejectsRevokedAndLegacyTokens(t *testing.T) {
+	st := store.NewMemory()
+	exp := time.Now().Add(time.Hour)
+	st.RevokeAccessToken(context.Background(), "gone", exp)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"revoked jti", jwt.MapClaims{"sub": 1, "jti": "gone", "exp": exp.Unix()}},
+		{"no jti", jwt.MapClaims{"sub": 1, "exp": exp.Unix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := AuthMiddleware("secret", st)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signedToken(t, tt.claims))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d; want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		auth       string
+		wantStatus int
+		wantUser   bool
+	}{
+		{"anonymous", "", http.StatusOK, false},
+		{"valid token", "Bearer " + signedToken(t, jwt.MapClaims{
+			"sub": 1, "jti": "e", "exp": time.Now().Add(time.Hour).Unix(),
+		}), http.StatusOK, true},
+		{"bad token", "Bearer nope", http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUser bool
+			h := OptionalAuth("secret", store.NewMemory())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, gotUser = r.Context().Value(ContextUserID).(int)
+			}))
+			req := httptest.NewRequest("GET", "/carts/current", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus || gotUser != tt.wantUser {
+				t.Errorf("status = %d, user = %v; want %d, %v", w.Code, gotUser, tt.wantStatus, tt.wantUser)
+			}
+		})
+	}
+}
@@ -1,118 +1,304 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/shipping"
+	"github.com/Heisenberg270/ecommerce-go/store"
+	"github.com/Heisenberg270/ecommerce-go/tax"
 )
 
-// OrderHandler manages orders
+// OrderHandler manages orders. Tax works out the tax on new orders, for
+// the region of the shipping address, else the region the client names,
+// else TaxRegion. Shipping prices the cart's shipping method.
 type OrderHandler struct {
-	DB *sqlx.DB
+	Orders     store.OrderStore
+	Carts      store.CartStore
+	Promotions store.PromotionStore
+	Addresses  store.AddressStore
+	Tax        tax.Calculator
+	TaxRegion  string
+	Shipping   shipping.RateProvider
 }
 
-// NewOrderHandler constructs an OrderHandler
-func NewOrderHandler(db *sqlx.DB) *OrderHandler {
-	return &OrderHandler{DB: db}
+// NewOrderHandler constructs an OrderHandler that charges no tax and the
+// default shipping rates
+func NewOrderHandler(orders store.OrderStore, carts store.CartStore, promos store.PromotionStore, addrs store.AddressStore) *OrderHandler {
+	return &OrderHandler{
+		Orders: orders, Carts: carts, Promotions: promos, Addresses: addrs,
+		Tax: &tax.Table{}, Shipping: shipping.DefaultTable(),
+	}
 }
 
 // CreateOrder handles POST /orders
+//
+// Clients may send an Idempotency-Key header; retrying a POST with the same
+// key returns the order created by the first attempt instead of a new one.
 func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	// 1) Get authenticated user
 	userID := r.Context().Value(ContextUserID).(int)
+	idemKey := r.Header.Get("Idempotency-Key")
+
+	// 2) Replay a previously completed request
+	if idemKey != "" && h.replayOrder(w, r, userID, idemKey) {
+		return
+	}
 
-	// 2) Parse cart_id from JSON
+	// 3) Parse cart_id, shipping address and tax region from JSON
 	var in struct {
-		CartID int `json:"cart_id"`
+		CartID    int    `json:"cart_id"`
+		AddressID int    `json:"address_id"`
+		Region    string `json:"region"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		http.Error(w, "invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	// 3) Fetch cart items with prices
-	var items []struct {
-		models.CartItem
-		UnitPrice float64 `db:"price"`
+	// 4) The cart must belong to the caller and not be checked out yet
+	cart, err := h.Carts.GetCart(r.Context(), in.CartID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "cart not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch cart", http.StatusInternalServerError)
+		}
+		return
 	}
-	itemQ := `
-		SELECT ci.cart_id, ci.product_id, ci.quantity, p.price
-		FROM cart_items ci
-		JOIN products p ON p.id = ci.product_id
-		WHERE ci.cart_id = $1`
-	if err := h.DB.Select(&items, itemQ, in.CartID); err != nil {
+	if !cart.OwnedBy(userID) {
+		http.Error(w, "cart belongs to another user", http.StatusForbidden)
+		return
+	}
+	if cart.Status != models.CartActive {
+		http.Error(w, "cart is no longer active", http.StatusConflict)
+		return
+	}
+
+	// 5) Fetch cart items with prices
+	lines, err := h.Carts.CartLines(r.Context(), in.CartID)
+	if err != nil {
 		http.Error(w, "failed to fetch cart items", http.StatusInternalServerError)
 		return
 	}
-	if len(items) == 0 {
+	if len(lines) == 0 {
 		http.Error(w, "cart is empty", http.StatusBadRequest)
 		return
 	}
+	// the customer must acknowledge prices that moved since they added items
+	models.TotalCart(lines)
+	var changed []models.CartLine
+	for _, l := range lines {
+		if l.PriceChanged {
+			changed = append(changed, l)
+		}
+	}
+	if len(changed) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(struct {
+			Error string            `json:"error"`
+			Items []models.CartLine `json:"items"`
+		}{Error: "prices changed", Items: changed})
+		return
+	}
 
-	// 4) Compute total
-	total := 0.0
-	for _, it := range items {
-		total += float64(it.Quantity) * it.UnitPrice
+	// 6) Compute total and snapshot prices onto the order items
+	currency := lines[0].Currency
+	var total models.Money
+	items := make([]models.OrderItem, 0, len(lines))
+	for _, l := range lines {
+		if l.Currency != currency {
+			http.Error(w, "cart mixes currencies", http.StatusConflict)
+			return
+		}
+		total += l.UnitPrice.Mul(l.Quantity)
+		items = append(items, models.OrderItem{
+			ProductID: l.ProductID,
+			VariantID: l.VariantID,
+			SKU:       l.SKU,
+			Quantity:  l.Quantity,
+			UnitPrice: l.UnitPrice,
+		})
 	}
 
-	// 5) Insert into orders
-	var order models.Order
-	ordQ := `
-		INSERT INTO orders (user_id, total_amount, status)
-		VALUES ($1, $2, $3)
-		RETURNING id, user_id, total_amount, status, created_at`
-	if err := h.DB.Get(&order, ordQ, userID, total, "pending"); err != nil {
-		http.Error(w, "failed to create order", http.StatusInternalServerError)
-		return
+	// 7) Take off the cart's coupon, which must still apply
+	order := models.Order{UserID: userID, Currency: currency, Status: models.StatusPending}
+	if cart.PromotionID != nil {
+		discount, ok := h.cartDiscount(w, r, *cart.PromotionID, lines, userID)
+		if !ok {
+			return
+		}
+		order.Discount = discount
+		total -= discount.Amount
 	}
 
-	// 6) Insert order_items and clear cart in a tx
-	tx, err := h.DB.Beginx()
-	if err != nil {
-		http.Error(w, "failed to start transaction", http.StatusInternalServerError)
+	// 8) Price shipping to the chosen or default address
+	dest, ok := h.shippingAddress(w, r, cart, in.AddressID, userID)
+	if !ok {
 		return
 	}
-	for _, it := range items {
-		if _, err := tx.Exec(
-			`INSERT INTO order_items (order_id, product_id, quantity, unit_price)
-			 VALUES ($1, $2, $3, $4)`,
-			order.ID, it.ProductID, it.Quantity, it.UnitPrice,
-		); err != nil {
-			tx.Rollback()
-			http.Error(w, "failed to insert order items", http.StatusInternalServerError)
+	if cart.ShippingMethod != "" {
+		quotes, err := h.Shipping.Quote(r.Context(), shippingRequest(*dest, lines))
+		if err != nil {
+			http.Error(w, "failed to quote shipping", http.StatusInternalServerError)
+			return
+		}
+		quote, ok := shipping.Find(quotes, cart.ShippingMethod)
+		if !ok {
+			http.Error(w, "shipping method not available for this address", http.StatusConflict)
 			return
 		}
+		sh := models.NewOrderShipping(*dest, cart.ShippingMethod)
+		order.Shipping = &sh
+		order.ShippingAmount = quote.Amount
+		total += quote.Amount
+	}
+
+	// 9) Work out tax per line, on the discounted amounts
+	region := in.Region
+	if dest != nil {
+		region = dest.TaxRegion()
+	}
+	if region == "" {
+		region = h.TaxRegion
 	}
-	// clear the cart
-	if _, err := tx.Exec(`DELETE FROM cart_items WHERE cart_id=$1`, in.CartID); err != nil {
-		tx.Rollback()
-		http.Error(w, "failed to clear cart", http.StatusInternalServerError)
+	var discount models.Money
+	if order.Discount != nil {
+		discount = order.Discount.Amount
+	}
+	taxes, err := h.Tax.Calculate(r.Context(), taxRequest(region, currency, lines, discount))
+	if err != nil {
+		http.Error(w, "failed to calculate tax", http.StatusInternalServerError)
+		return
+	}
+	for i := range items {
+		items[i].TaxAmount = taxes.Lines[i]
+	}
+	order.TaxAmount, order.TaxInclusive = taxes.Total, taxes.Inclusive
+	if !taxes.Inclusive {
+		total += taxes.Total
+	}
+	order.TotalAmount = total
+
+	// 10) Insert order and items, reserve stock and clear the cart in one transaction
+	if err := h.Orders.CreateOrder(r.Context(), &order, items, in.CartID, idemKey); err != nil {
+		var stockErr *store.InsufficientStockError
+		switch {
+		case errors.As(err, &stockErr):
+			writeStockConflict(w, stockErr.Shortages)
+		case errors.Is(err, store.ErrCouponUnavailable):
+			http.Error(w, "coupon no longer available", http.StatusConflict)
+		case errors.Is(err, store.ErrCartNotActive):
+			// a concurrent retry may have checked the cart out already
+			if idemKey == "" || !h.replayOrder(w, r, userID, idemKey) {
+				http.Error(w, "cart is no longer active", http.StatusConflict)
+			}
+		case errors.Is(err, store.ErrCartChanged):
+			http.Error(w, "cart changed while the order was being placed", http.StatusConflict)
+		case errors.Is(err, store.ErrDuplicateIdempotencyKey):
+			// a concurrent retry won the race; answer with its order
+			if !h.replayOrder(w, r, userID, idemKey) {
+				http.Error(w, "failed to create order", http.StatusInternalServerError)
+			}
+		default:
+			http.Error(w, "failed to create order", http.StatusInternalServerError)
+		}
 		return
 	}
-	tx.Commit()
 
-	// 7) Return the created order
+	// 11) Return the created order
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(order)
+}
+
+// shippingAddress returns the address the order goes to: addressID if
+// given, else the user's default when the cart is to be shipped. It is nil
+// for orders that are not shipped and name no address. An error is written
+// and false returned if the address is missing or not the user's.
+func (h *OrderHandler) shippingAddress(w http.ResponseWriter, r *http.Request, cart models.Cart, addressID, userID int) (*models.Address, bool) {
+	if addressID != 0 {
+		a, ok := userAddress(w, r, h.Addresses, addressID)
+		return &a, ok
+	}
+	if cart.ShippingMethod == "" {
+		return nil, true
+	}
+	a, err := h.Addresses.DefaultAddress(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "shipping address required", http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch address", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &a, true
+}
+
+// cartDiscount prices the coupon with promotionID against lines, writing
+// an error and returning false if it no longer applies.
+func (h *OrderHandler) cartDiscount(w http.ResponseWriter, r *http.Request, promotionID int, lines []models.CartLine, userID int) (*models.OrderDiscount, bool) {
+	promo, err := h.Promotions.GetPromotion(r.Context(), promotionID)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "coupon no longer available", http.StatusConflict)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch coupon", http.StatusInternalServerError)
+		return nil, false
+	}
+	off, reason, err := couponDiscount(r.Context(), h.Promotions, promo, lines, userID)
+	if err != nil {
+		http.Error(w, "failed to check coupon", http.StatusInternalServerError)
+		return nil, false
+	}
+	if reason != nil {
+		http.Error(w, reason.Error(), http.StatusConflict)
+		return nil, false
+	}
+	return &models.OrderDiscount{PromotionID: &promo.ID, Code: promo.Code, Amount: off}, true
+}
+
+// replayOrder writes the order previously created with key, reporting
+// whether one existed.
+func (h *OrderHandler) replayOrder(w http.ResponseWriter, r *http.Request, userID int, key string) bool {
+	order, err := h.Orders.GetOrderByIdempotencyKey(r.Context(), userID, key)
+	if errors.Is(err, store.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		http.Error(w, "failed to look up idempotency key", http.StatusInternalServerError)
+		return true
+	}
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Idempotent-Replayed", "true")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(order)
+	return true
+}
+
+// writeStockConflict responds 409 with the products that are short.
+func writeStockConflict(w http.ResponseWriter, shortages []store.StockShortage) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(struct {
+		Error string                `json:"error"`
+		Items []store.StockShortage `json:"items"`
+	}{Error: "insufficient stock", Items: shortages})
 }
 
 // ListOrders handles GET /orders
 func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(ContextUserID).(int)
-	var orders []models.Order
-	if err := h.DB.Select(
-		&orders,
-		`SELECT id, user_id, total_amount, status, created_at
-		 FROM orders WHERE user_id=$1 ORDER BY id`,
-		userID,
-	); err != nil {
+	orders, err := h.Orders.ListOrders(r.Context(), userID)
+	if err != nil {
 		http.Error(w, "failed to fetch orders", http.StatusInternalServerError)
 		return
 	}
@@ -122,47 +308,131 @@ func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 
 // GetOrder handles GET /orders/{orderID}
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
-	orderID, _ := strconv.Atoi(chi.URLParam(r, "orderID"))
-
 	// Fetch order
-	var order models.Order
-	if err := h.DB.Get(
-		&order,
-		`SELECT id, user_id, total_amount, status, created_at
-		 FROM orders WHERE id=$1`, orderID,
-	); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "order not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "failed to fetch order", http.StatusInternalServerError)
-		}
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
 		return
 	}
+	orderID := order.ID
 
 	// Fetch items
-	var items []struct {
-		models.OrderItem
-		ProductName string `db:"name" json:"product_name"`
-	}
-	itemQ := `
-		SELECT oi.order_id, oi.product_id, oi.quantity, oi.unit_price, p.name
-		FROM order_items oi
-		JOIN products p ON p.id = oi.product_id
-		WHERE oi.order_id = $1`
-	if err := h.DB.Select(&items, itemQ, orderID); err != nil {
+	items, err := h.Orders.OrderLines(r.Context(), orderID)
+	if err != nil {
 		http.Error(w, "failed to fetch order items", http.StatusInternalServerError)
 		return
 	}
 
+	// Fetch the discount, if any
+	discount, err := h.Orders.OrderDiscount(r.Context(), orderID)
+	switch {
+	case err == nil:
+		order.Discount = &discount
+	case !errors.Is(err, store.ErrNotFound):
+		http.Error(w, "failed to fetch order discount", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch the shipping address, if any
+	sh, err := h.Orders.OrderShipping(r.Context(), orderID)
+	switch {
+	case err == nil:
+		order.Shipping = &sh
+	case !errors.Is(err, store.ErrNotFound):
+		http.Error(w, "failed to fetch order shipping", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch status history
+	history, err := h.Orders.OrderStatusHistory(r.Context(), orderID)
+	if err != nil {
+		http.Error(w, "failed to fetch order history", http.StatusInternalServerError)
+		return
+	}
+
 	// Assemble response
 	resp := struct {
-		Order models.Order `json:"order"`
-		Items interface{}  `json:"items"`
+		Order   models.Order               `json:"order"`
+		Items   []models.OrderLine         `json:"items"`
+		History []models.OrderStatusChange `json:"history"`
 	}{
-		Order: order,
-		Items: items,
+		Order:   order,
+		Items:   items,
+		History: history,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// visibleOrder loads the order named by the orderID URL parameter, writing
+// an error and returning false if it does not exist or the caller may not
+// see it. Customers may only see their own orders; staff see all.
+func visibleOrder(w http.ResponseWriter, r *http.Request, orders store.OrderStore) (models.Order, bool) {
+	orderID, err := strconv.Atoi(chi.URLParam(r, "orderID"))
+	if err != nil {
+		http.Error(w, "invalid order ID", http.StatusBadRequest)
+		return models.Order{}, false
+	}
+	order, err := orders.GetOrder(r.Context(), orderID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "order not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch order", http.StatusInternalServerError)
+		}
+		return models.Order{}, false
+	}
+	if order.UserID != r.Context().Value(ContextUserID).(int) && !isStaff(r) {
+		http.Error(w, "order not found", http.StatusNotFound)
+		return models.Order{}, false
+	}
+	return order, true
+}
+
+// UpdateStatus handles PATCH /orders/{orderID}/status, letting staff move an
+// order through its lifecycle. Orders are cancelled and refunded through
+// RefundHandler instead, which also restocks them and returns the money.
+func (h *OrderHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+	orderID, err := strconv.Atoi(chi.URLParam(r, "orderID"))
+	if err != nil {
+		http.Error(w, "invalid order ID", http.StatusBadRequest)
+		return
+	}
+	var in struct {
+		Status models.OrderStatus `json:"status"`
+		Note   string             `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if !in.Status.Valid() {
+		http.Error(w, "unknown status", http.StatusBadRequest)
+		return
+	}
+	switch in.Status {
+	case models.StatusCancelled:
+		http.Error(w, "cancel orders with POST /orders/{orderID}/cancel", http.StatusConflict)
+		return
+	case models.StatusRefunded:
+		http.Error(w, "refund orders with POST /orders/{orderID}/refunds", http.StatusConflict)
+		return
+	}
+
+	order, err := h.Orders.TransitionOrder(r.Context(), orderID, in.Status, &userID, in.Note)
+	if err != nil {
+		var transErr *models.TransitionError
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "order not found", http.StatusNotFound)
+		case errors.As(err, &transErr):
+			http.Error(w, transErr.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "failed to update order status", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(order)
+}
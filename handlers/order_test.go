@@ -7,60 +7,20 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
-	"time"
-
-	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+	"github.com/Heisenberg270/ecommerce-go/tax"
 )
 
-func setupOrderMock(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to open sqlmock: %v", err)
-	}
-	return sqlx.NewDb(db, "sqlmock"), mock
-}
-
 func TestCreateOrder(t *testing.T) {
-	db, mock := setupOrderMock(t)
-	// 1) Cart items fetch
-	rows := sqlmock.NewRows([]string{"cart_id", "product_id", "quantity", "price"}).
-		AddRow(1, 10, 2, 5.00)
-	mock.ExpectQuery(`FROM cart_items`).
-		WithArgs(1).
-		WillReturnRows(rows)
-
-	// 2) Insert order
-	now := time.Now()
-	mock.ExpectQuery(`INSERT INTO orders`).
-		WithArgs(42, 10.00, "pending").
-		WillReturnRows(sqlmock.NewRows(
-			[]string{"id", "user_id", "total_amount", "status", "created_at"},
-		).AddRow(100, 42, 10.00, "pending", now))
-
-	// 3) Begin transaction
-	mock.ExpectBegin()
-
-	// 4) Insert order_items
-	mock.ExpectExec(`INSERT INTO order_items`).
-		WithArgs(100, 10, 2, 5.00).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	// 5) Clear cart_items
-	mock.ExpectExec(`DELETE FROM cart_items`).
-		WithArgs(1).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	// 6) Commit transaction
-	mock.ExpectCommit()
-
-	// Call handler
-	oh := NewOrderHandler(db)
-	payload := []byte(`{"cart_id":1}`)
-	req := httptest.NewRequest("POST", "/orders", bytes.NewReader(payload))
-	req = req.WithContext(context.WithValue(req.Context(), ContextUserID, 42))
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+	oh := NewOrderHandler(st, st, st, st)
+	req := withUser(httptest.NewRequest("POST", "/orders",
+		bytes.NewBufferString(`{"cart_id":1}`)), 42)
 	w := httptest.NewRecorder()
 
 	oh.CreateOrder(w, req)
@@ -71,12 +31,387 @@ func TestCreateOrder(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
 		t.Fatalf("invalid JSON: %v", err)
 	}
-	if resp.ID != 100 || resp.UserID != 42 {
-		t.Errorf("got %+v; want ID=100, UserID=42", resp)
+	if resp.ID == 0 || resp.UserID != 42 || resp.TotalAmount != 1000 || resp.Status != "pending" {
+		t.Errorf("got %+v; want pending order for user 42 totalling 10.00", resp)
+	}
+	if lines, _ := st.CartLines(context.Background(), cart.ID); len(lines) != 0 {
+		t.Errorf("cart not cleared: %+v", lines)
+	}
+	items, _ := st.OrderLines(context.Background(), resp.ID)
+	if len(items) != 1 || items[0].UnitPrice != 500 {
+		t.Errorf("got order items %+v; want one line at 5.00", items)
+	}
+	if got, _ := st.GetProduct(context.Background(), p.ID); got.StockQuantity != 8 {
+		t.Errorf("stock = %d; want 8", got.StockQuantity)
+	}
+}
+
+func TestCreateOrder_Tax(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      tax.Mode
+		body      string
+		wantTotal models.Money
+		wantTax   models.Money
+	}{
+		{"exclusive, default region", tax.ModeExclusive, `{"cart_id":1}`, 1080, 80},
+		{"exclusive, named region", tax.ModeExclusive, `{"cart_id":1,"region":"GB"}`, 1200, 200},
+		{"inclusive", tax.ModeInclusive, `{"cart_id":1,"region":"GB"}`, 1000, 167},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			p, cart := seedCart(t, st, 42)
+			st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+			oh := NewOrderHandler(st, st, st, st)
+			oh.Tax = &tax.Table{Mode: tt.mode, Rates: []tax.Rate{
+				{Region: "US", BasisPoints: 800},
+				{Region: "GB", BasisPoints: 2000},
+			}}
+			oh.TaxRegion = "US-TX"
+			w := httptest.NewRecorder()
+			oh.CreateOrder(w, withUser(httptest.NewRequest("POST", "/orders", bytes.NewBufferString(tt.body)), 42))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("CreateOrder status = %d; want %d (%s)", w.Code, http.StatusCreated, w.Body)
+			}
+			var order models.Order
+			json.Unmarshal(w.Body.Bytes(), &order)
+			if order.TotalAmount != tt.wantTotal || order.TaxAmount != tt.wantTax {
+				t.Errorf("got total %s, tax %s; want %s, %s", order.TotalAmount, order.TaxAmount, tt.wantTotal, tt.wantTax)
+			}
+			items, _ := st.OrderLines(context.Background(), order.ID)
+			if len(items) != 1 || items[0].TaxAmount != tt.wantTax {
+				t.Errorf("got items %+v; want one line taxed %s", items, tt.wantTax)
+			}
+		})
+	}
+}
+
+func TestCreateOrder_PriceChanged(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(ctx, cart.ID, p.ID, nil, 2)
+	p.Price = 650
+	st.UpdateProduct(ctx, &p)
+
+	oh := NewOrderHandler(st, st, st, st)
+	order := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		oh.CreateOrder(w, withUser(httptest.NewRequest("POST", "/orders",
+			bytes.NewBufferString(`{"cart_id":1}`)), 42))
+		return w
+	}
+
+	w := order()
+	if w.Code != http.StatusConflict {
+		t.Fatalf("CreateOrder status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	var resp struct {
+		Items []models.CartLine `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].AddedPrice != 500 || resp.Items[0].UnitPrice != 650 {
+		t.Errorf("got changed lines %+v; want one line from 5.00 to 6.50", resp.Items)
+	}
+
+	// once the customer accepts the new price the order goes through at it
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	w = httptest.NewRecorder()
+	ch.AcceptPrices(w, withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/accept-prices", nil),
+		"cartID", "1"), 42))
+	if w.Code != http.StatusOK {
+		t.Fatalf("AcceptPrices status = %d; want %d", w.Code, http.StatusOK)
+	}
+	w = order()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOrder after accept status = %d; want %d", w.Code, http.StatusCreated)
+	}
+	var o models.Order
+	json.Unmarshal(w.Body.Bytes(), &o)
+	if o.TotalAmount != 1300 {
+		t.Errorf("order total = %v; want 13.00", o.TotalAmount)
+	}
+}
+
+func TestCreateOrder_InsufficientStock(t *testing.T) {
+	st := store.NewMemory()
+	ctx := context.Background()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(ctx, cart.ID, p.ID, nil, 4)
+	// stock drops after the item was added to the cart
+	p.StockQuantity = 3
+	st.UpdateProduct(ctx, &p)
+
+	oh := NewOrderHandler(st, st, st, st)
+	req := withUser(httptest.NewRequest("POST", "/orders",
+		bytes.NewBufferString(`{"cart_id":1}`)), 42)
+	w := httptest.NewRecorder()
+
+	oh.CreateOrder(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("CreateOrder status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	var resp struct {
+		Items []store.StockShortage `json:"items"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].ProductID != p.ID || resp.Items[0].Available != 3 {
+		t.Errorf("got shortages %+v; want product %d with 3 available", resp.Items, p.ID)
+	}
+	if lines, _ := st.CartLines(ctx, cart.ID); len(lines) != 1 {
+		t.Errorf("cart should be untouched, got %+v", lines)
+	}
+}
+
+func TestCreateOrder_IdempotencyKey(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+	oh := NewOrderHandler(st, st, st, st)
+	var ids []int
+	for i := 0; i < 2; i++ {
+		req := withUser(httptest.NewRequest("POST", "/orders",
+			bytes.NewBufferString(`{"cart_id":1}`)), 42)
+		req.Header.Set("Idempotency-Key", "abc-123")
+		w := httptest.NewRecorder()
+		oh.CreateOrder(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("attempt %d: status = %d; want %d", i+1, w.Code, http.StatusCreated)
+		}
+		var resp models.Order
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		ids = append(ids, resp.ID)
+		if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != (i == 1) {
+			t.Errorf("attempt %d: Idempotent-Replayed = %v", i+1, replayed)
+		}
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("retry created order %d; want original %d", ids[1], ids[0])
+	}
+	if orders, _ := st.ListOrders(context.Background(), 42); len(orders) != 1 {
+		t.Errorf("got %d orders; want 1", len(orders))
+	}
+}
+
+func TestCreateOrder_Concurrent(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+
+	oh := NewOrderHandler(st, st, st, st)
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			req := withUser(httptest.NewRequest("POST", "/orders",
+				bytes.NewBufferString(`{"cart_id":1}`)), 42)
+			w := httptest.NewRecorder()
+			oh.CreateOrder(w, req)
+			codes <- w.Code
+		}()
+	}
+	got := map[int]int{}
+	for i := 0; i < 2; i++ {
+		got[<-codes]++
+	}
+	if got[http.StatusCreated] != 1 || got[http.StatusConflict] != 1 {
+		t.Errorf("statuses = %v; want one 201 and one 409", got)
+	}
+	if orders, _ := st.ListOrders(context.Background(), 42); len(orders) != 1 {
+		t.Errorf("got %d orders; want 1", len(orders))
+	}
+	if got, _ := st.GetProduct(context.Background(), p.ID); got.StockQuantity != 8 {
+		t.Errorf("stock = %d; want 8", got.StockQuantity)
+	}
+}
+
+func TestCreateOrder_ForeignCart(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 7)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 1)
+
+	oh := NewOrderHandler(st, st, st, st)
+	req := withUser(httptest.NewRequest("POST", "/orders",
+		bytes.NewBufferString(`{"cart_id":1}`)), 42)
+	w := httptest.NewRecorder()
+
+	oh.CreateOrder(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("CreateOrder status = %d; want %d", w.Code, http.StatusForbidden)
+	}
+	if lines, _ := st.CartLines(context.Background(), cart.ID); len(lines) != 1 {
+		t.Errorf("foreign cart was modified: %+v", lines)
+	}
+}
+
+func TestCreateOrder_EmptyCart(t *testing.T) {
+	st := store.NewMemory()
+	seedCart(t, st, 42)
+
+	oh := NewOrderHandler(st, st, st, st)
+	req := withUser(httptest.NewRequest("POST", "/orders",
+		bytes.NewBufferString(`{"cart_id":1}`)), 42)
+	w := httptest.NewRecorder()
+
+	oh.CreateOrder(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("CreateOrder status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 1)
+	order := models.Order{UserID: 42, TotalAmount: 500, Status: "pending"}
+	st.CreateOrder(context.Background(), &order,
+		[]models.OrderItem{{ProductID: p.ID, Quantity: 1, UnitPrice: 500}}, cart.ID, "")
+
+	oh := NewOrderHandler(st, st, st, st)
+	req := withURLParams(withUser(httptest.NewRequest("GET", "/orders/1", nil), 42),
+		"orderID", "1")
+	w := httptest.NewRecorder()
+	oh.GetOrder(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("GetOrder status = %d; want %d", w.Code, http.StatusOK)
+	}
+	var resp struct {
+		Order   models.Order               `json:"order"`
+		Items   []models.OrderLine         `json:"items"`
+		History []models.OrderStatusChange `json:"history"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Order.ID != order.ID || len(resp.Items) != 1 || resp.Items[0].ProductName != "Widget" {
+		t.Errorf("got %+v; want order %d with one Widget line", resp, order.ID)
+	}
+	if len(resp.History) != 1 || resp.History[0].FromStatus != nil || resp.History[0].ToStatus != models.StatusPending {
+		t.Errorf("got history %+v; want initial pending entry", resp.History)
+	}
+}
+
+func TestGetOrder_OtherCustomer(t *testing.T) {
+	st := store.NewMemory()
+	placeOrder(t, st, 42)
+
+	oh := NewOrderHandler(st, st, st, st)
+	req := withURLParams(withUser(httptest.NewRequest("GET", "/orders/1", nil), 7),
+		"orderID", "1")
+	w := httptest.NewRecorder()
+	oh.GetOrder(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("customer GetOrder status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), ContextUserRole, models.RoleStaff))
+	w = httptest.NewRecorder()
+	oh.GetOrder(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("staff GetOrder status = %d; want %d", w.Code, http.StatusOK)
+	}
+}
+
+// placeOrder seeds a pending order for userID and returns it.
+func placeOrder(t *testing.T, st *store.Memory, userID int) models.Order {
+	t.Helper()
+	p, cart := seedCart(t, st, userID)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 1)
+	order := models.Order{UserID: userID, TotalAmount: 500, Currency: "USD", Status: models.StatusPending}
+	if err := st.CreateOrder(context.Background(), &order,
+		[]models.OrderItem{{ProductID: p.ID, Quantity: 1, UnitPrice: 500}}, cart.ID, ""); err != nil {
+		t.Fatalf("seed order: %v", err)
 	}
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Fatalf("unmet expectations: %v", err)
+	return order
+}
+
+func TestUpdateStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "allowed", body: `{"status":"paid","note":"bank transfer"}`, wantStatus: http.StatusOK},
+		{name: "forbidden transition", body: `{"status":"delivered"}`, wantStatus: http.StatusConflict},
+		{name: "unknown status", body: `{"status":"lost"}`, wantStatus: http.StatusBadRequest},
+		{name: "cancelled", body: `{"status":"cancelled"}`, wantStatus: http.StatusConflict},
+		{name: "refunded", body: `{"status":"refunded"}`, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			order := placeOrder(t, st, 42)
+
+			oh := NewOrderHandler(st, st, st, st)
+			req := withURLParams(withUser(httptest.NewRequest("PATCH", "/orders/1/status",
+				bytes.NewBufferString(tt.body)), 7), "orderID", "1")
+			w := httptest.NewRecorder()
+			oh.UpdateStatus(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("UpdateStatus status = %d; want %d", w.Code, tt.wantStatus)
+			}
+			history, _ := st.OrderStatusHistory(context.Background(), order.ID)
+			if tt.wantStatus != http.StatusOK {
+				if len(history) != 1 {
+					t.Errorf("rejected change was recorded: %+v", history)
+				}
+				return
+			}
+			last := history[len(history)-1]
+			if last.ToStatus != models.StatusPaid || last.ChangedBy == nil || *last.ChangedBy != 7 || last.Note != "bank transfer" {
+				t.Errorf("got history entry %+v; want paid by user 7", last)
+			}
+		})
 	}
 }
 
-// ... TestCreateOrder_EmptyCart, and other tests ...
+func TestCreateOrder_Shipping(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+	st.SetCartShippingMethod(context.Background(), cart.ID, "express")
+
+	oh := NewOrderHandler(st, st, st, st)
+	oh.Tax = &tax.Table{Mode: tax.ModeExclusive, Rates: []tax.Rate{{Region: "US-IL", BasisPoints: 1000}}}
+	create := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		oh.CreateOrder(w, withUser(httptest.NewRequest("POST", "/orders",
+			bytes.NewBufferString(`{"cart_id":1}`)), 42))
+		return w
+	}
+	if w := create(); w.Code != http.StatusBadRequest {
+		t.Fatalf("without address status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+
+	addr := seedAddress(t, st, 42, "IL")
+	w := create()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOrder status = %d; want %d (%s)", w.Code, http.StatusCreated, w.Body)
+	}
+	var resp models.Order
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	// 10.00 of goods, 1.00 Illinois tax and 10.00 express shipping
+	if resp.ShippingAmount != 1000 || resp.TaxAmount != 100 || resp.TotalAmount != 2100 {
+		t.Errorf("got %+v; want shipping 10.00, tax 1.00, total 21.00", resp)
+	}
+
+	// later edits to the address book leave the order alone
+	addr.City = "Chicago"
+	st.UpdateAddress(context.Background(), &addr)
+	sh, err := st.OrderShipping(context.Background(), resp.ID)
+	if err != nil || sh.Method != "express" || sh.City != "Springfield" || sh.Region != "IL" {
+		t.Errorf("OrderShipping = %+v, %v; want express to Springfield, IL", sh, err)
+	}
+}
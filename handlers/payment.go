@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// PaymentHandler collects payment for orders through a payment provider
+type PaymentHandler struct {
+	Orders   store.OrderStore
+	Payments store.PaymentStore
+	Provider payments.Provider
+}
+
+// NewPaymentHandler constructs a PaymentHandler
+func NewPaymentHandler(orders store.OrderStore, ps store.PaymentStore, provider payments.Provider) *PaymentHandler {
+	return &PaymentHandler{Orders: orders, Payments: ps, Provider: provider}
+}
+
+// Pay handles POST /orders/{orderID}/pay. It authorizes and captures the
+// order total with the provider and moves the order to paid.
+func (h *PaymentHandler) Pay(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+
+	// 1) Parse the payment method token
+	var in struct {
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if in.PaymentMethod == "" {
+		http.Error(w, "payment_method is required", http.StatusBadRequest)
+		return
+	}
+
+	// 2) Only the owner may pay, and only while the order is pending
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	if order.UserID != userID {
+		http.Error(w, "order belongs to another user", http.StatusForbidden)
+		return
+	}
+	if order.Status != models.StatusPending {
+		http.Error(w, "order is not awaiting payment", http.StatusConflict)
+		return
+	}
+
+	// 3) Record the attempt before calling out, so concurrent attempts are refused
+	p := models.Payment{
+		OrderID:  order.ID,
+		Provider: h.Provider.Name(),
+		Amount:   order.TotalAmount,
+		Currency: order.Currency,
+		Status:   models.PaymentPending,
+	}
+	if err := h.Payments.CreatePayment(r.Context(), &p); err != nil {
+		if errors.Is(err, store.ErrActivePayment) {
+			http.Error(w, "order already has a payment in progress", http.StatusConflict)
+		} else {
+			http.Error(w, "failed to record payment", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 4) Authorize
+	ref, err := h.Provider.Authorize(r.Context(), payments.AuthorizeRequest{
+		OrderID:  order.ID,
+		Amount:   order.TotalAmount,
+		Currency: order.Currency,
+		Method:   in.PaymentMethod,
+	})
+	if err != nil {
+		p.Status, p.FailureReason = models.PaymentFailed, err.Error()
+		if errors.Is(err, payments.ErrDeclined) {
+			p.Status = models.PaymentDeclined
+		}
+		if err := h.Payments.UpdatePayment(r.Context(), &p); err != nil {
+			http.Error(w, "failed to record payment", http.StatusInternalServerError)
+			return
+		}
+		if p.Status == models.PaymentDeclined {
+			writePayment(w, http.StatusPaymentRequired, p, nil)
+		} else {
+			http.Error(w, "payment provider error", http.StatusBadGateway)
+		}
+		return
+	}
+	p.ProviderRef, p.Status = ref, models.PaymentAuthorized
+	if err := h.Payments.UpdatePayment(r.Context(), &p); err != nil {
+		h.Provider.Void(r.Context(), ref)
+		http.Error(w, "failed to record payment", http.StatusInternalServerError)
+		return
+	}
+
+	// 5) Capture, releasing the hold if that fails
+	if err := h.Provider.Capture(r.Context(), ref, p.Amount); err != nil {
+		h.Provider.Void(r.Context(), ref)
+		p.Status, p.FailureReason = models.PaymentFailed, err.Error()
+		h.Payments.UpdatePayment(r.Context(), &p)
+		http.Error(w, "payment provider error", http.StatusBadGateway)
+		return
+	}
+
+	// 6) Mark the payment captured and the order paid together
+	order, err = h.Payments.CapturePayment(r.Context(), &p, &userID)
+	if err != nil {
+		var transErr *models.TransitionError
+		if errors.As(err, &transErr) {
+			// the order moved on (e.g. was cancelled) while we were charging
+			if h.Provider.Refund(r.Context(), ref, p.Amount) == nil {
+				p.Status = models.PaymentRefunded
+			} else {
+				p.Status = models.PaymentCaptured
+			}
+			p.FailureReason = transErr.Error()
+			h.Payments.UpdatePayment(r.Context(), &p)
+			http.Error(w, transErr.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to record payment", http.StatusInternalServerError)
+		return
+	}
+	writePayment(w, http.StatusCreated, p, &order)
+}
+
+// ListPayments handles GET /orders/{orderID}/payments
+func (h *PaymentHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	ps, err := h.Payments.ListPayments(r.Context(), order.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps)
+}
+
+// writePayment responds with p and, once paid, its order.
+func writePayment(w http.ResponseWriter, status int, p models.Payment, order *models.Order) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Payment models.Payment `json:"payment"`
+		Order   *models.Order  `json:"order,omitempty"`
+	}{p, order})
+}
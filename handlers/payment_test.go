@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func payRequest(userID int, body string) *http.Request {
+	return withURLParams(withUser(httptest.NewRequest("POST", "/orders/1/pay",
+		bytes.NewBufferString(body)), userID), "orderID", "1")
+}
+
+func TestPay(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      int
+		body        string
+		outcome     payments.Outcome
+		wantStatus  int
+		wantOrder   models.OrderStatus
+		wantPayment models.PaymentStatus
+	}{
+		{
+			name: "approved", userID: 42, body: `{"payment_method":"tok_visa"}`,
+			outcome: payments.OutcomeApprove, wantStatus: http.StatusCreated,
+			wantOrder: models.StatusPaid, wantPayment: models.PaymentCaptured,
+		},
+		{
+			name: "declined", userID: 42, body: `{"payment_method":"tok_visa"}`,
+			outcome: payments.OutcomeDecline, wantStatus: http.StatusPaymentRequired,
+			wantOrder: models.StatusPending, wantPayment: models.PaymentDeclined,
+		},
+		{
+			name: "gateway error", userID: 42, body: `{"payment_method":"tok_visa"}`,
+			outcome: payments.OutcomeError, wantStatus: http.StatusBadGateway,
+			wantOrder: models.StatusPending, wantPayment: models.PaymentFailed,
+		},
+		{
+			name: "missing method", userID: 42, body: `{}`,
+			wantStatus: http.StatusBadRequest, wantOrder: models.StatusPending,
+		},
+		{
+			name: "another customer's order", userID: 7, body: `{"payment_method":"tok_visa"}`,
+			wantStatus: http.StatusNotFound, wantOrder: models.StatusPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			order := placeOrder(t, st, 42)
+			provider := payments.NewFake()
+			if tt.outcome != "" {
+				provider.Outcome = tt.outcome
+			}
+
+			ph := NewPaymentHandler(st, st, provider)
+			w := httptest.NewRecorder()
+			ph.Pay(w, payRequest(tt.userID, tt.body))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", w.Code, tt.wantStatus)
+			}
+
+			got, _ := st.GetOrder(context.Background(), order.ID)
+			if got.Status != tt.wantOrder {
+				t.Errorf("order status = %s; want %s", got.Status, tt.wantOrder)
+			}
+			ps, _ := st.ListPayments(context.Background(), order.ID)
+			if tt.wantPayment == "" {
+				if len(ps) != 0 {
+					t.Errorf("payments = %+v; want none", ps)
+				}
+				return
+			}
+			if len(ps) != 1 || ps[0].Status != tt.wantPayment || ps[0].Amount != order.TotalAmount {
+				t.Errorf("payments = %+v; want one %s payment of %d", ps, tt.wantPayment, order.TotalAmount)
+			}
+		})
+	}
+}
+
+func TestPay_AlreadyPaid(t *testing.T) {
+	st := store.NewMemory()
+	placeOrder(t, st, 42)
+	ph := NewPaymentHandler(st, st, payments.NewFake())
+
+	w := httptest.NewRecorder()
+	ph.Pay(w, payRequest(42, `{"payment_method":"tok_visa"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("first pay status = %d; want %d", w.Code, http.StatusCreated)
+	}
+	w = httptest.NewRecorder()
+	ph.Pay(w, payRequest(42, `{"payment_method":"tok_visa"}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("second pay status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
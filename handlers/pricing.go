@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/promotions"
+	"github.com/Heisenberg270/ecommerce-go/shipping"
+	"github.com/Heisenberg270/ecommerce-go/store"
+	"github.com/Heisenberg270/ecommerce-go/tax"
+)
+
+// cartCoupon describes the coupon applied to a cart. Error explains why a
+// coupon that no longer applies gives no discount.
+type cartCoupon struct {
+	Code     string       `json:"code"`
+	Discount models.Money `json:"discount"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// couponDiscount works out what p takes off lines for userID, which is 0
+// for guests; their per-user limit is checked once they log in to order.
+// reason is set when the coupon does not apply; err when the lookup failed.
+func couponDiscount(ctx context.Context, promos store.PromotionStore, p models.Promotion, lines []models.CartLine, userID int) (off models.Money, reason, err error) {
+	var cats map[int][]int
+	if len(p.CategoryIDs) > 0 {
+		ids := make([]int, len(lines))
+		for i, l := range lines {
+			ids[i] = l.ProductID
+		}
+		if cats, err = promos.ProductCategoryIDs(ctx, ids); err != nil {
+			return 0, nil, err
+		}
+	}
+	off, reason = promotions.Discount(p, lines, cats, time.Now())
+	if reason != nil {
+		return 0, reason, nil
+	}
+	total, byUser, err := promos.PromotionUsage(ctx, p.ID, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if reason := promotions.CheckUsage(p, total, byUser); reason != nil {
+		return 0, reason, nil
+	}
+	return off, nil, nil
+}
+
+// priceCart totals lines, takes off the cart's coupon if it still applies
+// and adds shipping and tax to dest, as CreateOrder would. Without a
+// destination tax is worked out for h.TaxRegion and shipping is left out,
+// as it is when the cart's method cannot ship to dest. coupon is nil when
+// the cart has none.
+func (h *CartHandler) priceCart(ctx context.Context, cart models.Cart, lines []models.CartLine, dest *models.Address, userID int) (models.CartTotals, *cartCoupon, error) {
+	totals := models.TotalCart(lines)
+	coupon, err := cartCouponFor(ctx, h.Promotions, cart, lines, userID)
+	if err != nil {
+		return totals, nil, err
+	}
+	if coupon != nil {
+		totals.Discount = coupon.Discount
+	}
+	region := h.TaxRegion
+	if dest != nil {
+		region = dest.TaxRegion()
+	}
+	if len(lines) > 0 && dest != nil && cart.ShippingMethod != "" {
+		quotes, err := h.Shipping.Quote(ctx, shippingRequest(*dest, lines))
+		if err != nil {
+			return totals, nil, err
+		}
+		if quote, ok := shipping.Find(quotes, cart.ShippingMethod); ok {
+			totals.Shipping = quote.Amount
+		}
+	}
+	if len(lines) > 0 {
+		taxes, err := h.Tax.Calculate(ctx, taxRequest(region, totals.Currency, lines, totals.Discount))
+		if err != nil {
+			return totals, nil, err
+		}
+		totals.Tax, totals.TaxInclusive = taxes.Total, taxes.Inclusive
+	}
+	totals.Total = totals.Subtotal - totals.Discount + totals.Shipping
+	if !totals.TaxInclusive {
+		totals.Total += totals.Tax
+	}
+	return totals, coupon, nil
+}
+
+// cartCouponFor prices the cart's coupon against lines. It is nil when the
+// cart has none.
+func cartCouponFor(ctx context.Context, promos store.PromotionStore, cart models.Cart, lines []models.CartLine, userID int) (*cartCoupon, error) {
+	if cart.PromotionID == nil {
+		return nil, nil
+	}
+	p, err := promos.GetPromotion(ctx, *cart.PromotionID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	off, reason, err := couponDiscount(ctx, promos, p, lines, userID)
+	if err != nil {
+		return nil, err
+	}
+	coupon := &cartCoupon{Code: p.Code, Discount: off}
+	if reason != nil {
+		coupon.Error = reason.Error()
+	}
+	return coupon, nil
+}
+
+// taxRequest asks for the tax on lines shipped to region after discount
+// is taken off them.
+func taxRequest(region, currency string, lines []models.CartLine, discount models.Money) tax.Request {
+	req := tax.Request{Region: region, Currency: currency, Lines: make([]tax.Line, len(lines)), Discount: discount}
+	for i, l := range lines {
+		req.Lines[i] = tax.Line{ProductID: l.ProductID, TaxClass: l.TaxClass, Quantity: l.Quantity, UnitPrice: l.UnitPrice}
+	}
+	return req
+}
+
+// shippingRequest asks what it costs to send lines to dest, in the
+// currency of the cart.
+func shippingRequest(dest models.Address, lines []models.CartLine) shipping.Request {
+	req := shipping.Request{Destination: dest, Items: make([]shipping.Item, len(lines))}
+	for i, l := range lines {
+		req.Currency = l.Currency
+		req.Items[i] = shipping.Item{ProductID: l.ProductID, Quantity: l.Quantity, WeightGrams: l.WeightGrams, UnitPrice: l.UnitPrice}
+	}
+	return req
+}
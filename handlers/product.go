@@ -1,24 +1,31 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
 )
 
-// ProductHandler holds DB reference
+// ProductHandler holds the product and variant stores
 type ProductHandler struct {
-	DB *sqlx.DB
+	Store    store.ProductStore
+	Variants store.VariantStore
 }
 
-// NewProductHandler returns a handler with DB injected
-func NewProductHandler(db *sqlx.DB) *ProductHandler {
-	return &ProductHandler{DB: db}
+// NewProductHandler returns a handler with the stores injected
+func NewProductHandler(s store.ProductStore, variants store.VariantStore) *ProductHandler {
+	return &ProductHandler{Store: s, Variants: variants}
 }
 
 // Create handles POST /products
@@ -28,24 +35,11 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// If table is empty, restart the ID sequence
-	var cnt int
-	if err := h.DB.Get(&cnt, "SELECT COUNT(*) FROM products"); err != nil {
-		http.Error(w, "Failed to check products count", http.StatusInternalServerError)
+	if msg := normalizeProduct(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
-	if cnt == 0 {
-		if _, err := h.DB.Exec("ALTER SEQUENCE products_id_seq RESTART WITH 1"); err != nil {
-			http.Error(w, "Failed to reset product ID sequence", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	query := `INSERT INTO products (name, description, price)
-            VALUES ($1, $2, $3)
-            RETURNING id, created_at, updated_at`
-	if err := h.DB.QueryRowx(query, p.Name, p.Description, p.Price).
-		StructScan(&p); err != nil {
+	if err := h.Store.CreateProduct(r.Context(), &p); err != nil {
 		http.Error(w, "Failed to insert product", http.StatusInternalServerError)
 		return
 	}
@@ -54,17 +48,133 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(p)
 }
 
-// List handles GET /products
+var taxClass = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// normalizeProduct defaults the currency and tax class and returns a
+// validation error message, or "" if p is acceptable.
+func normalizeProduct(p *models.Product) string {
+	if p.Currency == "" {
+		p.Currency = models.DefaultCurrency
+	}
+	if p.TaxClass == "" {
+		p.TaxClass = models.DefaultTaxClass
+	}
+	if !taxClass.MatchString(p.TaxClass) {
+		return "tax_class must be lower-case letters, digits or underscores"
+	}
+	if !models.ValidCurrency(p.Currency) {
+		return "currency must be a 3-letter ISO code"
+	}
+	if p.Price < 0 {
+		return "price must not be negative"
+	}
+	if p.WeightGrams < 0 {
+		return "weight_grams must not be negative"
+	}
+	if p.StockQuantity < 0 {
+		return "stock_quantity must not be negative"
+	}
+	return ""
+}
+
+// Page sizes for GET /products.
+const (
+	defaultProductLimit = 20
+	maxProductLimit     = 100
+)
+
+// List handles GET /products. It accepts q (full-text search), min_price,
+// max_price, category (an ID; subcategories are included), sort (id,
+// price, name or created_at; prefix "-" for descending), limit and cursor.
+// When more products follow, a Link header with rel="next" points at the
+// next page.
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
-	products := []models.Product{}
-	if err := h.DB.Select(&products, "SELECT * FROM products ORDER BY id"); err != nil {
+	f, msg := parseProductFilter(r.URL.Query())
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	limit := f.Limit
+	f.Limit++ // fetch one extra to learn whether there is a next page
+	products, err := h.Store.ListProducts(r.Context(), f)
+	if err != nil {
 		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
 		return
 	}
+	if len(products) > limit {
+		products = products[:limit]
+		next := r.URL.Query()
+		next.Set("cursor", encodeCursor(store.NewProductCursor(products[limit-1])))
+		u := url.URL{Path: r.URL.Path, RawQuery: next.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(products)
 }
 
+// parseProductFilter reads List's query parameters, returning a validation
+// error message if any is malformed.
+func parseProductFilter(q url.Values) (store.ProductFilter, string) {
+	f := store.ProductFilter{Search: q.Get("q"), Sort: store.SortByID, Limit: defaultProductLimit}
+	for _, p := range []struct {
+		name string
+		dst  **models.Money
+	}{{"min_price", &f.MinPrice}, {"max_price", &f.MaxPrice}} {
+		if v := q.Get(p.name); v != "" {
+			m, err := models.ParseMoney(v)
+			if err != nil {
+				return f, p.name + " must be a decimal amount"
+			}
+			*p.dst = &m
+		}
+	}
+	if v := q.Get("category"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return f, "category must be a category ID"
+		}
+		f.Category = &id
+	}
+	if v := q.Get("sort"); v != "" {
+		f.Desc = strings.HasPrefix(v, "-")
+		f.Sort = store.ProductSort(strings.TrimPrefix(v, "-"))
+		if !f.Sort.Valid() {
+			return f, "sort must be one of id, price, name, created_at"
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxProductLimit {
+			return f, fmt.Sprintf("limit must be between 1 and %d", maxProductLimit)
+		}
+		f.Limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return f, "invalid cursor"
+		}
+		f.After = &c
+	}
+	return f, ""
+}
+
+// encodeCursor and decodeCursor convert a page cursor to and from the
+// opaque string clients pass back.
+func encodeCursor(c store.ProductCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (store.ProductCursor, error) {
+	var c store.ProductCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	return c, json.Unmarshal(b, &c)
+}
+
 // Get handles GET /products/{id}
 func (h *ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
 	idParam := chi.URLParam(r, "id")
@@ -73,13 +183,35 @@ func (h *ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid product ID", http.StatusBadRequest)
 		return
 	}
-	var p models.Product
-	if err := h.DB.Get(&p, "SELECT * FROM products WHERE id=$1", id); err != nil {
+	p, err := h.Store.GetProduct(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, "Failed to fetch product", http.StatusInternalServerError)
+		return
+	}
+	opts, err := h.Variants.ProductOptions(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to fetch product options", http.StatusInternalServerError)
+		return
+	}
+	variants, err := h.Variants.ListVariants(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to fetch product variants", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(p)
+	json.NewEncoder(w).Encode(productDetail{Product: p, Options: opts, Variants: variants})
+}
+
+// productDetail is a product with its options and the variant matrix
+// built from them.
+type productDetail struct {
+	models.Product
+	Options  []models.ProductOption `json:"options"`
+	Variants []models.Variant       `json:"variants"`
 }
 
 // Update handles PUT /products/{id}
@@ -95,10 +227,16 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	_, err = h.DB.Exec(
-		`UPDATE products SET name=$1, description=$2, price=$3, updated_at=now() WHERE id=$4`,
-		p.Name, p.Description, p.Price, id,
-	)
+	if msg := normalizeProduct(&p); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	p.ID = id
+	err = h.Store.UpdateProduct(r.Context(), &p)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to update product", http.StatusInternalServerError)
 		return
@@ -114,7 +252,7 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid product ID", http.StatusBadRequest)
 		return
 	}
-	if _, err := h.DB.Exec("DELETE FROM products WHERE id=$1", id); err != nil {
+	if err := h.Store.DeleteProduct(r.Context(), id); err != nil {
 		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
 		return
 	}
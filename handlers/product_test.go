@@ -2,67 +2,69 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
-	"time"
-
-	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
 )
 
-// setupMock creates a sqlx.DB hooked to sqlmock
-func setupMock(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to open sqlmock: %v", err)
-	}
-	sqlxDB := sqlx.NewDb(db, "sqlmock")
-	return sqlxDB, mock
-}
-
 func TestCreateProduct(t *testing.T) {
 	tests := []struct {
 		name       string
 		body       string
-		mockExpect func(sqlmock.Sqlmock)
 		wantStatus int
 	}{
 		{
 			name:       "invalid JSON",
 			body:       `{"name":`,
-			mockExpect: func(_ sqlmock.Sqlmock) {},
 			wantStatus: http.StatusBadRequest,
 		},
 		{
-			name: "valid request",
-			body: `{"name":"Test","description":"Desc","price":1.23}`,
-			mockExpect: func(m sqlmock.Sqlmock) {
-				// Expect COUNT(*) query for sequence reset
-				m.ExpectQuery(`SELECT COUNT\(\*\) FROM products`).
-					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
-				m.ExpectExec(`ALTER SEQUENCE products_id_seq RESTART WITH 1`).
-					WillReturnResult(sqlmock.NewResult(0, 0))
-
-				// Expect the INSERT ... RETURNING query
-				m.ExpectQuery(`INSERT INTO products`).
-					WithArgs("Test", "Desc", 1.23).
-					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
-						AddRow(1, time.Now(), time.Now()))
-			},
+			name:       "invalid currency",
+			body:       `{"name":"Test","price":1.23,"currency":"dollars"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "too many decimals",
+			body:       `{"name":"Test","price":1.234}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid tax class",
+			body:       `{"name":"Test","price":1.23,"tax_class":"Reduced Rate"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative weight",
+			body:       `{"name":"Test","price":1.23,"weight_grams":-1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "negative stock",
+			body:       `{"name":"Test","price":1.23,"stock_quantity":-1}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "valid request",
+			body:       `{"name":"Test","description":"Desc","price":1.23}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "tax class",
+			body:       `{"name":"Bread","price":2.50,"tax_class":"food"}`,
 			wantStatus: http.StatusCreated,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db, mock := setupMock(t)
-			tt.mockExpect(mock)
-
-			handler := NewProductHandler(db)
+			st := store.NewMemory()
+			handler := NewProductHandler(st, st)
 			req := httptest.NewRequest("POST", "/products", bytes.NewBufferString(tt.body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
@@ -71,28 +73,15 @@ func TestCreateProduct(t *testing.T) {
 			if w.Code != tt.wantStatus {
 				t.Fatalf("status = %d; want %d", w.Code, tt.wantStatus)
 			}
-			if err := mock.ExpectationsWereMet(); err != nil {
-				t.Fatalf("unfulfilled expectations: %v", err)
-			}
 		})
 	}
 }
 
 func TestListProducts(t *testing.T) {
-	// prepare some fake products
-	now := time.Now()
-	sample := []models.Product{
-		{ID: 1, Name: "A", Description: "Alpha", Price: 9.99, CreatedAt: now, UpdatedAt: now},
-	}
+	st := store.NewMemory()
+	st.CreateProduct(context.Background(), &models.Product{Name: "A", Description: "Alpha", Price: 999})
 
-	db, mock := setupMock(t)
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "price", "created_at", "updated_at"})
-	for _, p := range sample {
-		rows.AddRow(p.ID, p.Name, p.Description, p.Price, p.CreatedAt, p.UpdatedAt)
-	}
-	mock.ExpectQuery(`SELECT \* FROM products ORDER BY id`).WillReturnRows(rows)
-
-	handler := NewProductHandler(db)
+	handler := NewProductHandler(st, st)
 	req := httptest.NewRequest("GET", "/products", nil)
 	w := httptest.NewRecorder()
 	handler.List(w, req)
@@ -104,10 +93,86 @@ func TestListProducts(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
 		t.Fatalf("failed to parse JSON: %v", err)
 	}
-	if len(got) != len(sample) {
-		t.Fatalf("got %d products; want %d", len(got), len(sample))
+	if len(got) != 1 || got[0].Name != "A" {
+		t.Fatalf("got %+v; want one product named A", got)
+	}
+}
+
+func TestListProducts_FilterAndPaginate(t *testing.T) {
+	st := store.NewMemory()
+	for _, p := range []models.Product{
+		{Name: "Mug", Price: 800},
+		{Name: "Tea", Price: 500},
+		{Name: "Kettle", Price: 2500},
+		{Name: "Cup", Price: 300},
+	} {
+		p := p
+		st.CreateProduct(context.Background(), &p)
+	}
+	handler := NewProductHandler(st, st)
+
+	list := func(target string) ([]models.Product, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		handler.List(w, httptest.NewRequest("GET", target, nil))
+		var got []models.Product
+		if w.Code == http.StatusOK {
+			json.Unmarshal(w.Body.Bytes(), &got)
+		}
+		return got, w
+	}
+
+	got, w := list("/products?max_price=10&sort=-price&limit=2")
+	if len(got) != 2 || got[0].Name != "Mug" || got[1].Name != "Tea" {
+		t.Fatalf("first page = %+v; want Mug, Tea", got)
+	}
+	link := w.Header().Get("Link")
+	if !strings.HasSuffix(link, `>; rel="next"`) {
+		t.Fatalf("Link = %q; want a next link", link)
+	}
+	next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	got, w = list(next)
+	if len(got) != 1 || got[0].Name != "Cup" {
+		t.Fatalf("second page = %+v; want Cup", got)
+	}
+	if link := w.Header().Get("Link"); link != "" {
+		t.Errorf("last page Link = %q; want none", link)
+	}
+
+	for _, bad := range []string{"sort=color", "limit=0", "min_price=abc", "cursor=%21"} {
+		if _, w := list("/products?" + bad); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d; want %d", bad, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetProduct_NotFound(t *testing.T) {
+	st := store.NewMemory()
+	handler := NewProductHandler(st, st)
+	req := withURLParams(httptest.NewRequest("GET", "/products/9", nil), "id", "9")
+	w := httptest.NewRecorder()
+	handler.Get(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Get status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	st := store.NewMemory()
+	p := models.Product{Name: "Old", Price: 100}
+	st.CreateProduct(context.Background(), &p)
+
+	handler := NewProductHandler(st, st)
+	req := withURLParams(httptest.NewRequest("PUT", "/products/1",
+		bytes.NewBufferString(`{"name":"New","price":2.5}`)), "id", "1")
+	w := httptest.NewRecorder()
+	handler.Update(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update status = %d; want %d", w.Code, http.StatusOK)
 	}
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Fatalf("unfulfilled expectations: %v", err)
+	got, _ := st.GetProduct(context.Background(), p.ID)
+	if got.Name != "New" || got.Price != 250 {
+		t.Errorf("got %+v; want name New, price 2.5", got)
 	}
 }
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// PromotionHandler manages coupon codes
+type PromotionHandler struct {
+	Store store.PromotionStore
+}
+
+// NewPromotionHandler constructs a PromotionHandler
+func NewPromotionHandler(s store.PromotionStore) *PromotionHandler {
+	return &PromotionHandler{Store: s}
+}
+
+var promotionCode = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// promotionInput is the body of create and update requests. Codes are
+// matched case-insensitively and stored upper case. Active defaults to
+// true.
+type promotionInput struct {
+	Code           string               `json:"code"`
+	Kind           models.PromotionKind `json:"kind"`
+	PercentOff     int                  `json:"percent_off"`
+	AmountOff      models.Money         `json:"amount_off"`
+	BuyQuantity    int                  `json:"buy_quantity"`
+	GetQuantity    int                  `json:"get_quantity"`
+	Currency       string               `json:"currency"`
+	MinSpend       models.Money         `json:"min_spend"`
+	MaxUses        *int                 `json:"max_uses"`
+	MaxUsesPerUser *int                 `json:"max_uses_per_user"`
+	ProductIDs     []int64              `json:"product_ids"`
+	CategoryIDs    []int64              `json:"category_ids"`
+	StartsAt       *time.Time           `json:"starts_at"`
+	EndsAt         *time.Time           `json:"ends_at"`
+	Active         *bool                `json:"active"`
+}
+
+// validate normalises in and returns a validation error message, or "" if
+// it is acceptable.
+func (in *promotionInput) validate() string {
+	in.Code = strings.ToUpper(strings.TrimSpace(in.Code))
+	if !promotionCode.MatchString(in.Code) {
+		return "code must be 3 to 32 letters, digits, dashes or underscores"
+	}
+	switch in.Kind {
+	case models.PromotionPercentage:
+		if in.PercentOff < 1 || in.PercentOff > 100 {
+			return "percent_off must be between 1 and 100"
+		}
+	case models.PromotionFixed:
+		if in.AmountOff <= 0 {
+			return "amount_off must be positive"
+		}
+		if in.Currency == "" {
+			return "currency is required for fixed amounts"
+		}
+	case models.PromotionBuyXGetY:
+		if in.BuyQuantity < 1 || in.GetQuantity < 1 {
+			return "buy_quantity and get_quantity must be positive"
+		}
+	default:
+		return "unknown kind " + strconv.Quote(string(in.Kind))
+	}
+	if in.Currency != "" && !models.ValidCurrency(in.Currency) {
+		return "currency must be a three-letter ISO 4217 code"
+	}
+	if in.MinSpend < 0 {
+		return "min_spend must not be negative"
+	}
+	if in.MinSpend > 0 && in.Currency == "" {
+		return "currency is required with min_spend"
+	}
+	if (in.MaxUses != nil && *in.MaxUses < 1) || (in.MaxUsesPerUser != nil && *in.MaxUsesPerUser < 1) {
+		return "usage limits must be positive"
+	}
+	for _, id := range in.ProductIDs {
+		if id < 1 {
+			return "product_ids must be positive"
+		}
+	}
+	for _, id := range in.CategoryIDs {
+		if id < 1 {
+			return "category_ids must be positive"
+		}
+	}
+	if in.StartsAt != nil && in.EndsAt != nil && !in.EndsAt.After(*in.StartsAt) {
+		return "ends_at must be after starts_at"
+	}
+	return ""
+}
+
+// apply copies in onto p.
+func (in promotionInput) apply(p *models.Promotion) {
+	p.Code, p.Kind = in.Code, in.Kind
+	p.PercentOff, p.AmountOff = in.PercentOff, in.AmountOff
+	p.BuyQuantity, p.GetQuantity = in.BuyQuantity, in.GetQuantity
+	p.Currency, p.MinSpend = in.Currency, in.MinSpend
+	p.MaxUses, p.MaxUsesPerUser = in.MaxUses, in.MaxUsesPerUser
+	p.ProductIDs = in.ProductIDs
+	if p.ProductIDs == nil {
+		p.ProductIDs = []int64{}
+	}
+	p.CategoryIDs = in.CategoryIDs
+	if p.CategoryIDs == nil {
+		p.CategoryIDs = []int64{}
+	}
+	p.StartsAt, p.EndsAt = in.StartsAt, in.EndsAt
+	if in.Active != nil {
+		p.Active = *in.Active
+	}
+}
+
+// decode reads and validates a promotionInput, writing the error and
+// returning false if it is unacceptable.
+func (in *promotionInput) decode(w http.ResponseWriter, r *http.Request) bool {
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return false
+	}
+	if msg := in.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Create handles POST /promotions
+func (h *PromotionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in promotionInput
+	if !in.decode(w, r) {
+		return
+	}
+	p := models.Promotion{Active: true}
+	in.apply(&p)
+	err := h.Store.CreatePromotion(r.Context(), &p)
+	if errors.Is(err, store.ErrDuplicatePromotionCode) {
+		http.Error(w, "code already in use", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create promotion", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(p)
+}
+
+// List handles GET /promotions
+func (h *PromotionHandler) List(w http.ResponseWriter, r *http.Request) {
+	promos, err := h.Store.ListPromotions(r.Context())
+	if err != nil {
+		http.Error(w, "failed to fetch promotions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(promos)
+}
+
+// Get handles GET /promotions/{promoID}
+func (h *PromotionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.promotion(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// Update handles PUT /promotions/{promoID}
+func (h *PromotionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	p, ok := h.promotion(w, r)
+	if !ok {
+		return
+	}
+	var in promotionInput
+	if !in.decode(w, r) {
+		return
+	}
+	in.apply(&p)
+	err := h.Store.UpdatePromotion(r.Context(), &p)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "promotion not found", http.StatusNotFound)
+		return
+	case errors.Is(err, store.ErrDuplicatePromotionCode):
+		http.Error(w, "code already in use", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "failed to update promotion", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p)
+}
+
+// Delete handles DELETE /promotions/{promoID}. Orders placed with the
+// promotion keep their discount.
+func (h *PromotionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "promoID"))
+	if err != nil {
+		http.Error(w, "invalid promotion ID", http.StatusBadRequest)
+		return
+	}
+	err = h.Store.DeletePromotion(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "promotion not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete promotion", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// promotion loads the promotion named by the promoID URL parameter,
+// writing an error and returning false if there is none.
+func (h *PromotionHandler) promotion(w http.ResponseWriter, r *http.Request) (models.Promotion, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "promoID"))
+	if err != nil {
+		http.Error(w, "invalid promotion ID", http.StatusBadRequest)
+		return models.Promotion{}, false
+	}
+	p, err := h.Store.GetPromotion(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "promotion not found", http.StatusNotFound)
+		return models.Promotion{}, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch promotion", http.StatusInternalServerError)
+		return models.Promotion{}, false
+	}
+	return p, true
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func TestCreatePromotion(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"percentage", `{"code":"save10","kind":"percentage","percent_off":10}`, http.StatusCreated},
+		{"fixed", `{"code":"FIVE-OFF","kind":"fixed","amount_off":5,"currency":"USD","min_spend":20}`, http.StatusCreated},
+		{"buy x get y", `{"code":"B2G1","kind":"buy_x_get_y","buy_quantity":2,"get_quantity":1,"product_ids":[1]}`, http.StatusCreated},
+		{"short code", `{"code":"X","kind":"percentage","percent_off":10}`, http.StatusBadRequest},
+		{"unknown kind", `{"code":"FREE","kind":"free","percent_off":10}`, http.StatusBadRequest},
+		{"percent over 100", `{"code":"ALL","kind":"percentage","percent_off":101}`, http.StatusBadRequest},
+		{"fixed without currency", `{"code":"FIVE","kind":"fixed","amount_off":5}`, http.StatusBadRequest},
+		{"category scoped", `{"code":"HOME10","kind":"percentage","percent_off":10,"category_ids":[3]}`, http.StatusCreated},
+		{"bad category", `{"code":"HOME10","kind":"percentage","percent_off":10,"category_ids":[0]}`, http.StatusBadRequest},
+		{"zero usage limit", `{"code":"NONE","kind":"percentage","percent_off":10,"max_uses":0}`, http.StatusBadRequest},
+		{"ends before start", `{"code":"BACK","kind":"percentage","percent_off":10,
+			"starts_at":"2026-02-01T00:00:00Z","ends_at":"2026-01-01T00:00:00Z"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewPromotionHandler(store.NewMemory())
+			w := httptest.NewRecorder()
+			h.Create(w, httptest.NewRequest("POST", "/promotions", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreatePromotion_DuplicateCode(t *testing.T) {
+	h := NewPromotionHandler(store.NewMemory())
+	body := `{"code":"SAVE10","kind":"percentage","percent_off":10}`
+	h.Create(httptest.NewRecorder(), httptest.NewRequest("POST", "/promotions", bytes.NewBufferString(body)))
+
+	w := httptest.NewRecorder()
+	h.Create(w, httptest.NewRequest("POST", "/promotions", bytes.NewBufferString(`{"code":"save10","kind":"fixed","amount_off":1,"currency":"USD"}`)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
+
+// seedPromotion stores p as an active promotion.
+func seedPromotion(t *testing.T, st *store.Memory, p models.Promotion) models.Promotion {
+	t.Helper()
+	p.Active = true
+	if err := st.CreatePromotion(context.Background(), &p); err != nil {
+		t.Fatalf("seed promotion: %v", err)
+	}
+	return p
+}
+
+func TestApplyCoupon(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       string
+		wantStatus int
+	}{
+		{"applies", "save10", http.StatusOK},
+		{"unknown code", "NOPE", http.StatusNotFound},
+		{"minimum spend not met", "BIGSPEND", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			p, cart := seedCart(t, st, 1)
+			st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+			seedPromotion(t, st, models.Promotion{Code: "SAVE10", Kind: models.PromotionPercentage, PercentOff: 10})
+			seedPromotion(t, st, models.Promotion{Code: "BIGSPEND", Kind: models.PromotionPercentage, PercentOff: 10,
+				MinSpend: 5000, Currency: "USD"})
+
+			ch := NewCartHandler(st, st, st, st, st, "secret")
+			req := httptest.NewRequest("POST", "/carts/1/coupon", bytes.NewBufferString(`{"code":"`+tt.code+`"}`))
+			w := httptest.NewRecorder()
+			ch.ApplyCoupon(w, withUser(withURLParams(req, "cartID", "1"), 1))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Totals models.CartTotals `json:"totals"`
+				Coupon cartCoupon        `json:"coupon"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			if resp.Totals.Discount != 100 || resp.Totals.Total != 900 || resp.Coupon.Code != "SAVE10" {
+				t.Errorf("got totals %+v, coupon %+v; want 1.00 off SAVE10, total 9.00", resp.Totals, resp.Coupon)
+			}
+		})
+	}
+}
+
+func TestGetCart_CouponNoLongerApplies(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 4)
+	promo := seedPromotion(t, st, models.Promotion{Code: "SPEND20", Kind: models.PromotionFixed, AmountOff: 300,
+		Currency: "USD", MinSpend: 2000})
+	st.SetCartPromotion(context.Background(), cart.ID, &promo.ID)
+	st.SetCartItem(context.Background(), cart.ID, p.ID, nil, 1)
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	w := httptest.NewRecorder()
+	ch.GetCart(w, withUser(withURLParams(httptest.NewRequest("GET", "/carts/1", nil), "cartID", "1"), 1))
+
+	var resp struct {
+		Totals models.CartTotals `json:"totals"`
+		Coupon cartCoupon        `json:"coupon"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Totals.Discount != 0 || resp.Totals.Total != 500 || resp.Coupon.Error == "" {
+		t.Errorf("got totals %+v, coupon %+v; want no discount and an explanation", resp.Totals, resp.Coupon)
+	}
+}
+
+func TestCreateOrder_Coupon(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 3)
+	one := 1
+	promo := seedPromotion(t, st, models.Promotion{Code: "B2G1", Kind: models.PromotionBuyXGetY,
+		BuyQuantity: 2, GetQuantity: 1, MaxUsesPerUser: &one})
+	st.SetCartPromotion(context.Background(), cart.ID, &promo.ID)
+
+	oh := NewOrderHandler(st, st, st, st)
+	w := httptest.NewRecorder()
+	oh.CreateOrder(w, withUser(httptest.NewRequest("POST", "/orders", bytes.NewBufferString(`{"cart_id":1}`)), 42))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOrder status = %d; want %d (%s)", w.Code, http.StatusCreated, w.Body)
+	}
+	var order models.Order
+	json.Unmarshal(w.Body.Bytes(), &order)
+	if order.TotalAmount != 1000 || order.Discount == nil || order.Discount.Amount != 500 {
+		t.Errorf("got %+v; want total 10.00 after 5.00 off", order)
+	}
+	if d, err := st.OrderDiscount(context.Background(), order.ID); err != nil || d.Code != "B2G1" {
+		t.Errorf("OrderDiscount = %+v, %v; want the B2G1 discount", d, err)
+	}
+
+	// the customer has used their one redemption
+	next, _, _ := st.ActiveCart(context.Background(), 42)
+	st.AddCartItem(context.Background(), next.ID, p.ID, nil, 3)
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	req := httptest.NewRequest("POST", "/carts/2/coupon", bytes.NewBufferString(`{"code":"B2G1"}`))
+	w = httptest.NewRecorder()
+	ch.ApplyCoupon(w, withUser(withURLParams(req, "cartID", "2"), 42))
+	if w.Code != http.StatusConflict {
+		t.Errorf("reapply status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestApplyCoupon_CategoryScoped(t *testing.T) {
+	st := store.NewMemory()
+	ctx := context.Background()
+	p, cart := seedCart(t, st, 1)
+	st.AddCartItem(ctx, cart.ID, p.ID, nil, 2)
+	home := models.Category{Name: "Home", Slug: "home"}
+	st.CreateCategory(ctx, &home)
+	mugs := models.Category{Name: "Mugs", Slug: "mugs", ParentID: &home.ID}
+	st.CreateCategory(ctx, &mugs)
+	garden := models.Category{Name: "Garden", Slug: "garden"}
+	st.CreateCategory(ctx, &garden)
+	st.SetProductCategories(ctx, p.ID, []int{mugs.ID})
+	seedPromotion(t, st, models.Promotion{Code: "HOME10", Kind: models.PromotionPercentage, PercentOff: 10,
+		CategoryIDs: []int64{int64(home.ID)}})
+	seedPromotion(t, st, models.Promotion{Code: "GARDEN10", Kind: models.PromotionPercentage, PercentOff: 10,
+		CategoryIDs: []int64{int64(garden.ID)}})
+
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	apply := func(code string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/carts/1/coupon", bytes.NewBufferString(`{"code":"`+code+`"}`))
+		ch.ApplyCoupon(w, withUser(withURLParams(req, "cartID", "1"), 1))
+		return w
+	}
+	if w := apply("GARDEN10"); w.Code != http.StatusConflict {
+		t.Errorf("other category status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	w := apply("HOME10")
+	if w.Code != http.StatusOK {
+		t.Fatalf("parent category status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	var resp struct {
+		Totals models.CartTotals `json:"totals"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Totals.Discount != 100 {
+		t.Errorf("discount = %s; want 1.00", resp.Totals.Discount)
+	}
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// RefundHandler cancels orders and returns customers' money through the
+// payment provider
+type RefundHandler struct {
+	Orders   store.OrderStore
+	Payments store.PaymentStore
+	Refunds  store.RefundStore
+	Provider payments.Provider
+}
+
+// NewRefundHandler constructs a RefundHandler
+func NewRefundHandler(orders store.OrderStore, ps store.PaymentStore, refunds store.RefundStore, provider payments.Provider) *RefundHandler {
+	return &RefundHandler{Orders: orders, Payments: ps, Refunds: refunds, Provider: provider}
+}
+
+// Cancel handles POST /orders/{orderID}/cancel. The owner or staff may
+// cancel until the order is fulfilled; its stock and coupon are released and, if it was
+// paid, the payment is refunded. The response carries the refund, whose
+// status shows whether the provider returned the money; staff can retry a
+// failed one.
+func (h *RefundHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+
+	// 1) An optional reason, kept in the order's history
+	var in struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	// 2) Only the owner or staff may cancel
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	if order.UserID != userID && !isStaff(r) {
+		http.Error(w, "order belongs to another user", http.StatusForbidden)
+		return
+	}
+
+	// 3) Cancel, restock and record any refund together
+	note := in.Reason
+	if note == "" {
+		note = "cancelled by customer"
+		if order.UserID != userID {
+			note = "cancelled by staff"
+		}
+	}
+	order, refund, err := h.Refunds.CancelOrder(r.Context(), order.ID, &userID, note)
+	if err != nil {
+		var transErr *models.TransitionError
+		switch {
+		case errors.As(err, &transErr):
+			http.Error(w, "order can no longer be cancelled", http.StatusConflict)
+		case errors.Is(err, store.ErrNoCapturedPayment):
+			http.Error(w, "order was paid outside the store and cannot be refunded here", http.StatusConflict)
+		default:
+			http.Error(w, "failed to cancel order", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 4) Return the money for a paid order
+	if refund != nil {
+		if err := h.refund(r.Context(), refund); err != nil {
+			http.Error(w, "failed to record refund", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Order  models.Order   `json:"order"`
+		Refund *models.Refund `json:"refund,omitempty"`
+	}{order, refund})
+}
+
+// Refund handles POST /orders/{orderID}/refunds, letting staff refund some
+// units of one order line, named by product_id and, for a variant,
+// variant_id. The amount is worked out from what the customer
+// paid for them, after discount and with tax.
+func (h *RefundHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+
+	// 1) Parse which units to refund
+	var in struct {
+		ProductID int    `json:"product_id"`
+		VariantID *int   `json:"variant_id"`
+		Quantity  int    `json:"quantity"`
+		Reason    string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if in.Quantity <= 0 {
+		http.Error(w, "quantity must be positive", http.StatusBadRequest)
+		return
+	}
+
+	// 2) The order must have been paid for
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	pay, ok := h.capturedPayment(w, r, order.ID)
+	if !ok {
+		return
+	}
+
+	// 3) Price the units from the order line
+	refund, ok := h.lineRefund(w, r, order, in.ProductID, in.VariantID, in.Quantity)
+	if !ok {
+		return
+	}
+	refund.PaymentID, refund.Reason, refund.CreatedBy = pay.ID, in.Reason, &userID
+
+	// 4) Record the refund before calling out, so concurrent refunds cannot
+	// return the same units twice
+	if err := h.Refunds.CreateRefund(r.Context(), &refund); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRefundExceeded):
+			http.Error(w, "refund exceeds what remains on the order", http.StatusConflict)
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "order has no captured payment", http.StatusConflict)
+		default:
+			http.Error(w, "failed to record refund", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 5) Ask the provider for the money back
+	if err := h.refund(r.Context(), &refund); err != nil {
+		http.Error(w, "failed to record refund", http.StatusInternalServerError)
+		return
+	}
+	if refund.Status == models.RefundFailed {
+		http.Error(w, "payment provider error", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(refund)
+}
+
+// Retry handles POST /orders/{orderID}/refunds/{refundID}/retry, letting
+// staff send a failed refund to the provider again.
+func (h *RefundHandler) Retry(w http.ResponseWriter, r *http.Request) {
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	refundID, err := strconv.Atoi(chi.URLParam(r, "refundID"))
+	if err != nil {
+		http.Error(w, "invalid refund ID", http.StatusBadRequest)
+		return
+	}
+
+	// Claim the refund again before calling out, so concurrent retries
+	// cannot return the money twice
+	refund, err := h.Refunds.RetryRefund(r.Context(), order.ID, refundID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "refund not found", http.StatusNotFound)
+		case errors.Is(err, store.ErrRefundNotFailed):
+			http.Error(w, "only failed refunds can be retried", http.StatusConflict)
+		case errors.Is(err, store.ErrRefundExceeded):
+			http.Error(w, "refund exceeds what remains on the order", http.StatusConflict)
+		default:
+			http.Error(w, "failed to record refund", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := h.refund(r.Context(), &refund); err != nil {
+		http.Error(w, "failed to record refund", http.StatusInternalServerError)
+		return
+	}
+	if refund.Status == models.RefundFailed {
+		http.Error(w, "payment provider error", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refund)
+}
+
+// capturedPayment finds the order's captured payment, writing an error and
+// returning false if it has none.
+func (h *RefundHandler) capturedPayment(w http.ResponseWriter, r *http.Request, orderID int) (models.Payment, bool) {
+	ps, err := h.Payments.ListPayments(r.Context(), orderID)
+	if err != nil {
+		http.Error(w, "failed to fetch payments", http.StatusInternalServerError)
+		return models.Payment{}, false
+	}
+	for _, p := range ps {
+		if p.Status == models.PaymentCaptured {
+			return p, true
+		}
+	}
+	http.Error(w, "order has no captured payment", http.StatusConflict)
+	return models.Payment{}, false
+}
+
+// lineRefund prices refunding quantity units of the line for productID and
+// variantID on order, writing an error and returning false if the line is
+// not on it.
+func (h *RefundHandler) lineRefund(w http.ResponseWriter, r *http.Request, order models.Order, productID int, variantID *int, quantity int) (models.Refund, bool) {
+	lines, err := h.Orders.OrderLines(r.Context(), order.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch order items", http.StatusInternalServerError)
+		return models.Refund{}, false
+	}
+	items := make([]models.OrderItem, len(lines))
+	var item *models.OrderItem
+	for i, l := range lines {
+		items[i] = l.OrderItem
+		if l.ProductID == productID && models.SameVariant(l.VariantID, variantID) {
+			item = &items[i]
+		}
+	}
+	if item == nil {
+		http.Error(w, "product not on order", http.StatusNotFound)
+		return models.Refund{}, false
+	}
+	discount, err := h.Orders.OrderDiscount(r.Context(), order.ID)
+	switch {
+	case err == nil:
+		order.Discount = &discount
+	case !errors.Is(err, store.ErrNotFound):
+		http.Error(w, "failed to fetch order discount", http.StatusInternalServerError)
+		return models.Refund{}, false
+	}
+	refunds, err := h.Refunds.ListRefunds(r.Context(), order.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch refunds", http.StatusInternalServerError)
+		return models.Refund{}, false
+	}
+	var refunded int
+	for _, rf := range refunds {
+		if rf.ProductID != nil && *rf.ProductID == productID && models.SameVariant(rf.VariantID, variantID) &&
+			rf.Status != models.RefundFailed {
+			refunded += *rf.Quantity
+		}
+	}
+	return models.Refund{
+		OrderID:   order.ID,
+		ProductID: &productID,
+		VariantID: variantID,
+		Quantity:  &quantity,
+		Amount:    models.LineRefundAmount(order, items, *item, refunded, quantity),
+	}, true
+}
+
+// refund asks the provider to return rf's amount and records the outcome
+// on rf. The error is only set if the outcome could not be saved.
+func (h *RefundHandler) refund(ctx context.Context, rf *models.Refund) error {
+	rf.Status = models.RefundSucceeded
+	if rf.Amount > 0 {
+		ps, err := h.Payments.ListPayments(ctx, rf.OrderID)
+		if err != nil {
+			return err
+		}
+		var ref string
+		for _, p := range ps {
+			if p.ID == rf.PaymentID {
+				ref = p.ProviderRef
+			}
+		}
+		if err := h.Provider.Refund(ctx, ref, rf.Amount); err != nil {
+			rf.Status, rf.FailureReason = models.RefundFailed, err.Error()
+		}
+	}
+	return h.Refunds.FinishRefund(ctx, rf)
+}
+
+// ListRefunds handles GET /orders/{orderID}/refunds
+func (h *RefundHandler) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	refunds, err := h.Refunds.ListRefunds(r.Context(), order.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch refunds", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(refunds)
+}
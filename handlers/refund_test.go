@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// paidOrder seeds an order for two Widgets and pays for it through
+// provider.
+func paidOrder(t *testing.T, st *store.Memory, provider payments.Provider) models.Order {
+	t.Helper()
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 2)
+	order := models.Order{UserID: 42, TotalAmount: 1000, Currency: "USD", Status: models.StatusPending}
+	if err := st.CreateOrder(context.Background(), &order,
+		[]models.OrderItem{{ProductID: p.ID, Quantity: 2, UnitPrice: 500}}, cart.ID, ""); err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+	w := httptest.NewRecorder()
+	NewPaymentHandler(st, st, provider).Pay(w, payRequest(42, `{"payment_method":"tok_visa"}`))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("pay status = %d; want %d", w.Code, http.StatusCreated)
+	}
+	order.Status = models.StatusPaid
+	return order
+}
+
+func cancelRequest(userID int) *http.Request {
+	return withURLParams(withUser(httptest.NewRequest("POST", "/orders/1/cancel", nil), userID), "orderID", "1")
+}
+
+func TestCancelOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     int
+		status     models.OrderStatus
+		wantStatus int
+		wantOrder  models.OrderStatus
+		wantStock  int
+	}{
+		{"pending", 42, models.StatusPending, http.StatusOK, models.StatusCancelled, 10},
+		{"fulfilled", 42, models.StatusFulfilled, http.StatusConflict, models.StatusFulfilled, 9},
+		{"paid without a captured payment", 42, models.StatusPaid, http.StatusConflict, models.StatusPaid, 9},
+		{"another customer's order", 7, models.StatusPending, http.StatusNotFound, models.StatusPending, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			order := placeOrder(t, st, 42)
+			if tt.status != models.StatusPending {
+				st.TransitionOrder(context.Background(), order.ID, models.StatusPaid, nil, "")
+			}
+			if tt.status == models.StatusFulfilled {
+				st.TransitionOrder(context.Background(), order.ID, models.StatusFulfilled, nil, "")
+			}
+
+			rh := NewRefundHandler(st, st, st, payments.NewFake())
+			w := httptest.NewRecorder()
+			rh.Cancel(w, cancelRequest(tt.userID))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if got, _ := st.GetOrder(context.Background(), order.ID); got.Status != tt.wantOrder {
+				t.Errorf("order status = %s; want %s", got.Status, tt.wantOrder)
+			}
+			if p, _ := st.GetProduct(context.Background(), 1); p.StockQuantity != tt.wantStock {
+				t.Errorf("stock = %d; want %d", p.StockQuantity, tt.wantStock)
+			}
+		})
+	}
+}
+
+func TestCancelOrder_Staff(t *testing.T) {
+	st := store.NewMemory()
+	order := placeOrder(t, st, 42)
+
+	req := cancelRequest(7)
+	req = req.WithContext(context.WithValue(req.Context(), ContextUserRole, models.RoleStaff))
+	w := httptest.NewRecorder()
+	NewRefundHandler(st, st, st, payments.NewFake()).Cancel(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	history, _ := st.OrderStatusHistory(context.Background(), order.ID)
+	if last := history[len(history)-1]; last.ToStatus != models.StatusCancelled || last.Note != "cancelled by staff" {
+		t.Errorf("got history entry %+v; want cancelled by staff", last)
+	}
+	if p, _ := st.GetProduct(context.Background(), 1); p.StockQuantity != 10 {
+		t.Errorf("stock = %d; want 10", p.StockQuantity)
+	}
+}
+
+func TestCancelOrder_Paid(t *testing.T) {
+	st := store.NewMemory()
+	provider := payments.NewFake()
+	order := paidOrder(t, st, provider)
+
+	rh := NewRefundHandler(st, st, st, provider)
+	w := httptest.NewRecorder()
+	rh.Cancel(w, cancelRequest(42))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	var resp struct {
+		Order  models.Order   `json:"order"`
+		Refund *models.Refund `json:"refund"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if resp.Order.Status != models.StatusCancelled || resp.Refund == nil ||
+		resp.Refund.Amount != 1000 || resp.Refund.Status != models.RefundSucceeded {
+		t.Errorf("got %+v; want cancelled order with a 10.00 refund", resp)
+	}
+	if ps, _ := st.ListPayments(context.Background(), order.ID); len(ps) != 1 || ps[0].Status != models.PaymentRefunded {
+		t.Errorf("payments = %+v; want one refunded", ps)
+	}
+	if p, _ := st.GetProduct(context.Background(), 1); p.StockQuantity != 10 {
+		t.Errorf("stock = %d; want 10", p.StockQuantity)
+	}
+}
+
+// downProvider is a provider whose refunds fail while down is set.
+type downProvider struct {
+	*payments.Fake
+	down bool
+}
+
+func (p *downProvider) Refund(ctx context.Context, ref string, amount models.Money) error {
+	if p.down {
+		return errors.New("gateway unavailable")
+	}
+	return p.Fake.Refund(ctx, ref, amount)
+}
+
+func TestRetryRefund(t *testing.T) {
+	st := store.NewMemory()
+	provider := &downProvider{Fake: payments.NewFake()}
+	order := paidOrder(t, st, provider)
+	rh := NewRefundHandler(st, st, st, provider)
+
+	provider.down = true
+	w := httptest.NewRecorder()
+	rh.Cancel(w, cancelRequest(42))
+	if w.Code != http.StatusOK {
+		t.Fatalf("cancel status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	var resp struct {
+		Refund *models.Refund `json:"refund"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Refund == nil || resp.Refund.Status != models.RefundFailed {
+		t.Fatalf("got refund %+v; want a failed one", resp.Refund)
+	}
+
+	retry := func(refundID int) *httptest.ResponseRecorder {
+		req := withURLParams(withUser(httptest.NewRequest("POST", "/", nil), 7),
+			"orderID", "1", "refundID", strconv.Itoa(refundID))
+		req = req.WithContext(context.WithValue(req.Context(), ContextUserRole, models.RoleStaff))
+		w := httptest.NewRecorder()
+		rh.Retry(w, req)
+		return w
+	}
+	if w := retry(resp.Refund.ID); w.Code != http.StatusBadGateway {
+		t.Errorf("retry while down status = %d; want %d", w.Code, http.StatusBadGateway)
+	}
+	provider.down = false
+	if w := retry(99); w.Code != http.StatusNotFound {
+		t.Errorf("unknown refund status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+	w = retry(resp.Refund.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	var got models.Refund
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Amount != 1000 || got.Status != models.RefundSucceeded || got.FailureReason != "" {
+		t.Errorf("got %+v; want a succeeded 10.00 refund", got)
+	}
+	if w := retry(resp.Refund.ID); w.Code != http.StatusConflict {
+		t.Errorf("second retry status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	if ps, _ := st.ListPayments(context.Background(), order.ID); len(ps) != 1 || ps[0].Status != models.PaymentRefunded {
+		t.Errorf("payments = %+v; want one refunded", ps)
+	}
+}
+
+func TestCancelOrder_ReleasesCoupon(t *testing.T) {
+	st := store.NewMemory()
+	limit := 1
+	promo := seedPromotion(t, st, models.Promotion{
+		Code: "ONCE", Kind: models.PromotionPercentage, PercentOff: 10, MaxUses: &limit,
+	})
+	p, cart := seedCart(t, st, 42)
+	st.AddCartItem(context.Background(), cart.ID, p.ID, nil, 1)
+	order := models.Order{UserID: 42, TotalAmount: 450, Currency: "USD", Status: models.StatusPending,
+		Discount: &models.OrderDiscount{PromotionID: &promo.ID, Code: promo.Code, Amount: 50}}
+	if err := st.CreateOrder(context.Background(), &order,
+		[]models.OrderItem{{ProductID: p.ID, Quantity: 1, UnitPrice: 500}}, cart.ID, ""); err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	NewRefundHandler(st, st, st, payments.NewFake()).Cancel(w, cancelRequest(42))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", w.Code, http.StatusOK)
+	}
+	if total, _, _ := st.PromotionUsage(context.Background(), promo.ID, 42); total != 0 {
+		t.Errorf("coupon uses = %d; want 0 after cancellation", total)
+	}
+}
+
+func TestRefundLine(t *testing.T) {
+	st := store.NewMemory()
+	provider := payments.NewFake()
+	order := paidOrder(t, st, provider)
+	rh := NewRefundHandler(st, st, st, provider)
+
+	refund := func(body string) *httptest.ResponseRecorder {
+		req := withURLParams(withUser(httptest.NewRequest("POST", "/orders/1/refunds",
+			bytes.NewBufferString(body)), 7), "orderID", "1")
+		req = req.WithContext(context.WithValue(req.Context(), ContextUserRole, models.RoleStaff))
+		w := httptest.NewRecorder()
+		rh.Refund(w, req)
+		return w
+	}
+	w := refund(`{"product_id":1,"quantity":1,"reason":"damaged"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d; want %d (%s)", w.Code, http.StatusCreated, w.Body)
+	}
+	var got models.Refund
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Amount != 500 || got.Status != models.RefundSucceeded || got.CreatedBy == nil || *got.CreatedBy != 7 {
+		t.Errorf("got %+v; want a 5.00 refund by user 7", got)
+	}
+	if o, _ := st.GetOrder(context.Background(), order.ID); o.Status != models.StatusPaid {
+		t.Errorf("order status = %s after partial refund; want paid", o.Status)
+	}
+
+	if w := refund(`{"product_id":1,"quantity":2}`); w.Code != http.StatusConflict {
+		t.Errorf("over-refund status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	if w := refund(`{"product_id":2,"quantity":1}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown line status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+	if w := refund(`{"product_id":1,"quantity":1}`); w.Code != http.StatusCreated {
+		t.Fatalf("final refund status = %d; want %d", w.Code, http.StatusCreated)
+	}
+	if o, _ := st.GetOrder(context.Background(), order.ID); o.Status != models.StatusRefunded {
+		t.Errorf("order status = %s; want refunded", o.Status)
+	}
+	if ps, _ := st.ListPayments(context.Background(), order.ID); ps[0].Status != models.PaymentRefunded {
+		t.Errorf("payment status = %s; want refunded", ps[0].Status)
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// ReturnHandler runs the returns (RMA) workflow. Refunds for approved
+// returns are issued through Refunder.
+type ReturnHandler struct {
+	Orders   store.OrderStore
+	Returns  store.ReturnStore
+	Refunder *RefundHandler
+}
+
+// NewReturnHandler constructs a ReturnHandler
+func NewReturnHandler(orders store.OrderStore, returns store.ReturnStore, refunder *RefundHandler) *ReturnHandler {
+	return &ReturnHandler{Orders: orders, Returns: returns, Refunder: refunder}
+}
+
+// Create handles POST /orders/{orderID}/returns, letting the owner ask to
+// send back units of some of the order's lines once it has shipped.
+func (h *ReturnHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+
+	// 1) Parse the reason and the units to return
+	var in struct {
+		Reason string              `json:"reason"`
+		Items  []models.ReturnItem `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" || len(in.Items) == 0 {
+		http.Error(w, "reason and items are required", http.StatusBadRequest)
+		return
+	}
+	seen := map[[2]int]bool{} // (product, variant or 0)
+	for _, it := range in.Items {
+		if it.Quantity <= 0 {
+			http.Error(w, "quantity must be positive", http.StatusBadRequest)
+			return
+		}
+		line := [2]int{it.ProductID}
+		if it.VariantID != nil {
+			line[1] = *it.VariantID
+		}
+		if seen[line] {
+			http.Error(w, "each line may only be listed once", http.StatusBadRequest)
+			return
+		}
+		seen[line] = true
+	}
+
+	// 2) Only the owner may return items, and only once they are on their way
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	if order.UserID != userID {
+		http.Error(w, "order belongs to another user", http.StatusForbidden)
+		return
+	}
+	if order.Status != models.StatusShipped && order.Status != models.StatusDelivered {
+		http.Error(w, "order has not shipped", http.StatusConflict)
+		return
+	}
+
+	// 3) Record the request
+	rt := models.Return{OrderID: order.ID, UserID: userID, Reason: in.Reason, Items: in.Items}
+	if err := h.Returns.CreateReturn(r.Context(), &rt); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			http.Error(w, "product not on order", http.StatusNotFound)
+		case errors.Is(err, store.ErrReturnExceeded):
+			http.Error(w, "return exceeds the quantity ordered", http.StatusConflict)
+		default:
+			http.Error(w, "failed to create return", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rt)
+}
+
+// List handles GET /orders/{orderID}/returns
+func (h *ReturnHandler) List(w http.ResponseWriter, r *http.Request) {
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return
+	}
+	returns, err := h.Returns.ListReturns(r.Context(), order.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch returns", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(returns)
+}
+
+// Get handles GET /orders/{orderID}/returns/{returnID}
+func (h *ReturnHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, rt, ok := h.orderReturn(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rt)
+}
+
+// Approve handles POST /orders/{orderID}/returns/{returnID}/approve. The
+// returned units are refunded straight away; the response shows each
+// refund's outcome, and a failed one may be retried as a line refund.
+func (h *ReturnHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+	note, ok := decodeNote(w, r)
+	if !ok {
+		return
+	}
+	order, rt, ok := h.orderReturn(w, r)
+	if !ok {
+		return
+	}
+	if rt.Status != models.ReturnRequested {
+		http.Error(w, (&models.ReturnTransitionError{From: rt.Status, To: models.ReturnApproved}).Error(), http.StatusConflict)
+		return
+	}
+
+	// 1) Price a refund for each returned line
+	pay, ok := h.Refunder.capturedPayment(w, r, order.ID)
+	if !ok {
+		return
+	}
+	refunds := make([]models.Refund, len(rt.Items))
+	for i, it := range rt.Items {
+		refund, ok := h.Refunder.lineRefund(w, r, order, it.ProductID, it.VariantID, it.Quantity)
+		if !ok {
+			return
+		}
+		refund.PaymentID, refund.Reason, refund.CreatedBy = pay.ID, "return: "+rt.Reason, &userID
+		refunds[i] = refund
+	}
+
+	// 2) Approve and record the refunds together
+	rt, err := h.Returns.ApproveReturn(r.Context(), rt.ID, &userID, note, refunds)
+	if err != nil {
+		var transErr *models.ReturnTransitionError
+		switch {
+		case errors.As(err, &transErr):
+			http.Error(w, transErr.Error(), http.StatusConflict)
+		case errors.Is(err, store.ErrRefundExceeded):
+			http.Error(w, "refund exceeds what remains on the order", http.StatusConflict)
+		default:
+			http.Error(w, "failed to approve return", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// 3) Ask the provider for the money back
+	for i := range refunds {
+		if err := h.Refunder.refund(r.Context(), &refunds[i]); err != nil {
+			http.Error(w, "failed to record refund", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Return  models.Return   `json:"return"`
+		Refunds []models.Refund `json:"refunds"`
+	}{rt, refunds})
+}
+
+// Reject handles POST /orders/{orderID}/returns/{returnID}/reject
+func (h *ReturnHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+	note, ok := decodeNote(w, r)
+	if !ok {
+		return
+	}
+	_, rt, ok := h.orderReturn(w, r)
+	if !ok {
+		return
+	}
+	rt, err := h.Returns.RejectReturn(r.Context(), rt.ID, &userID, note)
+	writeReturn(w, rt, err, "failed to reject return")
+}
+
+// Receive handles POST /orders/{orderID}/returns/{returnID}/receive,
+// recording that an approved return's items arrived. With "restock": true
+// they go back into stock.
+func (h *ReturnHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(ContextUserID).(int)
+	var in struct {
+		Note    string `json:"note"`
+		Restock bool   `json:"restock"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	_, rt, ok := h.orderReturn(w, r)
+	if !ok {
+		return
+	}
+	rt, err := h.Returns.ReceiveReturn(r.Context(), rt.ID, &userID, in.Note, in.Restock)
+	writeReturn(w, rt, err, "failed to receive return")
+}
+
+// decodeNote reads an optional {"note"} body, writing an error and
+// returning false if it is malformed.
+func decodeNote(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var in struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return "", false
+	}
+	return in.Note, true
+}
+
+// writeReturn responds with rt, or with the error from the status change
+// that produced it.
+func writeReturn(w http.ResponseWriter, rt models.Return, err error, failure string) {
+	var transErr *models.ReturnTransitionError
+	switch {
+	case errors.As(err, &transErr):
+		http.Error(w, transErr.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "return not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, failure, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rt)
+}
+
+// orderReturn loads the order and the return named by the URL, writing an
+// error and returning false if either does not exist, the caller may not
+// see the order, or the return is for another order.
+func (h *ReturnHandler) orderReturn(w http.ResponseWriter, r *http.Request) (models.Order, models.Return, bool) {
+	order, ok := visibleOrder(w, r, h.Orders)
+	if !ok {
+		return models.Order{}, models.Return{}, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "returnID"))
+	if err != nil {
+		http.Error(w, "invalid return ID", http.StatusBadRequest)
+		return models.Order{}, models.Return{}, false
+	}
+	rt, err := h.Returns.GetReturn(r.Context(), id)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "failed to fetch return", http.StatusInternalServerError)
+		return models.Order{}, models.Return{}, false
+	}
+	if err != nil || rt.OrderID != order.ID {
+		http.Error(w, "return not found", http.StatusNotFound)
+		return models.Order{}, models.Return{}, false
+	}
+	return order, rt, true
+}
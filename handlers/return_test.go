@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// shippedOrder seeds a paid order for two Widgets and ships it.
+func shippedOrder(t *testing.T, st *store.Memory, provider payments.Provider) models.Order {
+	t.Helper()
+	order := paidOrder(t, st, provider)
+	for _, s := range []models.OrderStatus{models.StatusFulfilled, models.StatusShipped} {
+		if _, err := st.TransitionOrder(context.Background(), order.ID, s, nil, ""); err != nil {
+			t.Fatalf("ship order: %v", err)
+		}
+	}
+	order.Status = models.StatusShipped
+	return order
+}
+
+// returnRequest builds a request to the returns endpoints for order 1.
+// Staff requests are made by user 7.
+func returnRequest(target, body string, staff bool, kv ...string) *http.Request {
+	userID := 42
+	if staff {
+		userID = 7
+	}
+	req := withURLParams(withUser(httptest.NewRequest("POST", target, bytes.NewBufferString(body)), userID),
+		append([]string{"orderID", "1"}, kv...)...)
+	if staff {
+		req = req.WithContext(context.WithValue(req.Context(), ContextUserRole, models.RoleStaff))
+	}
+	return req
+}
+
+func TestCreateReturn(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"reason":"too small","items":[{"product_id":1,"quantity":1}]}`, http.StatusCreated},
+		{"no reason", `{"items":[{"product_id":1,"quantity":1}]}`, http.StatusBadRequest},
+		{"no items", `{"reason":"too small","items":[]}`, http.StatusBadRequest},
+		{"product listed twice", `{"reason":"too small","items":[{"product_id":1,"quantity":1},{"product_id":1,"quantity":1}]}`, http.StatusBadRequest},
+		{"not on order", `{"reason":"too small","items":[{"product_id":9,"quantity":1}]}`, http.StatusNotFound},
+		{"more than ordered", `{"reason":"too small","items":[{"product_id":1,"quantity":3}]}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			provider := payments.NewFake()
+			shippedOrder(t, st, provider)
+			h := NewReturnHandler(st, st, NewRefundHandler(st, st, st, provider))
+
+			w := httptest.NewRecorder()
+			h.Create(w, returnRequest("/orders/1/returns", tt.body, false))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestCreateReturn_NotShipped(t *testing.T) {
+	st := store.NewMemory()
+	provider := payments.NewFake()
+	paidOrder(t, st, provider)
+	h := NewReturnHandler(st, st, NewRefundHandler(st, st, st, provider))
+
+	w := httptest.NewRecorder()
+	h.Create(w, returnRequest("/orders/1/returns", `{"reason":"changed my mind","items":[{"product_id":1,"quantity":1}]}`, false))
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestReturnWorkflow(t *testing.T) {
+	st := store.NewMemory()
+	provider := payments.NewFake()
+	order := shippedOrder(t, st, provider)
+	h := NewReturnHandler(st, st, NewRefundHandler(st, st, st, provider))
+
+	w := httptest.NewRecorder()
+	h.Create(w, returnRequest("/orders/1/returns", `{"reason":"broken","items":[{"product_id":1,"quantity":1}]}`, false))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create status = %d; want %d", w.Code, http.StatusCreated)
+	}
+
+	// receiving before approval is refused
+	w = httptest.NewRecorder()
+	h.Receive(w, returnRequest("/orders/1/returns/1/receive", `{"restock":true}`, true, "returnID", "1"))
+	if w.Code != http.StatusConflict {
+		t.Errorf("early Receive status = %d; want %d", w.Code, http.StatusConflict)
+	}
+
+	w = httptest.NewRecorder()
+	h.Approve(w, returnRequest("/orders/1/returns/1/approve", `{"note":"sorry about that"}`, true, "returnID", "1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Approve status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+	var approved struct {
+		Return  models.Return   `json:"return"`
+		Refunds []models.Refund `json:"refunds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &approved); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if approved.Return.Status != models.ReturnApproved || len(approved.Refunds) != 1 ||
+		approved.Refunds[0].Amount != 500 || approved.Refunds[0].Status != models.RefundSucceeded ||
+		approved.Refunds[0].ReturnID == nil || *approved.Refunds[0].ReturnID != 1 {
+		t.Errorf("got %+v; want approved return with one 5.00 refund", approved)
+	}
+
+	w = httptest.NewRecorder()
+	h.Receive(w, returnRequest("/orders/1/returns/1/receive", `{"restock":true}`, true, "returnID", "1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Receive status = %d; want %d", w.Code, http.StatusOK)
+	}
+	if p, _ := st.GetProduct(context.Background(), 1); p.StockQuantity != 9 {
+		t.Errorf("stock = %d; want 9 after restocking one of two", p.StockQuantity)
+	}
+	if rt, _ := st.GetReturn(context.Background(), 1); rt.Status != models.ReturnReceived || !rt.Restocked {
+		t.Errorf("return = %+v; want received and restocked", rt)
+	}
+	if o, _ := st.GetOrder(context.Background(), order.ID); o.Status != models.StatusShipped {
+		t.Errorf("order status = %s; want shipped while one unit is kept", o.Status)
+	}
+}
+
+func TestRejectReturn(t *testing.T) {
+	st := store.NewMemory()
+	provider := payments.NewFake()
+	shippedOrder(t, st, provider)
+	h := NewReturnHandler(st, st, NewRefundHandler(st, st, st, provider))
+	body := `{"reason":"unwanted","items":[{"product_id":1,"quantity":2}]}`
+	h.Create(httptest.NewRecorder(), returnRequest("/orders/1/returns", body, false))
+
+	w := httptest.NewRecorder()
+	h.Reject(w, returnRequest("/orders/1/returns/1/reject", `{"note":"outside the return window"}`, true, "returnID", "1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Reject status = %d; want %d", w.Code, http.StatusOK)
+	}
+	if refunds, _ := st.ListRefunds(context.Background(), 1); len(refunds) != 0 {
+		t.Errorf("refunds = %+v; want none", refunds)
+	}
+
+	// a rejected return no longer holds the units, so they may be asked for again
+	w = httptest.NewRecorder()
+	h.Create(w, returnRequest("/orders/1/returns", body, false))
+	if w.Code != http.StatusCreated {
+		t.Errorf("second Create status = %d; want %d", w.Code, http.StatusCreated)
+	}
+	w = httptest.NewRecorder()
+	h.Approve(w, returnRequest("/orders/1/returns/1/approve", ``, true, "returnID", "1"))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Approve rejected status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// SubscriptionHandler manages outbound webhook subscriptions
+type SubscriptionHandler struct {
+	Store store.SubscriptionStore
+}
+
+// NewSubscriptionHandler constructs a SubscriptionHandler
+func NewSubscriptionHandler(s store.SubscriptionStore) *SubscriptionHandler {
+	return &SubscriptionHandler{Store: s}
+}
+
+// subscriptionInput is the body of create and update requests. Active
+// defaults to true; an empty secret is generated on create and kept on
+// update.
+type subscriptionInput struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Secret     string   `json:"secret"`
+	Active     *bool    `json:"active"`
+}
+
+// validate returns a validation error message, or "" if in is acceptable.
+func (in subscriptionInput) validate() string {
+	u, err := url.Parse(in.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "url must be an absolute http or https URL"
+	}
+	if len(in.EventTypes) == 0 {
+		return "event_types must not be empty"
+	}
+	for _, t := range in.EventTypes {
+		if t != "*" && !models.KnownEventType(t) {
+			return "unknown event type " + strconv.Quote(t)
+		}
+	}
+	return ""
+}
+
+// Create handles POST /webhooks/subscriptions. The response is the only
+// time the secret is shown.
+func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var in subscriptionInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if msg := in.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	sub := models.WebhookSubscription{URL: in.URL, EventTypes: in.EventTypes, Secret: in.Secret, Active: true}
+	if in.Active != nil {
+		sub.Active = *in.Active
+	}
+	if sub.Secret == "" {
+		secret, err := randomToken(24)
+		if err != nil {
+			http.Error(w, "failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		sub.Secret = "whsec_" + secret
+	}
+	if err := h.Store.CreateWebhookSubscription(r.Context(), &sub); err != nil {
+		http.Error(w, "failed to create subscription", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sub)
+}
+
+// List handles GET /webhooks/subscriptions
+func (h *SubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.Store.ListWebhookSubscriptions(r.Context())
+	if err != nil {
+		http.Error(w, "failed to fetch subscriptions", http.StatusInternalServerError)
+		return
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subs)
+}
+
+// Get handles GET /webhooks/subscriptions/{subID}
+func (h *SubscriptionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	sub.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// Update handles PUT /webhooks/subscriptions/{subID}
+func (h *SubscriptionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	var in subscriptionInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if msg := in.validate(); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	sub.URL, sub.EventTypes = in.URL, in.EventTypes
+	if in.Secret != "" {
+		sub.Secret = in.Secret
+	}
+	if in.Active != nil {
+		sub.Active = *in.Active
+	}
+	err := h.Store.UpdateWebhookSubscription(r.Context(), &sub)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update subscription", http.StatusInternalServerError)
+		return
+	}
+	sub.Secret = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// Delete handles DELETE /webhooks/subscriptions/{subID}
+func (h *SubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "subID"))
+	if err != nil {
+		http.Error(w, "invalid subscription ID", http.StatusBadRequest)
+		return
+	}
+	err = h.Store.DeleteWebhookSubscription(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete subscription", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deliveries handles GET /webhooks/subscriptions/{subID}/deliveries,
+// listing recent delivery attempts, newest first.
+func (h *SubscriptionHandler) Deliveries(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.subscription(w, r)
+	if !ok {
+		return
+	}
+	deliveries, err := h.Store.ListWebhookDeliveries(r.Context(), sub.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// subscription loads the subscription named by the subID URL parameter,
+// writing an error and returning false if there is none.
+func (h *SubscriptionHandler) subscription(w http.ResponseWriter, r *http.Request) (models.WebhookSubscription, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "subID"))
+	if err != nil {
+		http.Error(w, "invalid subscription ID", http.StatusBadRequest)
+		return models.WebhookSubscription{}, false
+	}
+	sub, err := h.Store.GetWebhookSubscription(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "subscription not found", http.StatusNotFound)
+		return models.WebhookSubscription{}, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch subscription", http.StatusInternalServerError)
+		return models.WebhookSubscription{}, false
+	}
+	return sub, true
+}
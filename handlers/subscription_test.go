@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func TestCreateSubscription(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"url":"https://erp.example.com/hook","event_types":["order.placed","order.status_changed"]}`, http.StatusCreated},
+		{"wildcard", `{"url":"http://erp.local/hook","event_types":["*"]}`, http.StatusCreated},
+		{"relative url", `{"url":"/hook","event_types":["order.placed"]}`, http.StatusBadRequest},
+		{"no events", `{"url":"https://erp.example.com/hook","event_types":[]}`, http.StatusBadRequest},
+		{"unknown event", `{"url":"https://erp.example.com/hook","event_types":["order.shipped"]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSubscriptionHandler(store.NewMemory())
+			w := httptest.NewRecorder()
+			h.Create(w, httptest.NewRequest("POST", "/webhooks/subscriptions", bytes.NewBufferString(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			if w.Code != http.StatusCreated {
+				return
+			}
+			var sub models.WebhookSubscription
+			json.Unmarshal(w.Body.Bytes(), &sub)
+			if !strings.HasPrefix(sub.Secret, "whsec_") || !sub.Active {
+				t.Errorf("created = %+v; want an active subscription with a generated secret", sub)
+			}
+		})
+	}
+}
+
+func TestSubscriptionSecretHiddenAfterCreate(t *testing.T) {
+	st := store.NewMemory()
+	st.CreateWebhookSubscription(context.Background(), &models.WebhookSubscription{
+		URL: "https://erp.example.com/hook", EventTypes: []string{"*"}, Secret: "s", Active: true,
+	})
+	h := NewSubscriptionHandler(st)
+
+	w := httptest.NewRecorder()
+	h.List(w, httptest.NewRequest("GET", "/webhooks/subscriptions", nil))
+	if strings.Contains(w.Body.String(), `"secret"`) {
+		t.Errorf("List exposed the secret: %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.Update(w, withURLParams(httptest.NewRequest("PUT", "/webhooks/subscriptions/1",
+		bytes.NewBufferString(`{"url":"https://erp.example.com/v2","event_types":["order.placed"],"active":false}`)),
+		"subID", "1"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update status = %d; want %d", w.Code, http.StatusOK)
+	}
+	got, _ := st.GetWebhookSubscription(context.Background(), 1)
+	if got.URL != "https://erp.example.com/v2" || got.Active || got.Secret != "s" {
+		t.Errorf("after update = %+v; want new URL, inactive, secret kept", got)
+	}
+}
+
+func TestSubscriptionNotFound(t *testing.T) {
+	h := NewSubscriptionHandler(store.NewMemory())
+	for name, fn := range map[string]http.HandlerFunc{
+		"get": h.Get, "delete": h.Delete, "deliveries": h.Deliveries,
+	} {
+		w := httptest.NewRecorder()
+		fn(w, withURLParams(httptest.NewRequest("GET", "/", nil), "subID", "3"))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d; want %d", name, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// VariantHandler manages product options and the variants built from them
+type VariantHandler struct {
+	Store    store.VariantStore
+	Products store.ProductStore
+}
+
+// NewVariantHandler constructs a VariantHandler
+func NewVariantHandler(variants store.VariantStore, products store.ProductStore) *VariantHandler {
+	return &VariantHandler{Store: variants, Products: products}
+}
+
+// SetOptions handles PUT /products/{id}/options. The body's options
+// replace the product's current ones, in the order given; they can only be
+// changed while the product has no variants.
+func (h *VariantHandler) SetOptions(w http.ResponseWriter, r *http.Request) {
+	p, ok := productParam(w, r, h.Products)
+	if !ok {
+		return
+	}
+	var body struct {
+		Options []models.ProductOption `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if msg := normalizeOptions(body.Options); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	err := h.Store.SetProductOptions(r.Context(), p.ID, body.Options)
+	if errors.Is(err, store.ErrProductHasVariants) {
+		http.Error(w, "options cannot change while the product has variants", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update options", http.StatusInternalServerError)
+		return
+	}
+	if body.Options == nil {
+		body.Options = []models.ProductOption{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body.Options)
+}
+
+// normalizeOptions trims option names and values and returns a validation
+// error message, or "" if opts are acceptable.
+func normalizeOptions(opts []models.ProductOption) string {
+	names := map[string]bool{}
+	for i := range opts {
+		o := &opts[i]
+		o.Name = strings.TrimSpace(o.Name)
+		if o.Name == "" {
+			return "option names are required"
+		}
+		if names[o.Name] {
+			return "option names must be unique"
+		}
+		names[o.Name] = true
+		if len(o.Values) == 0 {
+			return "every option needs at least one value"
+		}
+		values := map[string]bool{}
+		for j, v := range o.Values {
+			v = strings.TrimSpace(v)
+			if v == "" || values[v] {
+				return "option values must be non-empty and unique"
+			}
+			values[v] = true
+			o.Values[j] = v
+		}
+	}
+	return ""
+}
+
+// variantInput is the body of create and update requests.
+type variantInput struct {
+	SKU           string                   `json:"sku"`
+	Price         models.Money             `json:"price"`
+	StockQuantity int                      `json:"stock_quantity"`
+	Attributes    models.VariantAttributes `json:"attributes"`
+}
+
+// decode reads and validates a variantInput against the product's
+// options, writing the error and returning false if it is unacceptable.
+func (in *variantInput) decode(w http.ResponseWriter, r *http.Request, opts []models.ProductOption) bool {
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return false
+	}
+	in.SKU = strings.TrimSpace(in.SKU)
+	if in.Attributes == nil {
+		in.Attributes = models.VariantAttributes{}
+	}
+	switch {
+	case in.SKU == "" || len(in.SKU) > 64:
+		http.Error(w, "sku must be 1 to 64 characters", http.StatusBadRequest)
+		return false
+	case in.Price < 0:
+		http.Error(w, "price must not be negative", http.StatusBadRequest)
+		return false
+	case in.StockQuantity < 0:
+		http.Error(w, "stock_quantity must not be negative", http.StatusBadRequest)
+		return false
+	case len(opts) == 0:
+		http.Error(w, "product has no options to vary by", http.StatusConflict)
+		return false
+	}
+	if err := models.CheckAttributes(opts, in.Attributes); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// apply copies in onto v.
+func (in variantInput) apply(v *models.Variant) {
+	v.SKU, v.Price, v.StockQuantity, v.Attributes = in.SKU, in.Price, in.StockQuantity, in.Attributes
+}
+
+// ListVariants handles GET /products/{id}/variants
+func (h *VariantHandler) ListVariants(w http.ResponseWriter, r *http.Request) {
+	p, ok := productParam(w, r, h.Products)
+	if !ok {
+		return
+	}
+	vs, err := h.Store.ListVariants(r.Context(), p.ID)
+	if err != nil {
+		http.Error(w, "failed to fetch variants", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(vs)
+}
+
+// CreateVariant handles POST /products/{id}/variants. The attributes must
+// pick a value for each of the product's options.
+func (h *VariantHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	p, ok := productParam(w, r, h.Products)
+	if !ok {
+		return
+	}
+	opts, ok := h.options(w, r, p.ID)
+	if !ok {
+		return
+	}
+	var in variantInput
+	if !in.decode(w, r, opts) {
+		return
+	}
+	v := models.Variant{ProductID: p.ID}
+	in.apply(&v)
+	if !variantSaved(w, h.Store.CreateVariant(r.Context(), &v), "failed to create variant") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(v)
+}
+
+// UpdateVariant handles PUT /products/{id}/variants/{variantID}
+func (h *VariantHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.variant(w, r)
+	if !ok {
+		return
+	}
+	opts, ok := h.options(w, r, v.ProductID)
+	if !ok {
+		return
+	}
+	var in variantInput
+	if !in.decode(w, r, opts) {
+		return
+	}
+	in.apply(&v)
+	if !variantSaved(w, h.Store.UpdateVariant(r.Context(), &v), "failed to update variant") {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// variantSaved writes the error for a failed create or update and reports
+// whether err was nil.
+func variantSaved(w http.ResponseWriter, err error, failure string) bool {
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		http.Error(w, "variant not found", http.StatusNotFound)
+	case errors.Is(err, store.ErrDuplicateSKU):
+		http.Error(w, "sku already in use", http.StatusConflict)
+	case errors.Is(err, store.ErrDuplicateVariant):
+		http.Error(w, "product already has a variant with these attributes", http.StatusConflict)
+	case err != nil:
+		http.Error(w, failure, http.StatusInternalServerError)
+	}
+	return err == nil
+}
+
+// DeleteVariant handles DELETE /products/{id}/variants/{variantID}. Cart
+// lines for the variant are removed with it; a variant that has been
+// ordered cannot be deleted.
+func (h *VariantHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.variant(w, r)
+	if !ok {
+		return
+	}
+	err := h.Store.DeleteVariant(r.Context(), v.ID)
+	if errors.Is(err, store.ErrVariantOrdered) {
+		http.Error(w, "variant has been ordered", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete variant", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *VariantHandler) options(w http.ResponseWriter, r *http.Request, productID int) ([]models.ProductOption, bool) {
+	opts, err := h.Store.ProductOptions(r.Context(), productID)
+	if err != nil {
+		http.Error(w, "failed to fetch options", http.StatusInternalServerError)
+		return nil, false
+	}
+	return opts, true
+}
+
+// variant loads the variant named by the variantID URL parameter, writing
+// an error and returning false if there is none or it belongs to a product
+// other than the one named by id.
+func (h *VariantHandler) variant(w http.ResponseWriter, r *http.Request) (models.Variant, bool) {
+	productID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return models.Variant{}, false
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "variantID"))
+	if err != nil {
+		http.Error(w, "invalid variant ID", http.StatusBadRequest)
+		return models.Variant{}, false
+	}
+	v, err := h.Store.GetVariant(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && v.ProductID != productID) {
+		http.Error(w, "variant not found", http.StatusNotFound)
+		return models.Variant{}, false
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch variant", http.StatusInternalServerError)
+		return models.Variant{}, false
+	}
+	return v, true
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// seedVariants gives p size options and S and M variants priced at 7.00
+// with 2 units of stock each.
+func seedVariants(t *testing.T, st *store.Memory, p models.Product) []models.Variant {
+	t.Helper()
+	ctx := context.Background()
+	if err := st.SetProductOptions(ctx, p.ID, []models.ProductOption{{Name: "size", Values: []string{"S", "M"}}}); err != nil {
+		t.Fatalf("seed options: %v", err)
+	}
+	var out []models.Variant
+	for _, size := range []string{"S", "M"} {
+		v := models.Variant{ProductID: p.ID, SKU: "WID-" + size, Price: 700, StockQuantity: 2,
+			Attributes: models.VariantAttributes{"size": size}}
+		if err := st.CreateVariant(ctx, &v); err != nil {
+			t.Fatalf("seed variant: %v", err)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func TestCreateVariant(t *testing.T) {
+	st := store.NewMemory()
+	p, _ := seedCart(t, st, 42)
+	h := NewVariantHandler(st, st)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.CreateVariant(w, withURLParams(httptest.NewRequest("POST", "/products/1/variants",
+			bytes.NewBufferString(body)), "id", strconv.Itoa(p.ID)))
+		return w
+	}
+
+	if w := post(`{"sku":"WID-S","price":7,"attributes":{"size":"S"}}`); w.Code != http.StatusConflict {
+		t.Fatalf("variant without options status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	w := httptest.NewRecorder()
+	h.SetOptions(w, withURLParams(httptest.NewRequest("PUT", "/products/1/options",
+		bytes.NewBufferString(`{"options":[{"name":" size ","values":["S","M"]},{"name":"colour","values":["red"]}]}`)),
+		"id", strconv.Itoa(p.ID)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("SetOptions status = %d; want %d (%s)", w.Code, http.StatusOK, w.Body)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid", `{"sku":"WID-S-RED","price":7,"stock_quantity":3,"attributes":{"size":"S","colour":"red"}}`, http.StatusCreated},
+		{"missing sku", `{"price":7,"attributes":{"size":"M","colour":"red"}}`, http.StatusBadRequest},
+		{"negative price", `{"sku":"X","price":-1,"attributes":{"size":"M","colour":"red"}}`, http.StatusBadRequest},
+		{"unknown value", `{"sku":"X","price":7,"attributes":{"size":"XL","colour":"red"}}`, http.StatusBadRequest},
+		{"missing option", `{"sku":"X","price":7,"attributes":{"size":"M"}}`, http.StatusBadRequest},
+		{"duplicate sku", `{"sku":"WID-S-RED","price":7,"attributes":{"size":"M","colour":"red"}}`, http.StatusConflict},
+		{"duplicate attributes", `{"sku":"OTHER","price":7,"attributes":{"colour":"red","size":"S"}}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := post(tt.body); w.Code != tt.wantStatus {
+				t.Errorf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	// options are frozen once variants are built from them
+	w = httptest.NewRecorder()
+	h.SetOptions(w, withURLParams(httptest.NewRequest("PUT", "/products/1/options",
+		bytes.NewBufferString(`{"options":[]}`)), "id", strconv.Itoa(p.ID)))
+	if w.Code != http.StatusConflict {
+		t.Errorf("SetOptions with variants status = %d; want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestVariant_OtherProduct(t *testing.T) {
+	st := store.NewMemory()
+	p, _ := seedCart(t, st, 42)
+	vs := seedVariants(t, st, p)
+	other := models.Product{Name: "Gadget", Price: 100, Currency: "USD"}
+	st.CreateProduct(context.Background(), &other)
+
+	w := httptest.NewRecorder()
+	NewVariantHandler(st, st).DeleteVariant(w, withURLParams(httptest.NewRequest("DELETE", "/", nil),
+		"id", strconv.Itoa(other.ID), "variantID", strconv.Itoa(vs[0].ID)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestDeleteVariant_Ordered(t *testing.T) {
+	st := store.NewMemory()
+	ctx := context.Background()
+	p, cart := seedCart(t, st, 42)
+	vs := seedVariants(t, st, p)
+	st.AddCartItem(ctx, cart.ID, p.ID, &vs[0].ID, 1)
+	order := models.Order{UserID: 42, TotalAmount: 700, Currency: "USD", Status: models.StatusPending}
+	if err := st.CreateOrder(ctx, &order, []models.OrderItem{
+		{ProductID: p.ID, VariantID: &vs[0].ID, SKU: vs[0].SKU, Quantity: 1, UnitPrice: 700},
+	}, cart.ID, ""); err != nil {
+		t.Fatalf("seed order: %v", err)
+	}
+
+	h := NewVariantHandler(st, st)
+	del := func(v models.Variant) int {
+		w := httptest.NewRecorder()
+		h.DeleteVariant(w, withURLParams(httptest.NewRequest("DELETE", "/", nil),
+			"id", strconv.Itoa(p.ID), "variantID", strconv.Itoa(v.ID)))
+		return w.Code
+	}
+	if code := del(vs[0]); code != http.StatusConflict {
+		t.Errorf("ordered variant status = %d; want %d", code, http.StatusConflict)
+	}
+	if code := del(vs[1]); code != http.StatusNoContent {
+		t.Errorf("unordered variant status = %d; want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestGetProduct_Variants(t *testing.T) {
+	st := store.NewMemory()
+	p, _ := seedCart(t, st, 42)
+	seedVariants(t, st, p)
+
+	w := httptest.NewRecorder()
+	NewProductHandler(st, st).Get(w, withURLParams(httptest.NewRequest("GET", "/products/1", nil),
+		"id", strconv.Itoa(p.ID)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", w.Code, http.StatusOK)
+	}
+	var got struct {
+		Name     string                 `json:"name"`
+		Options  []models.ProductOption `json:"options"`
+		Variants []models.Variant       `json:"variants"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Name != "Widget" || len(got.Options) != 1 || len(got.Variants) != 2 ||
+		got.Variants[1].SKU != "WID-M" || got.Variants[1].Attributes["size"] != "M" {
+		t.Errorf("got %+v; want Widget with one option and variants WID-S, WID-M", got)
+	}
+}
+
+func TestAddItem_Variant(t *testing.T) {
+	st := store.NewMemory()
+	p, cart := seedCart(t, st, 42)
+	vs := seedVariants(t, st, p)
+	ch := NewCartHandler(st, st, st, st, st, "secret")
+	add := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ch.AddItem(w, withUser(withURLParams(httptest.NewRequest("POST", "/carts/1/items",
+			bytes.NewBufferString(body)), "cartID", strconv.Itoa(cart.ID)), 42))
+		return w
+	}
+
+	if w := add(`{"product_id":1,"quantity":1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("no variant status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := add(`{"product_id":1,"variant_id":99,"quantity":1}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown variant status = %d; want %d", w.Code, http.StatusNotFound)
+	}
+	// the variant's stock applies, not the product's 10 units
+	if w := add(`{"product_id":1,"variant_id":` + strconv.Itoa(vs[0].ID) + `,"quantity":3}`); w.Code != http.StatusConflict {
+		t.Errorf("over variant stock status = %d; want %d", w.Code, http.StatusConflict)
+	}
+	for _, v := range vs {
+		if w := add(`{"product_id":1,"variant_id":` + strconv.Itoa(v.ID) + `,"quantity":2}`); w.Code != http.StatusNoContent {
+			t.Fatalf("add %s status = %d; want %d (%s)", v.SKU, w.Code, http.StatusNoContent, w.Body)
+		}
+	}
+
+	lines, err := st.CartLines(context.Background(), cart.ID)
+	if err != nil {
+		t.Fatalf("CartLines: %v", err)
+	}
+	if len(lines) != 2 || lines[0].SKU != "WID-S" || lines[1].SKU != "WID-M" ||
+		lines[0].UnitPrice != 700 || lines[0].Quantity != 2 {
+		t.Fatalf("got lines %+v; want two lines of 2 at 7.00", lines)
+	}
+
+	// removing one size leaves the other
+	w := httptest.NewRecorder()
+	ch.RemoveItem(w, withUser(withURLParams(httptest.NewRequest("DELETE",
+		"/carts/1/items/1?variant_id="+strconv.Itoa(vs[0].ID), nil),
+		"cartID", strconv.Itoa(cart.ID), "productID", strconv.Itoa(p.ID)), 42))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("RemoveItem status = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	lines, _ = st.CartLines(context.Background(), cart.ID)
+	if len(lines) != 1 || lines[0].VariantID == nil || *lines[0].VariantID != vs[1].ID {
+		t.Errorf("got lines %+v; want only WID-M", lines)
+	}
+}
+
+func TestCreateOrder_Variant(t *testing.T) {
+	st := store.NewMemory()
+	ctx := context.Background()
+	p, cart := seedCart(t, st, 42)
+	vs := seedVariants(t, st, p)
+	st.AddCartItem(ctx, cart.ID, p.ID, &vs[1].ID, 2)
+
+	w := httptest.NewRecorder()
+	NewOrderHandler(st, st, st, st).CreateOrder(w, withUser(httptest.NewRequest("POST", "/orders",
+		bytes.NewBufferString(`{"cart_id":`+strconv.Itoa(cart.ID)+`}`)), 42))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateOrder status = %d; want %d (%s)", w.Code, http.StatusCreated, w.Body)
+	}
+	var o models.Order
+	json.Unmarshal(w.Body.Bytes(), &o)
+	if o.TotalAmount != 1400 {
+		t.Errorf("order total = %v; want 14.00", o.TotalAmount)
+	}
+
+	items, err := st.OrderLines(ctx, o.ID)
+	if err != nil {
+		t.Fatalf("OrderLines: %v", err)
+	}
+	if len(items) != 1 || items[0].SKU != "WID-M" || items[0].VariantID == nil || *items[0].VariantID != vs[1].ID {
+		t.Errorf("got items %+v; want one WID-M line", items)
+	}
+	v, _ := st.GetVariant(ctx, vs[1].ID)
+	prod, _ := st.GetProduct(ctx, p.ID)
+	if v.StockQuantity != 0 || prod.StockQuantity != 10 {
+		t.Errorf("stock: variant %d, product %d; want 0 and untouched 10", v.StockQuantity, prod.StockQuantity)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// webhookTolerance bounds the age of a signature we accept.
+const webhookTolerance = 5 * time.Minute
+
+// WebhookHandler receives payment gateway webhooks
+type WebhookHandler struct {
+	Orders store.OrderStore
+	Events store.WebhookStore
+	Secret string
+}
+
+// NewWebhookHandler constructs a WebhookHandler verifying with secret
+func NewWebhookHandler(orders store.OrderStore, events store.WebhookStore, secret string) *WebhookHandler {
+	return &WebhookHandler{Orders: orders, Events: events, Secret: secret}
+}
+
+// Payments handles POST /webhooks/payments.
+//
+// The body must be signed with payments.SignWebhook. Each event ID is
+// applied once; redeliveries are acknowledged without effect. A succeeded
+// event pays a pending order and a refunded event covering the full total
+// refunds a paid one. Failed events are recorded but leave the order
+// pending so the customer can try again.
+func (h *WebhookHandler) Payments(w http.ResponseWriter, r *http.Request) {
+	// 1) Authenticate the sender
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	sig := r.Header.Get(payments.SignatureHeader)
+	if err := payments.VerifyWebhook(h.Secret, sig, body, time.Now(), webhookTolerance); err != nil {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	// 2) Parse the event
+	var ev payments.Event
+	if err := json.Unmarshal(body, &ev); err != nil || ev.ID == "" || ev.Type == "" {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	record := models.WebhookEvent{ID: ev.ID, Type: ev.Type, Payload: body}
+
+	// 3) Work out what the event means for its order
+	var to *models.OrderStatus
+	var note string
+	switch ev.Type {
+	case payments.EventPaymentSucceeded, payments.EventPaymentFailed, payments.EventPaymentRefunded:
+		order, err := h.Orders.GetOrder(r.Context(), ev.OrderID)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "order not found", http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to fetch order", http.StatusInternalServerError)
+			return
+		}
+		if ev.Currency != order.Currency {
+			http.Error(w, "currency does not match order", http.StatusUnprocessableEntity)
+			return
+		}
+		record.OrderID = &order.ID
+		to, note, err = paymentEventTarget(ev, order)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	// 4) Record the event and apply it together
+	err = h.Events.RecordWebhookEvent(r.Context(), &record, to, note)
+	var transErr *models.TransitionError
+	switch {
+	case errors.Is(err, store.ErrDuplicateEvent):
+		writeWebhookResult(w, "duplicate")
+	case errors.As(err, &transErr):
+		// the order moved in the meantime; let the sender retry
+		http.Error(w, transErr.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, "failed to record event", http.StatusInternalServerError)
+	case to == nil:
+		writeWebhookResult(w, "ignored")
+	default:
+		writeWebhookResult(w, "applied")
+	}
+}
+
+// paymentEventTarget returns the status ev moves order to, or nil if it
+// leaves the order as it is. It rejects amounts that do not fit the order.
+func paymentEventTarget(ev payments.Event, order models.Order) (*models.OrderStatus, string, error) {
+	var to models.OrderStatus
+	switch ev.Type {
+	case payments.EventPaymentSucceeded:
+		if ev.Amount != order.TotalAmount {
+			return nil, "", errors.New("amount does not match order total")
+		}
+		if order.Status != models.StatusPending {
+			return nil, "", nil // already paid, e.g. through POST /orders/{id}/pay
+		}
+		to = models.StatusPaid
+	case payments.EventPaymentRefunded:
+		if ev.Amount <= 0 || ev.Amount > order.TotalAmount {
+			return nil, "", errors.New("refund amount out of range")
+		}
+		if ev.Amount < order.TotalAmount || order.Status == models.StatusRefunded {
+			return nil, "", nil // partial refunds leave the order where it is
+		}
+		to = models.StatusRefunded
+	default:
+		return nil, "", nil
+	}
+	return &to, "webhook " + ev.ID, nil
+}
+
+func writeWebhookResult(w http.ResponseWriter, result string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"result": result})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func postWebhook(h *WebhookHandler, body, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/webhooks/payments", bytes.NewBufferString(body))
+	req.Header.Set(payments.SignatureHeader, payments.SignWebhook(secret, []byte(body), time.Now()))
+	w := httptest.NewRecorder()
+	h.Payments(w, req)
+	return w
+}
+
+func TestPaymentWebhook(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		secret     string
+		seedStatus models.OrderStatus
+		wantStatus int
+		wantOrder  models.OrderStatus
+	}{
+		{
+			name:       "succeeded pays the order",
+			body:       `{"id":"evt_1","type":"payment.succeeded","order_id":1,"amount":5.00,"currency":"USD"}`,
+			wantStatus: http.StatusOK,
+			wantOrder:  models.StatusPaid,
+		},
+		{
+			name:       "bad signature",
+			body:       `{"id":"evt_1","type":"payment.succeeded","order_id":1,"amount":5.00,"currency":"USD"}`,
+			secret:     "wrong",
+			wantStatus: http.StatusUnauthorized,
+			wantOrder:  models.StatusPending,
+		},
+		{
+			name:       "amount mismatch",
+			body:       `{"id":"evt_1","type":"payment.succeeded","order_id":1,"amount":4.99,"currency":"USD"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantOrder:  models.StatusPending,
+		},
+		{
+			name:       "currency mismatch",
+			body:       `{"id":"evt_1","type":"payment.succeeded","order_id":1,"amount":5.00,"currency":"EUR"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantOrder:  models.StatusPending,
+		},
+		{
+			name:       "failed leaves the order pending",
+			body:       `{"id":"evt_1","type":"payment.failed","order_id":1,"amount":5.00,"currency":"USD"}`,
+			wantStatus: http.StatusOK,
+			wantOrder:  models.StatusPending,
+		},
+		{
+			name:       "full refund",
+			body:       `{"id":"evt_1","type":"payment.refunded","order_id":1,"amount":5.00,"currency":"USD"}`,
+			seedStatus: models.StatusPaid,
+			wantStatus: http.StatusOK,
+			wantOrder:  models.StatusRefunded,
+		},
+		{
+			name:       "refund of an unpaid order",
+			body:       `{"id":"evt_1","type":"payment.refunded","order_id":1,"amount":5.00,"currency":"USD"}`,
+			wantStatus: http.StatusConflict,
+			wantOrder:  models.StatusPending,
+		},
+		{
+			name:       "unknown order",
+			body:       `{"id":"evt_1","type":"payment.succeeded","order_id":99,"amount":5.00,"currency":"USD"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantOrder:  models.StatusPending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := store.NewMemory()
+			order := placeOrder(t, st, 42)
+			if tt.seedStatus != "" {
+				st.TransitionOrder(context.Background(), order.ID, tt.seedStatus, nil, "")
+			}
+			secret := tt.secret
+			if secret == "" {
+				secret = "whsec"
+			}
+
+			w := postWebhook(NewWebhookHandler(st, st, "whsec"), tt.body, secret)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", w.Code, tt.wantStatus, w.Body)
+			}
+			got, _ := st.GetOrder(context.Background(), order.ID)
+			if got.Status != tt.wantOrder {
+				t.Errorf("order status = %s; want %s", got.Status, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestPaymentWebhook_Redelivery(t *testing.T) {
+	st := store.NewMemory()
+	order := placeOrder(t, st, 42)
+	h := NewWebhookHandler(st, st, "whsec")
+	body := `{"id":"evt_1","type":"payment.succeeded","order_id":1,"amount":5.00,"currency":"USD"}`
+
+	for i, want := range []string{`{"result":"applied"}`, `{"result":"duplicate"}`} {
+		w := postWebhook(h, body, "whsec")
+		if w.Code != http.StatusOK || w.Body.String() != want+"\n" {
+			t.Fatalf("delivery %d: %d %s; want 200 %s", i+1, w.Code, w.Body, want)
+		}
+	}
+	history, _ := st.OrderStatusHistory(context.Background(), order.ID)
+	if len(history) != 2 {
+		t.Errorf("history has %d entries; want 2 (placed, paid)", len(history))
+	}
+}
@@ -6,20 +6,20 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/payments"
 )
 
 func TestOrderWorkflow(t *testing.T) {
 	// 1) Create a product
 	prodPayload := map[string]interface{}{
-		"name":        "IntegrationProduct",
-		"description": "for orders",
-		"price":       9.99,
-	}
-	buf, _ := json.Marshal(prodPayload)
-	resp, err := http.Post("http://localhost:8080/products", "application/json", bytes.NewReader(buf))
-	if err != nil {
-		t.Fatalf("product create failed: %v", err)
+		"name":           "IntegrationProduct",
+		"description":    "for orders",
+		"price":          9.99,
+		"stock_quantity": 10,
 	}
+	resp := createProduct(t, adminToken(t), prodPayload)
 	if resp.StatusCode != http.StatusCreated {
 		t.Fatalf("product create status = %d; want %d", resp.StatusCode, http.StatusCreated)
 	}
@@ -29,8 +29,8 @@ func TestOrderWorkflow(t *testing.T) {
 
 	// 2) Sign up a user
 	userPayload := map[string]string{"email": "order@int.test", "password": "secret"}
-	buf, _ = json.Marshal(userPayload)
-	resp, err = http.Post("http://localhost:8080/users/signup", "application/json", bytes.NewReader(buf))
+	buf, _ := json.Marshal(userPayload)
+	resp, err := http.Post("http://localhost:8080/users/signup", "application/json", bytes.NewReader(buf))
 	if err != nil {
 		t.Fatalf("signup failed: %v", err)
 	}
@@ -112,7 +112,46 @@ func TestOrderWorkflow(t *testing.T) {
 		t.Fatalf("get order status = %d; want %d", resp.StatusCode, http.StatusOK)
 	}
 
-	// 8) List orders
+	// 8) Pay: a declined card leaves the order pending, a good one pays it
+	for _, tc := range []struct {
+		method string
+		want   int
+	}{
+		{"tok_decline", http.StatusPaymentRequired},
+		{"tok_approve", http.StatusCreated},
+	} {
+		buf, _ = json.Marshal(map[string]string{"payment_method": tc.method})
+		req, _ = http.NewRequest("POST", fmt.Sprintf("http://localhost:8080/orders/%d/pay", orderID), bytes.NewReader(buf))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("pay with %s failed: %v", tc.method, err)
+		}
+		if resp.StatusCode != tc.want {
+			t.Fatalf("pay with %s status = %d; want %d", tc.method, resp.StatusCode, tc.want)
+		}
+	}
+
+	// 9) A signed refund webhook for the full total refunds the order
+	event, _ := json.Marshal(map[string]interface{}{
+		"id":       fmt.Sprintf("evt_int_refund_%d", orderID),
+		"type":     "payment.refunded",
+		"order_id": orderID,
+		"amount":   order["total_amount"],
+		"currency": order["currency"],
+	})
+	req, _ = http.NewRequest("POST", "http://localhost:8080/webhooks/payments", bytes.NewReader(event))
+	req.Header.Set(payments.SignatureHeader, payments.SignWebhook("whsec_local", event, time.Now()))
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("webhook failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("webhook status = %d; want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// 10) List orders
 	req, _ = http.NewRequest("GET", "http://localhost:8080/orders", nil)
 	req.Header.Set("Authorization", "Bearer "+token)
 	resp, err = client.Do(req)
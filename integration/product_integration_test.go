@@ -14,18 +14,49 @@ func decode(t *testing.T, r io.Reader, v interface{}) {
 		t.Fatalf("decode error: %v", err)
 	}
 }
-func TestProductWorkflow(t *testing.T) {
-	// 1) Create
-	payload := map[string]interface{}{
-		"name":        "IntProd",
-		"description": "from integration test",
-		"price":       3.21,
+
+// adminToken logs in as the admin account seeded by
+// scripts/integration_test.sh.
+func adminToken(t *testing.T) string {
+	buf, _ := json.Marshal(map[string]string{"email": "admin@int.test", "password": "secret"})
+	resp, err := http.Post("http://localhost:8080/users/login", "application/json", bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("admin login failed: %v", err)
 	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("admin login status = %d; want %d", resp.StatusCode, http.StatusOK)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	decode(t, resp.Body, &login)
+	return login.Token
+}
+
+// createProduct posts payload to /products as the admin.
+func createProduct(t *testing.T, token string, payload map[string]interface{}) *http.Response {
 	body, _ := json.Marshal(payload)
-	resp, err := http.Post("http://localhost:8080/products", "application/json", bytes.NewReader(body))
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost:8080/products", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("create request failed: %v", err)
 	}
+	return resp
+}
+
+func TestProductWorkflow(t *testing.T) {
+	token := adminToken(t)
+
+	// 1) Create
+	payload := map[string]interface{}{
+		"name":           "IntProd",
+		"description":    "from integration test",
+		"price":          3.21,
+		"stock_quantity": 5,
+	}
+	resp := createProduct(t, token, payload)
 	if resp.StatusCode != http.StatusCreated {
 		t.Fatalf("expected 201 Created, got %d", resp.StatusCode)
 	}
@@ -34,7 +65,7 @@ func TestProductWorkflow(t *testing.T) {
 	id := int(created["id"].(float64))
 
 	// 2) Get
-	resp, err = http.Get(fmt.Sprintf("http://localhost:8080/products/%d", id))
+	resp, err := http.Get(fmt.Sprintf("http://localhost:8080/products/%d", id))
 	if err != nil {
 		t.Fatalf("get request failed: %v", err)
 	}
@@ -44,6 +75,7 @@ func TestProductWorkflow(t *testing.T) {
 
 	// 3) Delete
 	req, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://localhost:8080/products/%d", id), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp, err = http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("delete request failed: %v", err)
@@ -0,0 +1,74 @@
+// Package jobs holds the server's periodic background jobs.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// CartExpiry retires idle carts. Active carts untouched for IdleAfter are
+// marked inactive, which records a cart.abandoned event for user carts
+// with items, and guest carts untouched for GuestTTL are deleted.
+type CartExpiry struct {
+	Store store.CartExpiryStore
+	// IdleAfter is how long an active cart may go unchanged.
+	IdleAfter time.Duration
+	// GuestTTL is how long a guest cart is kept after its last change.
+	GuestTTL time.Duration
+	// Interval is the time between runs.
+	Interval time.Duration
+	// BatchSize caps the carts abandoned per store call.
+	BatchSize int
+	// Now returns the current time; tests replace it.
+	Now func() time.Time
+}
+
+// NewCartExpiry returns a CartExpiry with sensible defaults.
+func NewCartExpiry(s store.CartExpiryStore) *CartExpiry {
+	return &CartExpiry{
+		Store:     s,
+		IdleAfter: 24 * time.Hour,
+		GuestTTL:  7 * 24 * time.Hour,
+		Interval:  10 * time.Minute,
+		BatchSize: 500,
+		Now:       time.Now,
+	}
+}
+
+// Run expires carts every Interval until ctx is cancelled.
+func (j *CartExpiry) Run(ctx context.Context) {
+	for {
+		abandoned, deleted, err := j.RunOnce(ctx)
+		if err != nil {
+			log.Printf("jobs: cart expiry failed: %v", err)
+		} else if abandoned > 0 || deleted > 0 {
+			log.Printf("jobs: %d carts abandoned, %d guest carts deleted", abandoned, deleted)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.Interval):
+		}
+	}
+}
+
+// RunOnce performs one pass, returning how many carts it marked abandoned
+// and how many guest carts it deleted.
+func (j *CartExpiry) RunOnce(ctx context.Context) (abandoned, deleted int, err error) {
+	now := j.Now()
+	for {
+		carts, err := j.Store.AbandonIdleCarts(ctx, now.Add(-j.IdleAfter), j.BatchSize)
+		abandoned += len(carts)
+		if err != nil {
+			return abandoned, 0, err
+		}
+		if len(carts) < j.BatchSize {
+			break
+		}
+	}
+	deleted, err = j.Store.DeleteGuestCarts(ctx, now.Add(-j.GuestTTL))
+	return abandoned, deleted, err
+}
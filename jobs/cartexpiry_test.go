@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func TestCartExpiry(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	p := models.Product{Name: "Mug", Price: 500, Currency: "USD", StockQuantity: 10}
+	st.CreateProduct(ctx, &p)
+	full, _, _ := st.ActiveCart(ctx, 1)
+	st.AddCartItem(ctx, full.ID, p.ID, nil, 2)
+	empty, _, _ := st.ActiveCart(ctx, 2)
+	guest, _ := st.CreateGuestCart(ctx)
+	st.AddCartItem(ctx, guest.ID, p.ID, nil, 1)
+
+	start := time.Now()
+	j := NewCartExpiry(st)
+	j.BatchSize = 1 // exercise batching
+
+	// nothing has been idle long enough yet
+	j.Now = func() time.Time { return start.Add(time.Hour) }
+	if a, d, err := j.RunOnce(ctx); a != 0 || d != 0 || err != nil {
+		t.Fatalf("early RunOnce = %d, %d, %v; want 0, 0, nil", a, d, err)
+	}
+
+	j.Now = func() time.Time { return start.Add(25 * time.Hour) }
+	if a, d, err := j.RunOnce(ctx); a != 3 || d != 0 || err != nil {
+		t.Fatalf("RunOnce = %d, %d, %v; want 3, 0, nil", a, d, err)
+	}
+	for _, id := range []int{full.ID, empty.ID, guest.ID} {
+		if c, _ := st.GetCart(ctx, id); c.Status != models.CartInactive {
+			t.Errorf("cart %d status = %q; want inactive", id, c.Status)
+		}
+	}
+	// only the user's cart with items is worth a reminder
+	evs := st.OutboxEvents()
+	if len(evs) != 1 || evs[0].Type != models.EventCartAbandoned {
+		t.Fatalf("got events %+v; want one cart.abandoned", evs)
+	}
+	var payload models.CartAbandoned
+	json.Unmarshal(evs[0].Payload, &payload)
+	if payload.CartID != full.ID || payload.UserID != 1 || payload.ItemCount != 2 || payload.Subtotal != 1000 {
+		t.Errorf("payload = %+v; want cart %d of user 1 with 2 items worth 10.00", payload, full.ID)
+	}
+	if next, created, _ := st.ActiveCart(ctx, 1); !created || next.ID == full.ID {
+		t.Errorf("user 1 still has cart %d active; want a new one", next.ID)
+	}
+
+	j.Now = func() time.Time { return start.Add(8 * 24 * time.Hour) }
+	if _, d, err := j.RunOnce(ctx); d != 1 || err != nil {
+		t.Fatalf("RunOnce deleted %d, %v; want 1 guest cart", d, err)
+	}
+	if _, err := st.GetCart(ctx, guest.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("guest cart err = %v; want ErrNotFound", err)
+	}
+}
@@ -1,69 +1,235 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 
+	"github.com/Heisenberg270/ecommerce-go/events"
 	"github.com/Heisenberg270/ecommerce-go/handlers"
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/store"
+	"github.com/Heisenberg270/ecommerce-go/webhooks"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 )
 
 func main() {
+	// Subcommands: `server migrate ...`, `server admin ...`, `server webhook ...`
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "admin":
+			runAdmin(os.Args[2:])
+			return
+		case "webhook":
+			runWebhook(os.Args[2:])
+			return
+		}
+	}
+
 	initDB()
+	st := store.NewPostgres(db)
+
+	// Deliver outbox events in the background
+	dispatcher := events.NewDispatcher(st)
+	dispatcher.Subscribe(events.AllEvents, func(_ context.Context, ev models.OutboxEvent) error {
+		log.Printf("event %d %s %s", ev.ID, ev.Type, ev.Payload)
+		return nil
+	})
+	dispatcher.Subscribe(events.AllEvents, webhooks.NewNotifier(st).Deliver)
+	go dispatcher.Run(context.Background())
+
+	// Retire idle carts in the background
+	expiry, err := newCartExpiry(st)
+	if err != nil {
+		log.Fatalf("Failed to configure cart expiry: %v", err)
+	}
+	go expiry.Run(context.Background())
+
+	provider, err := newPaymentProvider()
+	if err != nil {
+		log.Fatalf("Failed to configure payments: %v", err)
+	}
+	taxCalc, taxRegion, err := newTaxCalculator()
+	if err != nil {
+		log.Fatalf("Failed to configure tax: %v", err)
+	}
+	rates, err := newShippingRates()
+	if err != nil {
+		log.Fatalf("Failed to configure shipping: %v", err)
+	}
 
 	r := chi.NewRouter()
 	// CORS — allow your frontend dev server to talk to us
 	r.Use(cors.Handler(cors.Options{
 		// put your actual domains here in production
 		AllowedOrigins:   []string{"http://localhost:5173"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", handlers.CartTokenHeader},
+		ExposedHeaders:   []string{"Link", handlers.CartTokenHeader},
 		AllowCredentials: false,
 		MaxAge:           300, // 5 minutes
 	}))
 
 	// Auth routes
 	jwtSecret := os.Getenv("JWT_SECRET")
-	ah := handlers.NewAuthHandler(db, jwtSecret)
+	auth := handlers.AuthMiddleware(jwtSecret, st)
+	ah := handlers.NewAuthHandler(st, st, st, jwtSecret)
 	r.Post("/users/signup", ah.Signup)
 	r.Post("/users/login", ah.Login)
+	r.Post("/users/refresh", ah.Refresh)
+	r.With(auth).Post("/users/logout", ah.Logout)
+
+	// Address book
+	adh := handlers.NewAddressHandler(st)
+	r.Route("/users/me/addresses", func(r chi.Router) {
+		r.Use(auth)
+		r.Post("/", adh.Create)
+		r.Get("/", adh.List)
+		r.Get("/{addressID}", adh.Get)
+		r.Put("/{addressID}", adh.Update)
+		r.Delete("/{addressID}", adh.Delete)
+	})
+
+	// Payment gateway webhooks, authenticated by signature rather than JWT
+	if secret := os.Getenv("PAYMENT_WEBHOOK_SECRET"); secret != "" {
+		wh := handlers.NewWebhookHandler(st, st, secret)
+		r.Post("/webhooks/payments", wh.Payments)
+	} else {
+		log.Println("PAYMENT_WEBHOOK_SECRET not set; payment webhooks disabled")
+	}
+
+	// Outbound webhook subscriptions (admin only)
+	sh := handlers.NewSubscriptionHandler(st)
+	r.Route("/webhooks/subscriptions", func(r chi.Router) {
+		r.Use(auth)
+		r.Use(handlers.RequireRole(models.RoleAdmin))
+		r.Post("/", sh.Create)
+		r.Get("/", sh.List)
+		r.Get("/{subID}", sh.Get)
+		r.Put("/{subID}", sh.Update)
+		r.Delete("/{subID}", sh.Delete)
+		r.Get("/{subID}/deliveries", sh.Deliveries)
+	})
 
 	// Health check
 	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.Write([]byte("OK"))
 	})
 
-	// Product routes
-	ph := handlers.NewProductHandler(db)
+	// Product routes: anyone may browse, only staff may edit the catalog
+	ph := handlers.NewProductHandler(st, st)
+	cth := handlers.NewCategoryHandler(st, st)
+	vh := handlers.NewVariantHandler(st, st)
 	r.Route("/products", func(r chi.Router) {
-		r.Post("/", ph.Create)
 		r.Get("/", ph.List)
 		r.Get("/{id}", ph.Get)
-		r.Put("/{id}", ph.Update)
-		r.Delete("/{id}", ph.Delete)
+		r.Get("/{id}/categories", cth.ProductCategories)
+		r.Get("/{id}/variants", vh.ListVariants)
+		r.Group(func(r chi.Router) {
+			r.Use(auth)
+			r.Use(handlers.RequireRole(models.RoleAdmin, models.RoleStaff))
+			r.Post("/", ph.Create)
+			r.Put("/{id}", ph.Update)
+			r.Delete("/{id}", ph.Delete)
+			r.Put("/{id}/categories", cth.SetProductCategories)
+			r.Put("/{id}/options", vh.SetOptions)
+			r.Post("/{id}/variants", vh.CreateVariant)
+			r.Put("/{id}/variants/{variantID}", vh.UpdateVariant)
+			r.Delete("/{id}/variants/{variantID}", vh.DeleteVariant)
+		})
 	})
 
-	// Cart routes (protected)
-	// Protected routes: Carts & Orders
+	// Category routes: anyone may browse the tree, only staff may edit it
+	r.Route("/categories", func(r chi.Router) {
+		r.Get("/", cth.Tree)
+		r.Get("/{categoryID}", cth.Get)
+		r.Group(func(r chi.Router) {
+			r.Use(auth)
+			r.Use(handlers.RequireRole(models.RoleAdmin, models.RoleStaff))
+			r.Post("/", cth.Create)
+			r.Put("/{categoryID}", cth.Update)
+			r.Delete("/{categoryID}", cth.Delete)
+		})
+	})
+
+	// Promotion routes: staff manage coupon codes
+	prh := handlers.NewPromotionHandler(st)
+	r.Route("/promotions", func(r chi.Router) {
+		r.Use(auth)
+		r.Use(handlers.RequireRole(models.RoleAdmin, models.RoleStaff))
+		r.Post("/", prh.Create)
+		r.Get("/", prh.List)
+		r.Get("/{promoID}", prh.Get)
+		r.Put("/{promoID}", prh.Update)
+		r.Delete("/{promoID}", prh.Delete)
+	})
+
+	// Cart routes: open to guests, who are identified by a cart token
 	r.Group(func(r chi.Router) {
-		r.Use(handlers.AuthMiddleware(jwtSecret))
+		r.Use(handlers.OptionalAuth(jwtSecret, st))
 
-		// Cart
-		ch := handlers.NewCartHandler(db)
+		ch := handlers.NewCartHandler(st, st, st, st, st, jwtSecret)
+		ch.Shipping = rates
+		ch.Tax, ch.TaxRegion = taxCalc, taxRegion
 		r.Post("/carts", ch.CreateCart)
+		r.Get("/carts/current", ch.CurrentCart)
 		r.Route("/carts/{cartID}", func(r chi.Router) {
 			r.Post("/items", ch.AddItem)
 			r.Get("/", ch.GetCart)
+			r.Delete("/", ch.EmptyCart)
+			r.Post("/accept-prices", ch.AcceptPrices)
+			r.Post("/coupon", ch.ApplyCoupon)
+			r.Delete("/coupon", ch.RemoveCoupon)
+			r.Put("/shipping-method", ch.SetShippingMethod)
+			r.Get("/shipping-rates", ch.ShippingRates)
+			r.Put("/items/{productID}", ch.SetItem)
 			r.Delete("/items/{productID}", ch.RemoveItem)
 		})
+	})
+
+	// Protected routes: Orders, Payments, Refunds & Returns
+	r.Group(func(r chi.Router) {
+		r.Use(auth)
+
 		// Orders
-		oh := handlers.NewOrderHandler(db)
+		oh := handlers.NewOrderHandler(st, st, st, st)
+		oh.Tax, oh.TaxRegion = taxCalc, taxRegion
+		oh.Shipping = rates
 		r.Post("/orders", oh.CreateOrder)
 		r.Get("/orders", oh.ListOrders)
 		r.Get("/orders/{orderID}", oh.GetOrder)
+		r.With(handlers.RequireRole(models.RoleAdmin, models.RoleStaff)).
+			Patch("/orders/{orderID}/status", oh.UpdateStatus)
+		// Payments
+		pay := handlers.NewPaymentHandler(st, st, provider)
+		r.Post("/orders/{orderID}/pay", pay.Pay)
+		r.Get("/orders/{orderID}/payments", pay.ListPayments)
+		// Cancellation and refunds
+		rh := handlers.NewRefundHandler(st, st, st, provider)
+		r.Post("/orders/{orderID}/cancel", rh.Cancel)
+		r.Get("/orders/{orderID}/refunds", rh.ListRefunds)
+		r.With(handlers.RequireRole(models.RoleAdmin, models.RoleStaff)).
+			Post("/orders/{orderID}/refunds", rh.Refund)
+		r.With(handlers.RequireRole(models.RoleAdmin, models.RoleStaff)).
+			Post("/orders/{orderID}/refunds/{refundID}/retry", rh.Retry)
+		// Returns: customers open them, staff decide and receive them
+		rth := handlers.NewReturnHandler(st, st, rh)
+		r.Route("/orders/{orderID}/returns", func(r chi.Router) {
+			r.Post("/", rth.Create)
+			r.Get("/", rth.List)
+			r.Get("/{returnID}", rth.Get)
+			r.Group(func(r chi.Router) {
+				r.Use(handlers.RequireRole(models.RoleAdmin, models.RoleStaff))
+				r.Post("/{returnID}/approve", rth.Approve)
+				r.Post("/{returnID}/reject", rth.Reject)
+				r.Post("/{returnID}/receive", rth.Receive)
+			})
+		})
 	})
 
 	log.Println("Starting server on :8080")
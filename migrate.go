@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/Heisenberg270/ecommerce-go/migrations"
+)
+
+// runMigrate implements `server migrate up|down [steps]|status`.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: server migrate up|down [steps]|status")
+		os.Exit(2)
+	}
+
+	connectDB()
+	m, err := migrations.New(db)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		ran, err := m.Up(ctx)
+		if err != nil {
+			log.Fatalf("migrate up: %v", err)
+		}
+		for _, mig := range ran {
+			fmt.Printf("applied %04d_%s\n", mig.Version, mig.Name)
+		}
+		if len(ran) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("migrate down: invalid step count %q", args[1])
+			}
+		}
+		ran, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatalf("migrate down: %v", err)
+		}
+		for _, mig := range ran {
+			fmt.Printf("reverted %04d_%s\n", mig.Version, mig.Name)
+		}
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Fatalf("migrate status: %v", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		os.Exit(2)
+	}
+}
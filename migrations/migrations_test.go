@@ -0,0 +1,152 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"0002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"0001_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+		"0001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"README.md":            {Data: []byte("ignored")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got) != 2 || got[0].Version != 1 || got[1].Version != 2 {
+		t.Fatalf("got %+v; want versions 1, 2", got)
+	}
+	if got[1].Name != "second" || got[1].Down != "DROP TABLE b;" {
+		t.Errorf("got %+v; want second with down file", got[1])
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{"0001_first.up.sql": {Data: []byte("x")}},
+		},
+		{
+			name: "bad name",
+			fsys: fstest.MapFS{"first.up.sql": {Data: []byte("x")}},
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"0001_a.up.sql":   {Data: []byte("x")},
+				"0001_a.down.sql": {Data: []byte("x")},
+				"0001_b.up.sql":   {Data: []byte("x")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fsys); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestAll_Embedded(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d; versions must be contiguous", i, m.Version)
+		}
+	}
+}
+
+func TestUp_AppliesPendingOnly(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	m := &Migrator{
+		DB: sqlx.NewDb(sqlDB, "sqlmock"),
+		Migrations: []Migration{
+			{Version: 1, Name: "first", Up: "CREATE TABLE a ()", Down: "DROP TABLE a"},
+			{Version: 2, Name: "second", Up: "CREATE TABLE b ()", Down: "DROP TABLE b"},
+		},
+	}
+
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(2, "second").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WithArgs(lockKey).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ran, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(ran) != 1 || ran[0].Version != 2 {
+		t.Errorf("ran %+v; want only version 2", ran)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestDown_RevertsNewestFirst(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	m := &Migrator{
+		DB: sqlx.NewDb(sqlDB, "sqlmock"),
+		Migrations: []Migration{
+			{Version: 1, Name: "first", Up: "CREATE TABLE a ()", Down: "DROP TABLE a"},
+			{Version: 2, Name: "second", Up: "CREATE TABLE b ()", Down: "DROP TABLE b"},
+		},
+	}
+
+	now := time.Now()
+	mock.ExpectExec(`SELECT pg_advisory_lock`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT version, applied_at FROM schema_migrations`).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).
+			AddRow(1, now).AddRow(2, now))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DROP TABLE b`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations`).WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(`SELECT pg_advisory_unlock`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ran, err := m.Down(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if len(ran) != 1 || ran[0].Version != 2 {
+		t.Errorf("ran %+v; want only version 2", ran)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+-- Baseline schema. Uses IF NOT EXISTS so databases created by the old
+-- inline initDB schema are adopted without changes.
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT,
+	price NUMERIC(10,2) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	email TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS carts (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id),
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+	cart_id INT NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+	product_id INT NOT NULL REFERENCES products(id),
+	quantity INT NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (cart_id, product_id)
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id),
+	total_amount NUMERIC(10,2) NOT NULL,
+	status TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS order_items (
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	product_id INT NOT NULL REFERENCES products(id),
+	quantity INT NOT NULL CHECK (quantity > 0),
+	unit_price NUMERIC(10,2) NOT NULL,
+	PRIMARY KEY (order_id, product_id)
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS orders_user_idempotency_key;
+ALTER TABLE orders DROP COLUMN idempotency_key;
@@ -0,0 +1,5 @@
+ALTER TABLE orders ADD COLUMN idempotency_key TEXT;
+
+CREATE UNIQUE INDEX orders_user_idempotency_key
+	ON orders (user_id, idempotency_key)
+	WHERE idempotency_key IS NOT NULL;
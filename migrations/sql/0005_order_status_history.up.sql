@@ -0,0 +1,15 @@
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+	'pending', 'paid', 'fulfilled', 'shipped', 'delivered', 'cancelled', 'refunded'
+));
+
+CREATE TABLE order_status_history (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	from_status TEXT,
+	to_status TEXT NOT NULL,
+	changed_by INT REFERENCES users(id),
+	note TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX order_status_history_order_id ON order_status_history (order_id);
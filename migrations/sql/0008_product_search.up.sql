@@ -0,0 +1,8 @@
+-- Backs full-text search on GET /products. The expression must match
+-- productSearchVector in store/postgres_product.go for the index to be used.
+CREATE INDEX products_search ON products
+	USING GIN (to_tsvector('english', name || ' ' || coalesce(description, '')));
+
+CREATE INDEX products_price_id ON products (price, id);
+CREATE INDEX products_name_id ON products (name, id);
+CREATE INDEX products_created_at_id ON products (created_at, id);
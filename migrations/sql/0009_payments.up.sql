@@ -0,0 +1,20 @@
+CREATE TABLE payments (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	provider TEXT NOT NULL,
+	provider_ref TEXT NOT NULL DEFAULT '',
+	amount NUMERIC(10,2) NOT NULL,
+	currency CHAR(3) NOT NULL,
+	status TEXT NOT NULL CHECK (status IN (
+		'pending', 'authorized', 'captured', 'declined', 'failed', 'voided', 'refunded'
+	)),
+	failure_reason TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX payments_order_id ON payments (order_id);
+
+-- at most one payment attempt per order may be in flight or succeeded
+CREATE UNIQUE INDEX payments_order_active ON payments (order_id)
+	WHERE status IN ('pending', 'authorized', 'captured');
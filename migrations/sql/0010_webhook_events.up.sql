@@ -0,0 +1,9 @@
+-- Inbound webhook deliveries, keyed by the sender's event ID so that
+-- retried deliveries are applied only once.
+CREATE TABLE webhook_events (
+	id TEXT PRIMARY KEY,
+	type TEXT NOT NULL,
+	order_id INT REFERENCES orders(id) ON DELETE SET NULL,
+	payload JSONB NOT NULL,
+	received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
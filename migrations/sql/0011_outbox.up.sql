@@ -0,0 +1,14 @@
+-- Domain events written in the same transaction as the change they
+-- describe, and delivered to subscribers by the dispatcher afterwards.
+CREATE TABLE outbox (
+	id BIGSERIAL PRIMARY KEY,
+	type TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	available_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX outbox_unpublished ON outbox (available_at, id) WHERE published_at IS NULL;
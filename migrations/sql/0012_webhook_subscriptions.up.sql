@@ -0,0 +1,27 @@
+CREATE TABLE webhook_subscriptions (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	event_types TEXT[] NOT NULL,
+	secret TEXT NOT NULL,
+	active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- One row per attempt to deliver an outbox event to a subscription.
+CREATE TABLE webhook_deliveries (
+	id SERIAL PRIMARY KEY,
+	subscription_id INT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+	event_id BIGINT NOT NULL REFERENCES outbox(id),
+	event_type TEXT NOT NULL,
+	attempt INT NOT NULL,
+	status_code INT,
+	error TEXT NOT NULL DEFAULT '',
+	succeeded BOOLEAN NOT NULL,
+	duration_ms INT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+CREATE INDEX webhook_deliveries_succeeded ON webhook_deliveries (subscription_id, event_id)
+	WHERE succeeded;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS carts_user_active;
+ALTER TABLE carts DROP COLUMN IF EXISTS status;
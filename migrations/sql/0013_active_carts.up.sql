@@ -0,0 +1,9 @@
+ALTER TABLE carts ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+	CHECK (status IN ('active', 'checked_out', 'inactive'));
+
+-- keep only each user's newest cart active before enforcing uniqueness
+UPDATE carts SET status = 'inactive'
+WHERE id NOT IN (SELECT max(id) FROM carts GROUP BY user_id);
+
+-- a user has at most one active cart
+CREATE UNIQUE INDEX carts_user_active ON carts (user_id) WHERE status = 'active';
@@ -0,0 +1,9 @@
+DELETE FROM carts WHERE user_id IS NULL;
+UPDATE carts SET status = 'inactive' WHERE status = 'merged';
+
+ALTER TABLE carts DROP CONSTRAINT carts_status_check;
+ALTER TABLE carts ADD CONSTRAINT carts_status_check CHECK (status IN (
+	'active', 'checked_out', 'inactive'
+));
+
+ALTER TABLE carts ALTER COLUMN user_id SET NOT NULL;
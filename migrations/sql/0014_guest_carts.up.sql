@@ -0,0 +1,7 @@
+-- guest carts have no user until they are merged on login
+ALTER TABLE carts ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE carts DROP CONSTRAINT carts_status_check;
+ALTER TABLE carts ADD CONSTRAINT carts_status_check CHECK (status IN (
+	'active', 'checked_out', 'inactive', 'merged'
+));
@@ -0,0 +1,7 @@
+-- the unit price the customer saw when they last changed the line
+ALTER TABLE cart_items ADD COLUMN added_price NUMERIC(10,2);
+
+UPDATE cart_items ci SET added_price = p.price
+FROM products p WHERE p.id = ci.product_id;
+
+ALTER TABLE cart_items ALTER COLUMN added_price SET NOT NULL;
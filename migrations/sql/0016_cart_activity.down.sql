@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS carts_guest_updated_at;
+DROP INDEX IF EXISTS carts_active_updated_at;
+ALTER TABLE carts DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,6 @@
+ALTER TABLE carts ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+UPDATE carts SET updated_at = created_at WHERE created_at IS NOT NULL;
+
+-- the expiry job looks for idle active carts and idle guest carts
+CREATE INDEX carts_active_updated_at ON carts (updated_at) WHERE status = 'active';
+CREATE INDEX carts_guest_updated_at ON carts (updated_at) WHERE user_id IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_discounts;
+ALTER TABLE carts DROP COLUMN IF EXISTS promotion_id;
+DROP TABLE IF EXISTS promotions;
@@ -0,0 +1,37 @@
+-- codes are stored upper case; customers may type them in any case
+CREATE TABLE promotions (
+	id SERIAL PRIMARY KEY,
+	code TEXT NOT NULL UNIQUE,
+	kind TEXT NOT NULL CHECK (kind IN ('percentage', 'fixed', 'buy_x_get_y')),
+	percent_off INT NOT NULL DEFAULT 0 CHECK (percent_off BETWEEN 0 AND 100),
+	amount_off NUMERIC(10,2) NOT NULL DEFAULT 0,
+	buy_quantity INT NOT NULL DEFAULT 0,
+	get_quantity INT NOT NULL DEFAULT 0,
+	currency CHAR(3) NOT NULL DEFAULT '',
+	min_spend NUMERIC(10,2) NOT NULL DEFAULT 0,
+	max_uses INT,
+	max_uses_per_user INT,
+	product_ids INT[] NOT NULL DEFAULT '{}',
+	-- a promotion scoped to categories also covers their subcategories
+	category_ids INT[] NOT NULL DEFAULT '{}',
+	starts_at TIMESTAMP WITH TIME ZONE,
+	ends_at TIMESTAMP WITH TIME ZONE,
+	active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+ALTER TABLE carts ADD COLUMN promotion_id INT REFERENCES promotions(id) ON DELETE SET NULL;
+
+-- the discount an order was placed with, frozen at checkout
+CREATE TABLE order_discounts (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	promotion_id INT REFERENCES promotions(id) ON DELETE SET NULL,
+	code TEXT NOT NULL,
+	amount NUMERIC(10,2) NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX order_discounts_order_id ON order_discounts (order_id);
+CREATE INDEX order_discounts_promotion_id ON order_discounts (promotion_id);
@@ -0,0 +1,4 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_inclusive;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_amount;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_class;
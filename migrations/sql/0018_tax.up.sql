@@ -0,0 +1,5 @@
+ALTER TABLE products ADD COLUMN tax_class TEXT NOT NULL DEFAULT 'standard';
+
+ALTER TABLE orders ADD COLUMN tax_amount NUMERIC(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN tax_inclusive BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE order_items ADD COLUMN tax_amount NUMERIC(10,2) NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS order_shipping;
+ALTER TABLE orders DROP COLUMN IF EXISTS shipping_amount;
+ALTER TABLE carts DROP COLUMN IF EXISTS shipping_method;
+DROP TABLE IF EXISTS addresses;
+ALTER TABLE products DROP COLUMN IF EXISTS weight_grams;
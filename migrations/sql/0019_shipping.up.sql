@@ -0,0 +1,39 @@
+ALTER TABLE products ADD COLUMN weight_grams INT NOT NULL DEFAULT 0 CHECK (weight_grams >= 0);
+
+CREATE TABLE addresses (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	line1 TEXT NOT NULL,
+	line2 TEXT NOT NULL DEFAULT '',
+	city TEXT NOT NULL,
+	region TEXT NOT NULL DEFAULT '',
+	postal_code TEXT NOT NULL DEFAULT '',
+	country CHAR(2) NOT NULL,
+	phone TEXT NOT NULL DEFAULT '',
+	is_default BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX addresses_user_id ON addresses (user_id);
+CREATE UNIQUE INDEX addresses_user_default ON addresses (user_id) WHERE is_default;
+
+ALTER TABLE carts ADD COLUMN shipping_method TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE orders ADD COLUMN shipping_amount NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+-- where an order ships, copied from the address book at checkout
+CREATE TABLE order_shipping (
+	order_id INT PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+	method TEXT NOT NULL,
+	name TEXT NOT NULL,
+	line1 TEXT NOT NULL,
+	line2 TEXT NOT NULL DEFAULT '',
+	city TEXT NOT NULL,
+	region TEXT NOT NULL DEFAULT '',
+	postal_code TEXT NOT NULL DEFAULT '',
+	country CHAR(2) NOT NULL,
+	phone TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
@@ -0,0 +1,22 @@
+-- Money returned for an order. Line refunds name the product and how many
+-- of its units are refunded; refunds of the whole order, made when a paid
+-- order is cancelled, leave both NULL.
+CREATE TABLE refunds (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	payment_id INT NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+	product_id INT,
+	quantity INT CHECK (quantity > 0),
+	amount NUMERIC(10,2) NOT NULL CHECK (amount >= 0),
+	currency CHAR(3) NOT NULL,
+	reason TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'failed')),
+	failure_reason TEXT NOT NULL DEFAULT '',
+	created_by INT REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	CHECK ((product_id IS NULL) = (quantity IS NULL))
+);
+
+CREATE INDEX refunds_order_id ON refunds (order_id);
+CREATE INDEX refunds_payment_id ON refunds (payment_id);
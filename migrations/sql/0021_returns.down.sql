@@ -0,0 +1,3 @@
+ALTER TABLE refunds DROP COLUMN IF EXISTS return_id;
+DROP TABLE IF EXISTS return_items;
+DROP TABLE IF EXISTS returns;
@@ -0,0 +1,25 @@
+-- Return requests (RMAs) customers open against the items of an order.
+CREATE TABLE returns (
+	id SERIAL PRIMARY KEY,
+	order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	status TEXT NOT NULL CHECK (status IN ('requested', 'approved', 'rejected', 'received')),
+	reason TEXT NOT NULL,
+	staff_note TEXT NOT NULL DEFAULT '',
+	restocked BOOLEAN NOT NULL DEFAULT false,
+	handled_by INT REFERENCES users(id) ON DELETE SET NULL,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX returns_order_id ON returns (order_id);
+
+CREATE TABLE return_items (
+	return_id INT NOT NULL REFERENCES returns(id) ON DELETE CASCADE,
+	product_id INT NOT NULL,
+	quantity INT NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (return_id, product_id)
+);
+
+-- refunds issued when a return is approved point back at it
+ALTER TABLE refunds ADD COLUMN return_id INT REFERENCES returns(id) ON DELETE SET NULL;
@@ -0,0 +1,20 @@
+-- Product categories form a tree through parent_id. A category with
+-- subcategories cannot be deleted until they are moved or deleted.
+CREATE TABLE categories (
+	id SERIAL PRIMARY KEY,
+	parent_id INT REFERENCES categories(id) ON DELETE RESTRICT,
+	name TEXT NOT NULL,
+	slug TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX categories_parent_id ON categories (parent_id);
+
+CREATE TABLE product_categories (
+	product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+	PRIMARY KEY (product_id, category_id)
+);
+
+CREATE INDEX product_categories_category_id ON product_categories (category_id);
@@ -0,0 +1,21 @@
+-- Lines for variants cannot be represented once lines are keyed by
+-- product alone, so they are dropped.
+DELETE FROM return_items WHERE variant_id IS NOT NULL;
+DROP INDEX IF EXISTS return_items_line;
+ALTER TABLE return_items DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE return_items ADD PRIMARY KEY (return_id, product_id);
+
+ALTER TABLE refunds DROP COLUMN IF EXISTS variant_id;
+
+DELETE FROM order_items WHERE variant_id IS NOT NULL;
+DROP INDEX IF EXISTS order_items_line;
+ALTER TABLE order_items DROP COLUMN IF EXISTS variant_id, DROP COLUMN IF EXISTS sku;
+ALTER TABLE order_items ADD PRIMARY KEY (order_id, product_id);
+
+DELETE FROM cart_items WHERE variant_id IS NOT NULL;
+DROP INDEX IF EXISTS cart_items_line;
+ALTER TABLE cart_items DROP COLUMN IF EXISTS variant_id;
+ALTER TABLE cart_items ADD PRIMARY KEY (cart_id, product_id);
+
+DROP TABLE IF EXISTS product_variants;
+DROP TABLE IF EXISTS product_options;
@@ -0,0 +1,45 @@
+-- Options are the ways a product varies, such as size or colour, each
+-- with the values it may take, in display order.
+CREATE TABLE product_options (
+	product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	position INT NOT NULL,
+	name TEXT NOT NULL,
+	option_values TEXT[] NOT NULL,
+	PRIMARY KEY (product_id, name)
+);
+
+-- Variants are the purchasable combinations of a product's options. Each
+-- has its own SKU, price and stock; attributes maps option names to values.
+CREATE TABLE product_variants (
+	id SERIAL PRIMARY KEY,
+	product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	sku TEXT NOT NULL UNIQUE,
+	price NUMERIC(10,2) NOT NULL CHECK (price >= 0),
+	stock_quantity INT NOT NULL DEFAULT 0 CHECK (stock_quantity >= 0),
+	attributes JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX product_variants_attributes ON product_variants (product_id, attributes);
+
+-- Lines are now identified by product and variant. Lines of products
+-- without variants have a NULL variant_id, which the unique indexes fold
+-- to 0 so that such a product still gets one line.
+ALTER TABLE cart_items ADD COLUMN variant_id INT REFERENCES product_variants(id) ON DELETE CASCADE;
+ALTER TABLE cart_items DROP CONSTRAINT cart_items_pkey;
+CREATE UNIQUE INDEX cart_items_line ON cart_items (cart_id, product_id, COALESCE(variant_id, 0));
+
+-- order lines keep the SKU they were sold under; ordered variants cannot
+-- be deleted
+ALTER TABLE order_items
+	ADD COLUMN variant_id INT REFERENCES product_variants(id),
+	ADD COLUMN sku TEXT NOT NULL DEFAULT '';
+ALTER TABLE order_items DROP CONSTRAINT order_items_pkey;
+CREATE UNIQUE INDEX order_items_line ON order_items (order_id, product_id, COALESCE(variant_id, 0));
+
+ALTER TABLE refunds ADD COLUMN variant_id INT;
+
+ALTER TABLE return_items ADD COLUMN variant_id INT;
+ALTER TABLE return_items DROP CONSTRAINT return_items_pkey;
+CREATE UNIQUE INDEX return_items_line ON return_items (return_id, product_id, COALESCE(variant_id, 0));
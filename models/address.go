@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Address is an entry in a user's address book. Country is an ISO 3166-1
+// alpha-2 code and Region the state or province within it, if any. Each
+// user has at most one default address.
+type Address struct {
+	ID         int       `db:"id" json:"id"`
+	UserID     int       `db:"user_id" json:"user_id"`
+	Name       string    `db:"name" json:"name"`
+	Line1      string    `db:"line1" json:"line1"`
+	Line2      string    `db:"line2" json:"line2,omitempty"`
+	City       string    `db:"city" json:"city"`
+	Region     string    `db:"region" json:"region,omitempty"`
+	PostalCode string    `db:"postal_code" json:"postal_code,omitempty"`
+	Country    string    `db:"country" json:"country"`
+	Phone      string    `db:"phone" json:"phone,omitempty"`
+	IsDefault  bool      `db:"is_default" json:"is_default"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// TaxRegion returns the address's region in the form tax rates are keyed
+// by, such as "US-CA", or just the country when it has no region.
+func (a Address) TaxRegion() string {
+	if a.Region == "" {
+		return a.Country
+	}
+	return a.Country + "-" + a.Region
+}
+
+// OrderShipping freezes where an order is going and how. The address is
+// copied rather than referenced so later edits to the address book do not
+// change past orders.
+type OrderShipping struct {
+	OrderID    int       `db:"order_id" json:"order_id"`
+	Method     string    `db:"method" json:"method"`
+	Name       string    `db:"name" json:"name"`
+	Line1      string    `db:"line1" json:"line1"`
+	Line2      string    `db:"line2" json:"line2,omitempty"`
+	City       string    `db:"city" json:"city"`
+	Region     string    `db:"region" json:"region,omitempty"`
+	PostalCode string    `db:"postal_code" json:"postal_code,omitempty"`
+	Country    string    `db:"country" json:"country"`
+	Phone      string    `db:"phone" json:"phone,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// NewOrderShipping snapshots a for an order shipped by method.
+func NewOrderShipping(a Address, method string) OrderShipping {
+	return OrderShipping{
+		Method: method, Name: a.Name, Line1: a.Line1, Line2: a.Line2, City: a.City,
+		Region: a.Region, PostalCode: a.PostalCode, Country: a.Country, Phone: a.Phone,
+	}
+}
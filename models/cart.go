@@ -2,16 +2,90 @@ package models
 
 import "time"
 
-// Cart represents a user’s shopping cart.
+// CartStatus is the lifecycle state of a cart.
+type CartStatus string
+
+// Cart statuses. A user has at most one active cart; placing an order
+// checks it out, and carts superseded without an order become inactive. A
+// guest cart whose items moved into a user's cart on login is merged.
+const (
+	CartActive     CartStatus = "active"
+	CartCheckedOut CartStatus = "checked_out"
+	CartInactive   CartStatus = "inactive"
+	CartMerged     CartStatus = "merged"
+)
+
+// Cart represents a user’s shopping cart. Guest carts have no UserID.
+// UpdatedAt moves whenever the cart's items change. PromotionID is the
+// coupon applied to the cart, if any, and ShippingMethod the delivery
+// option the customer chose.
 type Cart struct {
-	ID        int       `db:"id" json:"id"`
-	UserID    int       `db:"user_id" json:"user_id"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ID             int        `db:"id" json:"id"`
+	UserID         *int       `db:"user_id" json:"user_id"`
+	Status         CartStatus `db:"status" json:"status"`
+	PromotionID    *int       `db:"promotion_id" json:"promotion_id"`
+	ShippingMethod string     `db:"shipping_method" json:"shipping_method,omitempty"`
+	CreatedAt      time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at" json:"updated_at"`
 }
 
-// CartItem is a line item in a cart.
+// OwnedBy reports whether the cart belongs to the given user.
+func (c Cart) OwnedBy(userID int) bool {
+	return c.UserID != nil && *c.UserID == userID
+}
+
+// CartItem is a line item in a cart. VariantID is set for products sold in
+// variants. AddedPrice is the product's or variant's price when the
+// customer last added or changed the line.
 type CartItem struct {
-	CartID    int `db:"cart_id" json:"cart_id"`
-	ProductID int `db:"product_id" json:"product_id"`
-	Quantity  int `db:"quantity" json:"quantity"`
+	CartID     int   `db:"cart_id" json:"cart_id"`
+	ProductID  int   `db:"product_id" json:"product_id"`
+	VariantID  *int  `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity   int   `db:"quantity" json:"quantity"`
+	AddedPrice Money `db:"added_price" json:"added_price"`
+}
+
+// CartLine is a cart item joined with its product's current details. For
+// a variant, UnitPrice is the variant's price and SKU its SKU. LineTotal
+// and PriceChanged are filled in by TotalCart.
+type CartLine struct {
+	CartItem
+	ProductName  string `db:"name" json:"product_name"`
+	SKU          string `db:"sku" json:"sku,omitempty"`
+	UnitPrice    Money  `db:"price" json:"unit_price"`
+	Currency     string `db:"currency" json:"currency"`
+	TaxClass     string `db:"tax_class" json:"tax_class"`
+	WeightGrams  int    `db:"weight_grams" json:"weight_grams"`
+	LineTotal    Money  `db:"-" json:"line_total"`
+	PriceChanged bool   `db:"-" json:"price_changed"`
+}
+
+// CartTotals summarises what a cart costs at current prices. Shipping is
+// the price of the cart's shipping method, once it has one and knows
+// where it is going. Tax is only added to Total when TaxInclusive is
+// false; otherwise it is already part of the prices.
+type CartTotals struct {
+	Subtotal     Money  `json:"subtotal"`
+	Discount     Money  `json:"discount"`
+	Shipping     Money  `json:"shipping"`
+	Tax          Money  `json:"tax"`
+	TaxInclusive bool   `json:"tax_inclusive"`
+	Total        Money  `json:"total"`
+	Currency     string `json:"currency,omitempty"`
+}
+
+// TotalCart fills in each line's total and whether its price moved since
+// it was added, and returns the cart's totals. The lines must share a
+// currency.
+func TotalCart(lines []CartLine) CartTotals {
+	var t CartTotals
+	for i := range lines {
+		l := &lines[i]
+		l.LineTotal = l.UnitPrice.Mul(l.Quantity)
+		l.PriceChanged = l.UnitPrice != l.AddedPrice
+		t.Subtotal += l.LineTotal
+		t.Currency = l.Currency
+	}
+	t.Total = t.Subtotal - t.Discount + t.Tax
+	return t
 }
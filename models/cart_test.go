@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestTotalCart(t *testing.T) {
+	lines := []CartLine{
+		{CartItem: CartItem{Quantity: 2, AddedPrice: 500}, UnitPrice: 500, Currency: "USD"},
+		{CartItem: CartItem{Quantity: 3, AddedPrice: 250}, UnitPrice: 199, Currency: "USD"},
+	}
+	totals := TotalCart(lines)
+
+	if lines[0].LineTotal != 1000 || lines[0].PriceChanged {
+		t.Errorf("line 0 = %+v; want total 10.00, unchanged", lines[0])
+	}
+	if lines[1].LineTotal != 597 || !lines[1].PriceChanged {
+		t.Errorf("line 1 = %+v; want total 5.97, price changed", lines[1])
+	}
+	want := CartTotals{Subtotal: 1597, Total: 1597, Currency: "USD"}
+	if totals != want {
+		t.Errorf("totals = %+v; want %+v", totals, want)
+	}
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Category groups products for browsing. Categories nest through
+// ParentID; a nil ParentID makes a top-level category. Slug is the
+// category's unique URL-friendly name.
+type Category struct {
+	ID        int       `db:"id" json:"id"`
+	ParentID  *int      `db:"parent_id" json:"parent_id"`
+	Name      string    `db:"name" json:"name"`
+	Slug      string    `db:"slug" json:"slug"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// Children is filled in by CategoryTree.
+	Children []Category `db:"-" json:"children,omitempty"`
+}
+
+// CategoryTree nests a flat list of categories under their parents and
+// returns the top-level ones. Siblings keep their order in cats. A
+// category whose parent is not in cats is treated as top-level.
+func CategoryTree(cats []Category) []Category {
+	known := make(map[int]bool, len(cats))
+	children := map[int][]Category{}
+	for _, c := range cats {
+		known[c.ID] = true
+	}
+	roots := []Category{}
+	for _, c := range cats {
+		if c.ParentID != nil && known[*c.ParentID] {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+	var attach func(nodes []Category)
+	attach = func(nodes []Category) {
+		for i := range nodes {
+			nodes[i].Children = children[nodes[i].ID]
+			attach(nodes[i].Children)
+		}
+	}
+	attach(roots)
+	return roots
+}
@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestCategoryTree(t *testing.T) {
+	id := func(n int) *int { return &n }
+	tree := CategoryTree([]Category{
+		{ID: 1, Name: "Clothing"},
+		{ID: 3, Name: "Jackets", ParentID: id(2)},
+		{ID: 2, Name: "Outerwear", ParentID: id(1)},
+		{ID: 4, Name: "Shirts", ParentID: id(1)},
+		{ID: 5, Name: "Toys"},
+		{ID: 6, Name: "Orphan", ParentID: id(99)},
+	})
+	if len(tree) != 3 || tree[0].ID != 1 || tree[1].ID != 5 || tree[2].ID != 6 {
+		t.Fatalf("roots = %+v; want Clothing, Toys, Orphan", tree)
+	}
+	kids := tree[0].Children
+	if len(kids) != 2 || kids[0].ID != 2 || kids[1].ID != 4 {
+		t.Fatalf("Clothing children = %+v; want Outerwear, Shirts", kids)
+	}
+	if len(kids[0].Children) != 1 || kids[0].Children[0].ID != 3 {
+		t.Errorf("Outerwear children = %+v; want Jackets", kids[0].Children)
+	}
+	if tree[1].Children != nil {
+		t.Errorf("Toys children = %+v; want none", tree[1].Children)
+	}
+}
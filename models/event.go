@@ -0,0 +1,109 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Domain event types recorded in the outbox.
+const (
+	EventOrderPlaced         = "order.placed"
+	EventOrderStatusChanged  = "order.status_changed"
+	EventProductPriceChanged = "product.price_changed"
+	EventUserSignedUp        = "user.signed_up"
+	EventCartAbandoned       = "cart.abandoned"
+	EventOrderRefunded       = "order.refunded"
+)
+
+// EventTypes lists every domain event type.
+var EventTypes = []string{
+	EventOrderPlaced,
+	EventOrderStatusChanged,
+	EventProductPriceChanged,
+	EventUserSignedUp,
+	EventCartAbandoned,
+	EventOrderRefunded,
+}
+
+// KnownEventType reports whether t is one of EventTypes.
+func KnownEventType(t string) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderPlaced is the payload of EventOrderPlaced.
+type OrderPlaced struct {
+	OrderID     int    `json:"order_id"`
+	UserID      int    `json:"user_id"`
+	TotalAmount Money  `json:"total_amount"`
+	Currency    string `json:"currency"`
+}
+
+// OrderStatusChanged is the payload of EventOrderStatusChanged. ChangedBy
+// is nil for changes made by the system.
+type OrderStatusChanged struct {
+	OrderID   int         `json:"order_id"`
+	UserID    int         `json:"user_id"`
+	From      OrderStatus `json:"from"`
+	To        OrderStatus `json:"to"`
+	ChangedBy *int        `json:"changed_by"`
+}
+
+// ProductPriceChanged is the payload of EventProductPriceChanged. VariantID
+// is set when a variant's price changed rather than the product's.
+type ProductPriceChanged struct {
+	ProductID int    `json:"product_id"`
+	VariantID *int   `json:"variant_id,omitempty"`
+	OldPrice  Money  `json:"old_price"`
+	NewPrice  Money  `json:"new_price"`
+	Currency  string `json:"currency"`
+}
+
+// UserSignedUp is the payload of EventUserSignedUp.
+type UserSignedUp struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// CartAbandoned is the payload of EventCartAbandoned, recorded when a
+// user's cart with items goes idle.
+type CartAbandoned struct {
+	CartID       int       `json:"cart_id"`
+	UserID       int       `json:"user_id"`
+	ItemCount    int       `json:"item_count"`
+	Subtotal     Money     `json:"subtotal"`
+	Currency     string    `json:"currency"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// OrderRefunded is the payload of EventOrderRefunded, recorded when money
+// has been returned for an order. ProductID and Quantity are nil for a
+// refund of the whole order.
+type OrderRefunded struct {
+	OrderID   int    `json:"order_id"`
+	UserID    int    `json:"user_id"`
+	RefundID  int    `json:"refund_id"`
+	ProductID *int   `json:"product_id"`
+	VariantID *int   `json:"variant_id,omitempty"`
+	Quantity  *int   `json:"quantity"`
+	Amount    Money  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+// OutboxEvent is a domain event awaiting, or done with, delivery to
+// subscribers. Delivery is at least once, so subscribers must tolerate
+// seeing the same event ID again.
+type OutboxEvent struct {
+	ID          int64           `db:"id" json:"id"`
+	Type        string          `db:"type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	LastError   string          `db:"last_error" json:"last_error,omitempty"`
+	AvailableAt time.Time       `db:"available_at" json:"available_at"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	PublishedAt *time.Time      `db:"published_at" json:"published_at"`
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when a product is created without a currency.
+const DefaultCurrency = "USD"
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code.
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// Money is an amount in minor units (cents). It maps to NUMERIC(10,2)
+// columns and to JSON numbers with two decimal places, so values round-trip
+// without floating point drift.
+type Money int64
+
+var moneyPattern = regexp.MustCompile(`^(-?)(\d+)(?:\.(\d{1,2}))?$`)
+
+// ParseMoney parses a decimal string such as "12.34" or "5".
+func ParseMoney(s string) (Money, error) {
+	m := moneyPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid money amount %q", s)
+	}
+	whole, err := strconv.ParseInt(m[2], 10, 64)
+	if err != nil || whole > math.MaxInt64/100 {
+		return 0, fmt.Errorf("money amount %q out of range", s)
+	}
+	frac := m[3]
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+	v := Money(whole*100 + cents)
+	if m[1] == "-" {
+		v = -v
+	}
+	return v, nil
+}
+
+// Mul returns the amount multiplied by a quantity.
+func (m Money) Mul(qty int) Money {
+	return m * Money(qty)
+}
+
+// String formats the amount with two decimal places.
+func (m Money) String() string {
+	sign := ""
+	v := int64(m)
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/100, v%100)
+}
+
+// MarshalJSON encodes the amount as a JSON number, e.g. 12.34.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or string with at most two decimals.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	b = bytes.Trim(b, `"`)
+	v, err := ParseMoney(string(b))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Value implements driver.Valuer, sending the amount as a decimal string.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns.
+func (m *Money) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	case int64:
+		*m = Money(v * 100)
+		return nil
+	case float64:
+		*m = Money(math.Round(v * 100))
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+func (m *Money) scanString(s string) error {
+	// NUMERIC(10,2) always has two decimals, but be lenient about
+	// trailing zeros from other precisions
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = strings.TrimRight(s, "0")
+		s = strings.TrimSuffix(s, ".")
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Money
+		wantErr bool
+	}{
+		{in: "12.34", want: 1234},
+		{in: "5", want: 500},
+		{in: "0.1", want: 10},
+		{in: "-3.05", want: -305},
+		{in: "1.234", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMoney(%q) err = %v; wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d; want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSONRoundTrip(t *testing.T) {
+	var v struct {
+		Price Money `json:"price"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":0.30}`), &v); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	// 0.1 + 0.2 style drift must not occur
+	total := v.Price.Mul(3)
+	if total != 90 {
+		t.Fatalf("0.30 x 3 = %d cents; want 90", total)
+	}
+	out, _ := json.Marshal(struct {
+		Total Money `json:"total"`
+	}{total})
+	if string(out) != `{"total":0.90}` {
+		t.Errorf("Marshal = %s; want {\"total\":0.90}", out)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Money
+	}{
+		{src: []byte("19.99"), want: 1999},
+		{src: "7.50", want: 750},
+		{src: "7.500", want: 750},
+		{src: int64(4), want: 400},
+		{src: 0.07, want: 7},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v): %v", tt.src, err)
+			continue
+		}
+		if m != tt.want {
+			t.Errorf("Scan(%v) = %d; want %d", tt.src, m, tt.want)
+		}
+	}
+}
@@ -1,20 +1,108 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-// Order represents a completed (or pending) purchase.
+// OrderStatus is a step in an order's lifecycle.
+type OrderStatus string
+
+// The order lifecycle. See CanTransitionTo for the allowed moves.
+const (
+	StatusPending   OrderStatus = "pending"
+	StatusPaid      OrderStatus = "paid"
+	StatusFulfilled OrderStatus = "fulfilled"
+	StatusShipped   OrderStatus = "shipped"
+	StatusDelivered OrderStatus = "delivered"
+	StatusCancelled OrderStatus = "cancelled"
+	StatusRefunded  OrderStatus = "refunded"
+)
+
+// orderTransitions lists, for each status, the statuses it may move to.
+// Cancelled and refunded are terminal.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusFulfilled, StatusCancelled, StatusRefunded},
+	StatusFulfilled: {StatusShipped, StatusRefunded},
+	StatusShipped:   {StatusDelivered, StatusRefunded},
+	StatusDelivered: {StatusRefunded},
+	StatusCancelled: nil,
+	StatusRefunded:  nil,
+}
+
+// Valid reports whether s is a known status.
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionError is returned when a status change is not allowed.
+type TransitionError struct {
+	From, To OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot move order from %s to %s", e.From, e.To)
+}
+
+// Order represents a completed (or pending) purchase. TotalAmount is net
+// of Discount, which is set when a coupon was applied, and includes
+// TaxAmount and ShippingAmount. TaxInclusive records that the item prices
+// already contained the tax rather than having it added on top. Shipping
+// is set for orders that are delivered.
 type Order struct {
-	ID          int       `db:"id" json:"id"`
-	UserID      int       `db:"user_id" json:"user_id"`
-	TotalAmount float64   `db:"total_amount" json:"total_amount"`
-	Status      string    `db:"status" json:"status"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	ID             int            `db:"id" json:"id"`
+	UserID         int            `db:"user_id" json:"user_id"`
+	TotalAmount    Money          `db:"total_amount" json:"total_amount"`
+	TaxAmount      Money          `db:"tax_amount" json:"tax_amount"`
+	TaxInclusive   bool           `db:"tax_inclusive" json:"tax_inclusive"`
+	ShippingAmount Money          `db:"shipping_amount" json:"shipping_amount"`
+	Currency       string         `db:"currency" json:"currency"`
+	Status         OrderStatus    `db:"status" json:"status"`
+	CreatedAt      time.Time      `db:"created_at" json:"created_at"`
+	Discount       *OrderDiscount `db:"-" json:"discount,omitempty"`
+	Shipping       *OrderShipping `db:"-" json:"shipping,omitempty"`
 }
 
-// OrderItem is a line item within an order.
+// OrderItem is a line item within an order. VariantID and SKU record the
+// variant sold, if the product has variants. TaxAmount is the tax on the
+// whole line.
 type OrderItem struct {
-	OrderID   int     `db:"order_id" json:"order_id"`
-	ProductID int     `db:"product_id" json:"product_id"`
-	Quantity  int     `db:"quantity" json:"quantity"`
-	UnitPrice float64 `db:"unit_price" json:"unit_price"`
+	OrderID   int    `db:"order_id" json:"order_id"`
+	ProductID int    `db:"product_id" json:"product_id"`
+	VariantID *int   `db:"variant_id" json:"variant_id,omitempty"`
+	SKU       string `db:"sku" json:"sku,omitempty"`
+	Quantity  int    `db:"quantity" json:"quantity"`
+	UnitPrice Money  `db:"unit_price" json:"unit_price"`
+	TaxAmount Money  `db:"tax_amount" json:"tax_amount"`
+}
+
+// OrderLine is an order item joined with its product's name.
+type OrderLine struct {
+	OrderItem
+	ProductName string `db:"name" json:"product_name"`
+}
+
+// OrderStatusChange is one entry in an order's status history. FromStatus
+// is nil for the entry recorded when the order is placed, and ChangedBy is
+// nil for changes made by the system rather than a user.
+type OrderStatusChange struct {
+	ID         int          `db:"id" json:"id"`
+	OrderID    int          `db:"order_id" json:"order_id"`
+	FromStatus *OrderStatus `db:"from_status" json:"from_status"`
+	ToStatus   OrderStatus  `db:"to_status" json:"to_status"`
+	ChangedBy  *int         `db:"changed_by" json:"changed_by"`
+	Note       string       `db:"note" json:"note,omitempty"`
+	CreatedAt  time.Time    `db:"created_at" json:"created_at"`
 }
@@ -0,0 +1,78 @@
+package models
+
+import "testing"
+
+func TestOrderStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{StatusPending, StatusPaid, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusPending, StatusShipped, false},
+		{StatusPaid, StatusFulfilled, true},
+		{StatusFulfilled, StatusShipped, true},
+		{StatusShipped, StatusDelivered, true},
+		{StatusDelivered, StatusRefunded, true},
+		{StatusDelivered, StatusCancelled, false},
+		{StatusCancelled, StatusPaid, false},
+		{StatusRefunded, StatusPending, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s = %v; want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestOrderStatusValid(t *testing.T) {
+	if !StatusShipped.Valid() {
+		t.Error("shipped should be valid")
+	}
+	if OrderStatus("lost").Valid() {
+		t.Error("lost should not be valid")
+	}
+}
+
+func TestLineRefundAmount(t *testing.T) {
+	items := []OrderItem{
+		{ProductID: 1, Quantity: 3, UnitPrice: 1000, TaxAmount: 240},
+		{ProductID: 2, Quantity: 1, UnitPrice: 1000, TaxAmount: 80},
+	}
+	order := Order{Discount: &OrderDiscount{Amount: 400}}
+
+	// line 1 nets 30.00 - 3.00 discount + 2.40 tax = 29.40, or 9.80 a unit
+	var total Money
+	for refunded := 0; refunded < 3; refunded++ {
+		got := LineRefundAmount(order, items, items[0], refunded, 1)
+		if got != 980 {
+			t.Errorf("unit %d refund = %v; want 9.80", refunded+1, got)
+		}
+		total += got
+	}
+	if total != 2940 {
+		t.Errorf("refunded %v in all; want 29.40", total)
+	}
+
+	order.TaxInclusive = true
+	if got := LineRefundAmount(order, items, items[1], 0, 1); got != 900 {
+		t.Errorf("inclusive refund = %v; want 9.00", got)
+	}
+}
+
+func TestLineRefundAmount_DiscountRemainder(t *testing.T) {
+	// 1.00 off three equal lines does not split evenly
+	items := []OrderItem{
+		{ProductID: 1, Quantity: 1, UnitPrice: 1000},
+		{ProductID: 2, Quantity: 1, UnitPrice: 1000},
+		{ProductID: 3, Quantity: 1, UnitPrice: 1000},
+	}
+	order := Order{Discount: &OrderDiscount{Amount: 100}}
+	var total Money
+	for _, it := range items {
+		total += LineRefundAmount(order, items, it, 0, 1)
+	}
+	if total != 2900 {
+		t.Errorf("refunded %v for every line; want the 29.00 paid", total)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// PaymentStatus is the state of one attempt to pay for an order.
+type PaymentStatus string
+
+// Payment statuses. A payment starts pending, becomes authorized once the
+// gateway holds the funds and captured once they are collected. Declined,
+// failed, voided and refunded are terminal.
+const (
+	PaymentPending    PaymentStatus = "pending"
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentCaptured   PaymentStatus = "captured"
+	PaymentDeclined   PaymentStatus = "declined"
+	PaymentFailed     PaymentStatus = "failed"
+	PaymentVoided     PaymentStatus = "voided"
+	PaymentRefunded   PaymentStatus = "refunded"
+)
+
+// Payment records an attempt to collect an order's total through a
+// payment provider.
+type Payment struct {
+	ID            int           `db:"id" json:"id"`
+	OrderID       int           `db:"order_id" json:"order_id"`
+	Provider      string        `db:"provider" json:"provider"`
+	ProviderRef   string        `db:"provider_ref" json:"provider_ref,omitempty"`
+	Amount        Money         `db:"amount" json:"amount"`
+	Currency      string        `db:"currency" json:"currency"`
+	Status        PaymentStatus `db:"status" json:"status"`
+	FailureReason string        `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedAt     time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time     `db:"updated_at" json:"updated_at"`
+}
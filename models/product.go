@@ -2,12 +2,21 @@ package models
 
 import "time"
 
-// Product represents an item in our catalog.
+// DefaultTaxClass is used when a product is created without a tax class.
+const DefaultTaxClass = "standard"
+
+// Product represents an item in our catalog. TaxClass picks which tax rate
+// applies to it, such as "standard" or "food". WeightGrams is its shipping
+// weight.
 type Product struct {
-	ID          int       `db:"id" json:"id"`
-	Name        string    `db:"name" json:"name"`
-	Description string    `db:"description,omitempty" json:"description,omitempty"`
-	Price       float64   `db:"price" json:"price"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
-	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	ID            int       `db:"id" json:"id"`
+	Name          string    `db:"name" json:"name"`
+	Description   string    `db:"description,omitempty" json:"description,omitempty"`
+	Price         Money     `db:"price" json:"price"`
+	Currency      string    `db:"currency" json:"currency"`
+	StockQuantity int       `db:"stock_quantity" json:"stock_quantity"`
+	TaxClass      string    `db:"tax_class" json:"tax_class"`
+	WeightGrams   int       `db:"weight_grams" json:"weight_grams"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// PromotionKind is how a promotion discounts a cart.
+type PromotionKind string
+
+// Promotion kinds. A percentage promotion takes PercentOff percent off the
+// eligible items, a fixed one takes AmountOff off them, and buy-X-get-Y
+// makes GetQuantity of every BuyQuantity+GetQuantity units of an eligible
+// line free.
+const (
+	PromotionPercentage PromotionKind = "percentage"
+	PromotionFixed      PromotionKind = "fixed"
+	PromotionBuyXGetY   PromotionKind = "buy_x_get_y"
+)
+
+// Valid reports whether k is a known kind.
+func (k PromotionKind) Valid() bool {
+	switch k {
+	case PromotionPercentage, PromotionFixed, PromotionBuyXGetY:
+		return true
+	}
+	return false
+}
+
+// Promotion is a coupon code customers can apply to their cart. Currency
+// is required for fixed amounts and minimum spends, and limits the code to
+// carts in that currency. ProductIDs and CategoryIDs, when set, limit the
+// discount to those products and to products in those categories or their
+// subcategories. Nil limits and validity bounds mean unlimited.
+type Promotion struct {
+	ID             int           `db:"id" json:"id"`
+	Code           string        `db:"code" json:"code"`
+	Kind           PromotionKind `db:"kind" json:"kind"`
+	PercentOff     int           `db:"percent_off" json:"percent_off"`
+	AmountOff      Money         `db:"amount_off" json:"amount_off"`
+	BuyQuantity    int           `db:"buy_quantity" json:"buy_quantity"`
+	GetQuantity    int           `db:"get_quantity" json:"get_quantity"`
+	Currency       string        `db:"currency" json:"currency,omitempty"`
+	MinSpend       Money         `db:"min_spend" json:"min_spend"`
+	MaxUses        *int          `db:"max_uses" json:"max_uses"`
+	MaxUsesPerUser *int          `db:"max_uses_per_user" json:"max_uses_per_user"`
+	ProductIDs     []int64       `db:"product_ids" json:"product_ids"`
+	CategoryIDs    []int64       `db:"category_ids" json:"category_ids"`
+	StartsAt       *time.Time    `db:"starts_at" json:"starts_at"`
+	EndsAt         *time.Time    `db:"ends_at" json:"ends_at"`
+	Active         bool          `db:"active" json:"active"`
+	CreatedAt      time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time     `db:"updated_at" json:"updated_at"`
+}
+
+// OrderDiscount freezes the discount a promotion gave an order. The
+// promotion may later change or be deleted, leaving PromotionID nil; Code
+// and Amount keep what was applied.
+type OrderDiscount struct {
+	ID          int       `db:"id" json:"id"`
+	OrderID     int       `db:"order_id" json:"order_id"`
+	PromotionID *int      `db:"promotion_id" json:"promotion_id"`
+	Code        string    `db:"code" json:"code"`
+	Amount      Money     `db:"amount" json:"amount"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// RefundStatus is the state of a refund with the payment provider.
+type RefundStatus string
+
+// Refund statuses. A refund is recorded pending before the provider is
+// asked for the money back, then becomes succeeded or failed. Failed
+// refunds do not count against what may still be refunded.
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund returns money for an order. ProductID, VariantID and Quantity
+// name the units of one order line being refunded; they are nil for a
+// refund of the whole order, such as on cancellation. PaymentID is the
+// captured payment the money goes back to, and ReturnID the return whose
+// approval issued it.
+type Refund struct {
+	ID            int          `db:"id" json:"id"`
+	OrderID       int          `db:"order_id" json:"order_id"`
+	PaymentID     int          `db:"payment_id" json:"payment_id"`
+	ProductID     *int         `db:"product_id" json:"product_id,omitempty"`
+	VariantID     *int         `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity      *int         `db:"quantity" json:"quantity,omitempty"`
+	ReturnID      *int         `db:"return_id" json:"return_id,omitempty"`
+	Amount        Money        `db:"amount" json:"amount"`
+	Currency      string       `db:"currency" json:"currency"`
+	Reason        string       `db:"reason" json:"reason,omitempty"`
+	Status        RefundStatus `db:"status" json:"status"`
+	FailureReason string       `db:"failure_reason" json:"failure_reason,omitempty"`
+	CreatedBy     *int         `db:"created_by" json:"created_by"`
+	CreatedAt     time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+// LineRefundAmount works out what refunding quantity more units of item
+// returns when refunded units have been refunded already. The line's share
+// of the order's discount, spread over lines by value, is kept back and
+// its tax is returned unless prices already included it. Amounts are
+// rounded so that refunding every unit returns exactly the line's total,
+// and every line of the order exactly the order's.
+func LineRefundAmount(o Order, items []OrderItem, item OrderItem, refunded, quantity int) Money {
+	net := item.UnitPrice.Mul(item.Quantity)
+	if o.Discount != nil {
+		net -= lineDiscount(o.Discount.Amount, items, item)
+	}
+	if !o.TaxInclusive {
+		net += item.TaxAmount
+	}
+	upTo := func(units int) Money { return net * Money(units) / Money(item.Quantity) }
+	return upTo(refunded+quantity) - upTo(refunded)
+}
+
+// lineDiscount is item's share of a discount of amount spread over items
+// by value. As in tax.Allocate, shares are rounded down and the last line
+// with any value takes the remainder, so the shares add up to amount.
+func lineDiscount(amount Money, items []OrderItem, item OrderItem) Money {
+	var subtotal Money
+	last := -1
+	for i, it := range items {
+		if gross := it.UnitPrice.Mul(it.Quantity); gross > 0 {
+			subtotal += gross
+			last = i
+		}
+	}
+	var given Money
+	for i, it := range items {
+		var share Money
+		if subtotal > 0 {
+			share = amount * it.UnitPrice.Mul(it.Quantity) / subtotal
+		}
+		if i == last {
+			share = amount - given
+		}
+		if it.ProductID == item.ProductID && SameVariant(it.VariantID, item.VariantID) {
+			return share
+		}
+		given += share
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReturnStatus is a step in a return request's lifecycle.
+type ReturnStatus string
+
+// The return lifecycle. A customer opens a return as requested; staff
+// approve or reject it, and mark an approved return received once the
+// items arrive back. Rejected and received are terminal.
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved"
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received"
+)
+
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnRejected:  nil,
+	ReturnReceived:  nil,
+}
+
+// CanTransitionTo reports whether a return in status s may move to next.
+func (s ReturnStatus) CanTransitionTo(next ReturnStatus) bool {
+	for _, allowed := range returnTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ReturnTransitionError is returned when a return status change is not
+// allowed.
+type ReturnTransitionError struct {
+	From, To ReturnStatus
+}
+
+func (e *ReturnTransitionError) Error() string {
+	return fmt.Sprintf("cannot move return from %s to %s", e.From, e.To)
+}
+
+// Return is a customer's request to send back some of an order's items.
+// StaffNote and HandledBy record the last staff decision; Restocked is set
+// when the received items went back into stock.
+type Return struct {
+	ID        int          `db:"id" json:"id"`
+	OrderID   int          `db:"order_id" json:"order_id"`
+	UserID    int          `db:"user_id" json:"user_id"`
+	Status    ReturnStatus `db:"status" json:"status"`
+	Reason    string       `db:"reason" json:"reason"`
+	StaffNote string       `db:"staff_note" json:"staff_note,omitempty"`
+	Restocked bool         `db:"restocked" json:"restocked"`
+	HandledBy *int         `db:"handled_by" json:"handled_by"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time    `db:"updated_at" json:"updated_at"`
+	Items     []ReturnItem `db:"-" json:"items"`
+}
+
+// ReturnItem is some units of one order line being returned.
+type ReturnItem struct {
+	ReturnID  int  `db:"return_id" json:"return_id"`
+	ProductID int  `db:"product_id" json:"product_id"`
+	VariantID *int `db:"variant_id" json:"variant_id,omitempty"`
+	Quantity  int  `db:"quantity" json:"quantity"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RefreshToken is a long-lived credential exchanged for new access tokens.
+// Only a hash of the token is stored. Every rotation issues a new token in
+// the same family, so reuse of a rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID        int        `db:"id" json:"id"`
+	UserID    int        `db:"user_id" json:"user_id"`
+	FamilyID  string     `db:"family_id" json:"family_id"`
+	TokenHash string     `db:"token_hash" json:"-"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+}
@@ -2,10 +2,27 @@ package models
 
 import "time"
 
+// Role controls what a user may do beyond managing their own carts and orders.
+type Role string
+
+// Known roles. Staff manage the catalog and orders; admins can also
+// manage other users.
+const (
+	RoleCustomer Role = "customer"
+	RoleStaff    Role = "staff"
+	RoleAdmin    Role = "admin"
+)
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	return r == RoleCustomer || r == RoleStaff || r == RoleAdmin
+}
+
 // User represents a registered user in the system.
 type User struct {
 	ID           int       `db:"id" json:"id"`
 	Email        string    `db:"email" json:"email"`
 	PasswordHash string    `db:"password_hash" json:"-"`
+	Role         Role      `db:"role" json:"role"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 }
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ProductOption is one way a product varies, such as size or colour, and
+// the values it may take, in display order.
+type ProductOption struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// VariantAttributes maps each of a product's option names to a variant's
+// value for it, such as {"size": "M", "colour": "red"}. It maps to a JSONB
+// column.
+type VariantAttributes map[string]string
+
+// Value implements driver.Valuer, sending the attributes as JSON.
+func (a VariantAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(map[string]string(a))
+	return string(b), err
+}
+
+// Scan implements sql.Scanner for JSONB columns.
+func (a *VariantAttributes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	default:
+		return fmt.Errorf("cannot scan %T into VariantAttributes", src)
+	}
+}
+
+// Variant is one purchasable combination of a product's options. It has
+// its own SKU, price and stock; the product's own price and stock apply
+// only to products without variants. Price is in the product's currency.
+type Variant struct {
+	ID            int               `db:"id" json:"id"`
+	ProductID     int               `db:"product_id" json:"product_id"`
+	SKU           string            `db:"sku" json:"sku"`
+	Price         Money             `db:"price" json:"price"`
+	StockQuantity int               `db:"stock_quantity" json:"stock_quantity"`
+	Attributes    VariantAttributes `db:"attributes" json:"attributes"`
+	CreatedAt     time.Time         `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time         `db:"updated_at" json:"updated_at"`
+}
+
+// CheckAttributes returns an error unless attrs picks one of the allowed
+// values of every option in opts and names no other options.
+func CheckAttributes(opts []ProductOption, attrs VariantAttributes) error {
+	known := make(map[string]bool, len(opts))
+	for _, o := range opts {
+		known[o.Name] = true
+		v, ok := attrs[o.Name]
+		if !ok {
+			return fmt.Errorf("missing value for option %q", o.Name)
+		}
+		allowed := false
+		for _, ov := range o.Values {
+			allowed = allowed || ov == v
+		}
+		if !allowed {
+			return fmt.Errorf("%q is not a value of option %q", v, o.Name)
+		}
+	}
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown option %q", name)
+		}
+	}
+	return nil
+}
+
+// SameVariant reports whether two optional variant IDs name the same
+// variant, or both name none. Lines are identified by product and
+// variant together.
+func SameVariant(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestCheckAttributes(t *testing.T) {
+	opts := []ProductOption{
+		{Name: "size", Values: []string{"S", "M", "L"}},
+		{Name: "colour", Values: []string{"red", "blue"}},
+	}
+	tests := []struct {
+		name    string
+		attrs   VariantAttributes
+		wantErr bool
+	}{
+		{"complete", VariantAttributes{"size": "M", "colour": "red"}, false},
+		{"missing option", VariantAttributes{"size": "M"}, true},
+		{"unknown value", VariantAttributes{"size": "XL", "colour": "red"}, true},
+		{"unknown option", VariantAttributes{"size": "M", "colour": "red", "fit": "slim"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckAttributes(opts, tt.attrs); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v; want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSameVariant(t *testing.T) {
+	one, alsoOne, two := 1, 1, 2
+	if !SameVariant(nil, nil) || !SameVariant(&one, &alsoOne) {
+		t.Error("want nil/nil and equal IDs to match")
+	}
+	if SameVariant(&one, nil) || SameVariant(nil, &one) || SameVariant(&one, &two) {
+		t.Error("want nil/ID and different IDs not to match")
+	}
+}
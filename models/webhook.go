@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// WebhookEvent is an inbound webhook delivery, kept so that redelivered
+// events are recognised and applied only once.
+type WebhookEvent struct {
+	ID         string    `db:"id" json:"id"`
+	Type       string    `db:"type" json:"type"`
+	OrderID    *int      `db:"order_id" json:"order_id"`
+	Payload    []byte    `db:"payload" json:"-"`
+	ReceivedAt time.Time `db:"received_at" json:"received_at"`
+}
+
+// WebhookSubscription asks for domain events of the listed types to be
+// POSTed to URL, signed with Secret. The event type "*" matches all.
+type WebhookSubscription struct {
+	ID         int       `db:"id" json:"id"`
+	URL        string    `db:"url" json:"url"`
+	EventTypes []string  `db:"event_types" json:"event_types"`
+	Secret     string    `db:"secret" json:"secret,omitempty"`
+	Active     bool      `db:"active" json:"active"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Matches reports whether the subscription wants events of type t.
+func (s WebhookSubscription) Matches(t string) bool {
+	for _, want := range s.EventTypes {
+		if want == "*" || want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery logs one attempt to deliver an event to a subscription.
+// StatusCode is nil when no response was received.
+type WebhookDelivery struct {
+	ID             int       `db:"id" json:"id"`
+	SubscriptionID int       `db:"subscription_id" json:"subscription_id"`
+	EventID        int64     `db:"event_id" json:"event_id"`
+	EventType      string    `db:"event_type" json:"event_type"`
+	Attempt        int       `db:"attempt" json:"attempt"`
+	StatusCode     *int      `db:"status_code" json:"status_code"`
+	Error          string    `db:"error" json:"error,omitempty"`
+	Succeeded      bool      `db:"succeeded" json:"succeeded"`
+	DurationMS     int       `db:"duration_ms" json:"duration_ms"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/payments"
+)
+
+// newPaymentProvider builds the gateway named by PAYMENT_PROVIDER. Only the
+// built-in fake exists so far; FAKE_PAYMENT_OUTCOME (approve, decline or
+// error) and FAKE_PAYMENT_LATENCY (a duration such as 250ms) tune it.
+func newPaymentProvider() (payments.Provider, error) {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "", "fake":
+		f := payments.NewFake()
+		if v := os.Getenv("FAKE_PAYMENT_OUTCOME"); v != "" {
+			outcome, err := payments.ParseOutcome(v)
+			if err != nil {
+				return nil, err
+			}
+			f.Outcome = outcome
+		}
+		if v := os.Getenv("FAKE_PAYMENT_LATENCY"); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("FAKE_PAYMENT_LATENCY: %w", err)
+			}
+			f.Latency = d
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", name)
+	}
+}
@@ -0,0 +1,160 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Outcome is how the fake gateway answers an authorization.
+type Outcome string
+
+// Fake gateway outcomes.
+const (
+	OutcomeApprove Outcome = "approve"
+	OutcomeDecline Outcome = "decline"
+	OutcomeError   Outcome = "error"
+)
+
+// Payment method tokens that force an outcome regardless of the Fake's
+// configured default, so one server can exercise every path.
+const (
+	TokenApprove = "tok_approve"
+	TokenDecline = "tok_decline"
+	TokenError   = "tok_error"
+)
+
+// ParseOutcome validates an outcome name.
+func ParseOutcome(s string) (Outcome, error) {
+	switch o := Outcome(s); o {
+	case OutcomeApprove, OutcomeDecline, OutcomeError:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown fake payment outcome %q", s)
+}
+
+type fakeCharge struct {
+	authorized models.Money
+	captured   models.Money
+	refunded   models.Money
+	voided     bool
+}
+
+// Fake is an in-memory Provider. It is safe for concurrent use.
+type Fake struct {
+	// Outcome applies to methods other than the Token* values.
+	Outcome Outcome
+	// Latency delays every call, to mimic a remote gateway.
+	Latency time.Duration
+
+	mu      sync.Mutex
+	charges map[string]*fakeCharge
+	next    int
+}
+
+var _ Provider = (*Fake)(nil)
+
+// NewFake returns a Fake that approves everything instantly.
+func NewFake() *Fake {
+	return &Fake{Outcome: OutcomeApprove, charges: map[string]*fakeCharge{}}
+}
+
+// Name implements Provider.
+func (f *Fake) Name() string { return "fake" }
+
+// Authorize implements Provider.
+func (f *Fake) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	if err := f.wait(ctx); err != nil {
+		return "", err
+	}
+	outcome := f.Outcome
+	switch req.Method {
+	case TokenApprove:
+		outcome = OutcomeApprove
+	case TokenDecline:
+		outcome = OutcomeDecline
+	case TokenError:
+		outcome = OutcomeError
+	}
+	switch outcome {
+	case OutcomeDecline:
+		return "", fmt.Errorf("%w: card declined", ErrDeclined)
+	case OutcomeError:
+		return "", errors.New("fake gateway unavailable")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	ref := fmt.Sprintf("fake_%d", f.next)
+	f.charges[ref] = &fakeCharge{authorized: req.Amount}
+	return ref, nil
+}
+
+// Capture implements Provider.
+func (f *Fake) Capture(ctx context.Context, ref string, amount models.Money) error {
+	return f.update(ctx, ref, func(c *fakeCharge) error {
+		if c.voided || c.captured > 0 {
+			return errors.New("authorization is no longer open")
+		}
+		if amount > c.authorized {
+			return errors.New("capture exceeds authorized amount")
+		}
+		c.captured = amount
+		return nil
+	})
+}
+
+// Refund implements Provider.
+func (f *Fake) Refund(ctx context.Context, ref string, amount models.Money) error {
+	return f.update(ctx, ref, func(c *fakeCharge) error {
+		if c.refunded+amount > c.captured {
+			return errors.New("refund exceeds captured amount")
+		}
+		c.refunded += amount
+		return nil
+	})
+}
+
+// Void implements Provider.
+func (f *Fake) Void(ctx context.Context, ref string) error {
+	return f.update(ctx, ref, func(c *fakeCharge) error {
+		if c.captured > 0 {
+			return errors.New("cannot void a captured payment")
+		}
+		c.voided = true
+		return nil
+	})
+}
+
+func (f *Fake) update(ctx context.Context, ref string, fn func(*fakeCharge) error) error {
+	if err := f.wait(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.charges[ref]
+	if !ok {
+		return fmt.Errorf("unknown payment reference %q", ref)
+	}
+	return fn(c)
+}
+
+// wait sleeps for the configured latency or until ctx is done.
+func (f *Fake) wait(ctx context.Context) error {
+	if f.Latency <= 0 {
+		return nil
+	}
+	t := time.NewTimer(f.Latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
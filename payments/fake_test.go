@@ -0,0 +1,59 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	ref, err := f.Authorize(ctx, AuthorizeRequest{OrderID: 1, Amount: 1000, Currency: "USD", Method: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if err := f.Capture(ctx, ref, 1500); err == nil {
+		t.Error("capture above the authorized amount succeeded")
+	}
+	if err := f.Capture(ctx, ref, 1000); err != nil {
+		t.Fatalf("Capture: %v", err)
+	}
+	if err := f.Void(ctx, ref); err == nil {
+		t.Error("void after capture succeeded")
+	}
+	if err := f.Refund(ctx, ref, 600); err != nil {
+		t.Fatalf("Refund: %v", err)
+	}
+	if err := f.Refund(ctx, ref, 600); err == nil {
+		t.Error("refund above the captured amount succeeded")
+	}
+}
+
+func TestFakeOutcomes(t *testing.T) {
+	ctx := context.Background()
+	f := NewFake()
+	f.Outcome = OutcomeDecline
+
+	if _, err := f.Authorize(ctx, AuthorizeRequest{Amount: 100}); !errors.Is(err, ErrDeclined) {
+		t.Errorf("configured decline: err = %v; want ErrDeclined", err)
+	}
+	if _, err := f.Authorize(ctx, AuthorizeRequest{Amount: 100, Method: TokenApprove}); err != nil {
+		t.Errorf("approve token: err = %v", err)
+	}
+	_, err := f.Authorize(ctx, AuthorizeRequest{Amount: 100, Method: TokenError})
+	if err == nil || errors.Is(err, ErrDeclined) {
+		t.Errorf("error token: err = %v; want a gateway error", err)
+	}
+}
+
+func TestFakeLatencyHonoursContext(t *testing.T) {
+	f := NewFake()
+	f.Latency = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if _, err := f.Authorize(ctx, AuthorizeRequest{Amount: 100}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v; want context.DeadlineExceeded", err)
+	}
+}
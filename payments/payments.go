@@ -0,0 +1,37 @@
+// Package payments abstracts the gateway that collects money for orders.
+// Handlers talk to a Provider; the built-in Fake lets the full payment flow
+// run locally and in tests without a real gateway.
+package payments
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// ErrDeclined is returned by Authorize when the gateway refuses the
+// payment method. Providers wrap it with the reason they were given.
+var ErrDeclined = errors.New("payment declined")
+
+// AuthorizeRequest asks the gateway to hold funds for an order.
+type AuthorizeRequest struct {
+	OrderID  int
+	Amount   models.Money
+	Currency string
+	// Method is the opaque payment method token the client obtained from
+	// the gateway, e.g. a tokenized card.
+	Method string
+}
+
+// Provider is a payment gateway. Authorized funds are held until they are
+// captured or voided; captured funds can be refunded in whole or in part.
+type Provider interface {
+	// Name identifies the provider in stored payments.
+	Name() string
+	// Authorize places a hold and returns the gateway's reference for it.
+	Authorize(ctx context.Context, req AuthorizeRequest) (ref string, err error)
+	Capture(ctx context.Context, ref string, amount models.Money) error
+	Refund(ctx context.Context, ref string, amount models.Money) error
+	Void(ctx context.Context, ref string) error
+}
@@ -0,0 +1,81 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// SignatureHeader carries the HMAC signature of a payment webhook body, in
+// the form "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+const SignatureHeader = "X-Webhook-Signature"
+
+// ErrInvalidSignature is returned by VerifyWebhook for a missing, malformed,
+// stale or wrong signature.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Payment webhook event types.
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentRefunded  = "payment.refunded"
+)
+
+// Event is the body of a payment webhook.
+type Event struct {
+	ID          string       `json:"id"`
+	Type        string       `json:"type"`
+	OrderID     int          `json:"order_id"`
+	Amount      models.Money `json:"amount"`
+	Currency    string       `json:"currency"`
+	ProviderRef string       `json:"provider_ref,omitempty"`
+}
+
+// SignWebhook returns the SignatureHeader value for body sent at t. The
+// server uses it to verify deliveries; tests and local tooling use it to
+// post fake events.
+func SignWebhook(secret string, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + webhookMAC(secret, ts, body)
+}
+
+// VerifyWebhook checks header against body. Signatures older or newer than
+// tolerance relative to now are rejected to limit replays.
+func VerifyWebhook(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(webhookMAC(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func webhookMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
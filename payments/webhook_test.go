@@ -0,0 +1,41 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+	good := SignWebhook("s3cret", body, now)
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		ok     bool
+	}{
+		{"valid", "s3cret", good, body, now, true},
+		{"within tolerance", "s3cret", good, body, now.Add(4 * time.Minute), true},
+		{"stale", "s3cret", good, body, now.Add(10 * time.Minute), false},
+		{"wrong secret", "other", good, body, now, false},
+		{"tampered body", "s3cret", good, []byte(`{"id":"evt_2"}`), now, false},
+		{"malformed", "s3cret", "v1=abc", body, now, false},
+		{"missing", "s3cret", "", body, now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook(tt.secret, tt.header, tt.body, tt.now, 5*time.Minute)
+			if tt.ok && err != nil {
+				t.Errorf("err = %v; want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("err = %v; want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
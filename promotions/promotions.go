@@ -0,0 +1,124 @@
+// Package promotions works out what a coupon is worth against a cart.
+package promotions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Reasons a promotion does not apply to a cart.
+var (
+	ErrInactive        = errors.New("coupon is not active")
+	ErrNotStarted      = errors.New("coupon is not valid yet")
+	ErrExpired         = errors.New("coupon has expired")
+	ErrCurrency        = errors.New("coupon does not apply to this currency")
+	ErrNoEligibleItems = errors.New("coupon does not apply to any item in the cart")
+	ErrUsageLimit      = errors.New("coupon has been used up")
+)
+
+// MinimumSpendError reports a cart that spends less than a promotion
+// requires.
+type MinimumSpendError struct {
+	MinSpend models.Money
+	Currency string
+}
+
+func (e *MinimumSpendError) Error() string {
+	return fmt.Sprintf("coupon requires a minimum spend of %s %s", e.MinSpend, e.Currency)
+}
+
+// Discount returns what p takes off a cart holding lines at time now, or
+// an error saying why it does not apply. The discount never exceeds the
+// eligible items' value. cats maps product IDs to the categories they are
+// in, including every ancestor; it is only consulted when p is scoped to
+// categories. Usage limits are checked by CheckUsage.
+func Discount(p models.Promotion, lines []models.CartLine, cats map[int][]int, now time.Time) (models.Money, error) {
+	switch {
+	case !p.Active:
+		return 0, ErrInactive
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return 0, ErrNotStarted
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return 0, ErrExpired
+	}
+
+	var subtotal, eligible models.Money
+	var currency string
+	for _, l := range lines {
+		currency = l.Currency
+		total := l.UnitPrice.Mul(l.Quantity)
+		subtotal += total
+		if appliesTo(p, l.ProductID, cats) {
+			eligible += total
+		}
+	}
+	if p.Currency != "" && currency != "" && currency != p.Currency {
+		return 0, ErrCurrency
+	}
+	if subtotal < p.MinSpend {
+		return 0, &MinimumSpendError{MinSpend: p.MinSpend, Currency: p.Currency}
+	}
+	if eligible == 0 {
+		return 0, ErrNoEligibleItems
+	}
+
+	var off models.Money
+	switch p.Kind {
+	case models.PromotionPercentage:
+		// round half up to the cent
+		off = (eligible*models.Money(p.PercentOff) + 50) / 100
+	case models.PromotionFixed:
+		off = p.AmountOff
+	case models.PromotionBuyXGetY:
+		group := p.BuyQuantity + p.GetQuantity
+		for _, l := range lines {
+			if appliesTo(p, l.ProductID, cats) && group > 0 {
+				off += l.UnitPrice.Mul(l.Quantity / group * p.GetQuantity)
+			}
+		}
+		if off == 0 {
+			return 0, ErrNoEligibleItems
+		}
+	}
+	if off > eligible {
+		off = eligible
+	}
+	return off, nil
+}
+
+// CheckUsage returns ErrUsageLimit if p has already been redeemed its
+// maximum number of times, overall (total) or by this customer (byUser).
+func CheckUsage(p models.Promotion, total, byUser int) error {
+	if p.MaxUses != nil && total >= *p.MaxUses {
+		return ErrUsageLimit
+	}
+	if p.MaxUsesPerUser != nil && byUser >= *p.MaxUsesPerUser {
+		return ErrUsageLimit
+	}
+	return nil
+}
+
+// appliesTo reports whether p discounts productID: it is one of p's
+// products, or in one of p's categories or their subcategories, or p is
+// not scoped at all.
+func appliesTo(p models.Promotion, productID int, cats map[int][]int) bool {
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if int(id) == productID {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIDs {
+		for _, c := range cats[productID] {
+			if int(id) == c {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package promotions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+func TestDiscount(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	lines := []models.CartLine{
+		{CartItem: models.CartItem{ProductID: 1, Quantity: 3}, UnitPrice: 500, Currency: "USD"},
+		{CartItem: models.CartItem{ProductID: 2, Quantity: 1}, UnitPrice: 333, Currency: "USD"},
+	}
+	tests := []struct {
+		name    string
+		promo   models.Promotion
+		want    models.Money
+		wantErr error
+	}{
+		{"percentage", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10}, 183, nil},
+		{"percentage scoped to product", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 15, ProductIDs: []int64{2}}, 50, nil},
+		{"fixed", models.Promotion{Kind: models.PromotionFixed, AmountOff: 500, Currency: "USD"}, 500, nil},
+		{"fixed capped at eligible items", models.Promotion{Kind: models.PromotionFixed, AmountOff: 1000, Currency: "USD", ProductIDs: []int64{2}}, 333, nil},
+		{"buy two get one", models.Promotion{Kind: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1}, 500, nil},
+		{"buy two get one, too few", models.Promotion{Kind: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []int64{2}}, 0, ErrNoEligibleItems},
+		{"minimum spend met", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, MinSpend: 1833, Currency: "USD"}, 183, nil},
+		{"not started", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, StartsAt: &future}, 0, ErrNotStarted},
+		{"expired", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, StartsAt: &past, EndsAt: &now}, 0, ErrExpired},
+		{"other currency", models.Promotion{Kind: models.PromotionFixed, AmountOff: 500, Currency: "EUR"}, 0, ErrCurrency},
+		{"no eligible items", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, ProductIDs: []int64{9}}, 0, ErrNoEligibleItems},
+		{"scoped to ancestor category", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, CategoryIDs: []int64{20}}, 150, nil},
+		{"scoped to product or category", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, ProductIDs: []int64{2}, CategoryIDs: []int64{21}}, 183, nil},
+		{"category with no items", models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, CategoryIDs: []int64{99}}, 0, ErrNoEligibleItems},
+	}
+	// product 1 is in category 21, a subcategory of 20
+	cats := map[int][]int{1: {20, 21}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.promo.Active = true
+			got, err := Discount(tt.promo, lines, cats, now)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("Discount = %s, %v; want %s, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscount_MinimumSpend(t *testing.T) {
+	lines := []models.CartLine{{CartItem: models.CartItem{ProductID: 1, Quantity: 1}, UnitPrice: 500, Currency: "USD"}}
+	p := models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10, MinSpend: 2000, Currency: "USD", Active: true}
+	_, err := Discount(p, lines, nil, time.Now())
+	var minErr *MinimumSpendError
+	if !errors.As(err, &minErr) || minErr.MinSpend != 2000 {
+		t.Errorf("err = %v; want *MinimumSpendError for 20.00", err)
+	}
+}
+
+func TestDiscount_Inactive(t *testing.T) {
+	p := models.Promotion{Kind: models.PromotionPercentage, PercentOff: 10}
+	if _, err := Discount(p, nil, nil, time.Now()); !errors.Is(err, ErrInactive) {
+		t.Errorf("err = %v; want ErrInactive", err)
+	}
+}
+
+func TestCheckUsage(t *testing.T) {
+	two, one := 2, 1
+	p := models.Promotion{MaxUses: &two, MaxUsesPerUser: &one}
+	tests := []struct {
+		total, byUser int
+		want          error
+	}{
+		{0, 0, nil},
+		{1, 0, nil},
+		{1, 1, ErrUsageLimit},
+		{2, 0, ErrUsageLimit},
+	}
+	for _, tt := range tests {
+		if err := CheckUsage(p, tt.total, tt.byUser); !errors.Is(err, tt.want) {
+			t.Errorf("CheckUsage(%d, %d) = %v; want %v", tt.total, tt.byUser, err, tt.want)
+		}
+	}
+}
@@ -16,6 +16,12 @@ until curl -sf http://localhost:8080/healthz > /dev/null; do
   sleep 1
 done
 
+# Seed an admin account for catalog writes
+curl -sf -X POST http://localhost:8080/users/signup \
+  -H 'Content-Type: application/json' \
+  -d '{"email":"admin@int.test","password":"secret"}' > /dev/null || true
+docker compose exec -T api ./server admin promote admin@int.test admin
+
 # Run integration tests
 go test ./integration
 
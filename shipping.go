@@ -0,0 +1,22 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Heisenberg270/ecommerce-go/shipping"
+)
+
+// newShippingRates builds the shipping rate table from SHIPPING_METHODS, a
+// JSON array of methods, falling back to shipping.DefaultTable.
+func newShippingRates() (shipping.RateProvider, error) {
+	v := os.Getenv("SHIPPING_METHODS")
+	if v == "" {
+		return shipping.DefaultTable(), nil
+	}
+	t, err := shipping.ParseTable(v)
+	if err != nil {
+		return nil, fmt.Errorf("SHIPPING_METHODS: %w", err)
+	}
+	return t, nil
+}
@@ -0,0 +1,62 @@
+// Package shipping prices delivery of an order. Handlers talk to a
+// RateProvider; the built-in Table charges flat rates and weight bands.
+package shipping
+
+import (
+	"context"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Item is one order line to be shipped.
+type Item struct {
+	ProductID   int
+	Quantity    int
+	WeightGrams int
+	UnitPrice   models.Money
+}
+
+// Request asks what it costs to send Items to Destination. Only the
+// destination's country and region are consulted by the built-in Table.
+type Request struct {
+	Destination models.Address
+	Currency    string
+	Items       []Item
+}
+
+// WeightGrams returns the total weight of the request's items.
+func (r Request) WeightGrams() int {
+	var g int
+	for _, it := range r.Items {
+		g += it.WeightGrams * it.Quantity
+	}
+	return g
+}
+
+// Quote is the price of one shipping method for a Request.
+type Quote struct {
+	Method   string       `json:"method"`
+	Name     string       `json:"name"`
+	Amount   models.Money `json:"amount"`
+	Currency string       `json:"currency"`
+}
+
+// RateProvider prices shipping. Implementations backed by a carrier's API
+// should honour ctx.
+type RateProvider interface {
+	// Offers reports whether method is a known method code, whether or
+	// not it can ship any particular request.
+	Offers(method string) bool
+	// Quote returns the methods that can ship req, cheapest first.
+	Quote(ctx context.Context, req Request) ([]Quote, error)
+}
+
+// Find returns the quote for method, reporting whether there was one.
+func Find(quotes []Quote, method string) (Quote, bool) {
+	for _, q := range quotes {
+		if q.Method == method {
+			return q, true
+		}
+	}
+	return Quote{}, false
+}
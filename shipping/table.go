@@ -0,0 +1,113 @@
+package shipping
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Band adds Amount for shipments weighing up to UpToGrams. A band with
+// UpToGrams 0 has no upper limit.
+type Band struct {
+	UpToGrams int          `json:"up_to_grams"`
+	Amount    models.Money `json:"amount"`
+}
+
+// Method is one way of shipping in a Table. It costs Flat plus the first
+// band the shipment's weight fits, in order; a shipment heavier than every
+// band cannot use it. Countries, when set, limits where it ships.
+type Method struct {
+	Code      string       `json:"code"`
+	Name      string       `json:"name"`
+	Currency  string       `json:"currency"`
+	Flat      models.Money `json:"flat"`
+	Bands     []Band       `json:"bands"`
+	Countries []string     `json:"countries"`
+}
+
+// price returns what m charges for grams shipped to country, reporting
+// whether it can ship them at all.
+func (m Method) price(country string, grams int) (models.Money, bool) {
+	if len(m.Countries) > 0 {
+		found := false
+		for _, c := range m.Countries {
+			found = found || c == country
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	if len(m.Bands) == 0 {
+		return m.Flat, true
+	}
+	for _, b := range m.Bands {
+		if b.UpToGrams == 0 || grams <= b.UpToGrams {
+			return m.Flat + b.Amount, true
+		}
+	}
+	return 0, false
+}
+
+// Table is a RateProvider driven by a fixed list of methods.
+type Table struct {
+	Methods []Method
+}
+
+var _ RateProvider = (*Table)(nil)
+
+// DefaultTable offers standard shipping at a flat 5.00 USD and express
+// shipping priced by weight.
+func DefaultTable() *Table {
+	return &Table{Methods: []Method{
+		{Code: "standard", Name: "Standard", Currency: "USD", Flat: 500},
+		{Code: "express", Name: "Express", Currency: "USD", Flat: 1000, Bands: []Band{
+			{UpToGrams: 1000, Amount: 0},
+			{UpToGrams: 5000, Amount: 1000},
+			{UpToGrams: 20000, Amount: 2500},
+		}},
+	}}
+}
+
+// ParseTable reads a Table's methods from a JSON array.
+func ParseTable(data string) (*Table, error) {
+	var t Table
+	if err := json.Unmarshal([]byte(data), &t.Methods); err != nil {
+		return nil, err
+	}
+	for _, m := range t.Methods {
+		if m.Code == "" || !models.ValidCurrency(m.Currency) {
+			return nil, fmt.Errorf("shipping method %q: code and currency are required", m.Code)
+		}
+	}
+	return &t, nil
+}
+
+// Offers implements RateProvider.
+func (t *Table) Offers(method string) bool {
+	for _, m := range t.Methods {
+		if m.Code == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Quote implements RateProvider. Only methods priced in the request's
+// currency are offered.
+func (t *Table) Quote(_ context.Context, req Request) ([]Quote, error) {
+	grams := req.WeightGrams()
+	quotes := []Quote{}
+	for _, m := range t.Methods {
+		if m.Currency != req.Currency {
+			continue
+		}
+		if amount, ok := m.price(req.Destination.Country, grams); ok {
+			quotes = append(quotes, Quote{Method: m.Code, Name: m.Name, Amount: amount, Currency: m.Currency})
+		}
+	}
+	sort.SliceStable(quotes, func(i, j int) bool { return quotes[i].Amount < quotes[j].Amount })
+	return quotes, nil
+}
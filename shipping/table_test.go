@@ -0,0 +1,75 @@
+package shipping
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+func TestTableQuote(t *testing.T) {
+	table := DefaultTable()
+	table.Methods = append(table.Methods,
+		Method{Code: "courier", Name: "Courier", Currency: "USD", Flat: 300, Countries: []string{"US"}},
+		Method{Code: "royal", Name: "Royal Mail", Currency: "GBP", Flat: 400})
+	tests := []struct {
+		name     string
+		country  string
+		currency string
+		grams    int
+		want     []Quote
+	}{
+		{"light parcel at home", "US", "USD", 800, []Quote{
+			{Method: "courier", Name: "Courier", Amount: 300, Currency: "USD"},
+			{Method: "standard", Name: "Standard", Amount: 500, Currency: "USD"},
+			{Method: "express", Name: "Express", Amount: 1000, Currency: "USD"},
+		}},
+		{"heavy parcel abroad", "CA", "USD", 6000, []Quote{
+			{Method: "standard", Name: "Standard", Amount: 500, Currency: "USD"},
+			{Method: "express", Name: "Express", Amount: 3500, Currency: "USD"},
+		}},
+		{"too heavy for express", "CA", "USD", 25000, []Quote{
+			{Method: "standard", Name: "Standard", Amount: 500, Currency: "USD"},
+		}},
+		{"other currency", "GB", "GBP", 100, []Quote{
+			{Method: "royal", Name: "Royal Mail", Amount: 400, Currency: "GBP"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{
+				Destination: models.Address{Country: tt.country},
+				Currency:    tt.currency,
+				Items:       []Item{{ProductID: 1, Quantity: 2, WeightGrams: tt.grams / 2}},
+			}
+			got, err := table.Quote(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Quote: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTable(t *testing.T) {
+	table, err := ParseTable(`[{"code":"post","name":"Post","currency":"EUR","flat":3.5,
+		"bands":[{"up_to_grams":2000,"amount":0},{"amount":4}]}]`)
+	if err != nil {
+		t.Fatalf("ParseTable: %v", err)
+	}
+	if !table.Offers("post") || table.Offers("standard") {
+		t.Errorf("Offers wrong for %+v", table.Methods)
+	}
+	if amount, ok := table.Methods[0].price("DE", 50000); !ok || amount != 750 {
+		t.Errorf("open-ended band = %v, %v; want 7.50", amount, ok)
+	}
+
+	for _, bad := range []string{`{}`, `[{"name":"No code","currency":"EUR"}]`, `[{"code":"x","currency":"euro"}]`} {
+		if _, err := ParseTable(bad); err == nil {
+			t.Errorf("ParseTable(%s) succeeded; want error", bad)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package store
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Memory implements every store interface with in-process maps. It is
+// safe for concurrent use and intended for tests and local experiments.
+type Memory struct {
+	mu sync.Mutex
+
+	products   map[int]models.Product
+	users      map[int]models.User
+	carts      map[int]models.Cart
+	cartItems  map[int]map[lineKey]models.CartItem // cart ID -> line -> item
+	orders     map[int]models.Order
+	orderItems map[int][]models.OrderItem
+	orderKeys  map[orderKey]int // (user, idempotency key) -> order ID
+	history    map[int][]models.OrderStatusChange
+	refresh    map[int]models.RefreshToken
+	payments   map[int]models.Payment
+	events     map[string]models.WebhookEvent
+	outbox     []models.OutboxEvent // event ID - 1 -> event
+	subs       map[int]models.WebhookSubscription
+	deliveries []models.WebhookDelivery // delivery ID - 1 -> delivery
+	revoked    map[string]time.Time     // access token ID -> expiry
+	promos     map[int]models.Promotion
+	discounts  map[int]models.OrderDiscount // order ID -> discount
+	addresses  map[int]models.Address
+	shipments  map[int]models.OrderShipping // order ID -> shipping address
+	refunds    []models.Refund              // refund ID - 1 -> refund
+	returns    []models.Return              // return ID - 1 -> return
+	categories map[int]models.Category
+	productCat map[int]map[int]bool           // product ID -> category IDs
+	options    map[int][]models.ProductOption // product ID -> options
+	variants   map[int]models.Variant
+
+	nextProductID int
+	nextUserID    int
+	nextCartID    int
+	nextOrderID   int
+	nextChangeID  int
+	nextRefreshID int
+	nextPaymentID int
+	nextSubID     int
+	nextPromoID   int
+	nextDiscount  int
+	nextAddressID int
+	nextCategory  int
+	nextVariantID int
+}
+
+type orderKey struct {
+	userID int
+	key    string
+}
+
+// lineKey identifies a cart line by product and variant, with variant 0
+// for products without variants.
+type lineKey struct {
+	productID int
+	variantID int
+}
+
+func newLineKey(productID int, variantID *int) lineKey {
+	k := lineKey{productID: productID}
+	if variantID != nil {
+		k.variantID = *variantID
+	}
+	return k
+}
+
+var (
+	_ ProductStore      = (*Memory)(nil)
+	_ CategoryStore     = (*Memory)(nil)
+	_ VariantStore      = (*Memory)(nil)
+	_ UserStore         = (*Memory)(nil)
+	_ CartStore         = (*Memory)(nil)
+	_ CartExpiryStore   = (*Memory)(nil)
+	_ OrderStore        = (*Memory)(nil)
+	_ TokenStore        = (*Memory)(nil)
+	_ PaymentStore      = (*Memory)(nil)
+	_ RefundStore       = (*Memory)(nil)
+	_ ReturnStore       = (*Memory)(nil)
+	_ WebhookStore      = (*Memory)(nil)
+	_ OutboxStore       = (*Memory)(nil)
+	_ SubscriptionStore = (*Memory)(nil)
+	_ PromotionStore    = (*Memory)(nil)
+	_ AddressStore      = (*Memory)(nil)
+)
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		products:      map[int]models.Product{},
+		users:         map[int]models.User{},
+		carts:         map[int]models.Cart{},
+		cartItems:     map[int]map[lineKey]models.CartItem{},
+		orders:        map[int]models.Order{},
+		orderItems:    map[int][]models.OrderItem{},
+		orderKeys:     map[orderKey]int{},
+		history:       map[int][]models.OrderStatusChange{},
+		refresh:       map[int]models.RefreshToken{},
+		payments:      map[int]models.Payment{},
+		events:        map[string]models.WebhookEvent{},
+		subs:          map[int]models.WebhookSubscription{},
+		revoked:       map[string]time.Time{},
+		promos:        map[int]models.Promotion{},
+		discounts:     map[int]models.OrderDiscount{},
+		addresses:     map[int]models.Address{},
+		shipments:     map[int]models.OrderShipping{},
+		categories:    map[int]models.Category{},
+		productCat:    map[int]map[int]bool{},
+		options:       map[int][]models.ProductOption{},
+		variants:      map[int]models.Variant{},
+		nextProductID: 1,
+		nextUserID:    1,
+		nextCartID:    1,
+		nextOrderID:   1,
+		nextRefreshID: 1,
+		nextPaymentID: 1,
+		nextSubID:     1,
+		nextPromoID:   1,
+		nextDiscount:  1,
+		nextAddressID: 1,
+		nextCategory:  1,
+		nextVariantID: 1,
+	}
+}
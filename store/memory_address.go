@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateAddress implements AddressStore.
+func (m *Memory) CreateAddress(_ context.Context, a *models.Address) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !a.IsDefault {
+		_, hasDefault := m.defaultAddress(a.UserID)
+		a.IsDefault = !hasDefault
+	}
+	now := time.Now()
+	a.ID = m.nextAddressID
+	a.CreatedAt, a.UpdatedAt = now, now
+	m.nextAddressID++
+	m.clearDefaultAddress(*a)
+	m.addresses[a.ID] = *a
+	return nil
+}
+
+// defaultAddress returns the user's default address. The caller must hold
+// m.mu.
+func (m *Memory) defaultAddress(userID int) (models.Address, bool) {
+	for _, a := range m.addresses {
+		if a.UserID == userID && a.IsDefault {
+			return a, true
+		}
+	}
+	return models.Address{}, false
+}
+
+// clearDefaultAddress unsets the user's current default if a is to become
+// the default. The caller must hold m.mu.
+func (m *Memory) clearDefaultAddress(a models.Address) {
+	if !a.IsDefault {
+		return
+	}
+	for id, other := range m.addresses {
+		if other.UserID == a.UserID && other.IsDefault && id != a.ID {
+			other.IsDefault = false
+			other.UpdatedAt = time.Now()
+			m.addresses[id] = other
+		}
+	}
+}
+
+// ListAddresses implements AddressStore.
+func (m *Memory) ListAddresses(_ context.Context, userID int) ([]models.Address, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Address{}
+	for _, a := range m.addresses {
+		if a.UserID == userID {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].IsDefault != out[j].IsDefault {
+			return out[i].IsDefault
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+// GetAddress implements AddressStore.
+func (m *Memory) GetAddress(_ context.Context, id int) (models.Address, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.addresses[id]
+	if !ok {
+		return models.Address{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// DefaultAddress implements AddressStore.
+func (m *Memory) DefaultAddress(_ context.Context, userID int) (models.Address, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.defaultAddress(userID)
+	if !ok {
+		return models.Address{}, ErrNotFound
+	}
+	return a, nil
+}
+
+// UpdateAddress implements AddressStore.
+func (m *Memory) UpdateAddress(_ context.Context, a *models.Address) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.addresses[a.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	a.UserID = old.UserID
+	a.CreatedAt = old.CreatedAt
+	a.UpdatedAt = time.Now()
+	m.clearDefaultAddress(*a)
+	m.addresses[a.ID] = *a
+	return nil
+}
+
+// DeleteAddress implements AddressStore.
+func (m *Memory) DeleteAddress(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.addresses[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.addresses, id)
+	return nil
+}
@@ -0,0 +1,306 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// ActiveCart implements CartStore.
+func (m *Memory) ActiveCart(_ context.Context, userID int) (models.Cart, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.activeCart(userID)
+}
+
+// activeCart is ActiveCart without locking. The caller must hold m.mu.
+func (m *Memory) activeCart(userID int) (models.Cart, bool, error) {
+	for _, cart := range m.carts {
+		if cart.OwnedBy(userID) && cart.Status == models.CartActive {
+			return cart, false, nil
+		}
+	}
+	return m.newCart(&userID), true, nil
+}
+
+// newCart stores an empty active cart. The caller must hold m.mu.
+func (m *Memory) newCart(userID *int) models.Cart {
+	now := time.Now()
+	cart := models.Cart{ID: m.nextCartID, UserID: userID, Status: models.CartActive, CreatedAt: now, UpdatedAt: now}
+	m.nextCartID++
+	m.carts[cart.ID] = cart
+	m.cartItems[cart.ID] = map[lineKey]models.CartItem{}
+	return cart
+}
+
+// CreateGuestCart implements CartStore.
+func (m *Memory) CreateGuestCart(_ context.Context) (models.Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.newCart(nil), nil
+}
+
+// MergeCart implements CartStore.
+func (m *Memory) MergeCart(_ context.Context, guestCartID, userID int, limits CartLimits) (models.Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	guest, ok := m.carts[guestCartID]
+	if !ok || guest.UserID != nil || guest.Status != models.CartActive {
+		return models.Cart{}, ErrNotFound
+	}
+	cart, _, _ := m.activeCart(userID)
+	items := m.cartItems[cart.ID]
+	for _, l := range mergeLines(m.mergeLines(cart.ID), m.mergeLines(guestCartID), limits) {
+		items[newLineKey(l.ProductID, l.VariantID)] = models.CartItem{
+			CartID: cart.ID, ProductID: l.ProductID, VariantID: l.VariantID,
+			Quantity: l.Quantity, AddedPrice: l.AddedPrice,
+		}
+	}
+	m.cartItems[guestCartID] = map[lineKey]models.CartItem{}
+	if cart.PromotionID == nil {
+		cart.PromotionID = guest.PromotionID
+	}
+	if cart.ShippingMethod == "" {
+		cart.ShippingMethod = guest.ShippingMethod
+	}
+	m.carts[cart.ID] = cart
+	guest.Status = models.CartMerged
+	guest.UpdatedAt = time.Now()
+	m.carts[guestCartID] = guest
+	return m.touchCart(cart.ID), nil
+}
+
+// mergeLines returns the cart's lines for MergeCart in line order. The
+// caller must hold m.mu.
+func (m *Memory) mergeLines(cartID int) []mergeLine {
+	var out []mergeLine
+	for _, it := range m.cartItems[cartID] {
+		out = append(out, mergeLine{
+			ProductID: it.ProductID, VariantID: it.VariantID, Quantity: it.Quantity,
+			AddedPrice: it.AddedPrice, Currency: m.products[it.ProductID].Currency,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return lineLess(out[i].ProductID, out[i].VariantID, out[j].ProductID, out[j].VariantID)
+	})
+	return out
+}
+
+// GetCart implements CartStore.
+func (m *Memory) GetCart(_ context.Context, id int) (models.Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cart, ok := m.carts[id]
+	if !ok {
+		return models.Cart{}, ErrNotFound
+	}
+	return cart, nil
+}
+
+// AddCartItem implements CartStore.
+func (m *Memory) AddCartItem(_ context.Context, cartID, productID int, variantID *int, quantity int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items, ok := m.cartItems[cartID]
+	if !ok {
+		return ErrNotFound
+	}
+	price, ok := m.linePrice(productID, variantID)
+	if !ok {
+		return ErrNotFound
+	}
+	key := newLineKey(productID, variantID)
+	items[key] = models.CartItem{
+		CartID: cartID, ProductID: productID, VariantID: variantID,
+		Quantity: items[key].Quantity + quantity, AddedPrice: price,
+	}
+	m.touchCart(cartID)
+	return nil
+}
+
+// SetCartItem implements CartStore.
+func (m *Memory) SetCartItem(_ context.Context, cartID, productID int, variantID *int, quantity int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items, ok := m.cartItems[cartID]
+	if !ok {
+		return ErrNotFound
+	}
+	key := newLineKey(productID, variantID)
+	if quantity == 0 {
+		delete(items, key)
+		m.touchCart(cartID)
+		return nil
+	}
+	price, ok := m.linePrice(productID, variantID)
+	if !ok {
+		return ErrNotFound
+	}
+	items[key] = models.CartItem{
+		CartID: cartID, ProductID: productID, VariantID: variantID, Quantity: quantity, AddedPrice: price,
+	}
+	m.touchCart(cartID)
+	return nil
+}
+
+// RemoveCartItem implements CartStore.
+func (m *Memory) RemoveCartItem(_ context.Context, cartID, productID int, variantID *int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := newLineKey(productID, variantID)
+	if _, ok := m.cartItems[cartID][key]; !ok {
+		return ErrNotFound
+	}
+	delete(m.cartItems[cartID], key)
+	m.touchCart(cartID)
+	return nil
+}
+
+// ClearCart implements CartStore.
+func (m *Memory) ClearCart(_ context.Context, cartID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cartItems[cartID]; !ok {
+		return ErrNotFound
+	}
+	m.cartItems[cartID] = map[lineKey]models.CartItem{}
+	m.touchCart(cartID)
+	return nil
+}
+
+// AcceptCartPrices implements CartStore.
+func (m *Memory) AcceptCartPrices(_ context.Context, cartID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	items, ok := m.cartItems[cartID]
+	if !ok {
+		return ErrNotFound
+	}
+	for key, it := range items {
+		it.AddedPrice, _ = m.linePrice(it.ProductID, it.VariantID)
+		items[key] = it
+	}
+	m.touchCart(cartID)
+	return nil
+}
+
+// SetCartPromotion implements CartStore.
+func (m *Memory) SetCartPromotion(_ context.Context, cartID int, promotionID *int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cart, ok := m.carts[cartID]
+	if !ok {
+		return ErrNotFound
+	}
+	cart.PromotionID = promotionID
+	m.carts[cartID] = cart
+	m.touchCart(cartID)
+	return nil
+}
+
+// SetCartShippingMethod implements CartStore.
+func (m *Memory) SetCartShippingMethod(_ context.Context, cartID int, method string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cart, ok := m.carts[cartID]
+	if !ok {
+		return ErrNotFound
+	}
+	cart.ShippingMethod = method
+	m.carts[cartID] = cart
+	m.touchCart(cartID)
+	return nil
+}
+
+// touchCart records activity on a cart. The caller must hold m.mu.
+func (m *Memory) touchCart(cartID int) models.Cart {
+	cart := m.carts[cartID]
+	cart.UpdatedAt = time.Now()
+	m.carts[cartID] = cart
+	return cart
+}
+
+// CartLines implements CartStore.
+func (m *Memory) CartLines(_ context.Context, cartID int) ([]models.CartLine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.cartLines(cartID), nil
+}
+
+// cartLines builds the joined view of a cart; callers must hold m.mu.
+func (m *Memory) cartLines(cartID int) []models.CartLine {
+	lines := []models.CartLine{}
+	for _, it := range m.cartItems[cartID] {
+		p := m.products[it.ProductID]
+		price, _ := m.linePrice(it.ProductID, it.VariantID)
+		var sku string
+		if it.VariantID != nil {
+			sku = m.variants[*it.VariantID].SKU
+		}
+		lines = append(lines, models.CartLine{
+			CartItem:    it,
+			ProductName: p.Name,
+			SKU:         sku,
+			UnitPrice:   price,
+			Currency:    p.Currency,
+			TaxClass:    p.TaxClass,
+			WeightGrams: p.WeightGrams,
+		})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lineLess(lines[i].ProductID, lines[i].VariantID, lines[j].ProductID, lines[j].VariantID)
+	})
+	return lines
+}
+
+// AbandonIdleCarts implements CartExpiryStore.
+func (m *Memory) AbandonIdleCarts(_ context.Context, idleSince time.Time, limit int) ([]models.Cart, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	idle := []models.Cart{}
+	for _, cart := range m.carts {
+		if cart.Status == models.CartActive && cart.UpdatedAt.Before(idleSince) {
+			idle = append(idle, cart)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i].UpdatedAt.Before(idle[j].UpdatedAt) })
+	if len(idle) > limit {
+		idle = idle[:limit]
+	}
+	for i, cart := range idle {
+		cart.Status = models.CartInactive
+		m.carts[cart.ID] = cart
+		idle[i] = cart
+		lines := m.cartLines(cart.ID)
+		if cart.UserID == nil || len(lines) == 0 {
+			continue
+		}
+		totals := models.TotalCart(lines)
+		count := 0
+		for _, l := range lines {
+			count += l.Quantity
+		}
+		m.enqueue(models.EventCartAbandoned, models.CartAbandoned{
+			CartID: cart.ID, UserID: *cart.UserID, ItemCount: count,
+			Subtotal: totals.Subtotal, Currency: totals.Currency, LastActivity: cart.UpdatedAt,
+		})
+	}
+	return idle, nil
+}
+
+// DeleteGuestCarts implements CartExpiryStore.
+func (m *Memory) DeleteGuestCarts(_ context.Context, idleSince time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, cart := range m.carts {
+		if cart.UserID == nil && cart.UpdatedAt.Before(idleSince) {
+			delete(m.carts, id)
+			delete(m.cartItems, id)
+			n++
+		}
+	}
+	return n, nil
+}
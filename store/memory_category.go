@@ -0,0 +1,167 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateCategory implements CategoryStore.
+func (m *Memory) CreateCategory(_ context.Context, c *models.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkCategory(*c); err != nil {
+		return err
+	}
+	now := time.Now()
+	c.ID = m.nextCategory
+	c.CreatedAt, c.UpdatedAt = now, now
+	m.nextCategory++
+	m.categories[c.ID] = *c
+	return nil
+}
+
+// checkCategory mirrors the Postgres constraints on c's parent and slug.
+// The caller must hold m.mu.
+func (m *Memory) checkCategory(c models.Category) error {
+	if c.ParentID != nil {
+		if _, ok := m.categories[*c.ParentID]; !ok {
+			return ErrNotFound
+		}
+	}
+	for id, other := range m.categories {
+		if other.Slug == c.Slug && id != c.ID {
+			return ErrDuplicateCategorySlug
+		}
+	}
+	return nil
+}
+
+// categorySubtree returns the IDs of the category and all its
+// descendants. The caller must hold m.mu.
+func (m *Memory) categorySubtree(id int) map[int]bool {
+	in := map[int]bool{id: true}
+	for grew := true; grew; {
+		grew = false
+		for _, c := range m.categories {
+			if c.ParentID != nil && in[*c.ParentID] && !in[c.ID] {
+				in[c.ID] = true
+				grew = true
+			}
+		}
+	}
+	return in
+}
+
+// productIn reports whether the product is assigned to any of cats. The
+// caller must hold m.mu.
+func (m *Memory) productIn(productID int, cats map[int]bool) bool {
+	for id := range m.productCat[productID] {
+		if cats[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// sortCategories orders cats by name, then ID.
+func sortCategories(cats []models.Category) {
+	sort.Slice(cats, func(i, j int) bool {
+		if cats[i].Name != cats[j].Name {
+			return cats[i].Name < cats[j].Name
+		}
+		return cats[i].ID < cats[j].ID
+	})
+}
+
+// ListCategories implements CategoryStore.
+func (m *Memory) ListCategories(_ context.Context) ([]models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]models.Category, 0, len(m.categories))
+	for _, c := range m.categories {
+		out = append(out, c)
+	}
+	sortCategories(out)
+	return out, nil
+}
+
+// GetCategory implements CategoryStore.
+func (m *Memory) GetCategory(_ context.Context, id int) (models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.categories[id]
+	if !ok {
+		return models.Category{}, ErrNotFound
+	}
+	return c, nil
+}
+
+// UpdateCategory implements CategoryStore.
+func (m *Memory) UpdateCategory(_ context.Context, c *models.Category) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.categories[c.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if c.ParentID != nil && m.categorySubtree(c.ID)[*c.ParentID] {
+		return ErrCategoryCycle
+	}
+	if err := m.checkCategory(*c); err != nil {
+		return err
+	}
+	cur.ParentID, cur.Name, cur.Slug = c.ParentID, c.Name, c.Slug
+	cur.UpdatedAt = time.Now()
+	m.categories[c.ID] = cur
+	*c = cur
+	return nil
+}
+
+// DeleteCategory implements CategoryStore.
+func (m *Memory) DeleteCategory(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return ErrCategoryHasChildren
+		}
+	}
+	delete(m.categories, id)
+	for _, cats := range m.productCat {
+		delete(cats, id)
+	}
+	return nil
+}
+
+// SetProductCategories implements CategoryStore.
+func (m *Memory) SetProductCategories(_ context.Context, productID int, categoryIDs []int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.products[productID]; !ok {
+		return ErrNotFound
+	}
+	cats := make(map[int]bool, len(categoryIDs))
+	for _, id := range categoryIDs {
+		if _, ok := m.categories[id]; !ok {
+			return ErrNotFound
+		}
+		cats[id] = true
+	}
+	m.productCat[productID] = cats
+	return nil
+}
+
+// ProductCategories implements CategoryStore.
+func (m *Memory) ProductCategories(_ context.Context, productID int) ([]models.Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Category{}
+	for id := range m.productCat[productID] {
+		out = append(out, m.categories[id])
+	}
+	sortCategories(out)
+	return out, nil
+}
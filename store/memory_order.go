@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/promotions"
+)
+
+// CreateOrder implements OrderStore.
+func (m *Memory) CreateOrder(_ context.Context, o *models.Order, items []models.OrderItem, cartID int, idempotencyKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := orderKey{userID: o.UserID, key: idempotencyKey}
+	if idempotencyKey != "" {
+		if _, ok := m.orderKeys[key]; ok {
+			return ErrDuplicateIdempotencyKey
+		}
+	}
+
+	if m.carts[cartID].Status != models.CartActive {
+		return ErrCartNotActive
+	}
+	lines := make([]models.CartItem, 0, len(m.cartItems[cartID]))
+	for _, it := range m.cartItems[cartID] {
+		lines = append(lines, it)
+	}
+	if !sameLines(lines, items) {
+		return ErrCartChanged
+	}
+
+	if d := o.Discount; d != nil && d.PromotionID != nil {
+		p, ok := m.promos[*d.PromotionID]
+		if !ok {
+			return ErrCouponUnavailable
+		}
+		total, byUser := m.promotionUsage(p.ID, o.UserID)
+		if promotions.CheckUsage(p, total, byUser) != nil {
+			return ErrCouponUnavailable
+		}
+	}
+
+	var short []StockShortage
+	for _, it := range items {
+		if have := m.stockOf(it.ProductID, it.VariantID); have < it.Quantity {
+			short = append(short, StockShortage{
+				ProductID: it.ProductID, VariantID: it.VariantID, Requested: it.Quantity, Available: have,
+			})
+		}
+	}
+	if len(short) > 0 {
+		return &InsufficientStockError{Shortages: short}
+	}
+	for _, it := range items {
+		m.addStock(it.ProductID, it.VariantID, -it.Quantity)
+	}
+
+	o.ID = m.nextOrderID
+	o.CreatedAt = time.Now()
+	m.nextOrderID++
+	m.orders[o.ID] = *o
+	if idempotencyKey != "" {
+		m.orderKeys[key] = o.ID
+	}
+	userID := o.UserID
+	m.recordStatusChange(o.ID, nil, o.Status, &userID, "")
+	stored := make([]models.OrderItem, len(items))
+	for i, it := range items {
+		it.OrderID = o.ID
+		stored[i] = it
+	}
+	m.orderItems[o.ID] = stored
+	if d := o.Discount; d != nil {
+		d.ID = m.nextDiscount
+		d.OrderID = o.ID
+		d.CreatedAt = o.CreatedAt
+		m.nextDiscount++
+		m.discounts[o.ID] = *d
+	}
+	if sh := o.Shipping; sh != nil {
+		sh.OrderID = o.ID
+		sh.CreatedAt = o.CreatedAt
+		m.shipments[o.ID] = *sh
+	}
+	m.enqueue(models.EventOrderPlaced, models.OrderPlaced{
+		OrderID: o.ID, UserID: o.UserID, TotalAmount: o.TotalAmount, Currency: o.Currency,
+	})
+	cart := m.carts[cartID]
+	m.cartItems[cartID] = map[lineKey]models.CartItem{}
+	cart.Status = models.CartCheckedOut
+	m.carts[cartID] = cart
+	return nil
+}
+
+// GetOrderByIdempotencyKey implements OrderStore.
+func (m *Memory) GetOrderByIdempotencyKey(_ context.Context, userID int, key string) (models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id, ok := m.orderKeys[orderKey{userID: userID, key: key}]
+	if !ok {
+		return models.Order{}, ErrNotFound
+	}
+	return m.orders[id], nil
+}
+
+// ListOrders implements OrderStore.
+func (m *Memory) ListOrders(_ context.Context, userID int) ([]models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Order{}
+	for _, o := range m.orders {
+		if o.UserID == userID {
+			out = append(out, o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// GetOrder implements OrderStore.
+func (m *Memory) GetOrder(_ context.Context, id int) (models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.orders[id]
+	if !ok {
+		return models.Order{}, ErrNotFound
+	}
+	return o, nil
+}
+
+// OrderLines implements OrderStore.
+func (m *Memory) OrderLines(_ context.Context, orderID int) ([]models.OrderLine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lines := []models.OrderLine{}
+	for _, it := range m.orderItems[orderID] {
+		lines = append(lines, models.OrderLine{OrderItem: it, ProductName: m.products[it.ProductID].Name})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lineLess(lines[i].ProductID, lines[i].VariantID, lines[j].ProductID, lines[j].VariantID)
+	})
+	return lines, nil
+}
+
+// TransitionOrder implements OrderStore.
+func (m *Memory) TransitionOrder(_ context.Context, orderID int, to models.OrderStatus, changedBy *int, note string) (models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.transitionOrder(orderID, to, changedBy, note)
+}
+
+// transitionOrder applies a status change; callers must hold m.mu.
+func (m *Memory) transitionOrder(orderID int, to models.OrderStatus, changedBy *int, note string) (models.Order, error) {
+	o, ok := m.orders[orderID]
+	if !ok {
+		return models.Order{}, ErrNotFound
+	}
+	from := o.Status
+	if !from.CanTransitionTo(to) {
+		return models.Order{}, &models.TransitionError{From: from, To: to}
+	}
+	o.Status = to
+	m.orders[orderID] = o
+	m.recordStatusChange(orderID, &from, to, changedBy, note)
+	m.enqueue(models.EventOrderStatusChanged, models.OrderStatusChanged{
+		OrderID: orderID, UserID: o.UserID, From: from, To: to, ChangedBy: changedBy,
+	})
+	return o, nil
+}
+
+// recordStatusChange appends to an order's history; callers must hold m.mu.
+func (m *Memory) recordStatusChange(orderID int, from *models.OrderStatus, to models.OrderStatus, changedBy *int, note string) {
+	m.nextChangeID++
+	m.history[orderID] = append(m.history[orderID], models.OrderStatusChange{
+		ID:         m.nextChangeID,
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ChangedBy:  changedBy,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// OrderStatusHistory implements OrderStore.
+func (m *Memory) OrderStatusHistory(_ context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.OrderStatusChange{}, m.history[orderID]...), nil
+}
+
+// OrderDiscount implements OrderStore.
+func (m *Memory) OrderDiscount(_ context.Context, orderID int) (models.OrderDiscount, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.discounts[orderID]
+	if !ok {
+		return models.OrderDiscount{}, ErrNotFound
+	}
+	return d, nil
+}
+
+// OrderShipping implements OrderStore.
+func (m *Memory) OrderShipping(_ context.Context, orderID int) (models.OrderShipping, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sh, ok := m.shipments[orderID]
+	if !ok {
+		return models.OrderShipping{}, ErrNotFound
+	}
+	return sh, nil
+}
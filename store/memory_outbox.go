@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// enqueue appends a domain event to the outbox. The caller must hold m.mu.
+func (m *Memory) enqueue(eventType string, payload interface{}) {
+	b, _ := json.Marshal(payload)
+	now := time.Now()
+	m.outbox = append(m.outbox, models.OutboxEvent{
+		ID:          int64(len(m.outbox) + 1),
+		Type:        eventType,
+		Payload:     b,
+		AvailableAt: now,
+		CreatedAt:   now,
+	})
+}
+
+// ClaimOutboxEvents implements OutboxStore.
+func (m *Memory) ClaimOutboxEvents(_ context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	out := []models.OutboxEvent{}
+	for i := range m.outbox {
+		if len(out) == limit {
+			break
+		}
+		e := &m.outbox[i]
+		if e.PublishedAt != nil || e.AvailableAt.After(now) {
+			continue
+		}
+		e.AvailableAt = now.Add(lease)
+		out = append(out, *e)
+	}
+	return out, nil
+}
+
+// MarkOutboxPublished implements OutboxStore.
+func (m *Memory) MarkOutboxPublished(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.outboxEvent(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	e.PublishedAt = &now
+	return nil
+}
+
+// MarkOutboxFailed implements OutboxStore.
+func (m *Memory) MarkOutboxFailed(_ context.Context, id int64, reason string, retryAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, err := m.outboxEvent(id)
+	if err != nil {
+		return err
+	}
+	e.Attempts++
+	e.LastError = reason
+	e.AvailableAt = retryAt
+	return nil
+}
+
+// OutboxEvents returns a copy of every recorded event, for tests.
+func (m *Memory) OutboxEvents() []models.OutboxEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.OutboxEvent(nil), m.outbox...)
+}
+
+// outboxEvent finds an event by ID. The caller must hold m.mu.
+func (m *Memory) outboxEvent(id int64) (*models.OutboxEvent, error) {
+	if id < 1 || id > int64(len(m.outbox)) {
+		return nil, ErrNotFound
+	}
+	return &m.outbox[id-1], nil
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreatePayment implements PaymentStore.
+func (m *Memory) CreatePayment(_ context.Context, p *models.Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.payments {
+		if existing.OrderID == p.OrderID && activePayment(existing.Status) {
+			return ErrActivePayment
+		}
+	}
+	now := time.Now()
+	p.ID = m.nextPaymentID
+	p.CreatedAt, p.UpdatedAt = now, now
+	m.nextPaymentID++
+	m.payments[p.ID] = *p
+	return nil
+}
+
+// activePayment mirrors the payments_order_active index.
+func activePayment(s models.PaymentStatus) bool {
+	return s == models.PaymentPending || s == models.PaymentAuthorized || s == models.PaymentCaptured
+}
+
+// UpdatePayment implements PaymentStore.
+func (m *Memory) UpdatePayment(_ context.Context, p *models.Payment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updatePayment(p)
+}
+
+// updatePayment saves p's mutable fields. The caller must hold m.mu.
+func (m *Memory) updatePayment(p *models.Payment) error {
+	cur, ok := m.payments[p.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	cur.Status, cur.ProviderRef, cur.FailureReason = p.Status, p.ProviderRef, p.FailureReason
+	cur.UpdatedAt = time.Now()
+	m.payments[p.ID] = cur
+	*p = cur
+	return nil
+}
+
+// CapturePayment implements PaymentStore.
+func (m *Memory) CapturePayment(_ context.Context, p *models.Payment, changedBy *int) (models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.payments[p.ID]; !ok {
+		return models.Order{}, ErrNotFound
+	}
+	order, err := m.transitionOrder(p.OrderID, models.StatusPaid, changedBy, "payment captured")
+	if err != nil {
+		return models.Order{}, err
+	}
+	p.Status = models.PaymentCaptured
+	return order, m.updatePayment(p)
+}
+
+// ListPayments implements PaymentStore.
+func (m *Memory) ListPayments(_ context.Context, orderID int) ([]models.Payment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Payment{}
+	for _, p := range m.payments {
+		if p.OrderID == orderID {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
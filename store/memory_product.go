@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateProduct implements ProductStore.
+func (m *Memory) CreateProduct(_ context.Context, p *models.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// mirror the Postgres store: an empty catalog restarts IDs at 1
+	if len(m.products) == 0 {
+		m.nextProductID = 1
+	}
+	now := time.Now()
+	p.ID = m.nextProductID
+	p.CreatedAt, p.UpdatedAt = now, now
+	m.nextProductID++
+	m.products[p.ID] = *p
+	return nil
+}
+
+// ListProducts implements ProductStore. Search is a case-insensitive
+// match of every word against name and description, which approximates
+// the Postgres full-text search without stemming.
+func (m *Memory) ListProducts(_ context.Context, f ProductFilter) ([]models.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sortBy := f.Sort
+	if !sortBy.Valid() {
+		sortBy = SortByID
+	}
+	terms := strings.Fields(strings.ToLower(f.Search))
+	var inCategory map[int]bool
+	if f.Category != nil {
+		inCategory = m.categorySubtree(*f.Category)
+	}
+	out := make([]models.Product, 0, len(m.products))
+	for _, p := range m.products {
+		if !matchesTerms(p, terms) ||
+			(f.MinPrice != nil && p.Price < *f.MinPrice) ||
+			(f.MaxPrice != nil && p.Price > *f.MaxPrice) {
+			continue
+		}
+		if inCategory != nil && !m.productIn(p.ID, inCategory) {
+			continue
+		}
+		if f.After != nil && !productAfter(p, *f.After, sortBy, f.Desc) {
+			continue
+		}
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return productAfter(out[j], NewProductCursor(out[i]), sortBy, f.Desc)
+	})
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+func matchesTerms(p models.Product, terms []string) bool {
+	text := strings.ToLower(p.Name + " " + p.Description)
+	for _, t := range terms {
+		if !strings.Contains(text, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// productAfter reports whether p sorts after the cursor c, or before it
+// when desc is set.
+func productAfter(p models.Product, c ProductCursor, by ProductSort, desc bool) bool {
+	var cmp int
+	switch by {
+	case SortByPrice:
+		cmp = compare(p.Price < c.Price, p.Price > c.Price)
+	case SortByName:
+		cmp = strings.Compare(p.Name, c.Name)
+	case SortByCreatedAt:
+		cmp = compare(p.CreatedAt.Before(c.CreatedAt), p.CreatedAt.After(c.CreatedAt))
+	}
+	if cmp == 0 {
+		cmp = compare(p.ID < c.ID, p.ID > c.ID)
+	}
+	if desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// GetProduct implements ProductStore.
+func (m *Memory) GetProduct(_ context.Context, id int) (models.Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.products[id]
+	if !ok {
+		return models.Product{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// UpdateProduct implements ProductStore.
+func (m *Memory) UpdateProduct(_ context.Context, p *models.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.products[p.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if cur.Price != p.Price {
+		m.enqueue(models.EventProductPriceChanged, models.ProductPriceChanged{
+			ProductID: p.ID, OldPrice: cur.Price, NewPrice: p.Price, Currency: p.Currency,
+		})
+	}
+	cur.Name, cur.Description, cur.Price = p.Name, p.Description, p.Price
+	cur.Currency, cur.StockQuantity = p.Currency, p.StockQuantity
+	cur.TaxClass, cur.WeightGrams = p.TaxClass, p.WeightGrams
+	cur.UpdatedAt = time.Now()
+	m.products[p.ID] = cur
+	return nil
+}
+
+// DeleteProduct implements ProductStore.
+func (m *Memory) DeleteProduct(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.products, id)
+	delete(m.productCat, id)
+	delete(m.options, id)
+	for vid, v := range m.variants {
+		if v.ProductID == id {
+			delete(m.variants, vid)
+		}
+	}
+	for _, items := range m.cartItems {
+		for k := range items {
+			if k.productID == id {
+				delete(items, k)
+			}
+		}
+	}
+	return nil
+}
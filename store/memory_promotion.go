@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreatePromotion implements PromotionStore.
+func (m *Memory) CreatePromotion(_ context.Context, p *models.Promotion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.codeTaken(p.Code, 0) {
+		return ErrDuplicatePromotionCode
+	}
+	now := time.Now()
+	p.ID = m.nextPromoID
+	p.CreatedAt, p.UpdatedAt = now, now
+	p.ProductIDs = append([]int64{}, p.ProductIDs...)
+	p.CategoryIDs = append([]int64{}, p.CategoryIDs...)
+	m.nextPromoID++
+	m.promos[p.ID] = *p
+	return nil
+}
+
+// codeTaken reports whether a promotion other than id uses code. The
+// caller must hold m.mu.
+func (m *Memory) codeTaken(code string, id int) bool {
+	for _, p := range m.promos {
+		if p.Code == code && p.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// ListPromotions implements PromotionStore.
+func (m *Memory) ListPromotions(_ context.Context) ([]models.Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]models.Promotion, 0, len(m.promos))
+	for _, p := range m.promos {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// GetPromotion implements PromotionStore.
+func (m *Memory) GetPromotion(_ context.Context, id int) (models.Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.promos[id]
+	if !ok {
+		return models.Promotion{}, ErrNotFound
+	}
+	return p, nil
+}
+
+// GetPromotionByCode implements PromotionStore.
+func (m *Memory) GetPromotionByCode(_ context.Context, code string) (models.Promotion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.promos {
+		if p.Code == code {
+			return p, nil
+		}
+	}
+	return models.Promotion{}, ErrNotFound
+}
+
+// UpdatePromotion implements PromotionStore.
+func (m *Memory) UpdatePromotion(_ context.Context, p *models.Promotion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.promos[p.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if m.codeTaken(p.Code, p.ID) {
+		return ErrDuplicatePromotionCode
+	}
+	updated := *p
+	updated.ProductIDs = append([]int64{}, p.ProductIDs...)
+	updated.CategoryIDs = append([]int64{}, p.CategoryIDs...)
+	updated.CreatedAt = cur.CreatedAt
+	updated.UpdatedAt = time.Now()
+	m.promos[p.ID] = updated
+	*p = updated
+	return nil
+}
+
+// DeletePromotion implements PromotionStore. Carts and order discounts
+// that referenced the promotion keep their rows but lose the link.
+func (m *Memory) DeletePromotion(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.promos[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.promos, id)
+	for cartID, cart := range m.carts {
+		if cart.PromotionID != nil && *cart.PromotionID == id {
+			cart.PromotionID = nil
+			m.carts[cartID] = cart
+		}
+	}
+	for orderID, d := range m.discounts {
+		if d.PromotionID != nil && *d.PromotionID == id {
+			d.PromotionID = nil
+			m.discounts[orderID] = d
+		}
+	}
+	return nil
+}
+
+// ProductCategoryIDs implements PromotionStore.
+func (m *Memory) ProductCategoryIDs(_ context.Context, productIDs []int) (map[int][]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := map[int][]int{}
+	for _, pid := range productIDs {
+		seen := map[int]bool{}
+		for id := range m.productCat[pid] {
+			// walk up to the root, stopping at ancestors already seen
+			for cur := &id; cur != nil && !seen[*cur]; cur = m.categories[*cur].ParentID {
+				seen[*cur] = true
+			}
+		}
+		for id := range seen {
+			out[pid] = append(out[pid], id)
+		}
+		sort.Ints(out[pid])
+	}
+	return out, nil
+}
+
+// PromotionUsage implements PromotionStore.
+func (m *Memory) PromotionUsage(_ context.Context, promotionID, userID int) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total, byUser := m.promotionUsage(promotionID, userID)
+	return total, byUser, nil
+}
+
+// promotionUsage is PromotionUsage without locking. The caller must hold
+// m.mu.
+func (m *Memory) promotionUsage(promotionID, userID int) (total, byUser int) {
+	for orderID, d := range m.discounts {
+		o := m.orders[orderID]
+		if d.PromotionID == nil || *d.PromotionID != promotionID || o.Status == models.StatusCancelled {
+			continue
+		}
+		total++
+		if o.UserID == userID {
+			byUser++
+		}
+	}
+	return total, byUser
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CancelOrder implements RefundStore.
+func (m *Memory) CancelOrder(_ context.Context, orderID int, changedBy *int, note string) (models.Order, *models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pay, captured := m.capturedPayment(orderID)
+	if o, ok := m.orders[orderID]; ok && o.Status == models.StatusPaid && !captured {
+		return models.Order{}, nil, ErrNoCapturedPayment
+	}
+	order, err := m.transitionOrder(orderID, models.StatusCancelled, changedBy, note)
+	if err != nil {
+		return models.Order{}, nil, err
+	}
+	for _, it := range m.orderItems[orderID] {
+		m.addStock(it.ProductID, it.VariantID, it.Quantity)
+	}
+
+	if !captured {
+		return order, nil, nil
+	}
+	refunded := m.refundedAmount(pay.ID)
+	if refunded >= pay.Amount {
+		return order, nil, nil
+	}
+	r := &models.Refund{
+		OrderID: orderID, PaymentID: pay.ID, Amount: pay.Amount - refunded,
+		Currency: pay.Currency, Reason: note, CreatedBy: changedBy,
+	}
+	m.insertRefund(r)
+	return order, r, nil
+}
+
+// capturedPayment returns the order's captured payment. The caller must
+// hold m.mu.
+func (m *Memory) capturedPayment(orderID int) (models.Payment, bool) {
+	for _, p := range m.payments {
+		if p.OrderID == orderID && p.Status == models.PaymentCaptured {
+			return p, true
+		}
+	}
+	return models.Payment{}, false
+}
+
+// refundedAmount sums the refunds against a payment that have not failed.
+// The caller must hold m.mu.
+func (m *Memory) refundedAmount(paymentID int) models.Money {
+	var sum models.Money
+	for _, r := range m.refunds {
+		if r.PaymentID == paymentID && r.Status != models.RefundFailed {
+			sum += r.Amount
+		}
+	}
+	return sum
+}
+
+// refundedUnits counts the units of a line refunded with the given
+// statuses. The caller must hold m.mu.
+func (m *Memory) refundedUnits(orderID int, line models.OrderItem, include func(models.RefundStatus) bool) int {
+	var n int
+	for _, r := range m.refunds {
+		if r.OrderID == orderID && r.ProductID != nil && *r.ProductID == line.ProductID &&
+			models.SameVariant(r.VariantID, line.VariantID) && include(r.Status) {
+			n += *r.Quantity
+		}
+	}
+	return n
+}
+
+// insertRefund stores r as pending. The caller must hold m.mu.
+func (m *Memory) insertRefund(r *models.Refund) {
+	now := time.Now()
+	r.ID = len(m.refunds) + 1
+	r.Status = models.RefundPending
+	r.CreatedAt, r.UpdatedAt = now, now
+	m.refunds = append(m.refunds, *r)
+}
+
+// CreateRefund implements RefundStore.
+func (m *Memory) CreateRefund(_ context.Context, r *models.Refund) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkRefund(r); err != nil {
+		return err
+	}
+	m.insertRefund(r)
+	return nil
+}
+
+// checkRefund applies CreateRefund's checks to r and fills in its
+// currency. The caller must hold m.mu.
+func (m *Memory) checkRefund(r *models.Refund) error {
+	pay, ok := m.payments[r.PaymentID]
+	if !ok || pay.OrderID != r.OrderID || pay.Status != models.PaymentCaptured {
+		return ErrNotFound
+	}
+	if err := m.checkRefundLimits(r, pay); err != nil {
+		return err
+	}
+	r.Currency = pay.Currency
+	return nil
+}
+
+// checkRefundLimits returns ErrRefundExceeded if r would return more units
+// of its line, or more of pay, than the refunds that have not failed
+// leave. r must not be among them. The caller must hold m.mu.
+func (m *Memory) checkRefundLimits(r *models.Refund, pay models.Payment) error {
+	if r.ProductID != nil {
+		var item *models.OrderItem
+		for _, it := range m.orderItems[r.OrderID] {
+			if it.ProductID == *r.ProductID && models.SameVariant(it.VariantID, r.VariantID) {
+				it := it
+				item = &it
+			}
+		}
+		if item == nil {
+			return ErrNotFound
+		}
+		notFailed := func(s models.RefundStatus) bool { return s != models.RefundFailed }
+		if m.refundedUnits(r.OrderID, *item, notFailed)+*r.Quantity > item.Quantity {
+			return ErrRefundExceeded
+		}
+	}
+	if m.refundedAmount(pay.ID)+r.Amount > pay.Amount {
+		return ErrRefundExceeded
+	}
+	return nil
+}
+
+// FinishRefund implements RefundStore.
+func (m *Memory) FinishRefund(_ context.Context, r *models.Refund) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.ID < 1 || r.ID > len(m.refunds) {
+		return ErrNotFound
+	}
+	cur := &m.refunds[r.ID-1]
+	cur.Status, cur.FailureReason = r.Status, r.FailureReason
+	cur.UpdatedAt = time.Now()
+	*r = *cur
+	if r.Status != models.RefundSucceeded {
+		return nil
+	}
+
+	order := m.orders[r.OrderID]
+	m.enqueue(models.EventOrderRefunded, models.OrderRefunded{
+		OrderID: r.OrderID, UserID: order.UserID, RefundID: r.ID, ProductID: r.ProductID,
+		VariantID: r.VariantID, Quantity: r.Quantity, Amount: r.Amount, Currency: r.Currency,
+	})
+	var succeeded models.Money
+	for _, other := range m.refunds {
+		if other.PaymentID == r.PaymentID && other.Status == models.RefundSucceeded {
+			succeeded += other.Amount
+		}
+	}
+	if pay := m.payments[r.PaymentID]; pay.Amount <= succeeded {
+		pay.Status = models.PaymentRefunded
+		m.updatePayment(&pay)
+	}
+	if r.ProductID == nil {
+		return nil
+	}
+	isSucceeded := func(s models.RefundStatus) bool { return s == models.RefundSucceeded }
+	for _, it := range m.orderItems[r.OrderID] {
+		if m.refundedUnits(r.OrderID, it, isSucceeded) < it.Quantity {
+			return nil
+		}
+	}
+	_, err := m.transitionOrder(r.OrderID, models.StatusRefunded, r.CreatedBy, "all items refunded")
+	var transErr *models.TransitionError
+	if err != nil && !errors.As(err, &transErr) {
+		return err
+	}
+	return nil
+}
+
+// RetryRefund implements RefundStore.
+func (m *Memory) RetryRefund(_ context.Context, orderID, refundID int) (models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if refundID < 1 || refundID > len(m.refunds) || m.refunds[refundID-1].OrderID != orderID {
+		return models.Refund{}, ErrNotFound
+	}
+	r := &m.refunds[refundID-1]
+	if r.Status != models.RefundFailed {
+		return models.Refund{}, ErrRefundNotFailed
+	}
+	pay := m.payments[r.PaymentID]
+	if pay.Status != models.PaymentCaptured {
+		return models.Refund{}, ErrRefundExceeded
+	}
+	if err := m.checkRefundLimits(r, pay); err != nil {
+		return models.Refund{}, err
+	}
+	r.Status, r.FailureReason = models.RefundPending, ""
+	r.UpdatedAt = time.Now()
+	return *r, nil
+}
+
+// ListRefunds implements RefundStore.
+func (m *Memory) ListRefunds(_ context.Context, orderID int) ([]models.Refund, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Refund{}
+	for _, r := range m.refunds {
+		if r.OrderID == orderID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
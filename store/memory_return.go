@@ -0,0 +1,156 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateReturn implements ReturnStore.
+func (m *Memory) CreateReturn(_ context.Context, rt *models.Return) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, it := range rt.Items {
+		ordered := -1
+		for _, oi := range m.orderItems[rt.OrderID] {
+			if oi.ProductID == it.ProductID && models.SameVariant(oi.VariantID, it.VariantID) {
+				ordered = oi.Quantity
+			}
+		}
+		if ordered < 0 {
+			return ErrNotFound
+		}
+		if m.returnedUnits(rt.OrderID, it)+it.Quantity > ordered {
+			return ErrReturnExceeded
+		}
+	}
+	now := time.Now()
+	rt.ID = len(m.returns) + 1
+	rt.Status = models.ReturnRequested
+	rt.CreatedAt, rt.UpdatedAt = now, now
+	items := make([]models.ReturnItem, len(rt.Items))
+	for i, it := range rt.Items {
+		it.ReturnID = rt.ID
+		items[i] = it
+	}
+	rt.Items = items
+	m.returns = append(m.returns, *rt)
+	return nil
+}
+
+// returnedUnits counts the units of a line in returns that were not
+// rejected. The caller must hold m.mu.
+func (m *Memory) returnedUnits(orderID int, line models.ReturnItem) int {
+	var n int
+	for _, rt := range m.returns {
+		if rt.OrderID != orderID || rt.Status == models.ReturnRejected {
+			continue
+		}
+		for _, it := range rt.Items {
+			if it.ProductID == line.ProductID && models.SameVariant(it.VariantID, line.VariantID) {
+				n += it.Quantity
+			}
+		}
+	}
+	return n
+}
+
+// GetReturn implements ReturnStore.
+func (m *Memory) GetReturn(_ context.Context, id int) (models.Return, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.returns) {
+		return models.Return{}, ErrNotFound
+	}
+	return copyReturn(m.returns[id-1]), nil
+}
+
+// copyReturn returns rt with its own copy of the items.
+func copyReturn(rt models.Return) models.Return {
+	rt.Items = append([]models.ReturnItem{}, rt.Items...)
+	return rt
+}
+
+// ListReturns implements ReturnStore.
+func (m *Memory) ListReturns(_ context.Context, orderID int) ([]models.Return, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Return{}
+	for _, rt := range m.returns {
+		if rt.OrderID == orderID {
+			out = append(out, copyReturn(rt))
+		}
+	}
+	return out, nil
+}
+
+// transitionReturn moves a return to status to. The caller must hold m.mu.
+func (m *Memory) transitionReturn(id int, to models.ReturnStatus, changedBy *int, note string) (*models.Return, error) {
+	if id < 1 || id > len(m.returns) {
+		return nil, ErrNotFound
+	}
+	rt := &m.returns[id-1]
+	if !rt.Status.CanTransitionTo(to) {
+		return nil, &models.ReturnTransitionError{From: rt.Status, To: to}
+	}
+	rt.Status, rt.StaffNote, rt.HandledBy = to, note, changedBy
+	rt.UpdatedAt = time.Now()
+	return rt, nil
+}
+
+// ApproveReturn implements ReturnStore.
+func (m *Memory) ApproveReturn(_ context.Context, id int, changedBy *int, note string, refunds []models.Refund) (models.Return, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || id > len(m.returns) {
+		return models.Return{}, ErrNotFound
+	}
+	if from := m.returns[id-1].Status; !from.CanTransitionTo(models.ReturnApproved) {
+		return models.Return{}, &models.ReturnTransitionError{From: from, To: models.ReturnApproved}
+	}
+	// check every refund before recording any, so it is all or nothing;
+	// each is counted against the next as it is recorded
+	start := len(m.refunds)
+	for i := range refunds {
+		refunds[i].ReturnID = &id
+		if err := m.checkRefund(&refunds[i]); err != nil {
+			m.refunds = m.refunds[:start]
+			return models.Return{}, err
+		}
+		m.insertRefund(&refunds[i])
+	}
+	rt, err := m.transitionReturn(id, models.ReturnApproved, changedBy, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+	return copyReturn(*rt), nil
+}
+
+// RejectReturn implements ReturnStore.
+func (m *Memory) RejectReturn(_ context.Context, id int, changedBy *int, note string) (models.Return, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rt, err := m.transitionReturn(id, models.ReturnRejected, changedBy, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+	return copyReturn(*rt), nil
+}
+
+// ReceiveReturn implements ReturnStore.
+func (m *Memory) ReceiveReturn(_ context.Context, id int, changedBy *int, note string, restock bool) (models.Return, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rt, err := m.transitionReturn(id, models.ReturnReceived, changedBy, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+	if restock {
+		for _, it := range rt.Items {
+			m.addStock(it.ProductID, it.VariantID, it.Quantity)
+		}
+		rt.Restocked = true
+	}
+	return copyReturn(*rt), nil
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateWebhookSubscription implements SubscriptionStore.
+func (m *Memory) CreateWebhookSubscription(_ context.Context, sub *models.WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	sub.ID = m.nextSubID
+	sub.CreatedAt, sub.UpdatedAt = now, now
+	sub.EventTypes = append([]string(nil), sub.EventTypes...)
+	m.nextSubID++
+	m.subs[sub.ID] = *sub
+	return nil
+}
+
+// ListWebhookSubscriptions implements SubscriptionStore.
+func (m *Memory) ListWebhookSubscriptions(_ context.Context) ([]models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedSubscriptions(func(models.WebhookSubscription) bool { return true }), nil
+}
+
+// GetWebhookSubscription implements SubscriptionStore.
+func (m *Memory) GetWebhookSubscription(_ context.Context, id int) (models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sub, ok := m.subs[id]
+	if !ok {
+		return models.WebhookSubscription{}, ErrNotFound
+	}
+	return sub, nil
+}
+
+// UpdateWebhookSubscription implements SubscriptionStore.
+func (m *Memory) UpdateWebhookSubscription(_ context.Context, sub *models.WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.subs[sub.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	cur.URL, cur.Secret, cur.Active = sub.URL, sub.Secret, sub.Active
+	cur.EventTypes = append([]string(nil), sub.EventTypes...)
+	cur.UpdatedAt = time.Now()
+	m.subs[sub.ID] = cur
+	*sub = cur
+	return nil
+}
+
+// DeleteWebhookSubscription implements SubscriptionStore.
+func (m *Memory) DeleteWebhookSubscription(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.subs, id)
+	return nil
+}
+
+// SubscriptionsForEvent implements SubscriptionStore.
+func (m *Memory) SubscriptionsForEvent(_ context.Context, eventType string) ([]models.WebhookSubscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedSubscriptions(func(s models.WebhookSubscription) bool {
+		return s.Active && s.Matches(eventType)
+	}), nil
+}
+
+// sortedSubscriptions returns the subscriptions keep accepts, by ID. The
+// caller must hold m.mu.
+func (m *Memory) sortedSubscriptions(keep func(models.WebhookSubscription) bool) []models.WebhookSubscription {
+	out := []models.WebhookSubscription{}
+	for _, s := range m.subs {
+		if keep(s) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RecordWebhookDelivery implements SubscriptionStore.
+func (m *Memory) RecordWebhookDelivery(_ context.Context, d *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d.ID = len(m.deliveries) + 1
+	d.CreatedAt = time.Now()
+	m.deliveries = append(m.deliveries, *d)
+	return nil
+}
+
+// WebhookDelivered implements SubscriptionStore.
+func (m *Memory) WebhookDelivered(_ context.Context, subscriptionID int, eventID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.deliveries {
+		if d.SubscriptionID == subscriptionID && d.EventID == eventID && d.Succeeded {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// deliveryLogLimit caps the attempts ListWebhookDeliveries returns.
+const deliveryLogLimit = 100
+
+// ListWebhookDeliveries implements SubscriptionStore.
+func (m *Memory) ListWebhookDeliveries(_ context.Context, subscriptionID int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.WebhookDelivery{}
+	for i := len(m.deliveries) - 1; i >= 0 && len(out) < deliveryLogLimit; i-- {
+		if m.deliveries[i].SubscriptionID == subscriptionID {
+			out = append(out, m.deliveries[i])
+		}
+	}
+	return out, nil
+}
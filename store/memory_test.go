@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+func TestMemoryProducts(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+
+	p := models.Product{Name: "A", Price: 100}
+	if err := m.CreateProduct(ctx, &p); err != nil || p.ID != 1 {
+		t.Fatalf("CreateProduct = %v, ID %d; want nil, 1", err, p.ID)
+	}
+	p.Name = "B"
+	if err := m.UpdateProduct(ctx, &p); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if got, _ := m.GetProduct(ctx, p.ID); got.Name != "B" {
+		t.Errorf("got name %q; want B", got.Name)
+	}
+	if err := m.UpdateProduct(ctx, &models.Product{ID: 99}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateProduct missing = %v; want ErrNotFound", err)
+	}
+	cart, _, _ := m.ActiveCart(ctx, 1)
+	m.AddCartItem(ctx, cart.ID, p.ID, nil, 1)
+	m.DeleteProduct(ctx, p.ID)
+	if _, err := m.GetProduct(ctx, p.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetProduct after delete = %v; want ErrNotFound", err)
+	}
+	if n := len(m.cartItems[cart.ID]); n != 0 {
+		t.Errorf("cart has %d lines after delete; want 0", n)
+	}
+}
+
+func TestMemoryUsers_DuplicateEmail(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	if err := m.CreateUser(ctx, &models.User{Email: "a@example.com"}); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := m.CreateUser(ctx, &models.User{Email: "a@example.com"}); err == nil {
+		t.Error("expected duplicate email error")
+	}
+}
+
+func TestMemoryCreateOrder_ClearsCart(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	p := models.Product{Name: "A", Price: 200, StockQuantity: 5}
+	m.CreateProduct(ctx, &p)
+	cart, _, _ := m.ActiveCart(ctx, 1)
+	m.AddCartItem(ctx, cart.ID, p.ID, nil, 2)
+	m.AddCartItem(ctx, cart.ID, p.ID, nil, 1)
+
+	lines, _ := m.CartLines(ctx, cart.ID)
+	if len(lines) != 1 || lines[0].Quantity != 3 || lines[0].UnitPrice != 200 {
+		t.Fatalf("got lines %+v; want one line of 3 at 2.00", lines)
+	}
+
+	o := models.Order{UserID: 1, TotalAmount: 600, Status: "pending"}
+	items := []models.OrderItem{{ProductID: p.ID, Quantity: 3, UnitPrice: 200}}
+	if err := m.CreateOrder(ctx, &o, items, cart.ID, ""); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if lines, _ := m.CartLines(ctx, cart.ID); len(lines) != 0 {
+		t.Errorf("cart not cleared: %+v", lines)
+	}
+	if next, created, _ := m.ActiveCart(ctx, 1); !created || next.ID == cart.ID {
+		t.Errorf("got active cart %+v after checkout; want a new one", next)
+	}
+	if got, _ := m.OrderLines(ctx, o.ID); len(got) != 1 || got[0].OrderID != o.ID {
+		t.Errorf("got order lines %+v; want one line for order %d", got, o.ID)
+	}
+}
+
+func TestMemoryBootstrapAdmin(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	if _, err := m.BootstrapAdmin(ctx); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("empty store: err = %v; want ErrNotFound", err)
+	}
+	m.CreateUser(ctx, &models.User{Email: "first@example.com"})
+	m.CreateUser(ctx, &models.User{Email: "second@example.com"})
+
+	u, err := m.BootstrapAdmin(ctx)
+	if err != nil || u.Email != "first@example.com" || u.Role != models.RoleAdmin {
+		t.Fatalf("BootstrapAdmin = %+v, %v; want first user promoted", u, err)
+	}
+	if _, err := m.BootstrapAdmin(ctx); !errors.Is(err, ErrAdminExists) {
+		t.Errorf("second bootstrap: err = %v; want ErrAdminExists", err)
+	}
+}
+
+func TestMemoryRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	first := models.RefreshToken{UserID: 1, FamilyID: "f", TokenHash: "h1", ExpiresAt: time.Now().Add(time.Hour)}
+	m.CreateRefreshToken(ctx, &first)
+
+	next := models.RefreshToken{TokenHash: "h2", ExpiresAt: time.Now().Add(time.Hour)}
+	old, err := m.RotateRefreshToken(ctx, "h1", &next)
+	if err != nil || old.ID != first.ID || next.UserID != 1 || next.FamilyID != "f" {
+		t.Fatalf("RotateRefreshToken = %+v, %v; next = %+v", old, err, next)
+	}
+
+	again := models.RefreshToken{TokenHash: "h3", ExpiresAt: time.Now().Add(time.Hour)}
+	if _, err := m.RotateRefreshToken(ctx, "h1", &again); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse: err = %v; want ErrRefreshTokenReused", err)
+	}
+	if _, err := m.RotateRefreshToken(ctx, "h2", &again); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("family member after reuse: err = %v; want ErrRefreshTokenReused", err)
+	}
+}
+
+func TestMemoryListProducts_SortAndPage(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	for _, p := range []models.Product{
+		{Name: "Red shoe", Price: 3000},
+		{Name: "Blue shoe", Price: 1000},
+		{Name: "Red hat", Price: 2000},
+		{Name: "Green shoe", Price: 2000},
+	} {
+		p := p
+		m.CreateProduct(ctx, &p)
+	}
+
+	f := ProductFilter{Search: "SHOE", Sort: SortByPrice, Desc: true, Limit: 2}
+	page, _ := m.ListProducts(ctx, f)
+	if len(page) != 2 || page[0].ID != 1 || page[1].ID != 4 {
+		t.Fatalf("first page = %+v; want products 1, 4", page)
+	}
+	c := NewProductCursor(page[1])
+	f.After = &c
+	page, _ = m.ListProducts(ctx, f)
+	if len(page) != 1 || page[0].ID != 2 {
+		t.Fatalf("second page = %+v; want product 2", page)
+	}
+}
+
+func TestMemoryCreatePayment_OneActivePerOrder(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	first := models.Payment{OrderID: 1, Status: models.PaymentPending}
+	if err := m.CreatePayment(ctx, &first); err != nil {
+		t.Fatalf("CreatePayment: %v", err)
+	}
+	if err := m.CreatePayment(ctx, &models.Payment{OrderID: 1, Status: models.PaymentPending}); !errors.Is(err, ErrActivePayment) {
+		t.Fatalf("second attempt: err = %v; want ErrActivePayment", err)
+	}
+	first.Status = models.PaymentDeclined
+	m.UpdatePayment(ctx, &first)
+	if err := m.CreatePayment(ctx, &models.Payment{OrderID: 1, Status: models.PaymentPending}); err != nil {
+		t.Errorf("retry after decline: %v", err)
+	}
+}
+
+func TestMemoryMergeCart_CurrencyAndLimits(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	usd := models.Product{Name: "Mug", Price: 500, Currency: "USD", StockQuantity: 50}
+	eur := models.Product{Name: "Tasse", Price: 400, Currency: "EUR", StockQuantity: 50}
+	tea := models.Product{Name: "Tea", Price: 300, Currency: "USD", StockQuantity: 50}
+	for _, p := range []*models.Product{&usd, &eur, &tea} {
+		m.CreateProduct(ctx, p)
+	}
+	cart, _, _ := m.ActiveCart(ctx, 1)
+	m.AddCartItem(ctx, cart.ID, usd.ID, nil, 3)
+	guest, _ := m.CreateGuestCart(ctx)
+	m.AddCartItem(ctx, guest.ID, usd.ID, nil, 9)
+	m.AddCartItem(ctx, guest.ID, eur.ID, nil, 1)
+	m.AddCartItem(ctx, guest.ID, tea.ID, nil, 4)
+
+	if _, err := m.MergeCart(ctx, guest.ID, 1, CartLimits{MaxLine: 5, MaxTotal: 7}); err != nil {
+		t.Fatalf("MergeCart: %v", err)
+	}
+	lines, _ := m.CartLines(ctx, cart.ID)
+	// the mug is capped at 5 a line, which leaves room for 2 of the tea
+	if len(lines) != 2 || lines[0].ProductID != usd.ID || lines[0].Quantity != 5 ||
+		lines[1].ProductID != tea.ID || lines[1].Quantity != 2 {
+		t.Errorf("got lines %+v; want 5 mugs and 2 teas, no EUR line", lines)
+	}
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateRefreshToken implements TokenStore.
+func (m *Memory) CreateRefreshToken(_ context.Context, t *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.insertRefreshToken(t)
+	return nil
+}
+
+// insertRefreshToken stores t. The caller must hold m.mu.
+func (m *Memory) insertRefreshToken(t *models.RefreshToken) {
+	t.ID = m.nextRefreshID
+	t.CreatedAt = time.Now()
+	t.RevokedAt = nil
+	m.nextRefreshID++
+	m.refresh[t.ID] = *t
+}
+
+// RotateRefreshToken implements TokenStore.
+func (m *Memory) RotateRefreshToken(_ context.Context, oldHash string, next *models.RefreshToken) (models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old, ok := m.refreshTokenByHash(oldHash)
+	if !ok {
+		return models.RefreshToken{}, ErrNotFound
+	}
+	if old.RevokedAt != nil {
+		m.revokeFamily(old.FamilyID)
+		return models.RefreshToken{}, ErrRefreshTokenReused
+	}
+	now := time.Now()
+	if !now.Before(old.ExpiresAt) {
+		return models.RefreshToken{}, ErrRefreshTokenExpired
+	}
+	old.RevokedAt = &now
+	m.refresh[old.ID] = old
+	next.UserID = old.UserID
+	next.FamilyID = old.FamilyID
+	m.insertRefreshToken(next)
+	return old, nil
+}
+
+// RevokeRefreshFamily implements TokenStore.
+func (m *Memory) RevokeRefreshFamily(_ context.Context, userID int, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.refreshTokenByHash(hash)
+	if !ok || t.UserID != userID {
+		return ErrNotFound
+	}
+	m.revokeFamily(t.FamilyID)
+	return nil
+}
+
+// refreshTokenByHash finds a token by hash. The caller must hold m.mu.
+func (m *Memory) refreshTokenByHash(hash string) (models.RefreshToken, bool) {
+	for _, t := range m.refresh {
+		if t.TokenHash == hash {
+			return t, true
+		}
+	}
+	return models.RefreshToken{}, false
+}
+
+// revokeFamily revokes every live token in family. The caller must hold m.mu.
+func (m *Memory) revokeFamily(family string) {
+	now := time.Now()
+	for id, t := range m.refresh {
+		if t.FamilyID == family && t.RevokedAt == nil {
+			t.RevokedAt = &now
+			m.refresh[id] = t
+		}
+	}
+}
+
+// RevokeAccessToken implements TokenStore.
+func (m *Memory) RevokeAccessToken(_ context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[jti] = expiresAt
+	return nil
+}
+
+// AccessTokenRevoked implements TokenStore.
+func (m *Memory) AccessTokenRevoked(_ context.Context, jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.revoked[jti]
+	return ok, nil
+}
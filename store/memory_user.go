@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateUser implements UserStore.
+func (m *Memory) CreateUser(_ context.Context, u *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.users {
+		if existing.Email == u.Email {
+			return fmt.Errorf("store: email %q already registered", u.Email)
+		}
+	}
+	if u.Role == "" {
+		u.Role = models.RoleCustomer
+	}
+	u.ID = m.nextUserID
+	u.CreatedAt = time.Now()
+	m.nextUserID++
+	m.users[u.ID] = *u
+	m.enqueue(models.EventUserSignedUp, models.UserSignedUp{UserID: u.ID, Email: u.Email})
+	return nil
+}
+
+// GetUserByEmail implements UserStore.
+func (m *Memory) GetUserByEmail(_ context.Context, email string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// GetUser implements UserStore.
+func (m *Memory) GetUser(_ context.Context, id int) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+// SetUserRole implements UserStore.
+func (m *Memory) SetUserRole(_ context.Context, email string, role models.Role) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, u := range m.users {
+		if u.Email == email {
+			u.Role = role
+			m.users[id] = u
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// BootstrapAdmin implements UserStore.
+func (m *Memory) BootstrapAdmin(_ context.Context) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	first := 0
+	for id, u := range m.users {
+		if u.Role == models.RoleAdmin {
+			return models.User{}, ErrAdminExists
+		}
+		if first == 0 || id < first {
+			first = id
+		}
+	}
+	if first == 0 {
+		return models.User{}, ErrNotFound
+	}
+	u := m.users[first]
+	u.Role = models.RoleAdmin
+	m.users[first] = u
+	return u, nil
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// linePrice returns the current unit price of a line: the variant's price,
+// or the product's for lines without one. It reports false if the product
+// does not exist or the variant is not one of its. The caller must hold
+// m.mu.
+func (m *Memory) linePrice(productID int, variantID *int) (models.Money, bool) {
+	p, ok := m.products[productID]
+	if !ok {
+		return 0, false
+	}
+	if variantID == nil {
+		return p.Price, true
+	}
+	v, ok := m.variants[*variantID]
+	if !ok || v.ProductID != productID {
+		return 0, false
+	}
+	return v.Price, true
+}
+
+// stockOf returns the stock a line draws on. The caller must hold m.mu.
+func (m *Memory) stockOf(productID int, variantID *int) int {
+	if variantID != nil {
+		return m.variants[*variantID].StockQuantity
+	}
+	return m.products[productID].StockQuantity
+}
+
+// addStock adds delta units to the stock a line draws on, if the product
+// or variant still exists. The caller must hold m.mu.
+func (m *Memory) addStock(productID int, variantID *int, delta int) {
+	if variantID != nil {
+		if v, ok := m.variants[*variantID]; ok {
+			v.StockQuantity += delta
+			m.variants[v.ID] = v
+		}
+		return
+	}
+	if p, ok := m.products[productID]; ok {
+		p.StockQuantity += delta
+		m.products[productID] = p
+	}
+}
+
+// lineLess orders lines by product, then variant, with a product's line
+// without a variant first.
+func lineLess(productA int, variantA *int, productB int, variantB *int) bool {
+	a, b := newLineKey(productA, variantA), newLineKey(productB, variantB)
+	if a.productID != b.productID {
+		return a.productID < b.productID
+	}
+	return a.variantID < b.variantID
+}
+
+// SetProductOptions implements VariantStore.
+func (m *Memory) SetProductOptions(_ context.Context, productID int, opts []models.ProductOption) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.products[productID]; !ok {
+		return ErrNotFound
+	}
+	for _, v := range m.variants {
+		if v.ProductID == productID {
+			return ErrProductHasVariants
+		}
+	}
+	m.options[productID] = append([]models.ProductOption{}, opts...)
+	return nil
+}
+
+// ProductOptions implements VariantStore.
+func (m *Memory) ProductOptions(_ context.Context, productID int) ([]models.ProductOption, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.ProductOption{}, m.options[productID]...), nil
+}
+
+// CreateVariant implements VariantStore.
+func (m *Memory) CreateVariant(_ context.Context, v *models.Variant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.products[v.ProductID]; !ok {
+		return ErrNotFound
+	}
+	if err := m.checkVariant(*v); err != nil {
+		return err
+	}
+	now := time.Now()
+	v.ID = m.nextVariantID
+	v.CreatedAt, v.UpdatedAt = now, now
+	m.nextVariantID++
+	m.variants[v.ID] = *v
+	return nil
+}
+
+// checkVariant mirrors the Postgres unique constraints on v's SKU and
+// attributes. The caller must hold m.mu.
+func (m *Memory) checkVariant(v models.Variant) error {
+	for id, other := range m.variants {
+		if id == v.ID {
+			continue
+		}
+		if other.SKU == v.SKU {
+			return ErrDuplicateSKU
+		}
+		if other.ProductID == v.ProductID && sameAttributes(other.Attributes, v.Attributes) {
+			return ErrDuplicateVariant
+		}
+	}
+	return nil
+}
+
+func sameAttributes(a, b models.VariantAttributes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ListVariants implements VariantStore.
+func (m *Memory) ListVariants(_ context.Context, productID int) ([]models.Variant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := []models.Variant{}
+	for _, v := range m.variants {
+		if v.ProductID == productID {
+			out = append(out, v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// GetVariant implements VariantStore.
+func (m *Memory) GetVariant(_ context.Context, id int) (models.Variant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.variants[id]
+	if !ok {
+		return models.Variant{}, ErrNotFound
+	}
+	return v, nil
+}
+
+// UpdateVariant implements VariantStore.
+func (m *Memory) UpdateVariant(_ context.Context, v *models.Variant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.variants[v.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	v.ProductID = cur.ProductID
+	if err := m.checkVariant(*v); err != nil {
+		return err
+	}
+	if cur.Price != v.Price {
+		id := cur.ID
+		m.enqueue(models.EventProductPriceChanged, models.ProductPriceChanged{
+			ProductID: cur.ProductID, VariantID: &id, OldPrice: cur.Price, NewPrice: v.Price,
+			Currency: m.products[cur.ProductID].Currency,
+		})
+	}
+	cur.SKU, cur.Price, cur.StockQuantity, cur.Attributes = v.SKU, v.Price, v.StockQuantity, v.Attributes
+	cur.UpdatedAt = time.Now()
+	m.variants[v.ID] = cur
+	*v = cur
+	return nil
+}
+
+// DeleteVariant implements VariantStore.
+func (m *Memory) DeleteVariant(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, items := range m.orderItems {
+		for _, it := range items {
+			if it.VariantID != nil && *it.VariantID == id {
+				return ErrVariantOrdered
+			}
+		}
+	}
+	v, ok := m.variants[id]
+	if !ok {
+		return nil
+	}
+	delete(m.variants, id)
+	for _, items := range m.cartItems {
+		delete(items, newLineKey(v.ProductID, &id))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// RecordWebhookEvent implements WebhookStore.
+func (m *Memory) RecordWebhookEvent(_ context.Context, e *models.WebhookEvent, to *models.OrderStatus, note string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.events[e.ID]; ok {
+		return ErrDuplicateEvent
+	}
+	if to != nil && e.OrderID != nil {
+		if _, err := m.transitionOrder(*e.OrderID, *to, nil, note); err != nil {
+			return err
+		}
+	}
+	e.ReceivedAt = time.Now()
+	m.events[e.ID] = *e
+	return nil
+}
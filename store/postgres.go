@@ -0,0 +1,45 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Postgres implements every store interface on top of a sqlx handle.
+type Postgres struct {
+	DB *sqlx.DB
+}
+
+var (
+	_ ProductStore      = (*Postgres)(nil)
+	_ CategoryStore     = (*Postgres)(nil)
+	_ VariantStore      = (*Postgres)(nil)
+	_ UserStore         = (*Postgres)(nil)
+	_ CartStore         = (*Postgres)(nil)
+	_ CartExpiryStore   = (*Postgres)(nil)
+	_ OrderStore        = (*Postgres)(nil)
+	_ TokenStore        = (*Postgres)(nil)
+	_ PaymentStore      = (*Postgres)(nil)
+	_ RefundStore       = (*Postgres)(nil)
+	_ ReturnStore       = (*Postgres)(nil)
+	_ WebhookStore      = (*Postgres)(nil)
+	_ OutboxStore       = (*Postgres)(nil)
+	_ SubscriptionStore = (*Postgres)(nil)
+	_ PromotionStore    = (*Postgres)(nil)
+	_ AddressStore      = (*Postgres)(nil)
+)
+
+// NewPostgres returns a Postgres store using db.
+func NewPostgres(db *sqlx.DB) *Postgres {
+	return &Postgres{DB: db}
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound and passes other errors through.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateAddress implements AddressStore.
+func (s *Postgres) CreateAddress(ctx context.Context, a *models.Address) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// serialise changes to the user's address book
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM users WHERE id=$1 FOR UPDATE`, a.UserID); err != nil {
+		return err
+	}
+	if !a.IsDefault {
+		var hasDefault bool
+		if err := tx.GetContext(ctx, &hasDefault,
+			`SELECT EXISTS (SELECT 1 FROM addresses WHERE user_id=$1 AND is_default)`, a.UserID); err != nil {
+			return err
+		}
+		a.IsDefault = !hasDefault
+	}
+	if err := clearDefaultAddress(ctx, tx, a); err != nil {
+		return err
+	}
+	if err := tx.QueryRowxContext(ctx,
+		`INSERT INTO addresses (user_id, name, line1, line2, city, region, postal_code, country, phone, is_default)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING *`,
+		a.UserID, a.Name, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country, a.Phone, a.IsDefault,
+	).StructScan(a); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// clearDefaultAddress unsets the user's current default if a is to become
+// the default.
+func clearDefaultAddress(ctx context.Context, tx *sqlx.Tx, a *models.Address) error {
+	if !a.IsDefault {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		`UPDATE addresses SET is_default=false, updated_at=now()
+		 WHERE user_id=$1 AND is_default AND id<>$2`, a.UserID, a.ID)
+	return err
+}
+
+// ListAddresses implements AddressStore.
+func (s *Postgres) ListAddresses(ctx context.Context, userID int) ([]models.Address, error) {
+	addrs := []models.Address{}
+	if err := s.DB.SelectContext(ctx, &addrs,
+		`SELECT * FROM addresses WHERE user_id=$1 ORDER BY is_default DESC, id`, userID); err != nil {
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// GetAddress implements AddressStore.
+func (s *Postgres) GetAddress(ctx context.Context, id int) (models.Address, error) {
+	var a models.Address
+	err := s.DB.GetContext(ctx, &a, `SELECT * FROM addresses WHERE id=$1`, id)
+	return a, notFound(err)
+}
+
+// DefaultAddress implements AddressStore.
+func (s *Postgres) DefaultAddress(ctx context.Context, userID int) (models.Address, error) {
+	var a models.Address
+	err := s.DB.GetContext(ctx, &a,
+		`SELECT * FROM addresses WHERE user_id=$1 AND is_default`, userID)
+	return a, notFound(err)
+}
+
+// UpdateAddress implements AddressStore.
+func (s *Postgres) UpdateAddress(ctx context.Context, a *models.Address) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.GetContext(ctx, &a.UserID,
+		`SELECT user_id FROM addresses WHERE id=$1 FOR UPDATE`, a.ID); err != nil {
+		return notFound(err)
+	}
+	if err := clearDefaultAddress(ctx, tx, a); err != nil {
+		return err
+	}
+	if err := tx.QueryRowxContext(ctx,
+		`UPDATE addresses
+		 SET name=$1, line1=$2, line2=$3, city=$4, region=$5, postal_code=$6, country=$7, phone=$8,
+			is_default=$9, updated_at=now()
+		 WHERE id=$10
+		 RETURNING *`,
+		a.Name, a.Line1, a.Line2, a.City, a.Region, a.PostalCode, a.Country, a.Phone, a.IsDefault, a.ID,
+	).StructScan(a); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAddress implements AddressStore.
+func (s *Postgres) DeleteAddress(ctx context.Context, id int) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM addresses WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// cartColumns lists the columns of models.Cart.
+const cartColumns = `id, user_id, status, promotion_id, shipping_method, created_at, updated_at`
+
+// activeCartQuery selects a user's active cart.
+const activeCartQuery = `
+    SELECT ` + cartColumns + ` FROM carts
+    WHERE user_id=$1 AND status='active'`
+
+// touchCart prefixes statements that change cart $1's items, so the cart
+// records when it was last active.
+const touchCart = `
+    WITH touched AS (UPDATE carts SET updated_at=now() WHERE id=$1)`
+
+// cartLineSource selects the line to upsert for cart $1, product $2,
+// variant $3 and quantity $4, priced at the variant's price if there is
+// one. It selects nothing if the product does not exist or the variant is
+// not one of the product's. Lines are unique on cartLineKey.
+const cartLineSource = `
+    SELECT $1, p.id, v.id, $4, COALESCE(v.price, p.price)
+    FROM products p
+    LEFT JOIN product_variants v ON v.id=$3 AND v.product_id=p.id
+    WHERE p.id=$2 AND (v.id IS NULL) = ($3::int IS NULL)`
+
+const cartLineKey = `(cart_id, product_id, COALESCE(variant_id, 0))`
+
+// ActiveCart implements CartStore. The carts_user_active index makes
+// concurrent first requests agree on a single cart.
+func (s *Postgres) ActiveCart(ctx context.Context, userID int) (models.Cart, bool, error) {
+	var cart models.Cart
+	err := s.DB.GetContext(ctx, &cart, activeCartQuery, userID)
+	if err == nil {
+		return cart, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return cart, false, err
+	}
+
+	err = s.DB.GetContext(ctx, &cart, `
+    INSERT INTO carts (user_id) VALUES ($1)
+    ON CONFLICT (user_id) WHERE status='active' DO NOTHING
+    RETURNING `+cartColumns, userID)
+	if err == nil {
+		return cart, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return cart, false, err
+	}
+	// lost the race to a concurrent request; use its cart
+	err = s.DB.GetContext(ctx, &cart, activeCartQuery, userID)
+	return cart, false, err
+}
+
+// CreateGuestCart implements CartStore.
+func (s *Postgres) CreateGuestCart(ctx context.Context) (models.Cart, error) {
+	var cart models.Cart
+	err := s.DB.GetContext(ctx, &cart,
+		`INSERT INTO carts (user_id) VALUES (NULL) RETURNING `+cartColumns)
+	return cart, err
+}
+
+// mergeLinesQuery reads cart $1's lines for MergeCart, locked and in line
+// order.
+const mergeLinesQuery = `
+    SELECT ci.product_id, ci.variant_id, ci.quantity, ci.added_price, p.currency
+    FROM cart_items ci
+    JOIN products p ON p.id = ci.product_id
+    WHERE ci.cart_id=$1
+    ORDER BY ci.product_id, COALESCE(ci.variant_id, 0)
+    FOR UPDATE OF ci`
+
+// MergeCart implements CartStore.
+func (s *Postgres) MergeCart(ctx context.Context, guestCartID, userID int, limits CartLimits) (models.Cart, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Cart{}, err
+	}
+	defer tx.Rollback()
+
+	// lock the guest cart so a concurrent login cannot merge it twice
+	var guestID int
+	err = tx.GetContext(ctx, &guestID, `
+    SELECT id FROM carts
+    WHERE id=$1 AND user_id IS NULL AND status='active'
+    FOR UPDATE`, guestCartID)
+	if err != nil {
+		return models.Cart{}, notFound(err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+    INSERT INTO carts (user_id) VALUES ($1)
+    ON CONFLICT (user_id) WHERE status='active' DO NOTHING`, userID); err != nil {
+		return models.Cart{}, err
+	}
+	var cart models.Cart
+	if err := tx.GetContext(ctx, &cart, activeCartQuery+` FOR UPDATE`, userID); err != nil {
+		return models.Cart{}, err
+	}
+
+	var user, guest []mergeLine
+	if err := tx.SelectContext(ctx, &user, mergeLinesQuery, cart.ID); err != nil {
+		return models.Cart{}, err
+	}
+	if err := tx.SelectContext(ctx, &guest, mergeLinesQuery, guestID); err != nil {
+		return models.Cart{}, err
+	}
+	for _, l := range mergeLines(user, guest, limits) {
+		if _, err := tx.ExecContext(ctx, `
+    INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, added_price)
+    VALUES ($1, $2, $3, $4, $5)
+    ON CONFLICT `+cartLineKey+` DO UPDATE
+      SET quantity = EXCLUDED.quantity, added_price = EXCLUDED.added_price`,
+			cart.ID, l.ProductID, l.VariantID, l.Quantity, l.AddedPrice); err != nil {
+			return models.Cart{}, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id=$1`, guestID); err != nil {
+		return models.Cart{}, err
+	}
+	// the user's own coupon and shipping method win over those chosen while
+	// browsing as a guest
+	if err := tx.GetContext(ctx, &cart, `
+    UPDATE carts SET promotion_id = COALESCE(carts.promotion_id, g.promotion_id),
+                     shipping_method = COALESCE(NULLIF(carts.shipping_method, ''), g.shipping_method),
+                     updated_at = now()
+    FROM carts g
+    WHERE carts.id=$1 AND g.id=$2
+    RETURNING carts.id, carts.user_id, carts.status, carts.promotion_id, carts.shipping_method,
+              carts.created_at, carts.updated_at`,
+		cart.ID, guestID); err != nil {
+		return models.Cart{}, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE carts SET status='merged', updated_at=now() WHERE id=$1`, guestID,
+	); err != nil {
+		return models.Cart{}, err
+	}
+	return cart, tx.Commit()
+}
+
+// GetCart implements CartStore.
+func (s *Postgres) GetCart(ctx context.Context, id int) (models.Cart, error) {
+	var cart models.Cart
+	err := s.DB.GetContext(ctx, &cart,
+		`SELECT `+cartColumns+` FROM carts WHERE id=$1`, id)
+	return cart, notFound(err)
+}
+
+// AddCartItem implements CartStore.
+func (s *Postgres) AddCartItem(ctx context.Context, cartID, productID int, variantID *int, quantity int) error {
+	res, err := s.DB.ExecContext(ctx, touchCart+`
+    INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, added_price)`+cartLineSource+`
+    ON CONFLICT `+cartLineKey+` DO UPDATE
+      SET quantity = cart_items.quantity + EXCLUDED.quantity,
+          added_price = EXCLUDED.added_price
+  `, cartID, productID, variantID, quantity)
+	return insertedCartItem(res, err)
+}
+
+// SetCartItem implements CartStore.
+func (s *Postgres) SetCartItem(ctx context.Context, cartID, productID int, variantID *int, quantity int) error {
+	if quantity == 0 {
+		_, err := s.DB.ExecContext(ctx,
+			touchCart+` DELETE FROM cart_items WHERE cart_id=$1 AND product_id=$2 AND variant_id IS NOT DISTINCT FROM $3`,
+			cartID, productID, variantID,
+		)
+		return err
+	}
+	res, err := s.DB.ExecContext(ctx, touchCart+`
+    INSERT INTO cart_items (cart_id, product_id, variant_id, quantity, added_price)`+cartLineSource+`
+    ON CONFLICT `+cartLineKey+` DO UPDATE
+      SET quantity = EXCLUDED.quantity,
+          added_price = EXCLUDED.added_price
+  `, cartID, productID, variantID, quantity)
+	return insertedCartItem(res, err)
+}
+
+// insertedCartItem maps an upsert that selected no line to ErrNotFound.
+func insertedCartItem(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RemoveCartItem implements CartStore.
+func (s *Postgres) RemoveCartItem(ctx context.Context, cartID, productID int, variantID *int) error {
+	res, err := s.DB.ExecContext(ctx,
+		touchCart+` DELETE FROM cart_items WHERE cart_id=$1 AND product_id=$2 AND variant_id IS NOT DISTINCT FROM $3`,
+		cartID, productID, variantID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClearCart implements CartStore.
+func (s *Postgres) ClearCart(ctx context.Context, cartID int) error {
+	_, err := s.DB.ExecContext(ctx, touchCart+` DELETE FROM cart_items WHERE cart_id=$1`, cartID)
+	return err
+}
+
+// AcceptCartPrices implements CartStore.
+func (s *Postgres) AcceptCartPrices(ctx context.Context, cartID int) error {
+	_, err := s.DB.ExecContext(ctx, touchCart+`
+    UPDATE cart_items ci
+    SET added_price = COALESCE((SELECT v.price FROM product_variants v WHERE v.id = ci.variant_id), p.price)
+    FROM products p
+    WHERE p.id = ci.product_id AND ci.cart_id=$1`, cartID)
+	return err
+}
+
+// CartLines implements CartStore.
+func (s *Postgres) CartLines(ctx context.Context, cartID int) ([]models.CartLine, error) {
+	lines := []models.CartLine{}
+	query := `
+    SELECT ci.cart_id, ci.product_id, ci.variant_id, ci.quantity, ci.added_price,
+           p.name, COALESCE(v.sku, '') AS sku, COALESCE(v.price, p.price) AS price,
+           p.currency, p.tax_class, p.weight_grams
+    FROM cart_items ci
+    JOIN products p ON p.id=ci.product_id
+    LEFT JOIN product_variants v ON v.id=ci.variant_id
+    WHERE ci.cart_id=$1
+    ORDER BY ci.product_id, COALESCE(ci.variant_id, 0)`
+	if err := s.DB.SelectContext(ctx, &lines, query, cartID); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// SetCartPromotion implements CartStore.
+func (s *Postgres) SetCartPromotion(ctx context.Context, cartID int, promotionID *int) error {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE carts SET promotion_id=$2, updated_at=now() WHERE id=$1`, cartID, promotionID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetCartShippingMethod implements CartStore.
+func (s *Postgres) SetCartShippingMethod(ctx context.Context, cartID int, method string) error {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE carts SET shipping_method=$2, updated_at=now() WHERE id=$1`, cartID, method)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AbandonIdleCarts implements CartExpiryStore.
+func (s *Postgres) AbandonIdleCarts(ctx context.Context, idleSince time.Time, limit int) ([]models.Cart, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	carts := []models.Cart{}
+	if err := tx.SelectContext(ctx, &carts, `
+    UPDATE carts SET status='inactive'
+    WHERE id IN (
+      SELECT id FROM carts
+      WHERE status='active' AND updated_at < $1
+      ORDER BY updated_at
+      LIMIT $2
+      FOR UPDATE SKIP LOCKED
+    )
+    RETURNING `+cartColumns, idleSince, limit); err != nil {
+		return nil, err
+	}
+	for _, c := range carts {
+		if c.UserID == nil {
+			continue // guests cannot be reminded
+		}
+		var sum struct {
+			Items    int          `db:"items"`
+			Subtotal models.Money `db:"subtotal"`
+			Currency string       `db:"currency"`
+		}
+		if err := tx.GetContext(ctx, &sum, `
+    SELECT COALESCE(SUM(ci.quantity), 0) AS items,
+           COALESCE(SUM(COALESCE(v.price, p.price) * ci.quantity), 0) AS subtotal,
+           COALESCE(MIN(p.currency), '') AS currency
+    FROM cart_items ci
+    JOIN products p ON p.id=ci.product_id
+    LEFT JOIN product_variants v ON v.id=ci.variant_id
+    WHERE ci.cart_id=$1`, c.ID); err != nil {
+			return nil, err
+		}
+		if sum.Items == 0 {
+			continue
+		}
+		if err := enqueue(ctx, tx, models.EventCartAbandoned, models.CartAbandoned{
+			CartID: c.ID, UserID: *c.UserID, ItemCount: sum.Items,
+			Subtotal: sum.Subtotal, Currency: sum.Currency, LastActivity: c.UpdatedAt,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return carts, tx.Commit()
+}
+
+// DeleteGuestCarts implements CartExpiryStore.
+func (s *Postgres) DeleteGuestCarts(ctx context.Context, idleSince time.Time) (int, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`DELETE FROM carts WHERE user_id IS NULL AND updated_at < $1`, idleSince)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
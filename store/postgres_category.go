@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// categorySubtree is a recursive CTE named subtree holding the IDs of the
+// category given by param and all of its descendants.
+func categorySubtree(param string) string {
+	return `WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id=` + param + `
+		UNION ALL
+		SELECT c.id FROM categories c JOIN subtree s ON c.parent_id=s.id
+	)`
+}
+
+// categoryErr maps constraint violations on categories and their product
+// assignments to the store's errors.
+func categoryErr(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "categories_slug_key":
+			return ErrDuplicateCategorySlug
+		case "categories_parent_id_fkey", "product_categories_category_id_fkey":
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+// CreateCategory implements CategoryStore.
+func (s *Postgres) CreateCategory(ctx context.Context, c *models.Category) error {
+	err := s.DB.QueryRowxContext(ctx,
+		`INSERT INTO categories (parent_id, name, slug) VALUES ($1, $2, $3) RETURNING *`,
+		c.ParentID, c.Name, c.Slug,
+	).StructScan(c)
+	return categoryErr(err)
+}
+
+// ListCategories implements CategoryStore.
+func (s *Postgres) ListCategories(ctx context.Context) ([]models.Category, error) {
+	cats := []models.Category{}
+	if err := s.DB.SelectContext(ctx, &cats, `SELECT * FROM categories ORDER BY name, id`); err != nil {
+		return nil, err
+	}
+	return cats, nil
+}
+
+// GetCategory implements CategoryStore.
+func (s *Postgres) GetCategory(ctx context.Context, id int) (models.Category, error) {
+	var c models.Category
+	err := s.DB.GetContext(ctx, &c, `SELECT * FROM categories WHERE id=$1`, id)
+	return c, notFound(err)
+}
+
+// UpdateCategory implements CategoryStore. The table is locked against
+// other writers while the move is checked, so two concurrent moves cannot
+// together form a cycle.
+func (s *Postgres) UpdateCategory(ctx context.Context, c *models.Category) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if c.ParentID != nil {
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		var cycle bool
+		if err := tx.GetContext(ctx, &cycle,
+			categorySubtree("$1")+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id=$2)`,
+			c.ID, *c.ParentID); err != nil {
+			return err
+		}
+		if cycle {
+			return ErrCategoryCycle
+		}
+	}
+	if err := tx.QueryRowxContext(ctx,
+		`UPDATE categories SET parent_id=$1, name=$2, slug=$3, updated_at=now()
+		 WHERE id=$4 RETURNING *`,
+		c.ParentID, c.Name, c.Slug, c.ID,
+	).StructScan(c); err != nil {
+		return categoryErr(notFound(err))
+	}
+	return tx.Commit()
+}
+
+// DeleteCategory implements CategoryStore.
+func (s *Postgres) DeleteCategory(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM categories WHERE id=$1`, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "categories_parent_id_fkey" {
+		return ErrCategoryHasChildren
+	}
+	return err
+}
+
+// SetProductCategories implements CategoryStore.
+func (s *Postgres) SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.GetContext(ctx, &id, `SELECT id FROM products WHERE id=$1 FOR UPDATE`, productID); err != nil {
+		return notFound(err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_categories WHERE product_id=$1`, productID); err != nil {
+		return err
+	}
+	if len(categoryIDs) > 0 {
+		ids := make([]int64, len(categoryIDs))
+		for i, c := range categoryIDs {
+			ids[i] = int64(c)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO product_categories (product_id, category_id)
+			 SELECT $1, unnest($2::int[]) ON CONFLICT DO NOTHING`,
+			productID, pq.Array(ids)); err != nil {
+			return categoryErr(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ProductCategories implements CategoryStore.
+func (s *Postgres) ProductCategories(ctx context.Context, productID int) ([]models.Category, error) {
+	cats := []models.Category{}
+	if err := s.DB.SelectContext(ctx, &cats,
+		`SELECT c.* FROM categories c
+		 JOIN product_categories pc ON pc.category_id=c.id
+		 WHERE pc.product_id=$1 ORDER BY c.name, c.id`, productID); err != nil {
+		return nil, err
+	}
+	return cats, nil
+}
@@ -0,0 +1,355 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/promotions"
+)
+
+// orderColumns lists the columns of models.Order.
+const orderColumns = `id, user_id, total_amount, tax_amount, tax_inclusive, shipping_amount, currency, status, created_at`
+
+// CreateOrder implements OrderStore.
+func (s *Postgres) CreateOrder(ctx context.Context, o *models.Order, items []models.OrderItem, cartID int, idempotencyKey string) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the cart row lock makes concurrent checkouts of one cart take turns
+	if err := lockCheckoutCart(ctx, tx, cartID, items); err != nil {
+		return err
+	}
+	if err := reserveStock(ctx, tx, items); err != nil {
+		return err
+	}
+	if o.Discount != nil && o.Discount.PromotionID != nil {
+		if err := claimPromotion(ctx, tx, *o.Discount.PromotionID, o.UserID); err != nil {
+			return err
+		}
+	}
+
+	ordQ := `
+		INSERT INTO orders (user_id, total_amount, tax_amount, tax_inclusive, shipping_amount, currency, status, idempotency_key)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+		RETURNING ` + orderColumns
+	if err := tx.GetContext(ctx, o, ordQ,
+		o.UserID, o.TotalAmount, o.TaxAmount, o.TaxInclusive, o.ShippingAmount, o.Currency, o.Status, idempotencyKey); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Constraint == "orders_user_idempotency_key" {
+			return ErrDuplicateIdempotencyKey
+		}
+		return err
+	}
+	if err := recordStatusChange(ctx, tx, o.ID, nil, o.Status, &o.UserID, ""); err != nil {
+		return err
+	}
+	for _, it := range items {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO order_items (order_id, product_id, variant_id, sku, quantity, unit_price, tax_amount)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			o.ID, it.ProductID, it.VariantID, it.SKU, it.Quantity, it.UnitPrice, it.TaxAmount,
+		); err != nil {
+			return err
+		}
+	}
+	if d := o.Discount; d != nil {
+		d.OrderID = o.ID
+		if err := tx.QueryRowxContext(ctx,
+			`INSERT INTO order_discounts (order_id, promotion_id, code, amount)
+			 VALUES ($1, $2, $3, $4)
+			 RETURNING id, created_at`,
+			d.OrderID, d.PromotionID, d.Code, d.Amount,
+		).Scan(&d.ID, &d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if sh := o.Shipping; sh != nil {
+		sh.OrderID = o.ID
+		if err := tx.QueryRowxContext(ctx,
+			`INSERT INTO order_shipping (order_id, method, name, line1, line2, city, region, postal_code, country, phone)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			 RETURNING created_at`,
+			sh.OrderID, sh.Method, sh.Name, sh.Line1, sh.Line2, sh.City, sh.Region, sh.PostalCode, sh.Country, sh.Phone,
+		).Scan(&sh.CreatedAt); err != nil {
+			return err
+		}
+	}
+	if err := enqueue(ctx, tx, models.EventOrderPlaced, models.OrderPlaced{
+		OrderID: o.ID, UserID: o.UserID, TotalAmount: o.TotalAmount, Currency: o.Currency,
+	}); err != nil {
+		return err
+	}
+	// clear the cart and check it out
+	if _, err := tx.ExecContext(ctx, `DELETE FROM cart_items WHERE cart_id=$1`, cartID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE carts SET status='checked_out' WHERE id=$1`, cartID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockCheckoutCart locks cartID and returns ErrCartNotActive if it is no
+// longer active, or ErrCartChanged if its lines are not the ones items were
+// built from.
+func lockCheckoutCart(ctx context.Context, tx *sqlx.Tx, cartID int, items []models.OrderItem) error {
+	var id int
+	err := tx.GetContext(ctx, &id,
+		`SELECT id FROM carts WHERE id=$1 AND status=$2 FOR UPDATE`, cartID, models.CartActive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCartNotActive
+	}
+	if err != nil {
+		return err
+	}
+	var lines []models.CartItem
+	if err := tx.SelectContext(ctx, &lines,
+		`SELECT product_id, variant_id, quantity FROM cart_items WHERE cart_id=$1`, cartID); err != nil {
+		return err
+	}
+	if !sameLines(lines, items) {
+		return ErrCartChanged
+	}
+	return nil
+}
+
+// reserveStock locks the ordered products and decrements their stock, or
+// returns *InsufficientStockError without touching anything. Lines for a
+// variant draw on the variant's stock instead of the product's.
+func reserveStock(ctx context.Context, tx *sqlx.Tx, items []models.OrderItem) error {
+	var productIDs, variantIDs []int64
+	for _, it := range items {
+		if it.VariantID != nil {
+			variantIDs = append(variantIDs, int64(*it.VariantID))
+		} else {
+			productIDs = append(productIDs, int64(it.ProductID))
+		}
+	}
+	// always products before variants, so concurrent checkouts cannot
+	// deadlock
+	products, err := lockStock(ctx, tx, "products", productIDs)
+	if err != nil {
+		return err
+	}
+	variants, err := lockStock(ctx, tx, "product_variants", variantIDs)
+	if err != nil {
+		return err
+	}
+
+	var short []StockShortage
+	for _, it := range items {
+		have := products[it.ProductID]
+		if it.VariantID != nil {
+			have = variants[*it.VariantID]
+		}
+		if have < it.Quantity {
+			short = append(short, StockShortage{
+				ProductID: it.ProductID, VariantID: it.VariantID, Requested: it.Quantity, Available: have,
+			})
+		}
+	}
+	if len(short) > 0 {
+		return &InsufficientStockError{Shortages: short}
+	}
+
+	for _, it := range items {
+		table, id := "products", it.ProductID
+		if it.VariantID != nil {
+			table, id = "product_variants", *it.VariantID
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE `+table+` SET stock_quantity = stock_quantity - $1, updated_at = now() WHERE id = $2`,
+			it.Quantity, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockStock locks the rows of table with the given IDs and returns their
+// stock by ID.
+func lockStock(ctx context.Context, tx *sqlx.Tx, table string, ids []int64) (map[int]int, error) {
+	available := make(map[int]int, len(ids))
+	if len(ids) == 0 {
+		return available, nil
+	}
+	// lock in id order so concurrent checkouts cannot deadlock
+	var rows []struct {
+		ID    int `db:"id"`
+		Stock int `db:"stock_quantity"`
+	}
+	if err := tx.SelectContext(ctx, &rows,
+		`SELECT id, stock_quantity FROM `+table+` WHERE id = ANY($1) ORDER BY id FOR UPDATE`,
+		pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		available[r.ID] = r.Stock
+	}
+	return available, nil
+}
+
+// claimPromotion locks the promotion so concurrent checkouts count its
+// uses one at a time, and returns ErrCouponUnavailable if it is gone or
+// the user may not use it again.
+func claimPromotion(ctx context.Context, tx *sqlx.Tx, promotionID, userID int) error {
+	p, err := scanPromotion(tx.QueryRowContext(ctx,
+		`SELECT `+promotionColumns+` FROM promotions WHERE id=$1 FOR UPDATE`, promotionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrCouponUnavailable
+	}
+	if err != nil {
+		return err
+	}
+	var usage struct {
+		Total  int `db:"total"`
+		ByUser int `db:"by_user"`
+	}
+	if err := tx.GetContext(ctx, &usage, promotionUsageQuery, promotionID, userID); err != nil {
+		return err
+	}
+	if promotions.CheckUsage(p, usage.Total, usage.ByUser) != nil {
+		return ErrCouponUnavailable
+	}
+	return nil
+}
+
+// GetOrderByIdempotencyKey implements OrderStore.
+func (s *Postgres) GetOrderByIdempotencyKey(ctx context.Context, userID int, key string) (models.Order, error) {
+	var order models.Order
+	err := s.DB.GetContext(ctx,
+		&order,
+		`SELECT `+orderColumns+`
+		 FROM orders WHERE user_id=$1 AND idempotency_key=$2`, userID, key,
+	)
+	return order, notFound(err)
+}
+
+// ListOrders implements OrderStore.
+func (s *Postgres) ListOrders(ctx context.Context, userID int) ([]models.Order, error) {
+	orders := []models.Order{}
+	if err := s.DB.SelectContext(ctx,
+		&orders,
+		`SELECT `+orderColumns+`
+		 FROM orders WHERE user_id=$1 ORDER BY id`,
+		userID,
+	); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// GetOrder implements OrderStore.
+func (s *Postgres) GetOrder(ctx context.Context, id int) (models.Order, error) {
+	var order models.Order
+	err := s.DB.GetContext(ctx,
+		&order,
+		`SELECT `+orderColumns+`
+		 FROM orders WHERE id=$1`, id,
+	)
+	return order, notFound(err)
+}
+
+// OrderLines implements OrderStore.
+func (s *Postgres) OrderLines(ctx context.Context, orderID int) ([]models.OrderLine, error) {
+	lines := []models.OrderLine{}
+	itemQ := `
+		SELECT oi.order_id, oi.product_id, oi.variant_id, oi.sku, oi.quantity, oi.unit_price, oi.tax_amount, p.name
+		FROM order_items oi
+		JOIN products p ON p.id = oi.product_id
+		WHERE oi.order_id = $1
+		ORDER BY oi.product_id, COALESCE(oi.variant_id, 0)`
+	if err := s.DB.SelectContext(ctx, &lines, itemQ, orderID); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// TransitionOrder implements OrderStore.
+func (s *Postgres) TransitionOrder(ctx context.Context, orderID int, to models.OrderStatus, changedBy *int, note string) (models.Order, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Order{}, err
+	}
+	defer tx.Rollback()
+
+	order, err := transitionOrderTx(ctx, tx, orderID, to, changedBy, note)
+	if err != nil {
+		return models.Order{}, err
+	}
+	return order, tx.Commit()
+}
+
+// transitionOrderTx locks the order row, checks the move against the
+// lifecycle and applies it within tx.
+func transitionOrderTx(ctx context.Context, tx *sqlx.Tx, orderID int, to models.OrderStatus, changedBy *int, note string) (models.Order, error) {
+	var order models.Order
+	if err := tx.GetContext(ctx, &order,
+		`SELECT `+orderColumns+`
+		 FROM orders WHERE id=$1 FOR UPDATE`, orderID); err != nil {
+		return models.Order{}, notFound(err)
+	}
+	from := order.Status
+	if !from.CanTransitionTo(to) {
+		return models.Order{}, &models.TransitionError{From: from, To: to}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE orders SET status=$1 WHERE id=$2`, to, orderID); err != nil {
+		return models.Order{}, err
+	}
+	if err := recordStatusChange(ctx, tx, orderID, &from, to, changedBy, note); err != nil {
+		return models.Order{}, err
+	}
+	if err := enqueue(ctx, tx, models.EventOrderStatusChanged, models.OrderStatusChanged{
+		OrderID: orderID, UserID: order.UserID, From: from, To: to, ChangedBy: changedBy,
+	}); err != nil {
+		return models.Order{}, err
+	}
+	order.Status = to
+	return order, nil
+}
+
+func recordStatusChange(ctx context.Context, tx *sqlx.Tx, orderID int, from *models.OrderStatus, to models.OrderStatus, changedBy *int, note string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, changed_by, note)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		orderID, from, to, changedBy, note)
+	return err
+}
+
+// OrderStatusHistory implements OrderStore.
+func (s *Postgres) OrderStatusHistory(ctx context.Context, orderID int) ([]models.OrderStatusChange, error) {
+	history := []models.OrderStatusChange{}
+	if err := s.DB.SelectContext(ctx, &history,
+		`SELECT id, order_id, from_status, to_status, changed_by, note, created_at
+		 FROM order_status_history WHERE order_id=$1 ORDER BY id`, orderID); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// OrderDiscount implements OrderStore.
+func (s *Postgres) OrderDiscount(ctx context.Context, orderID int) (models.OrderDiscount, error) {
+	var d models.OrderDiscount
+	err := s.DB.GetContext(ctx, &d,
+		`SELECT id, order_id, promotion_id, code, amount, created_at
+		 FROM order_discounts WHERE order_id=$1`, orderID)
+	return d, notFound(err)
+}
+
+// OrderShipping implements OrderStore.
+func (s *Postgres) OrderShipping(ctx context.Context, orderID int) (models.OrderShipping, error) {
+	var sh models.OrderShipping
+	err := s.DB.GetContext(ctx, &sh, `SELECT * FROM order_shipping WHERE order_id=$1`, orderID)
+	return sh, notFound(err)
+}
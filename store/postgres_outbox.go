@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// enqueue records a domain event in the outbox within tx, so it is
+// published if and only if the change it describes commits.
+func enqueue(ctx context.Context, tx *sqlx.Tx, eventType string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	// pass JSON as text; lib/pq would send []byte as bytea
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox (type, payload) VALUES ($1, $2)`, eventType, string(b))
+	return err
+}
+
+// ClaimOutboxEvents implements OutboxStore. SKIP LOCKED lets several
+// dispatchers share the table without handing out the same event twice.
+func (s *Postgres) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error) {
+	events := []models.OutboxEvent{}
+	if err := s.DB.SelectContext(ctx, &events,
+		`UPDATE outbox SET available_at = now() + make_interval(secs => $2)
+		 WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND available_at <= now()
+			ORDER BY id LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING *`, limit, lease.Seconds()); err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+// MarkOutboxPublished implements OutboxStore.
+func (s *Postgres) MarkOutboxPublished(ctx context.Context, id int64) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE outbox SET published_at=now() WHERE id=$1`, id)
+	return err
+}
+
+// MarkOutboxFailed implements OutboxStore.
+func (s *Postgres) MarkOutboxFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	_, err := s.DB.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error=$1, available_at=$2 WHERE id=$3`,
+		reason, retryAt, id)
+	return err
+}
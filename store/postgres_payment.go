@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreatePayment implements PaymentStore.
+func (s *Postgres) CreatePayment(ctx context.Context, p *models.Payment) error {
+	err := s.DB.GetContext(ctx, p,
+		`INSERT INTO payments (order_id, provider, provider_ref, amount, currency, status, failure_reason)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING *`,
+		p.OrderID, p.Provider, p.ProviderRef, p.Amount, p.Currency, p.Status, p.FailureReason)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "payments_order_active" {
+		return ErrActivePayment
+	}
+	return err
+}
+
+// UpdatePayment implements PaymentStore.
+func (s *Postgres) UpdatePayment(ctx context.Context, p *models.Payment) error {
+	err := s.DB.GetContext(ctx, p,
+		`UPDATE payments SET status=$1, provider_ref=$2, failure_reason=$3, updated_at=now()
+		 WHERE id=$4 RETURNING *`,
+		p.Status, p.ProviderRef, p.FailureReason, p.ID)
+	return notFound(err)
+}
+
+// CapturePayment implements PaymentStore.
+func (s *Postgres) CapturePayment(ctx context.Context, p *models.Payment, changedBy *int) (models.Order, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Order{}, err
+	}
+	defer tx.Rollback()
+
+	order, err := transitionOrderTx(ctx, tx, p.OrderID, models.StatusPaid, changedBy, "payment captured")
+	if err != nil {
+		return models.Order{}, err
+	}
+	if err := tx.GetContext(ctx, p,
+		`UPDATE payments SET status=$1, updated_at=now() WHERE id=$2 RETURNING *`,
+		models.PaymentCaptured, p.ID); err != nil {
+		return models.Order{}, notFound(err)
+	}
+	return order, tx.Commit()
+}
+
+// ListPayments implements PaymentStore.
+func (s *Postgres) ListPayments(ctx context.Context, orderID int) ([]models.Payment, error) {
+	payments := []models.Payment{}
+	if err := s.DB.SelectContext(ctx, &payments,
+		`SELECT * FROM payments WHERE order_id=$1 ORDER BY id`, orderID); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
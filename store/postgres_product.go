@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateProduct implements ProductStore.
+func (s *Postgres) CreateProduct(ctx context.Context, p *models.Product) error {
+	// If table is empty, restart the ID sequence
+	var cnt int
+	if err := s.DB.GetContext(ctx, &cnt, "SELECT COUNT(*) FROM products"); err != nil {
+		return err
+	}
+	if cnt == 0 {
+		if _, err := s.DB.ExecContext(ctx, "ALTER SEQUENCE products_id_seq RESTART WITH 1"); err != nil {
+			return err
+		}
+	}
+
+	query := `INSERT INTO products (name, description, price, currency, stock_quantity, tax_class, weight_grams)
+            VALUES ($1, $2, $3, $4, $5, $6, $7)
+            RETURNING id, created_at, updated_at`
+	return s.DB.QueryRowxContext(ctx, query,
+		p.Name, p.Description, p.Price, p.Currency, p.StockQuantity, p.TaxClass, p.WeightGrams).StructScan(p)
+}
+
+// productSearchVector is the document searched by ProductFilter.Search. It
+// matches the products_search index.
+const productSearchVector = `to_tsvector('english', name || ' ' || coalesce(description, ''))`
+
+// ListProducts implements ProductStore.
+func (s *Postgres) ListProducts(ctx context.Context, f ProductFilter) ([]models.Product, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if f.Search != "" {
+		where = append(where, productSearchVector+" @@ plainto_tsquery('english', "+arg(f.Search)+")")
+	}
+	if f.MinPrice != nil {
+		where = append(where, "price >= "+arg(*f.MinPrice))
+	}
+	if f.MaxPrice != nil {
+		where = append(where, "price <= "+arg(*f.MaxPrice))
+	}
+	if f.Category != nil {
+		where = append(where, "id IN ("+categorySubtree(arg(*f.Category))+
+			" SELECT pc.product_id FROM product_categories pc JOIN subtree s ON s.id=pc.category_id)")
+	}
+	sort := f.Sort
+	if !sort.Valid() {
+		sort = SortByID
+	}
+	dir, cmp := "ASC", ">"
+	if f.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if f.After != nil {
+		// row comparison gives keyset pagination on (sort column, id)
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			sort, cmp, arg(f.After.value(sort)), arg(f.After.ID)))
+	}
+
+	query := "SELECT * FROM products"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s", sort, dir, dir)
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+
+	products := []models.Product{}
+	if err := s.DB.SelectContext(ctx, &products, query, args...); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// GetProduct implements ProductStore.
+func (s *Postgres) GetProduct(ctx context.Context, id int) (models.Product, error) {
+	var p models.Product
+	err := s.DB.GetContext(ctx, &p, "SELECT * FROM products WHERE id=$1", id)
+	return p, notFound(err)
+}
+
+// UpdateProduct implements ProductStore.
+func (s *Postgres) UpdateProduct(ctx context.Context, p *models.Product) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldPrice models.Money
+	if err := tx.GetContext(ctx, &oldPrice,
+		`SELECT price FROM products WHERE id=$1 FOR UPDATE`, p.ID); err != nil {
+		return notFound(err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE products SET name=$1, description=$2, price=$3, currency=$4, stock_quantity=$5,
+		 tax_class=$6, weight_grams=$7, updated_at=now() WHERE id=$8`,
+		p.Name, p.Description, p.Price, p.Currency, p.StockQuantity, p.TaxClass, p.WeightGrams, p.ID,
+	); err != nil {
+		return err
+	}
+	if oldPrice != p.Price {
+		if err := enqueue(ctx, tx, models.EventProductPriceChanged, models.ProductPriceChanged{
+			ProductID: p.ID, OldPrice: oldPrice, NewPrice: p.Price, Currency: p.Currency,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteProduct implements ProductStore.
+func (s *Postgres) DeleteProduct(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, "DELETE FROM products WHERE id=$1", id)
+	return err
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+const promotionColumns = `id, code, kind, percent_off, amount_off, buy_quantity, get_quantity,
+	currency, min_spend, max_uses, max_uses_per_user, product_ids, category_ids, starts_at, ends_at,
+	active, created_at, updated_at`
+
+// scanPromotion reads one promotions row; sqlx cannot map an INT[] column
+// onto []int64 by itself.
+func scanPromotion(row interface{ Scan(...interface{}) error }) (models.Promotion, error) {
+	var p models.Promotion
+	err := row.Scan(&p.ID, &p.Code, &p.Kind, &p.PercentOff, &p.AmountOff, &p.BuyQuantity, &p.GetQuantity,
+		&p.Currency, &p.MinSpend, &p.MaxUses, &p.MaxUsesPerUser, pq.Array(&p.ProductIDs), pq.Array(&p.CategoryIDs),
+		&p.StartsAt, &p.EndsAt,
+		&p.Active, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// duplicateCode maps a clash on the promotions code column to
+// ErrDuplicatePromotionCode.
+func duplicateCode(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "promotions_code_key" {
+		return ErrDuplicatePromotionCode
+	}
+	return err
+}
+
+// CreatePromotion implements PromotionStore.
+func (s *Postgres) CreatePromotion(ctx context.Context, p *models.Promotion) error {
+	created, err := scanPromotion(s.DB.QueryRowContext(ctx,
+		`INSERT INTO promotions (code, kind, percent_off, amount_off, buy_quantity, get_quantity,
+			currency, min_spend, max_uses, max_uses_per_user, product_ids, category_ids, starts_at, ends_at, active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		 RETURNING `+promotionColumns,
+		p.Code, p.Kind, p.PercentOff, p.AmountOff, p.BuyQuantity, p.GetQuantity,
+		p.Currency, p.MinSpend, p.MaxUses, p.MaxUsesPerUser, pq.Array(p.ProductIDs), pq.Array(p.CategoryIDs),
+		p.StartsAt, p.EndsAt, p.Active))
+	if err != nil {
+		return duplicateCode(err)
+	}
+	*p = created
+	return nil
+}
+
+// ListPromotions implements PromotionStore.
+func (s *Postgres) ListPromotions(ctx context.Context) ([]models.Promotion, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+promotionColumns+` FROM promotions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	promos := []models.Promotion{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promos = append(promos, p)
+	}
+	return promos, rows.Err()
+}
+
+// GetPromotion implements PromotionStore.
+func (s *Postgres) GetPromotion(ctx context.Context, id int) (models.Promotion, error) {
+	p, err := scanPromotion(s.DB.QueryRowContext(ctx,
+		`SELECT `+promotionColumns+` FROM promotions WHERE id=$1`, id))
+	return p, notFound(err)
+}
+
+// GetPromotionByCode implements PromotionStore.
+func (s *Postgres) GetPromotionByCode(ctx context.Context, code string) (models.Promotion, error) {
+	p, err := scanPromotion(s.DB.QueryRowContext(ctx,
+		`SELECT `+promotionColumns+` FROM promotions WHERE code=$1`, code))
+	return p, notFound(err)
+}
+
+// UpdatePromotion implements PromotionStore.
+func (s *Postgres) UpdatePromotion(ctx context.Context, p *models.Promotion) error {
+	updated, err := scanPromotion(s.DB.QueryRowContext(ctx,
+		`UPDATE promotions
+		 SET code=$1, kind=$2, percent_off=$3, amount_off=$4, buy_quantity=$5, get_quantity=$6,
+			currency=$7, min_spend=$8, max_uses=$9, max_uses_per_user=$10, product_ids=$11,
+			category_ids=$12, starts_at=$13, ends_at=$14, active=$15, updated_at=now()
+		 WHERE id=$16
+		 RETURNING `+promotionColumns,
+		p.Code, p.Kind, p.PercentOff, p.AmountOff, p.BuyQuantity, p.GetQuantity,
+		p.Currency, p.MinSpend, p.MaxUses, p.MaxUsesPerUser, pq.Array(p.ProductIDs), pq.Array(p.CategoryIDs),
+		p.StartsAt, p.EndsAt, p.Active, p.ID))
+	if err != nil {
+		return duplicateCode(notFound(err))
+	}
+	*p = updated
+	return nil
+}
+
+// DeletePromotion implements PromotionStore.
+func (s *Postgres) DeletePromotion(ctx context.Context, id int) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM promotions WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ProductCategoryIDs implements PromotionStore.
+func (s *Postgres) ProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+	var rows []struct {
+		ProductID  int `db:"product_id"`
+		CategoryID int `db:"category_id"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+    WITH RECURSIVE up AS (
+        SELECT product_id, category_id FROM product_categories WHERE product_id = ANY($1)
+        UNION
+        SELECT up.product_id, c.parent_id FROM up
+        JOIN categories c ON c.id = up.category_id
+        WHERE c.parent_id IS NOT NULL
+    )
+    SELECT product_id, category_id FROM up ORDER BY product_id, category_id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	out := map[int][]int{}
+	for _, r := range rows {
+		out[r.ProductID] = append(out[r.ProductID], r.CategoryID)
+	}
+	return out, nil
+}
+
+// promotionUsageQuery counts the live orders placed with promotion $1,
+// overall and by user $2.
+const promotionUsageQuery = `
+    SELECT COUNT(*) AS total,
+           COUNT(*) FILTER (WHERE o.user_id=$2) AS by_user
+    FROM order_discounts d
+    JOIN orders o ON o.id = d.order_id
+    WHERE d.promotion_id=$1 AND o.status <> 'cancelled'`
+
+// PromotionUsage implements PromotionStore.
+func (s *Postgres) PromotionUsage(ctx context.Context, promotionID, userID int) (int, int, error) {
+	var usage struct {
+		Total  int `db:"total"`
+		ByUser int `db:"by_user"`
+	}
+	err := s.DB.GetContext(ctx, &usage, promotionUsageQuery, promotionID, userID)
+	return usage.Total, usage.ByUser, err
+}
@@ -0,0 +1,271 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CancelOrder implements RefundStore.
+func (s *Postgres) CancelOrder(ctx context.Context, orderID int, changedBy *int, note string) (models.Order, *models.Refund, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Order{}, nil, err
+	}
+	defer tx.Rollback()
+
+	var from models.OrderStatus
+	if err := tx.GetContext(ctx, &from,
+		`SELECT status FROM orders WHERE id=$1 FOR UPDATE`, orderID); err != nil {
+		return models.Order{}, nil, notFound(err)
+	}
+	order, err := transitionOrderTx(ctx, tx, orderID, models.StatusCancelled, changedBy, note)
+	if err != nil {
+		return models.Order{}, nil, err
+	}
+	if err := restockLines(ctx, tx, "order_items", "order_id", orderID); err != nil {
+		return models.Order{}, nil, err
+	}
+
+	// give back whatever of a captured payment has not been refunded yet
+	var pay models.Payment
+	err = tx.GetContext(ctx, &pay,
+		`SELECT * FROM payments WHERE order_id=$1 AND status=$2 FOR UPDATE`,
+		orderID, models.PaymentCaptured)
+	if errors.Is(err, sql.ErrNoRows) {
+		if from == models.StatusPaid {
+			return models.Order{}, nil, ErrNoCapturedPayment
+		}
+		return order, nil, tx.Commit()
+	}
+	if err != nil {
+		return models.Order{}, nil, err
+	}
+	refunded, err := refundedAmount(ctx, tx, pay.ID)
+	if err != nil {
+		return models.Order{}, nil, err
+	}
+	if refunded >= pay.Amount {
+		return order, nil, tx.Commit()
+	}
+	r := &models.Refund{
+		OrderID: orderID, PaymentID: pay.ID, Amount: pay.Amount - refunded,
+		Currency: pay.Currency, Reason: note, CreatedBy: changedBy,
+	}
+	if err := insertRefund(ctx, tx, r); err != nil {
+		return models.Order{}, nil, err
+	}
+	return order, r, tx.Commit()
+}
+
+// restockLines puts the units of the lines in table whose column equals id
+// back into stock: the variant's stock for variant lines and the
+// product's for the rest.
+func restockLines(ctx context.Context, tx *sqlx.Tx, table, column string, id int) error {
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE products p SET stock_quantity = p.stock_quantity + l.quantity, updated_at = now()
+    FROM `+table+` l
+    WHERE l.`+column+`=$1 AND p.id=l.product_id AND l.variant_id IS NULL`, id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+    UPDATE product_variants v SET stock_quantity = v.stock_quantity + l.quantity, updated_at = now()
+    FROM `+table+` l
+    WHERE l.`+column+`=$1 AND v.id=l.variant_id`, id)
+	return err
+}
+
+// refundedAmount sums the refunds against a payment that have not failed.
+func refundedAmount(ctx context.Context, tx *sqlx.Tx, paymentID int) (models.Money, error) {
+	var sum models.Money
+	err := tx.GetContext(ctx, &sum,
+		`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id=$1 AND status<>$2`,
+		paymentID, models.RefundFailed)
+	return sum, err
+}
+
+func insertRefund(ctx context.Context, tx *sqlx.Tx, r *models.Refund) error {
+	r.Status = models.RefundPending
+	return tx.GetContext(ctx, r,
+		`INSERT INTO refunds (order_id, payment_id, product_id, variant_id, quantity, return_id, amount, currency, reason, status, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING *`,
+		r.OrderID, r.PaymentID, r.ProductID, r.VariantID, r.Quantity, r.ReturnID, r.Amount, r.Currency, r.Reason, r.Status, r.CreatedBy)
+}
+
+// CreateRefund implements RefundStore.
+func (s *Postgres) CreateRefund(ctx context.Context, r *models.Refund) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createRefundTx(ctx, tx, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// createRefundTx checks and records a line refund within tx.
+func createRefundTx(ctx context.Context, tx *sqlx.Tx, r *models.Refund) error {
+	// the payment row lock serialises refunds against it
+	var pay models.Payment
+	if err := tx.GetContext(ctx, &pay,
+		`SELECT * FROM payments WHERE id=$1 AND order_id=$2 AND status=$3 FOR UPDATE`,
+		r.PaymentID, r.OrderID, models.PaymentCaptured); err != nil {
+		return notFound(err)
+	}
+	if err := checkRefundTx(ctx, tx, r, pay); err != nil {
+		return err
+	}
+	r.Currency = pay.Currency
+	return insertRefund(ctx, tx, r)
+}
+
+// checkRefundTx returns ErrRefundExceeded if r would return more units of
+// its line, or more of pay, than the refunds that have not failed leave.
+// r must not be among them. The caller must hold pay's row lock.
+func checkRefundTx(ctx context.Context, tx *sqlx.Tx, r *models.Refund, pay models.Payment) error {
+	if r.ProductID != nil {
+		var line struct {
+			Quantity int `db:"quantity"`
+			Refunded int `db:"refunded"`
+		}
+		if err := tx.GetContext(ctx, &line, `
+    SELECT oi.quantity,
+           (SELECT COALESCE(SUM(r.quantity), 0) FROM refunds r
+            WHERE r.order_id=oi.order_id AND r.product_id=oi.product_id
+              AND r.variant_id IS NOT DISTINCT FROM oi.variant_id AND r.status<>$3) AS refunded
+    FROM order_items oi
+    WHERE oi.order_id=$1 AND oi.product_id=$2 AND oi.variant_id IS NOT DISTINCT FROM $4`,
+			r.OrderID, *r.ProductID, models.RefundFailed, r.VariantID); err != nil {
+			return notFound(err)
+		}
+		if line.Refunded+*r.Quantity > line.Quantity {
+			return ErrRefundExceeded
+		}
+	}
+	refunded, err := refundedAmount(ctx, tx, pay.ID)
+	if err != nil {
+		return err
+	}
+	if refunded+r.Amount > pay.Amount {
+		return ErrRefundExceeded
+	}
+	return nil
+}
+
+// FinishRefund implements RefundStore.
+func (s *Postgres) FinishRefund(ctx context.Context, r *models.Refund) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.GetContext(ctx, r,
+		`UPDATE refunds SET status=$1, failure_reason=$2, updated_at=now()
+		 WHERE id=$3 RETURNING *`,
+		r.Status, r.FailureReason, r.ID); err != nil {
+		return notFound(err)
+	}
+	if r.Status != models.RefundSucceeded {
+		return tx.Commit()
+	}
+
+	var userID int
+	if err := tx.GetContext(ctx, &userID, `SELECT user_id FROM orders WHERE id=$1`, r.OrderID); err != nil {
+		return notFound(err)
+	}
+	if err := enqueue(ctx, tx, models.EventOrderRefunded, models.OrderRefunded{
+		OrderID: r.OrderID, UserID: userID, RefundID: r.ID, ProductID: r.ProductID,
+		VariantID: r.VariantID, Quantity: r.Quantity, Amount: r.Amount, Currency: r.Currency,
+	}); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+    UPDATE payments SET status=$2, updated_at=now()
+    WHERE id=$1 AND amount <= (SELECT COALESCE(SUM(amount), 0) FROM refunds
+                               WHERE payment_id=$1 AND status=$3)`,
+		r.PaymentID, models.PaymentRefunded, models.RefundSucceeded); err != nil {
+		return err
+	}
+	if r.ProductID == nil {
+		return tx.Commit()
+	}
+	var allRefunded bool
+	if err := tx.GetContext(ctx, &allRefunded, `
+    SELECT NOT EXISTS (
+        SELECT 1 FROM order_items oi
+        WHERE oi.order_id=$1 AND oi.quantity > (
+            SELECT COALESCE(SUM(r.quantity), 0) FROM refunds r
+            WHERE r.order_id=oi.order_id AND r.product_id=oi.product_id
+              AND r.variant_id IS NOT DISTINCT FROM oi.variant_id AND r.status=$2))`,
+		r.OrderID, models.RefundSucceeded); err != nil {
+		return err
+	}
+	if allRefunded {
+		_, err := transitionOrderTx(ctx, tx, r.OrderID, models.StatusRefunded, r.CreatedBy, "all items refunded")
+		var transErr *models.TransitionError
+		if err != nil && !errors.As(err, &transErr) {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RetryRefund implements RefundStore.
+func (s *Postgres) RetryRefund(ctx context.Context, orderID, refundID int) (models.Refund, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	defer tx.Rollback()
+
+	// the refund row lock stops two retries sending it twice
+	var r models.Refund
+	if err := tx.GetContext(ctx, &r,
+		`SELECT * FROM refunds WHERE id=$1 AND order_id=$2 FOR UPDATE`,
+		refundID, orderID); err != nil {
+		return models.Refund{}, notFound(err)
+	}
+	if r.Status != models.RefundFailed {
+		return models.Refund{}, ErrRefundNotFailed
+	}
+	// a payment no longer captured has nothing left to refund
+	var pay models.Payment
+	err = tx.GetContext(ctx, &pay,
+		`SELECT * FROM payments WHERE id=$1 AND status=$2 FOR UPDATE`,
+		r.PaymentID, models.PaymentCaptured)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Refund{}, ErrRefundExceeded
+	}
+	if err != nil {
+		return models.Refund{}, err
+	}
+	if err := checkRefundTx(ctx, tx, &r, pay); err != nil {
+		return models.Refund{}, err
+	}
+	if err := tx.GetContext(ctx, &r,
+		`UPDATE refunds SET status=$1, failure_reason='', updated_at=now()
+		 WHERE id=$2 RETURNING *`,
+		models.RefundPending, r.ID); err != nil {
+		return models.Refund{}, err
+	}
+	return r, tx.Commit()
+}
+
+// ListRefunds implements RefundStore.
+func (s *Postgres) ListRefunds(ctx context.Context, orderID int) ([]models.Refund, error) {
+	refunds := []models.Refund{}
+	if err := s.DB.SelectContext(ctx, &refunds,
+		`SELECT * FROM refunds WHERE order_id=$1 ORDER BY id`, orderID); err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
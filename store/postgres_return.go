@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateReturn implements ReturnStore.
+func (s *Postgres) CreateReturn(ctx context.Context, rt *models.Return) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the order row lock serialises returns against it
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM orders WHERE id=$1 FOR UPDATE`, rt.OrderID); err != nil {
+		return err
+	}
+	for _, it := range rt.Items {
+		var line struct {
+			Quantity int `db:"quantity"`
+			Returned int `db:"returned"`
+		}
+		if err := tx.GetContext(ctx, &line, `
+    SELECT oi.quantity,
+           (SELECT COALESCE(SUM(ri.quantity), 0)
+            FROM return_items ri JOIN returns rt ON rt.id=ri.return_id
+            WHERE rt.order_id=oi.order_id AND ri.product_id=oi.product_id
+              AND ri.variant_id IS NOT DISTINCT FROM oi.variant_id AND rt.status<>$3) AS returned
+    FROM order_items oi
+    WHERE oi.order_id=$1 AND oi.product_id=$2 AND oi.variant_id IS NOT DISTINCT FROM $4`,
+			rt.OrderID, it.ProductID, models.ReturnRejected, it.VariantID); err != nil {
+			return notFound(err)
+		}
+		if line.Returned+it.Quantity > line.Quantity {
+			return ErrReturnExceeded
+		}
+	}
+
+	rt.Status = models.ReturnRequested
+	items := rt.Items
+	if err := tx.GetContext(ctx, rt,
+		`INSERT INTO returns (order_id, user_id, status, reason)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING *`,
+		rt.OrderID, rt.UserID, rt.Status, rt.Reason); err != nil {
+		return err
+	}
+	for i := range items {
+		items[i].ReturnID = rt.ID
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO return_items (return_id, product_id, variant_id, quantity) VALUES ($1, $2, $3, $4)`,
+			rt.ID, items[i].ProductID, items[i].VariantID, items[i].Quantity); err != nil {
+			return err
+		}
+	}
+	rt.Items = items
+	return tx.Commit()
+}
+
+// GetReturn implements ReturnStore.
+func (s *Postgres) GetReturn(ctx context.Context, id int) (models.Return, error) {
+	var rt models.Return
+	if err := s.DB.GetContext(ctx, &rt, `SELECT * FROM returns WHERE id=$1`, id); err != nil {
+		return models.Return{}, notFound(err)
+	}
+	rt.Items = []models.ReturnItem{}
+	err := s.DB.SelectContext(ctx, &rt.Items,
+		`SELECT * FROM return_items WHERE return_id=$1 ORDER BY product_id, COALESCE(variant_id, 0)`, id)
+	return rt, err
+}
+
+// ListReturns implements ReturnStore.
+func (s *Postgres) ListReturns(ctx context.Context, orderID int) ([]models.Return, error) {
+	returns := []models.Return{}
+	if err := s.DB.SelectContext(ctx, &returns,
+		`SELECT * FROM returns WHERE order_id=$1 ORDER BY id`, orderID); err != nil {
+		return nil, err
+	}
+	var items []models.ReturnItem
+	if err := s.DB.SelectContext(ctx, &items, `
+    SELECT ri.* FROM return_items ri JOIN returns rt ON rt.id=ri.return_id
+    WHERE rt.order_id=$1
+    ORDER BY ri.return_id, ri.product_id, COALESCE(ri.variant_id, 0)`, orderID); err != nil {
+		return nil, err
+	}
+	byReturn := map[int][]models.ReturnItem{}
+	for _, it := range items {
+		byReturn[it.ReturnID] = append(byReturn[it.ReturnID], it)
+	}
+	for i := range returns {
+		returns[i].Items = append([]models.ReturnItem{}, byReturn[returns[i].ID]...)
+	}
+	return returns, nil
+}
+
+// transitionReturnTx moves a return to status to within tx, recording the
+// staff member and their note.
+func transitionReturnTx(ctx context.Context, tx *sqlx.Tx, id int, to models.ReturnStatus, changedBy *int, note string) (models.Return, error) {
+	var rt models.Return
+	if err := tx.GetContext(ctx, &rt, `SELECT * FROM returns WHERE id=$1 FOR UPDATE`, id); err != nil {
+		return models.Return{}, notFound(err)
+	}
+	if !rt.Status.CanTransitionTo(to) {
+		return models.Return{}, &models.ReturnTransitionError{From: rt.Status, To: to}
+	}
+	if err := tx.GetContext(ctx, &rt,
+		`UPDATE returns SET status=$1, staff_note=$2, handled_by=$3, updated_at=now()
+		 WHERE id=$4 RETURNING *`,
+		to, note, changedBy, id); err != nil {
+		return models.Return{}, err
+	}
+	rt.Items = []models.ReturnItem{}
+	err := tx.SelectContext(ctx, &rt.Items,
+		`SELECT * FROM return_items WHERE return_id=$1 ORDER BY product_id, COALESCE(variant_id, 0)`, id)
+	return rt, err
+}
+
+// ApproveReturn implements ReturnStore.
+func (s *Postgres) ApproveReturn(ctx context.Context, id int, changedBy *int, note string, refunds []models.Refund) (models.Return, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Return{}, err
+	}
+	defer tx.Rollback()
+
+	rt, err := transitionReturnTx(ctx, tx, id, models.ReturnApproved, changedBy, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+	for i := range refunds {
+		refunds[i].ReturnID = &rt.ID
+		if err := createRefundTx(ctx, tx, &refunds[i]); err != nil {
+			return models.Return{}, err
+		}
+	}
+	return rt, tx.Commit()
+}
+
+// RejectReturn implements ReturnStore.
+func (s *Postgres) RejectReturn(ctx context.Context, id int, changedBy *int, note string) (models.Return, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Return{}, err
+	}
+	defer tx.Rollback()
+
+	rt, err := transitionReturnTx(ctx, tx, id, models.ReturnRejected, changedBy, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+	return rt, tx.Commit()
+}
+
+// ReceiveReturn implements ReturnStore.
+func (s *Postgres) ReceiveReturn(ctx context.Context, id int, changedBy *int, note string, restock bool) (models.Return, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.Return{}, err
+	}
+	defer tx.Rollback()
+
+	rt, err := transitionReturnTx(ctx, tx, id, models.ReturnReceived, changedBy, note)
+	if err != nil {
+		return models.Return{}, err
+	}
+	if restock {
+		if err := restockLines(ctx, tx, "return_items", "return_id", id); err != nil {
+			return models.Return{}, err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE returns SET restocked=true WHERE id=$1`, id); err != nil {
+			return models.Return{}, err
+		}
+		rt.Restocked = true
+	}
+	return rt, tx.Commit()
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+const subscriptionColumns = `id, url, event_types, secret, active, created_at, updated_at`
+
+// scanSubscription reads one webhook_subscriptions row; sqlx cannot map a
+// TEXT[] column onto []string by itself.
+func scanSubscription(row interface{ Scan(...interface{}) error }) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := row.Scan(&sub.ID, &sub.URL, pq.Array(&sub.EventTypes), &sub.Secret,
+		&sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	return sub, err
+}
+
+// selectSubscriptions runs query and scans every row.
+func (s *Postgres) selectSubscriptions(ctx context.Context, query string, args ...interface{}) ([]models.WebhookSubscription, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// CreateWebhookSubscription implements SubscriptionStore.
+func (s *Postgres) CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	created, err := scanSubscription(s.DB.QueryRowContext(ctx,
+		`INSERT INTO webhook_subscriptions (url, event_types, secret, active)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+subscriptionColumns,
+		sub.URL, pq.Array(sub.EventTypes), sub.Secret, sub.Active))
+	if err != nil {
+		return err
+	}
+	*sub = created
+	return nil
+}
+
+// ListWebhookSubscriptions implements SubscriptionStore.
+func (s *Postgres) ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return s.selectSubscriptions(ctx,
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY id`)
+}
+
+// GetWebhookSubscription implements SubscriptionStore.
+func (s *Postgres) GetWebhookSubscription(ctx context.Context, id int) (models.WebhookSubscription, error) {
+	sub, err := scanSubscription(s.DB.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id=$1`, id))
+	return sub, notFound(err)
+}
+
+// UpdateWebhookSubscription implements SubscriptionStore.
+func (s *Postgres) UpdateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	updated, err := scanSubscription(s.DB.QueryRowContext(ctx,
+		`UPDATE webhook_subscriptions
+		 SET url=$1, event_types=$2, secret=$3, active=$4, updated_at=now()
+		 WHERE id=$5
+		 RETURNING `+subscriptionColumns,
+		sub.URL, pq.Array(sub.EventTypes), sub.Secret, sub.Active, sub.ID))
+	if err != nil {
+		return notFound(err)
+	}
+	*sub = updated
+	return nil
+}
+
+// DeleteWebhookSubscription implements SubscriptionStore.
+func (s *Postgres) DeleteWebhookSubscription(ctx context.Context, id int) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SubscriptionsForEvent implements SubscriptionStore.
+func (s *Postgres) SubscriptionsForEvent(ctx context.Context, eventType string) ([]models.WebhookSubscription, error) {
+	return s.selectSubscriptions(ctx,
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions
+		 WHERE active AND ($1 = ANY(event_types) OR '*' = ANY(event_types))
+		 ORDER BY id`, eventType)
+}
+
+// RecordWebhookDelivery implements SubscriptionStore.
+func (s *Postgres) RecordWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	return s.DB.QueryRowxContext(ctx,
+		`INSERT INTO webhook_deliveries
+			(subscription_id, event_id, event_type, attempt, status_code, error, succeeded, duration_ms)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 RETURNING id, created_at`,
+		d.SubscriptionID, d.EventID, d.EventType, d.Attempt, d.StatusCode, d.Error, d.Succeeded, d.DurationMS,
+	).Scan(&d.ID, &d.CreatedAt)
+}
+
+// WebhookDelivered implements SubscriptionStore.
+func (s *Postgres) WebhookDelivered(ctx context.Context, subscriptionID int, eventID int64) (bool, error) {
+	var delivered bool
+	err := s.DB.GetContext(ctx, &delivered,
+		`SELECT EXISTS (SELECT 1 FROM webhook_deliveries
+		 WHERE subscription_id=$1 AND event_id=$2 AND succeeded)`, subscriptionID, eventID)
+	return delivered, err
+}
+
+// ListWebhookDeliveries implements SubscriptionStore.
+func (s *Postgres) ListWebhookDeliveries(ctx context.Context, subscriptionID int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	if err := s.DB.SelectContext(ctx, &deliveries,
+		`SELECT * FROM webhook_deliveries WHERE subscription_id=$1 ORDER BY id DESC LIMIT $2`,
+		subscriptionID, deliveryLogLimit); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
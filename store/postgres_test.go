@@ -0,0 +1,868 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// setupMock creates a Postgres store hooked to sqlmock
+func setupMock(t *testing.T) (*Postgres, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+	return NewPostgres(sqlx.NewDb(db, "sqlmock")), mock
+}
+
+func TestPostgresCreateProduct(t *testing.T) {
+	s, mock := setupMock(t)
+	// Expect COUNT(*) query for sequence reset
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM products`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(`ALTER SEQUENCE products_id_seq RESTART WITH 1`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// Expect the INSERT ... RETURNING query
+	mock.ExpectQuery(`INSERT INTO products`).
+		WithArgs("Test", "Desc", "1.23", "USD", 0, "food", 250).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
+			AddRow(1, time.Now(), time.Now()))
+
+	p := models.Product{Name: "Test", Description: "Desc", Price: 123, Currency: "USD", TaxClass: "food", WeightGrams: 250}
+	if err := s.CreateProduct(context.Background(), &p); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	if p.ID != 1 {
+		t.Errorf("got ID %d; want 1", p.ID)
+	}
+}
+
+func TestPostgresListProducts(t *testing.T) {
+	s, mock := setupMock(t)
+	now := time.Now()
+	mock.ExpectQuery(`SELECT \* FROM products ORDER BY id ASC, id ASC`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "price", "created_at", "updated_at"}).
+			AddRow(1, "A", "Alpha", "9.99", now, now))
+
+	got, err := s.ListProducts(context.Background(), ProductFilter{})
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "A" {
+		t.Errorf("got %+v; want one product A", got)
+	}
+}
+
+func TestPostgresListProducts_Filtered(t *testing.T) {
+	s, mock := setupMock(t)
+	minPrice := models.Money(500)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM products WHERE `+
+		productSearchVector+` @@ plainto_tsquery('english', $1) AND price >= $2 `+
+		`AND (price, id) < ($3, $4) ORDER BY price DESC, id DESC LIMIT $5`)).
+		WithArgs("red shoe", "5.00", "20.00", 9, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := s.ListProducts(context.Background(), ProductFilter{
+		Search:   "red shoe",
+		MinPrice: &minPrice,
+		Sort:     SortByPrice,
+		Desc:     true,
+		After:    &ProductCursor{ID: 9, Price: 2000},
+		Limit:    10,
+	})
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+}
+
+func TestPostgresUpdateProduct_NotFound(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT price FROM products WHERE id=\$1 FOR UPDATE`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}))
+	mock.ExpectRollback()
+
+	err := s.UpdateProduct(context.Background(), &models.Product{ID: 5, Name: "X", Price: 100, Currency: "USD"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v; want ErrNotFound", err)
+	}
+}
+
+func TestPostgresUpdateProduct_PriceChangeEvent(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT price FROM products WHERE id=\$1 FOR UPDATE`).
+		WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("2.50"))
+	mock.ExpectExec(`UPDATE products SET`).
+		WithArgs("X", "", "1.00", "USD", 0, "", 0, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).
+		WithArgs(models.EventProductPriceChanged,
+			`{"product_id":5,"old_price":2.50,"new_price":1.00,"currency":"USD"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := s.UpdateProduct(context.Background(),
+		&models.Product{ID: 5, Name: "X", Price: 100, Currency: "USD"}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+}
+
+func TestPostgresGetUserByEmail_NotFound(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectQuery(`SELECT \* FROM users WHERE email=\$1`).
+		WithArgs("noone@example.com").
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := s.GetUserByEmail(context.Background(), "noone@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v; want ErrNotFound", err)
+	}
+}
+
+func TestPostgresBootstrapAdmin_AdminExists(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(`LOCK TABLE users`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE role='admin'`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	if _, err := s.BootstrapAdmin(context.Background()); !errors.Is(err, ErrAdminExists) {
+		t.Errorf("err = %v; want ErrAdminExists", err)
+	}
+}
+
+func TestPostgresRotateRefreshToken_Reuse(t *testing.T) {
+	s, mock := setupMock(t)
+	cols := []string{"id", "user_id", "family_id", "token_hash", "expires_at", "revoked_at", "created_at"}
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM refresh_tokens WHERE token_hash=\$1 FOR UPDATE`).
+		WithArgs("h1").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(1, 7, "fam", "h1", now.Add(time.Hour), now, now))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at=now\(\)\s+WHERE family_id=\$1`).
+		WithArgs("fam").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	next := models.RefreshToken{TokenHash: "h2", ExpiresAt: now.Add(time.Hour)}
+	if _, err := s.RotateRefreshToken(context.Background(), "h1", &next); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("err = %v; want ErrRefreshTokenReused", err)
+	}
+}
+
+func TestPostgresCreatePayment_Active(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectQuery(`INSERT INTO payments`).
+		WithArgs(3, "fake", "", "5.00", "USD", models.PaymentPending, "").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "payments_order_active"})
+
+	err := s.CreatePayment(context.Background(), &models.Payment{
+		OrderID: 3, Provider: "fake", Amount: 500, Currency: "USD", Status: models.PaymentPending,
+	})
+	if !errors.Is(err, ErrActivePayment) {
+		t.Errorf("err = %v; want ErrActivePayment", err)
+	}
+}
+
+func TestPostgresRecordWebhookEvent_Duplicate(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO webhook_events .* ON CONFLICT \(id\) DO NOTHING`).
+		WithArgs("evt_1", "payment.succeeded", 3, `{}`).
+		WillReturnRows(sqlmock.NewRows([]string{"received_at"}))
+	mock.ExpectRollback()
+
+	orderID := 3
+	paid := models.StatusPaid
+	err := s.RecordWebhookEvent(context.Background(),
+		&models.WebhookEvent{ID: "evt_1", Type: "payment.succeeded", OrderID: &orderID, Payload: []byte(`{}`)},
+		&paid, "")
+	if !errors.Is(err, ErrDuplicateEvent) {
+		t.Errorf("err = %v; want ErrDuplicateEvent", err)
+	}
+}
+
+func TestPostgresSubscriptionsForEvent(t *testing.T) {
+	s, mock := setupMock(t)
+	now := time.Now()
+	mock.ExpectQuery(`SELECT .* FROM webhook_subscriptions\s+WHERE active AND \(\$1 = ANY\(event_types\)`).
+		WithArgs(models.EventOrderPlaced).
+		WillReturnRows(sqlmock.NewRows(
+			[]string{"id", "url", "event_types", "secret", "active", "created_at", "updated_at"},
+		).AddRow(1, "https://erp.example.com/hook", `{order.placed,order.status_changed}`, "s", true, now, now))
+
+	subs, err := s.SubscriptionsForEvent(context.Background(), models.EventOrderPlaced)
+	if err != nil {
+		t.Fatalf("SubscriptionsForEvent: %v", err)
+	}
+	if len(subs) != 1 || len(subs[0].EventTypes) != 2 || subs[0].EventTypes[1] != models.EventOrderStatusChanged {
+		t.Errorf("got %+v; want one subscription with two event types", subs)
+	}
+}
+
+func TestPostgresActiveCart_Creates(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectQuery(`SELECT id, user_id, status, promotion_id, shipping_method, created_at, updated_at FROM carts`).
+		WithArgs(99).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO carts .*ON CONFLICT .*RETURNING id, user_id, status, promotion_id, shipping_method, created_at`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "created_at"}).
+			AddRow(123, 99, "active", time.Now()))
+
+	cart, created, err := s.ActiveCart(context.Background(), 99)
+	if err != nil {
+		t.Fatalf("ActiveCart: %v", err)
+	}
+	if !created || cart.ID != 123 || !cart.OwnedBy(99) {
+		t.Errorf("got cart %+v, created=%v; want new cart ID=123, UserID=99", cart, created)
+	}
+}
+
+func TestPostgresActiveCart_LostRace(t *testing.T) {
+	s, mock := setupMock(t)
+	cols := []string{"id", "user_id", "status", "created_at"}
+	mock.ExpectQuery(`SELECT id, user_id, status, promotion_id, shipping_method, created_at, updated_at FROM carts`).
+		WithArgs(99).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`INSERT INTO carts`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows(cols))
+	mock.ExpectQuery(`SELECT id, user_id, status, promotion_id, shipping_method, created_at, updated_at FROM carts`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(124, 99, "active", time.Now()))
+
+	cart, created, err := s.ActiveCart(context.Background(), 99)
+	if err != nil {
+		t.Fatalf("ActiveCart: %v", err)
+	}
+	if created || cart.ID != 124 {
+		t.Errorf("got cart %+v, created=%v; want existing cart 124", cart, created)
+	}
+}
+
+func TestPostgresAddCartItem_UnknownProduct(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectExec(`INSERT INTO cart_items .*SELECT .* FROM products`).
+		WithArgs(5, 99, nil, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := s.AddCartItem(context.Background(), 5, 99, nil, 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v; want ErrNotFound", err)
+	}
+}
+
+func TestPostgresMergeCart(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM carts .*user_id IS NULL .*FOR UPDATE`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO carts .*ON CONFLICT`).
+		WithArgs(99).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT id, user_id, status, promotion_id, shipping_method, created_at, updated_at FROM carts .*FOR UPDATE`).
+		WithArgs(99).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "created_at"}).
+			AddRow(3, 99, "active", time.Now()))
+	lineCols := []string{"product_id", "variant_id", "quantity", "added_price", "currency"}
+	mock.ExpectQuery(`SELECT ci.product_id, .* FROM cart_items ci .*FOR UPDATE OF ci`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows(lineCols).AddRow(10, nil, 2, "5.00", "USD"))
+	mock.ExpectQuery(`SELECT ci.product_id, .* FROM cart_items ci .*FOR UPDATE OF ci`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(lineCols).
+			AddRow(10, nil, 5, "4.50", "USD").
+			AddRow(11, nil, 1, "9.00", "EUR").
+			AddRow(12, nil, 3, "2.00", "USD"))
+	// the line limit caps product 10 at 4 and the EUR line is dropped
+	mock.ExpectExec(`INSERT INTO cart_items .*SET quantity = EXCLUDED.quantity`).
+		WithArgs(3, 10, nil, 4, "4.50").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO cart_items .*SET quantity = EXCLUDED.quantity`).
+		WithArgs(3, 12, nil, 3, "2.00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM cart_items WHERE cart_id=\$1`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(`UPDATE carts SET promotion_id = COALESCE\(carts.promotion_id, g.promotion_id\)`).
+		WithArgs(3, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "promotion_id", "created_at"}).
+			AddRow(3, 99, "active", 4, time.Now()))
+	mock.ExpectExec(`UPDATE carts SET status='merged'`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	cart, err := s.MergeCart(context.Background(), 7, 99, CartLimits{MaxLine: 4, MaxTotal: 10})
+	if err != nil {
+		t.Fatalf("MergeCart: %v", err)
+	}
+	if cart.ID != 3 || cart.PromotionID == nil || *cart.PromotionID != 4 {
+		t.Errorf("got cart %+v; want the user's cart 3 with the guest's coupon 4", cart)
+	}
+}
+
+func TestPostgresMergeCart_NotGuest(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM carts`).
+		WithArgs(7).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := s.MergeCart(context.Background(), 7, 99, CartLimits{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v; want ErrNotFound", err)
+	}
+}
+
+func TestPostgresAddCartItem(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectExec(`INSERT INTO cart_items`).
+		WithArgs(5, 10, nil, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if err := s.AddCartItem(context.Background(), 5, 10, nil, 3); err != nil {
+		t.Fatalf("AddCartItem: %v", err)
+	}
+}
+
+func TestPostgresSetCartItem(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectExec(`INSERT INTO cart_items .*SET quantity = EXCLUDED.quantity`).
+		WithArgs(5, 10, nil, 4).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM cart_items WHERE cart_id=\$1 AND product_id=\$2`).
+		WithArgs(5, 10, nil).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.SetCartItem(context.Background(), 5, 10, nil, 4); err != nil {
+		t.Fatalf("SetCartItem: %v", err)
+	}
+	if err := s.SetCartItem(context.Background(), 5, 10, nil, 0); err != nil {
+		t.Fatalf("SetCartItem zero: %v", err)
+	}
+}
+
+func TestPostgresRemoveCartItem_NotFound(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectExec(`DELETE FROM cart_items`).
+		WithArgs(5, 10, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := s.RemoveCartItem(context.Background(), 5, 10, nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v; want ErrNotFound", err)
+	}
+}
+
+func TestPostgresGetCart_NotFound(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectQuery(`SELECT id, user_id, status, promotion_id, shipping_method, created_at, updated_at FROM carts`).
+		WithArgs(7).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := s.GetCart(context.Background(), 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v; want ErrNotFound", err)
+	}
+}
+
+func TestPostgresAbandonIdleCarts(t *testing.T) {
+	s, mock := setupMock(t)
+	idleSince := time.Now().Add(-24 * time.Hour)
+	cols := []string{"id", "user_id", "status", "created_at", "updated_at"}
+	mock.ExpectBegin()
+	mock.ExpectQuery(`UPDATE carts SET status='inactive' .*FOR UPDATE SKIP LOCKED`).
+		WithArgs(idleSince, 10).
+		WillReturnRows(sqlmock.NewRows(cols).
+			AddRow(3, 42, "inactive", idleSince, idleSince).
+			AddRow(4, nil, "inactive", idleSince, idleSince))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(ci.quantity\), 0\) AS items`).
+		WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"items", "subtotal", "currency"}).AddRow(2, "10.00", "USD"))
+	mock.ExpectExec(`INSERT INTO outbox`).
+		WithArgs(models.EventCartAbandoned, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	carts, err := s.AbandonIdleCarts(context.Background(), idleSince, 10)
+	if err != nil {
+		t.Fatalf("AbandonIdleCarts: %v", err)
+	}
+	if len(carts) != 2 {
+		t.Errorf("got %d carts; want 2", len(carts))
+	}
+}
+
+// expectCheckoutCart expects CreateOrder to lock active cart 1 and find
+// it holding lines for items.
+func expectCheckoutCart(mock sqlmock.Sqlmock, items ...models.OrderItem) {
+	mock.ExpectQuery(`SELECT id FROM carts WHERE id=\$1 AND status=\$2 FOR UPDATE`).
+		WithArgs(1, "active").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	rows := sqlmock.NewRows([]string{"product_id", "variant_id", "quantity"})
+	for _, it := range items {
+		var variantID interface{}
+		if it.VariantID != nil {
+			variantID = *it.VariantID
+		}
+		rows.AddRow(it.ProductID, variantID, it.Quantity)
+	}
+	mock.ExpectQuery(`SELECT product_id, variant_id, quantity FROM cart_items WHERE cart_id=\$1`).
+		WithArgs(1).
+		WillReturnRows(rows)
+}
+
+func TestPostgresCreateOrder(t *testing.T) {
+	s, mock := setupMock(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 2})
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM products .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(10, 5))
+	mock.ExpectExec(`UPDATE products SET stock_quantity = stock_quantity - \$1`).
+		WithArgs(2, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO orders`).
+		WithArgs(42, "10.80", "0.80", false, "0.00", "USD", "pending", "").
+		WillReturnRows(sqlmock.NewRows(
+			[]string{"id", "user_id", "total_amount", "tax_amount", "tax_inclusive", "shipping_amount", "currency", "status", "created_at"},
+		).AddRow(100, 42, "10.80", "0.80", false, "0.00", "USD", "pending", now))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WithArgs(100, nil, "pending", 42, "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO order_items`).
+		WithArgs(100, 10, nil, "", 2, "5.00", "0.80").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).
+		WithArgs(models.EventOrderPlaced, `{"order_id":100,"user_id":42,"total_amount":10.80,"currency":"USD"}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM cart_items`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE carts SET status='checked_out'`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	o := models.Order{UserID: 42, TotalAmount: 1080, TaxAmount: 80, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500, TaxAmount: 80}}
+	if err := s.CreateOrder(context.Background(), &o, items, 1, ""); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if o.ID != 100 || o.TaxAmount != 80 {
+		t.Errorf("got order %+v; want ID 100 with 0.80 tax", o)
+	}
+}
+
+func TestPostgresCreateOrder_CouponUsedUp(t *testing.T) {
+	s, mock := setupMock(t)
+	now := time.Now()
+	mock.ExpectBegin()
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 2})
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM products .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(10, 5))
+	mock.ExpectExec(`UPDATE products SET stock_quantity`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT .* FROM promotions WHERE id=\$1 FOR UPDATE`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "code", "kind", "percent_off", "amount_off", "buy_quantity", "get_quantity",
+			"currency", "min_spend", "max_uses", "max_uses_per_user", "product_ids", "category_ids",
+			"starts_at", "ends_at", "active", "created_at", "updated_at",
+		}).AddRow(7, "SAVE10", "percentage", 10, "0.00", 0, 0,
+			"", "0.00", 5, nil, "{}", "{}", nil, nil, true, now, now))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) AS total, .*FROM order_discounts`).
+		WithArgs(7, 42).
+		WillReturnRows(sqlmock.NewRows([]string{"total", "by_user"}).AddRow(5, 0))
+	mock.ExpectRollback()
+
+	promoID := 7
+	o := models.Order{UserID: 42, TotalAmount: 900, Currency: "USD", Status: "pending",
+		Discount: &models.OrderDiscount{PromotionID: &promoID, Code: "SAVE10", Amount: 100}}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500}}
+	err := s.CreateOrder(context.Background(), &o, items, 1, "")
+	if !errors.Is(err, ErrCouponUnavailable) {
+		t.Errorf("err = %v; want ErrCouponUnavailable", err)
+	}
+}
+
+func TestPostgresCreateOrder_CartNotActive(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id FROM carts WHERE id=\$1 AND status=\$2 FOR UPDATE`).
+		WithArgs(1, "active").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	o := models.Order{UserID: 42, TotalAmount: 1000, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500}}
+	if err := s.CreateOrder(context.Background(), &o, items, 1, ""); !errors.Is(err, ErrCartNotActive) {
+		t.Errorf("err = %v; want ErrCartNotActive", err)
+	}
+}
+
+func TestPostgresCreateOrder_CartChanged(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	// another request added a unit after the order was priced
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 3})
+	mock.ExpectRollback()
+
+	o := models.Order{UserID: 42, TotalAmount: 1000, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500}}
+	if err := s.CreateOrder(context.Background(), &o, items, 1, ""); !errors.Is(err, ErrCartChanged) {
+		t.Errorf("err = %v; want ErrCartChanged", err)
+	}
+}
+
+func TestPostgresCreateOrder_InsufficientStock(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 2})
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM products .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(10, 1))
+	mock.ExpectRollback()
+
+	o := models.Order{UserID: 42, TotalAmount: 1000, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500}}
+	err := s.CreateOrder(context.Background(), &o, items, 1, "")
+	var stockErr *InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("err = %v; want *InsufficientStockError", err)
+	}
+	if got := stockErr.Shortages; len(got) != 1 || got[0].Requested != 2 || got[0].Available != 1 {
+		t.Errorf("got shortages %+v; want requested 2, available 1", got)
+	}
+}
+
+func TestPostgresCreateOrder_VariantStock(t *testing.T) {
+	s, mock := setupMock(t)
+	variantID := 7
+	mock.ExpectBegin()
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 1},
+		models.OrderItem{ProductID: 11, VariantID: &variantID, Quantity: 2})
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM products .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(10, 5))
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM product_variants .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(7, 1))
+	mock.ExpectRollback()
+
+	o := models.Order{UserID: 42, TotalAmount: 1500, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{
+		{ProductID: 10, Quantity: 1, UnitPrice: 500},
+		{ProductID: 11, VariantID: &variantID, SKU: "TEE-M", Quantity: 2, UnitPrice: 500},
+	}
+	err := s.CreateOrder(context.Background(), &o, items, 1, "")
+	var stockErr *InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("err = %v; want *InsufficientStockError", err)
+	}
+	got := stockErr.Shortages
+	if len(got) != 1 || got[0].ProductID != 11 || got[0].VariantID == nil || *got[0].VariantID != 7 || got[0].Available != 1 {
+		t.Errorf("got shortages %+v; want variant 7 of product 11 with 1 available", got)
+	}
+}
+
+func TestPostgresDeleteVariant_Ordered(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectExec(`DELETE FROM product_variants WHERE id=\$1`).
+		WithArgs(7).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "order_items_variant_id_fkey"})
+
+	if err := s.DeleteVariant(context.Background(), 7); !errors.Is(err, ErrVariantOrdered) {
+		t.Errorf("err = %v; want ErrVariantOrdered", err)
+	}
+}
+
+func TestPostgresCreateOrder_DuplicateIdempotencyKey(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 2})
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM products .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(10, 5))
+	mock.ExpectExec(`UPDATE products SET stock_quantity`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO orders`).
+		WithArgs(42, "10.00", "0.00", false, "0.00", "USD", "pending", "key-1").
+		WillReturnError(&pq.Error{Code: "23505", Constraint: "orders_user_idempotency_key"})
+	mock.ExpectRollback()
+
+	o := models.Order{UserID: 42, TotalAmount: 1000, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500}}
+	err := s.CreateOrder(context.Background(), &o, items, 1, "key-1")
+	if !errors.Is(err, ErrDuplicateIdempotencyKey) {
+		t.Errorf("err = %v; want ErrDuplicateIdempotencyKey", err)
+	}
+}
+
+func TestPostgresCreateOrder_RollsBackOnItemFailure(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	expectCheckoutCart(mock, models.OrderItem{ProductID: 10, Quantity: 2})
+	mock.ExpectQuery(`SELECT id, stock_quantity FROM products .* FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "stock_quantity"}).AddRow(10, 5))
+	mock.ExpectExec(`UPDATE products SET stock_quantity`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO orders`).
+		WillReturnRows(sqlmock.NewRows(
+			[]string{"id", "user_id", "total_amount", "currency", "status", "created_at"},
+		).AddRow(100, 42, "10.00", "USD", "pending", time.Now()))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO order_items`).
+		WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+
+	o := models.Order{UserID: 42, TotalAmount: 1000, Currency: "USD", Status: "pending"}
+	items := []models.OrderItem{{ProductID: 10, Quantity: 2, UnitPrice: 500}}
+	if err := s.CreateOrder(context.Background(), &o, items, 1, ""); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestPostgresTransitionOrder(t *testing.T) {
+	s, mock := setupMock(t)
+	staff := 7
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .* FROM orders WHERE id=\$1 FOR UPDATE`).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows(
+			[]string{"id", "user_id", "total_amount", "currency", "status", "created_at"},
+		).AddRow(100, 42, "10.00", "USD", "pending", time.Now()))
+	mock.ExpectExec(`UPDATE orders SET status=\$1 WHERE id=\$2`).
+		WithArgs("paid", 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WithArgs(100, "pending", "paid", staff, "manual").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).
+		WithArgs(models.EventOrderStatusChanged,
+			`{"order_id":100,"user_id":42,"from":"pending","to":"paid","changed_by":7}`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	o, err := s.TransitionOrder(context.Background(), 100, models.StatusPaid, &staff, "manual")
+	if err != nil {
+		t.Fatalf("TransitionOrder: %v", err)
+	}
+	if o.Status != models.StatusPaid {
+		t.Errorf("status = %s; want paid", o.Status)
+	}
+}
+
+func TestPostgresTransitionOrder_Forbidden(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .* FROM orders WHERE id=\$1 FOR UPDATE`).
+		WillReturnRows(sqlmock.NewRows(
+			[]string{"id", "user_id", "total_amount", "currency", "status", "created_at"},
+		).AddRow(100, 42, "10.00", "USD", "cancelled", time.Now()))
+	mock.ExpectRollback()
+
+	_, err := s.TransitionOrder(context.Background(), 100, models.StatusPaid, nil, "")
+	var transErr *models.TransitionError
+	if !errors.As(err, &transErr) {
+		t.Errorf("err = %v; want *models.TransitionError", err)
+	}
+}
+
+func TestPostgresCancelOrder_Paid(t *testing.T) {
+	s, mock := setupMock(t)
+	userID := 42
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT status FROM orders WHERE id=\$1 FOR UPDATE`).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("paid"))
+	mock.ExpectQuery(`SELECT .* FROM orders WHERE id=\$1 FOR UPDATE`).
+		WithArgs(100).
+		WillReturnRows(sqlmock.NewRows(
+			[]string{"id", "user_id", "total_amount", "currency", "status", "created_at"},
+		).AddRow(100, 42, "10.00", "USD", "paid", now))
+	mock.ExpectExec(`UPDATE orders SET status=\$1 WHERE id=\$2`).
+		WithArgs("cancelled", 100).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO order_status_history`).
+		WithArgs(100, "paid", "cancelled", userID, "changed my mind").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO outbox`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE products p SET stock_quantity = p.stock_quantity \+ l.quantity.* l.variant_id IS NULL`).
+		WithArgs(100).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`UPDATE product_variants v SET stock_quantity = v.stock_quantity \+ l.quantity`).
+		WithArgs(100).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT \* FROM payments WHERE order_id=\$1 AND status=\$2 FOR UPDATE`).
+		WithArgs(100, "captured").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "amount", "currency", "status"}).
+			AddRow(9, 100, "10.00", "USD", "captured"))
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM refunds WHERE payment_id=\$1`).
+		WithArgs(9, "failed").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("2.50"))
+	mock.ExpectQuery(`INSERT INTO refunds`).
+		WithArgs(100, 9, nil, nil, nil, nil, "7.50", "USD", "changed my mind", "pending", userID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "payment_id", "amount", "currency", "status"}).
+			AddRow(3, 100, 9, "7.50", "USD", "pending"))
+	mock.ExpectCommit()
+
+	o, refund, err := s.CancelOrder(context.Background(), 100, &userID, "changed my mind")
+	if err != nil {
+		t.Fatalf("CancelOrder: %v", err)
+	}
+	if o.Status != models.StatusCancelled || refund == nil || refund.ID != 3 || refund.Amount != 750 {
+		t.Errorf("got %+v, %+v; want cancelled order and a 7.50 refund", o, refund)
+	}
+}
+
+func TestPostgresCreateRefund_Exceeded(t *testing.T) {
+	s, mock := setupMock(t)
+	productID, qty := 5, 2
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM payments WHERE id=\$1 AND order_id=\$2 AND status=\$3 FOR UPDATE`).
+		WithArgs(9, 100, "captured").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "amount", "currency", "status"}).
+			AddRow(9, 100, "10.00", "USD", "captured"))
+	mock.ExpectQuery(`SELECT oi.quantity, .* FROM order_items oi`).
+		WithArgs(100, productID, "failed", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"quantity", "refunded"}).AddRow(2, 1))
+	mock.ExpectRollback()
+
+	err := s.CreateRefund(context.Background(), &models.Refund{
+		OrderID: 100, PaymentID: 9, ProductID: &productID, Quantity: &qty, Amount: 500,
+	})
+	if !errors.Is(err, ErrRefundExceeded) {
+		t.Errorf("err = %v; want ErrRefundExceeded", err)
+	}
+}
+
+func TestPostgresRetryRefund_Exceeded(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM refunds WHERE id=\$1 AND order_id=\$2 FOR UPDATE`).
+		WithArgs(3, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "payment_id", "amount", "status"}).
+			AddRow(3, 100, 9, "10.00", "failed"))
+	mock.ExpectQuery(`SELECT \* FROM payments WHERE id=\$1 AND status=\$2 FOR UPDATE`).
+		WithArgs(9, "captured").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "amount", "currency", "status"}).
+			AddRow(9, 100, "10.00", "USD", "captured"))
+	// a line refund has been made since the cancellation's refund failed
+	mock.ExpectQuery(`SELECT COALESCE\(SUM\(amount\), 0\) FROM refunds`).
+		WithArgs(9, "failed").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("5.00"))
+	mock.ExpectRollback()
+
+	if _, err := s.RetryRefund(context.Background(), 100, 3); !errors.Is(err, ErrRefundExceeded) {
+		t.Errorf("err = %v; want ErrRefundExceeded", err)
+	}
+}
+
+func TestPostgresRetryRefund_NotFailed(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM refunds WHERE id=\$1 AND order_id=\$2 FOR UPDATE`).
+		WithArgs(3, 100).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "payment_id", "amount", "status"}).
+			AddRow(3, 100, 9, "10.00", "succeeded"))
+	mock.ExpectRollback()
+
+	if _, err := s.RetryRefund(context.Background(), 100, 3); !errors.Is(err, ErrRefundNotFailed) {
+		t.Errorf("err = %v; want ErrRefundNotFailed", err)
+	}
+}
+
+func TestPostgresReceiveReturn_Restock(t *testing.T) {
+	s, mock := setupMock(t)
+	staff := 7
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM returns WHERE id=\$1 FOR UPDATE`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "status", "created_at"}).
+			AddRow(4, 100, "approved", now))
+	mock.ExpectQuery(`UPDATE returns SET status=\$1, staff_note=\$2, handled_by=\$3`).
+		WithArgs("received", "", staff, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "status", "handled_by", "created_at"}).
+			AddRow(4, 100, "received", staff, now))
+	mock.ExpectQuery(`SELECT \* FROM return_items WHERE return_id=\$1`).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"return_id", "product_id", "quantity"}).AddRow(4, 5, 1))
+	mock.ExpectExec(`UPDATE products p SET stock_quantity = p.stock_quantity \+ l.quantity.* l.variant_id IS NULL`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE product_variants v SET stock_quantity = v.stock_quantity \+ l.quantity`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE returns SET restocked=true WHERE id=\$1`).
+		WithArgs(4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	rt, err := s.ReceiveReturn(context.Background(), 4, &staff, "", true)
+	if err != nil {
+		t.Fatalf("ReceiveReturn: %v", err)
+	}
+	if rt.Status != models.ReturnReceived || !rt.Restocked || len(rt.Items) != 1 {
+		t.Errorf("got %+v; want received, restocked return with one item", rt)
+	}
+}
+
+func TestPostgresListProducts_Category(t *testing.T) {
+	s, mock := setupMock(t)
+	category := 3
+	mock.ExpectQuery(`SELECT \* FROM products WHERE id IN \(WITH RECURSIVE subtree AS \(\s*`+
+		`SELECT id FROM categories WHERE id=\$1.*JOIN subtree s ON c.parent_id=s.id\s*\) `+
+		`SELECT pc.product_id FROM product_categories pc JOIN subtree s ON s.id=pc.category_id\) `+
+		`ORDER BY id ASC, id ASC LIMIT \$2`).
+		WithArgs(3, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err := s.ListProducts(context.Background(), ProductFilter{Category: &category, Limit: 10}); err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+}
+
+func TestPostgresUpdateCategory_Cycle(t *testing.T) {
+	s, mock := setupMock(t)
+	parent := 7
+	mock.ExpectBegin()
+	mock.ExpectExec(`LOCK TABLE categories`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`WITH RECURSIVE subtree .* SELECT EXISTS \(SELECT 1 FROM subtree WHERE id=\$2\)`).
+		WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	err := s.UpdateCategory(context.Background(), &models.Category{ID: 2, ParentID: &parent, Name: "Shoes", Slug: "shoes"})
+	if !errors.Is(err, ErrCategoryCycle) {
+		t.Fatalf("err = %v; want ErrCategoryCycle", err)
+	}
+}
+
+func TestPostgresDeleteCategory_HasChildren(t *testing.T) {
+	s, mock := setupMock(t)
+	mock.ExpectExec(`DELETE FROM categories WHERE id=\$1`).
+		WithArgs(1).
+		WillReturnError(&pq.Error{Code: "23503", Constraint: "categories_parent_id_fkey"})
+
+	if err := s.DeleteCategory(context.Background(), 1); !errors.Is(err, ErrCategoryHasChildren) {
+		t.Fatalf("err = %v; want ErrCategoryHasChildren", err)
+	}
+}
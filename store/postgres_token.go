@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateRefreshToken implements TokenStore.
+func (s *Postgres) CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error {
+	return s.DB.GetContext(ctx, t,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING *`,
+		t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt)
+}
+
+// RotateRefreshToken implements TokenStore.
+func (s *Postgres) RotateRefreshToken(ctx context.Context, oldHash string, next *models.RefreshToken) (models.RefreshToken, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+	defer tx.Rollback()
+
+	var old models.RefreshToken
+	if err := tx.GetContext(ctx, &old,
+		`SELECT * FROM refresh_tokens WHERE token_hash=$1 FOR UPDATE`, oldHash); err != nil {
+		return models.RefreshToken{}, notFound(err)
+	}
+	if old.RevokedAt != nil {
+		// a rotated token came back: assume it was stolen and end the session
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE refresh_tokens SET revoked_at=now()
+			 WHERE family_id=$1 AND revoked_at IS NULL`, old.FamilyID); err != nil {
+			return models.RefreshToken{}, err
+		}
+		if err := tx.Commit(); err != nil {
+			return models.RefreshToken{}, err
+		}
+		return models.RefreshToken{}, ErrRefreshTokenReused
+	}
+	if !time.Now().Before(old.ExpiresAt) {
+		return models.RefreshToken{}, ErrRefreshTokenExpired
+	}
+	if err := tx.GetContext(ctx, &old.RevokedAt,
+		`UPDATE refresh_tokens SET revoked_at=now() WHERE id=$1 RETURNING revoked_at`, old.ID); err != nil {
+		return models.RefreshToken{}, err
+	}
+	if err := tx.GetContext(ctx, next,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING *`,
+		old.UserID, old.FamilyID, next.TokenHash, next.ExpiresAt); err != nil {
+		return models.RefreshToken{}, err
+	}
+	return old, tx.Commit()
+}
+
+// RevokeRefreshFamily implements TokenStore.
+func (s *Postgres) RevokeRefreshFamily(ctx context.Context, userID int, hash string) error {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at=now()
+		 WHERE revoked_at IS NULL AND family_id = (
+			SELECT family_id FROM refresh_tokens WHERE token_hash=$1 AND user_id=$2
+		 )`, hash, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// either unknown or already revoked; only the former is an error
+		var exists bool
+		if err := s.DB.GetContext(ctx, &exists,
+			`SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE token_hash=$1 AND user_id=$2)`,
+			hash, userID); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// RevokeAccessToken implements TokenStore. Expired entries are pruned on
+// the way in, since the tokens they block are no longer accepted anyway.
+func (s *Postgres) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if _, err := s.DB.ExecContext(ctx,
+		`DELETE FROM revoked_access_tokens WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO revoked_access_tokens (jti, expires_at) VALUES ($1, $2)
+		 ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+// AccessTokenRevoked implements TokenStore.
+func (s *Postgres) AccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := s.DB.GetContext(ctx, &revoked,
+		`SELECT EXISTS (SELECT 1 FROM revoked_access_tokens WHERE jti=$1)`, jti)
+	return revoked, err
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// CreateUser implements UserStore.
+func (s *Postgres) CreateUser(ctx context.Context, u *models.User) error {
+	if u.Role == "" {
+		u.Role = models.RoleCustomer
+	}
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := `INSERT INTO users (email, password_hash, role) VALUES ($1, $2, $3)
+	           RETURNING id, email, role, created_at`
+	if err := tx.QueryRowxContext(ctx, insert, u.Email, u.PasswordHash, u.Role).StructScan(u); err != nil {
+		return err
+	}
+	if err := enqueue(ctx, tx, models.EventUserSignedUp, models.UserSignedUp{UserID: u.ID, Email: u.Email}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetUserByEmail implements UserStore.
+func (s *Postgres) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	var u models.User
+	err := s.DB.GetContext(ctx, &u, "SELECT * FROM users WHERE email=$1", email)
+	return u, notFound(err)
+}
+
+// GetUser implements UserStore.
+func (s *Postgres) GetUser(ctx context.Context, id int) (models.User, error) {
+	var u models.User
+	err := s.DB.GetContext(ctx, &u, "SELECT * FROM users WHERE id=$1", id)
+	return u, notFound(err)
+}
+
+// SetUserRole implements UserStore.
+func (s *Postgres) SetUserRole(ctx context.Context, email string, role models.Role) (models.User, error) {
+	var u models.User
+	err := s.DB.GetContext(ctx, &u,
+		`UPDATE users SET role=$1 WHERE email=$2 RETURNING *`, role, email)
+	return u, notFound(err)
+}
+
+// BootstrapAdmin implements UserStore.
+func (s *Postgres) BootstrapAdmin(ctx context.Context) (models.User, error) {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	// serialize concurrent bootstraps so only one user is promoted
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return models.User{}, err
+	}
+	var admins int
+	if err := tx.GetContext(ctx, &admins, `SELECT COUNT(*) FROM users WHERE role='admin'`); err != nil {
+		return models.User{}, err
+	}
+	if admins > 0 {
+		return models.User{}, ErrAdminExists
+	}
+	var u models.User
+	if err := tx.GetContext(ctx, &u,
+		`UPDATE users SET role='admin'
+		 WHERE id = (SELECT id FROM users ORDER BY id LIMIT 1)
+		 RETURNING *`); err != nil {
+		return models.User{}, notFound(err)
+	}
+	return u, tx.Commit()
+}
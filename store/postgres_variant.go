@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// variantErr maps constraint violations on product_variants to the
+// store's errors.
+func variantErr(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Constraint {
+		case "product_variants_sku_key":
+			return ErrDuplicateSKU
+		case "product_variants_attributes":
+			return ErrDuplicateVariant
+		case "product_variants_product_id_fkey":
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+// SetProductOptions implements VariantStore.
+func (s *Postgres) SetProductOptions(ctx context.Context, productID int, opts []models.ProductOption) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.GetContext(ctx, &id, `SELECT id FROM products WHERE id=$1 FOR UPDATE`, productID); err != nil {
+		return notFound(err)
+	}
+	var hasVariants bool
+	if err := tx.GetContext(ctx, &hasVariants,
+		`SELECT EXISTS (SELECT 1 FROM product_variants WHERE product_id=$1)`, productID); err != nil {
+		return err
+	}
+	if hasVariants {
+		return ErrProductHasVariants
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_options WHERE product_id=$1`, productID); err != nil {
+		return err
+	}
+	for i, o := range opts {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO product_options (product_id, position, name, option_values) VALUES ($1, $2, $3, $4)`,
+			productID, i, o.Name, pq.Array(o.Values)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ProductOptions implements VariantStore.
+func (s *Postgres) ProductOptions(ctx context.Context, productID int) ([]models.ProductOption, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT name, option_values FROM product_options WHERE product_id=$1 ORDER BY position`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	opts := []models.ProductOption{}
+	for rows.Next() {
+		var o models.ProductOption
+		if err := rows.Scan(&o.Name, pq.Array(&o.Values)); err != nil {
+			return nil, err
+		}
+		opts = append(opts, o)
+	}
+	return opts, rows.Err()
+}
+
+// CreateVariant implements VariantStore.
+func (s *Postgres) CreateVariant(ctx context.Context, v *models.Variant) error {
+	err := s.DB.QueryRowxContext(ctx,
+		`INSERT INTO product_variants (product_id, sku, price, stock_quantity, attributes)
+		 VALUES ($1, $2, $3, $4, $5)
+		 RETURNING *`,
+		v.ProductID, v.SKU, v.Price, v.StockQuantity, v.Attributes,
+	).StructScan(v)
+	return variantErr(err)
+}
+
+// ListVariants implements VariantStore.
+func (s *Postgres) ListVariants(ctx context.Context, productID int) ([]models.Variant, error) {
+	variants := []models.Variant{}
+	if err := s.DB.SelectContext(ctx, &variants,
+		`SELECT * FROM product_variants WHERE product_id=$1 ORDER BY id`, productID); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+// GetVariant implements VariantStore.
+func (s *Postgres) GetVariant(ctx context.Context, id int) (models.Variant, error) {
+	var v models.Variant
+	err := s.DB.GetContext(ctx, &v, `SELECT * FROM product_variants WHERE id=$1`, id)
+	return v, notFound(err)
+}
+
+// UpdateVariant implements VariantStore.
+func (s *Postgres) UpdateVariant(ctx context.Context, v *models.Variant) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var old struct {
+		Price    models.Money `db:"price"`
+		Currency string       `db:"currency"`
+	}
+	if err := tx.GetContext(ctx, &old, `
+    SELECT v.price, p.currency FROM product_variants v
+    JOIN products p ON p.id=v.product_id
+    WHERE v.id=$1 FOR UPDATE OF v`, v.ID); err != nil {
+		return notFound(err)
+	}
+	if err := tx.QueryRowxContext(ctx,
+		`UPDATE product_variants SET sku=$1, price=$2, stock_quantity=$3, attributes=$4, updated_at=now()
+		 WHERE id=$5 RETURNING *`,
+		v.SKU, v.Price, v.StockQuantity, v.Attributes, v.ID,
+	).StructScan(v); err != nil {
+		return variantErr(err)
+	}
+	if old.Price != v.Price {
+		if err := enqueue(ctx, tx, models.EventProductPriceChanged, models.ProductPriceChanged{
+			ProductID: v.ProductID, VariantID: &v.ID, OldPrice: old.Price, NewPrice: v.Price, Currency: old.Currency,
+		}); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteVariant implements VariantStore. Cart lines for the variant go
+// with it; order lines keep it from being deleted.
+func (s *Postgres) DeleteVariant(ctx context.Context, id int) error {
+	_, err := s.DB.ExecContext(ctx, `DELETE FROM product_variants WHERE id=$1`, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Constraint == "order_items_variant_id_fkey" {
+		return ErrVariantOrdered
+	}
+	return err
+}
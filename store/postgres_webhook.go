@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// RecordWebhookEvent implements WebhookStore.
+func (s *Postgres) RecordWebhookEvent(ctx context.Context, e *models.WebhookEvent, to *models.OrderStatus, note string) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// ON CONFLICT waits for a concurrent delivery of the same event to
+	// finish, so exactly one of them gets the row back
+	err = tx.GetContext(ctx, &e.ReceivedAt,
+		`INSERT INTO webhook_events (id, type, order_id, payload)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (id) DO NOTHING
+		 RETURNING received_at`,
+		e.ID, e.Type, e.OrderID, string(e.Payload))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDuplicateEvent
+		}
+		return err
+	}
+	if to != nil && e.OrderID != nil {
+		if _, err := transitionOrderTx(ctx, tx, *e.OrderID, *to, nil, note); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,579 @@
+// Package store defines the persistence interfaces the HTTP handlers
+// depend on, with a Postgres implementation for production and an
+// in-memory implementation for tests and local experiments.
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist.
+	ErrNotFound = errors.New("store: not found")
+	// ErrDuplicateIdempotencyKey is returned by CreateOrder when the user
+	// already has an order placed with the same idempotency key.
+	ErrDuplicateIdempotencyKey = errors.New("store: duplicate idempotency key")
+	// ErrCartNotActive is returned by CreateOrder when the cart has already
+	// been checked out or is otherwise no longer active.
+	ErrCartNotActive = errors.New("store: cart is not active")
+	// ErrCartChanged is returned by CreateOrder when the cart no longer
+	// holds the lines the order was built from.
+	ErrCartChanged = errors.New("store: cart changed")
+	// ErrAdminExists is returned by BootstrapAdmin once any admin exists.
+	ErrAdminExists = errors.New("store: an admin already exists")
+	// ErrActivePayment is returned by CreatePayment when the order already
+	// has a payment that is pending, authorized or captured.
+	ErrActivePayment = errors.New("store: order already has an active payment")
+	// ErrDuplicateEvent is returned by RecordWebhookEvent for an event ID
+	// that has already been recorded.
+	ErrDuplicateEvent = errors.New("store: duplicate webhook event")
+	// ErrRefreshTokenReused is returned by RotateRefreshToken when an
+	// already rotated or revoked token is presented. Its family is revoked.
+	ErrRefreshTokenReused = errors.New("store: refresh token reused")
+	// ErrRefreshTokenExpired is returned by RotateRefreshToken for a token
+	// past its expiry.
+	ErrRefreshTokenExpired = errors.New("store: refresh token expired")
+	// ErrDuplicatePromotionCode is returned when a promotion is saved with
+	// a code another promotion already uses.
+	ErrDuplicatePromotionCode = errors.New("store: duplicate promotion code")
+	// ErrCouponUnavailable is returned by CreateOrder when the order's
+	// promotion was used up or deleted while the customer was checking out.
+	ErrCouponUnavailable = errors.New("store: coupon no longer available")
+	// ErrRefundExceeded is returned by CreateRefund when the refund would
+	// return more units of a line, or more money, than remain unrefunded.
+	ErrRefundExceeded = errors.New("store: refund exceeds what remains")
+	// ErrNoCapturedPayment is returned by CancelOrder for a paid order
+	// with no captured payment to refund, such as one a payment webhook
+	// marked paid.
+	ErrNoCapturedPayment = errors.New("store: order has no captured payment")
+	// ErrRefundNotFailed is returned by RetryRefund for a refund that has
+	// not failed.
+	ErrRefundNotFailed = errors.New("store: refund has not failed")
+	// ErrReturnExceeded is returned by CreateReturn when more units of a
+	// line would be returned than were ordered.
+	ErrReturnExceeded = errors.New("store: return exceeds ordered quantity")
+	// ErrDuplicateCategorySlug is returned when a category's slug is
+	// already taken.
+	ErrDuplicateCategorySlug = errors.New("store: duplicate category slug")
+	// ErrCategoryCycle is returned by UpdateCategory when the new parent is
+	// the category itself or one of its descendants.
+	ErrCategoryCycle = errors.New("store: category would become its own ancestor")
+	// ErrCategoryHasChildren is returned by DeleteCategory while the
+	// category still has subcategories.
+	ErrCategoryHasChildren = errors.New("store: category has subcategories")
+	// ErrDuplicateSKU is returned when a variant's SKU is already taken.
+	ErrDuplicateSKU = errors.New("store: duplicate SKU")
+	// ErrDuplicateVariant is returned when a product already has a variant
+	// with the same attributes.
+	ErrDuplicateVariant = errors.New("store: duplicate variant attributes")
+	// ErrProductHasVariants is returned by SetProductOptions while the
+	// product has variants built from its current options.
+	ErrProductHasVariants = errors.New("store: product has variants")
+	// ErrVariantOrdered is returned by DeleteVariant once the variant has
+	// been ordered.
+	ErrVariantOrdered = errors.New("store: variant has been ordered")
+)
+
+// StockShortage describes a product that cannot cover a requested quantity.
+type StockShortage struct {
+	ProductID int  `json:"product_id"`
+	VariantID *int `json:"variant_id,omitempty"`
+	Requested int  `json:"requested"`
+	Available int  `json:"available"`
+}
+
+// InsufficientStockError is returned when an order asks for more units than
+// are in stock. Nothing is written when it is returned.
+type InsufficientStockError struct {
+	Shortages []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("store: insufficient stock for %d product(s)", len(e.Shortages))
+}
+
+// CartLimits caps what a cart may hold: MaxLine units of any one line and
+// MaxTotal units in all. Zero means no limit.
+type CartLimits struct {
+	MaxLine  int
+	MaxTotal int
+}
+
+// sameLines reports whether a cart holding lines is the one items were
+// built from: each line has an item for the same product, variant and
+// quantity, and there are no other items.
+func sameLines(lines []models.CartItem, items []models.OrderItem) bool {
+	if len(lines) != len(items) {
+		return false
+	}
+	for _, l := range lines {
+		found := false
+		for _, it := range items {
+			if it.ProductID == l.ProductID && models.SameVariant(it.VariantID, l.VariantID) && it.Quantity == l.Quantity {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeLine is a cart line as MergeCart sees it, with its product's
+// currency.
+type mergeLine struct {
+	ProductID  int          `db:"product_id"`
+	VariantID  *int         `db:"variant_id"`
+	Quantity   int          `db:"quantity"`
+	AddedPrice models.Money `db:"added_price"`
+	Currency   string       `db:"currency"`
+}
+
+// mergeLines works out which of the guest's lines to write into the
+// user's cart, and at what quantity. Guest lines in another currency than
+// the user's cart are dropped; where both carts hold a line the larger
+// quantity wins; and quantities are capped by limits, later guest lines
+// giving way once the cart is full. Both slices are in line order.
+func mergeLines(user, guest []mergeLine, limits CartLimits) []mergeLine {
+	var currency string
+	total := 0
+	have := map[[2]int]int{}
+	key := func(l mergeLine) [2]int {
+		k := [2]int{l.ProductID}
+		if l.VariantID != nil {
+			k[1] = *l.VariantID
+		}
+		return k
+	}
+	for _, l := range user {
+		currency = l.Currency
+		total += l.Quantity
+		have[key(l)] = l.Quantity
+	}
+	var out []mergeLine
+	for _, l := range guest {
+		if currency == "" {
+			currency = l.Currency
+		}
+		if l.Currency != currency {
+			continue
+		}
+		cur := have[key(l)]
+		want := l.Quantity
+		if limits.MaxLine > 0 && want > limits.MaxLine {
+			want = limits.MaxLine
+		}
+		if limits.MaxTotal > 0 && total+want-cur > limits.MaxTotal {
+			want = limits.MaxTotal - total + cur
+		}
+		if want <= cur {
+			continue
+		}
+		total += want - cur
+		l.Quantity = want
+		out = append(out, l)
+	}
+	return out
+}
+
+// ProductSort is a column products can be listed by.
+type ProductSort string
+
+// Sortable product columns. Ties are always broken by ID.
+const (
+	SortByID        ProductSort = "id"
+	SortByPrice     ProductSort = "price"
+	SortByName      ProductSort = "name"
+	SortByCreatedAt ProductSort = "created_at"
+)
+
+// Valid reports whether s is a known sort column.
+func (s ProductSort) Valid() bool {
+	switch s {
+	case SortByID, SortByPrice, SortByName, SortByCreatedAt:
+		return true
+	}
+	return false
+}
+
+// ProductCursor is the sort key of the last product on a page. Only the
+// field for the active sort, plus ID, is consulted.
+type ProductCursor struct {
+	ID        int          `json:"id"`
+	Price     models.Money `json:"price,omitempty"`
+	Name      string       `json:"name,omitempty"`
+	CreatedAt time.Time    `json:"created_at,omitempty"`
+}
+
+// NewProductCursor returns the cursor that resumes listing after p.
+func NewProductCursor(p models.Product) ProductCursor {
+	return ProductCursor{ID: p.ID, Price: p.Price, Name: p.Name, CreatedAt: p.CreatedAt}
+}
+
+// value returns the cursor's key for the sort column by.
+func (c ProductCursor) value(by ProductSort) interface{} {
+	switch by {
+	case SortByPrice:
+		return c.Price
+	case SortByName:
+		return c.Name
+	case SortByCreatedAt:
+		return c.CreatedAt
+	}
+	return c.ID
+}
+
+// ProductFilter selects, orders and pages the products ListProducts
+// returns. The zero value lists everything by ID.
+type ProductFilter struct {
+	// Search is free text matched against name and description.
+	Search   string
+	MinPrice *models.Money
+	MaxPrice *models.Money
+	Sort     ProductSort
+	Desc     bool
+	// After resumes listing just past this key.
+	After *ProductCursor
+	// Limit caps the number of products returned; 0 means no limit.
+	Limit int
+	// Category, when set, keeps products assigned to that category or
+	// any of its descendants.
+	Category *int
+}
+
+// ProductStore persists the product catalog.
+type ProductStore interface {
+	// CreateProduct inserts p and fills in its ID and timestamps.
+	CreateProduct(ctx context.Context, p *models.Product) error
+	// ListProducts returns the products matching f, in f's order.
+	ListProducts(ctx context.Context, f ProductFilter) ([]models.Product, error)
+	GetProduct(ctx context.Context, id int) (models.Product, error)
+	// UpdateProduct overwrites the editable fields of the product with p.ID.
+	UpdateProduct(ctx context.Context, p *models.Product) error
+	DeleteProduct(ctx context.Context, id int) error
+}
+
+// CategoryStore persists the category tree and which products belong to
+// which categories.
+type CategoryStore interface {
+	// CreateCategory inserts c and fills in its ID and timestamps. It
+	// returns ErrNotFound if the parent does not exist and
+	// ErrDuplicateCategorySlug if the slug is taken.
+	CreateCategory(ctx context.Context, c *models.Category) error
+	// ListCategories returns every category, flat and ordered by name.
+	ListCategories(ctx context.Context) ([]models.Category, error)
+	GetCategory(ctx context.Context, id int) (models.Category, error)
+	// UpdateCategory overwrites the name, slug and parent of the category
+	// with c.ID. Besides the errors of CreateCategory it returns
+	// ErrCategoryCycle if the category would end up under itself.
+	UpdateCategory(ctx context.Context, c *models.Category) error
+	// DeleteCategory removes the category and its product assignments,
+	// or returns ErrCategoryHasChildren.
+	DeleteCategory(ctx context.Context, id int) error
+	// SetProductCategories replaces the product's categories. It returns
+	// ErrNotFound if the product or any category does not exist.
+	SetProductCategories(ctx context.Context, productID int, categoryIDs []int) error
+	// ProductCategories returns the categories the product is directly
+	// assigned to, ordered by name.
+	ProductCategories(ctx context.Context, productID int) ([]models.Category, error)
+}
+
+// VariantStore persists product options and the variants built from them.
+type VariantStore interface {
+	// SetProductOptions replaces the product's options. It returns
+	// ErrNotFound if the product does not exist and ErrProductHasVariants
+	// if it has any variants.
+	SetProductOptions(ctx context.Context, productID int, opts []models.ProductOption) error
+	// ProductOptions returns the product's options in display order.
+	ProductOptions(ctx context.Context, productID int) ([]models.ProductOption, error)
+	// CreateVariant inserts v and fills in its ID and timestamps. It
+	// returns ErrNotFound if the product does not exist, ErrDuplicateSKU
+	// and ErrDuplicateVariant.
+	CreateVariant(ctx context.Context, v *models.Variant) error
+	// ListVariants returns the product's variants ordered by ID.
+	ListVariants(ctx context.Context, productID int) ([]models.Variant, error)
+	GetVariant(ctx context.Context, id int) (models.Variant, error)
+	// UpdateVariant overwrites the SKU, price, stock and attributes of the
+	// variant with v.ID. A price change is recorded as
+	// EventProductPriceChanged.
+	UpdateVariant(ctx context.Context, v *models.Variant) error
+	// DeleteVariant removes the variant and any cart lines for it, or
+	// returns ErrVariantOrdered.
+	DeleteVariant(ctx context.Context, id int) error
+}
+
+// UserStore persists registered users.
+type UserStore interface {
+	// CreateUser inserts u and fills in its ID and creation time.
+	CreateUser(ctx context.Context, u *models.User) error
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	// SetUserRole changes the role of the user with the given email.
+	SetUserRole(ctx context.Context, email string, role models.Role) (models.User, error)
+	// BootstrapAdmin promotes the earliest registered user to admin. It
+	// returns ErrAdminExists if there already is one and ErrNotFound if
+	// nobody has signed up yet.
+	BootstrapAdmin(ctx context.Context) (models.User, error)
+}
+
+// CartStore persists shopping carts and their items.
+type CartStore interface {
+	// ActiveCart returns the user's active cart, creating one if none
+	// exists; created reports whether it did.
+	ActiveCart(ctx context.Context, userID int) (cart models.Cart, created bool, err error)
+	// CreateGuestCart creates an active cart with no user.
+	CreateGuestCart(ctx context.Context) (models.Cart, error)
+	GetCart(ctx context.Context, id int) (models.Cart, error)
+	// MergeCart moves the items of an active guest cart into the user's
+	// active cart and marks the guest cart merged. Where both carts hold
+	// a line the larger quantity wins. Lines in another currency than the
+	// user's cart are dropped and quantities are capped by limits. It
+	// returns ErrNotFound if the guest cart does not exist, is not a guest
+	// cart or is no longer active.
+	MergeCart(ctx context.Context, guestCartID, userID int, limits CartLimits) (models.Cart, error)
+	// AddCartItem adds quantity to the line for productID and variantID,
+	// which is nil for products without variants, creating it if needed.
+	// This and SetCartItem snapshot the current price and return
+	// ErrNotFound if the variant is not one of the product's.
+	AddCartItem(ctx context.Context, cartID, productID int, variantID *int, quantity int) error
+	// SetCartItem sets the line for productID and variantID to quantity,
+	// removing it when quantity is 0.
+	SetCartItem(ctx context.Context, cartID, productID int, variantID *int, quantity int) error
+	// RemoveCartItem deletes a line, returning ErrNotFound if there is none.
+	RemoveCartItem(ctx context.Context, cartID, productID int, variantID *int) error
+	// ClearCart deletes every line in the cart.
+	ClearCart(ctx context.Context, cartID int) error
+	// AcceptCartPrices records every line's current product price as the
+	// price the customer has seen.
+	AcceptCartPrices(ctx context.Context, cartID int) error
+	// CartLines returns the cart's items with current product name and price.
+	CartLines(ctx context.Context, cartID int) ([]models.CartLine, error)
+	// SetCartPromotion applies the promotion to the cart, or removes the
+	// cart's coupon when promotionID is nil.
+	SetCartPromotion(ctx context.Context, cartID int, promotionID *int) error
+	// SetCartShippingMethod records the shipping method chosen for the
+	// cart; "" clears it.
+	SetCartShippingMethod(ctx context.Context, cartID int, method string) error
+}
+
+// CartExpiryStore supports the job that retires idle carts.
+type CartExpiryStore interface {
+	// AbandonIdleCarts marks up to limit active carts not updated since
+	// idleSince inactive, recording EventCartAbandoned for each user cart
+	// that still holds items, and returns the carts it changed.
+	AbandonIdleCarts(ctx context.Context, idleSince time.Time, limit int) ([]models.Cart, error)
+	// DeleteGuestCarts removes guest carts not updated since idleSince,
+	// whatever their status, returning how many it deleted.
+	DeleteGuestCarts(ctx context.Context, idleSince time.Time) (int, error)
+}
+
+// OrderStore persists orders and their items.
+type OrderStore interface {
+	// CreateOrder inserts o and its items, decrements product stock and
+	// empties cartID, all or nothing. It fills in o's ID and creation time
+	// and returns *InsufficientStockError if any item is short. The cart is
+	// locked first: ErrCartNotActive is returned if it is no longer active
+	// and ErrCartChanged if its lines are not the items'. A non-empty
+	// idempotencyKey is recorded against the order. If o.Discount is set its
+	// promotion's usage limits are checked again under lock, returning
+	// ErrCouponUnavailable if they are reached, and the discount is stored.
+	// o.Shipping, if set, is stored alongside the order.
+	CreateOrder(ctx context.Context, o *models.Order, items []models.OrderItem, cartID int, idempotencyKey string) error
+	// GetOrderByIdempotencyKey finds the user's order placed with key.
+	GetOrderByIdempotencyKey(ctx context.Context, userID int, key string) (models.Order, error)
+	ListOrders(ctx context.Context, userID int) ([]models.Order, error)
+	GetOrder(ctx context.Context, id int) (models.Order, error)
+	// OrderLines returns the order's items with their product names.
+	OrderLines(ctx context.Context, orderID int) ([]models.OrderLine, error)
+	// TransitionOrder moves an order to status to and records the change in
+	// its history. changedBy is nil for changes made by the system. It
+	// returns *models.TransitionError if the lifecycle forbids the move.
+	TransitionOrder(ctx context.Context, orderID int, to models.OrderStatus, changedBy *int, note string) (models.Order, error)
+	// OrderStatusHistory returns the order's status changes, oldest first.
+	OrderStatusHistory(ctx context.Context, orderID int) ([]models.OrderStatusChange, error)
+	// OrderDiscount returns the discount the order was placed with, or
+	// ErrNotFound if it had none.
+	OrderDiscount(ctx context.Context, orderID int) (models.OrderDiscount, error)
+	// OrderShipping returns where the order ships, or ErrNotFound if it
+	// is not shipped.
+	OrderShipping(ctx context.Context, orderID int) (models.OrderShipping, error)
+}
+
+// AddressStore persists users' address books.
+type AddressStore interface {
+	// CreateAddress inserts a and fills in its ID and timestamps. A user's
+	// first address becomes their default; making a later one the default
+	// clears the flag on the others.
+	CreateAddress(ctx context.Context, a *models.Address) error
+	// ListAddresses returns the user's addresses, default first.
+	ListAddresses(ctx context.Context, userID int) ([]models.Address, error)
+	GetAddress(ctx context.Context, id int) (models.Address, error)
+	// DefaultAddress returns the user's default address, or ErrNotFound.
+	DefaultAddress(ctx context.Context, userID int) (models.Address, error)
+	// UpdateAddress overwrites the editable fields of the address with a.ID.
+	UpdateAddress(ctx context.Context, a *models.Address) error
+	DeleteAddress(ctx context.Context, id int) error
+}
+
+// PromotionStore persists coupon codes.
+type PromotionStore interface {
+	// CreatePromotion inserts p and fills in its ID and timestamps.
+	CreatePromotion(ctx context.Context, p *models.Promotion) error
+	ListPromotions(ctx context.Context) ([]models.Promotion, error)
+	GetPromotion(ctx context.Context, id int) (models.Promotion, error)
+	// GetPromotionByCode looks a promotion up by its upper-case code.
+	GetPromotionByCode(ctx context.Context, code string) (models.Promotion, error)
+	// UpdatePromotion overwrites the editable fields of the promotion with p.ID.
+	UpdatePromotion(ctx context.Context, p *models.Promotion) error
+	DeletePromotion(ctx context.Context, id int) error
+	// PromotionUsage counts the orders placed with the promotion, overall
+	// and by userID. Cancelled orders do not count.
+	PromotionUsage(ctx context.Context, promotionID, userID int) (total, byUser int, err error)
+	// ProductCategoryIDs returns the IDs of the categories each of
+	// productIDs is in, along with all their ancestors, for matching
+	// category-scoped promotions. Products in no category are left out.
+	ProductCategoryIDs(ctx context.Context, productIDs []int) (map[int][]int, error)
+}
+
+// TokenStore persists refresh tokens and revoked access tokens.
+type TokenStore interface {
+	// CreateRefreshToken inserts t and fills in its ID and creation time.
+	CreateRefreshToken(ctx context.Context, t *models.RefreshToken) error
+	// RotateRefreshToken revokes the live token with hash oldHash and
+	// inserts next in its place, copying the user and family. It returns
+	// the revoked token. Presenting a token that was already revoked
+	// revokes its whole family and returns ErrRefreshTokenReused.
+	RotateRefreshToken(ctx context.Context, oldHash string, next *models.RefreshToken) (models.RefreshToken, error)
+	// RevokeRefreshFamily revokes every token in the family of the user's
+	// token with the given hash.
+	RevokeRefreshFamily(ctx context.Context, userID int, hash string) error
+	// RevokeAccessToken blocks the access token with the given ID until it
+	// expires.
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	AccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RefundStore cancels orders and records money returned to customers.
+type RefundStore interface {
+	// CancelOrder moves the order to cancelled and returns its items to
+	// stock in one transaction. The promotion it used becomes available
+	// again, since cancelled orders do not count towards usage limits. If
+	// the order was paid, a pending refund of what remains of its captured
+	// payment is recorded and returned; it is nil otherwise. It returns
+	// *models.TransitionError if the order can no longer be cancelled, and
+	// ErrNoCapturedPayment if it is paid but there is nothing to refund.
+	CancelOrder(ctx context.Context, orderID int, changedBy *int, note string) (models.Order, *models.Refund, error)
+	// CreateRefund records r as a pending refund of units of one order
+	// line, filling in its ID, status and timestamps. It returns
+	// ErrNotFound if the line is not on the order or the payment is not
+	// its captured payment, and ErrRefundExceeded if too much would be
+	// refunded.
+	CreateRefund(ctx context.Context, r *models.Refund) error
+	// FinishRefund saves r's status and failure reason once the provider
+	// has answered. When a refund succeeds EventOrderRefunded is recorded,
+	// the payment is marked refunded once all of it has been returned, and
+	// the order moves to refunded once every unit has been.
+	FinishRefund(ctx context.Context, r *models.Refund) error
+	// RetryRefund moves the order's failed refund back to pending, so it
+	// can be sent to the provider again, and returns it. It returns
+	// ErrNotFound if the refund is not on the order, ErrRefundNotFailed if
+	// it has not failed, and ErrRefundExceeded if refunds made since would
+	// leave too little for it.
+	RetryRefund(ctx context.Context, orderID, refundID int) (models.Refund, error)
+	// ListRefunds returns the order's refunds, oldest first.
+	ListRefunds(ctx context.Context, orderID int) ([]models.Refund, error)
+}
+
+// ReturnStore tracks return requests (RMAs). Returns are loaded with
+// their items.
+type ReturnStore interface {
+	// CreateReturn inserts rt and its items as a requested return, filling
+	// in its ID and timestamps. It returns ErrNotFound if an item is not on
+	// the order and ErrReturnExceeded if more units would be returned than
+	// were ordered, counting other returns that were not rejected.
+	CreateReturn(ctx context.Context, rt *models.Return) error
+	GetReturn(ctx context.Context, id int) (models.Return, error)
+	// ListReturns returns the order's returns, oldest first.
+	ListReturns(ctx context.Context, orderID int) ([]models.Return, error)
+	// ApproveReturn approves the return and records refunds as pending
+	// against it, all or nothing. Each refund is checked as by CreateRefund
+	// and filled in. It returns *models.ReturnTransitionError if the return
+	// is no longer awaiting a decision.
+	ApproveReturn(ctx context.Context, id int, changedBy *int, note string, refunds []models.Refund) (models.Return, error)
+	// RejectReturn rejects the return, or returns
+	// *models.ReturnTransitionError if it is no longer awaiting a decision.
+	RejectReturn(ctx context.Context, id int, changedBy *int, note string) (models.Return, error)
+	// ReceiveReturn marks an approved return's items as arrived back and,
+	// if restock is set, puts them back into stock in the same
+	// transaction. It returns *models.ReturnTransitionError if the return
+	// was not approved.
+	ReceiveReturn(ctx context.Context, id int, changedBy *int, note string, restock bool) (models.Return, error)
+}
+
+// PaymentStore persists attempts to pay for orders.
+type PaymentStore interface {
+	// CreatePayment inserts p and fills in its ID and timestamps. It
+	// returns ErrActivePayment if another attempt for the order is still
+	// open or has succeeded.
+	CreatePayment(ctx context.Context, p *models.Payment) error
+	// UpdatePayment saves p's status, provider reference and failure reason.
+	UpdatePayment(ctx context.Context, p *models.Payment) error
+	// CapturePayment marks p captured and moves its order to paid in one
+	// transaction, returning the order. It returns *models.TransitionError
+	// if the order can no longer be paid, in which case nothing changes.
+	CapturePayment(ctx context.Context, p *models.Payment, changedBy *int) (models.Order, error)
+	// ListPayments returns the order's payments, oldest first.
+	ListPayments(ctx context.Context, orderID int) ([]models.Payment, error)
+}
+
+// WebhookStore records inbound webhook events.
+type WebhookStore interface {
+	// RecordWebhookEvent stores e and, if to is non-nil, moves e's order to
+	// *to in the same transaction. It returns ErrDuplicateEvent if e was
+	// recorded before and *models.TransitionError if the order cannot make
+	// the move; in both cases nothing changes.
+	RecordWebhookEvent(ctx context.Context, e *models.WebhookEvent, to *models.OrderStatus, note string) error
+}
+
+// OutboxStore hands recorded domain events to the dispatcher. Events are
+// written by the other stores in the same transaction as their change.
+type OutboxStore interface {
+	// ClaimOutboxEvents leases up to limit due, unpublished events, oldest
+	// first. Claimed events are hidden from other claimers for lease, after
+	// which they are handed out again unless marked published.
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEvent, error)
+	MarkOutboxPublished(ctx context.Context, id int64) error
+	// MarkOutboxFailed records a failed delivery and makes the event due
+	// again at retryAt.
+	MarkOutboxFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+}
+
+// SubscriptionStore persists outbound webhook subscriptions and the log of
+// attempts to deliver events to them.
+type SubscriptionStore interface {
+	// CreateWebhookSubscription inserts sub and fills in its ID and timestamps.
+	CreateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, id int) (models.WebhookSubscription, error)
+	// UpdateWebhookSubscription overwrites the URL, event types, secret and
+	// active flag of the subscription with sub.ID.
+	UpdateWebhookSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	DeleteWebhookSubscription(ctx context.Context, id int) error
+	// SubscriptionsForEvent returns the active subscriptions matching eventType.
+	SubscriptionsForEvent(ctx context.Context, eventType string) ([]models.WebhookSubscription, error)
+	// RecordWebhookDelivery logs an attempt and fills in its ID and time.
+	RecordWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error
+	// WebhookDelivered reports whether eventID has been delivered to the
+	// subscription successfully.
+	WebhookDelivered(ctx context.Context, subscriptionID int, eventID int64) (bool, error)
+	// ListWebhookDeliveries returns the subscription's most recent attempts,
+	// newest first.
+	ListWebhookDeliveries(ctx context.Context, subscriptionID int) ([]models.WebhookDelivery, error)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Heisenberg270/ecommerce-go/tax"
+)
+
+// newTaxCalculator builds the tax table from TAX_RATES, a list such as
+// "US-CA=7.25,GB=20,GB/books=0", and TAX_MODE (exclusive, the default, or
+// inclusive). TAX_REGION is the region taxed when an order names none.
+func newTaxCalculator() (tax.Calculator, string, error) {
+	t := &tax.Table{Mode: tax.ModeExclusive}
+	if v := os.Getenv("TAX_MODE"); v != "" {
+		t.Mode = tax.Mode(v)
+		if !t.Mode.Valid() {
+			return nil, "", fmt.Errorf("unknown TAX_MODE %q", v)
+		}
+	}
+	rates, err := tax.ParseRates(os.Getenv("TAX_RATES"))
+	if err != nil {
+		return nil, "", fmt.Errorf("TAX_RATES: %w", err)
+	}
+	t.Rates = rates
+	return t, os.Getenv("TAX_REGION"), nil
+}
@@ -0,0 +1,98 @@
+package tax
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Rate is the tax rate for products of Class sold into Region, in basis
+// points (hundredths of a percent). An empty Class applies to every class
+// without a rate of its own.
+type Rate struct {
+	Region      string
+	Class       string
+	BasisPoints int
+}
+
+// Table is a Calculator driven by a fixed list of rates. A region such as
+// "US-CA" falls back to the rates of its country, "US", and regions with
+// no rate at all are not taxed. The zero Table charges no tax.
+type Table struct {
+	Mode  Mode
+	Rates []Rate
+}
+
+var _ Calculator = (*Table)(nil)
+
+// Calculate implements Calculator. Each line's tax is rounded half up to
+// the cent.
+func (t *Table) Calculate(_ context.Context, req Request) (Result, error) {
+	amounts := make([]models.Money, len(req.Lines))
+	for i, l := range req.Lines {
+		amounts[i] = l.UnitPrice.Mul(l.Quantity)
+	}
+	discounts := Allocate(req.Discount, amounts)
+
+	res := Result{Lines: make([]models.Money, len(req.Lines)), Inclusive: t.Mode == ModeInclusive}
+	for i, l := range req.Lines {
+		base := amounts[i] - discounts[i]
+		bp := models.Money(t.rate(req.Region, l.TaxClass))
+		if res.Inclusive {
+			res.Lines[i] = (base*bp + (10000+bp)/2) / (10000 + bp)
+		} else {
+			res.Lines[i] = (base*bp + 5000) / 10000
+		}
+		res.Total += res.Lines[i]
+	}
+	return res, nil
+}
+
+// rate finds the rate for class in region, trying the region and then its
+// country, each first for the class and then for any class.
+func (t *Table) rate(region, class string) int {
+	regions := []string{region}
+	if country, _, ok := strings.Cut(region, "-"); ok {
+		regions = append(regions, country)
+	}
+	for _, r := range regions {
+		for _, c := range []string{class, ""} {
+			for _, rate := range t.Rates {
+				if strings.EqualFold(rate.Region, r) && rate.Class == c {
+					return rate.BasisPoints
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// ParseRates reads a comma-separated list of region[/class]=percent
+// entries, such as "US-CA=7.25,GB=20,GB/books=0".
+func ParseRates(s string) ([]Rate, error) {
+	var rates []Rate
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, pct, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("tax rate %q: want region[/class]=percent", entry)
+		}
+		region, class, _ := strings.Cut(key, "/")
+		if region == "" {
+			return nil, fmt.Errorf("tax rate %q: missing region", entry)
+		}
+		f, err := strconv.ParseFloat(pct, 64)
+		if err != nil || f < 0 || f > 100 {
+			return nil, fmt.Errorf("tax rate %q: percent must be between 0 and 100", entry)
+		}
+		rates = append(rates, Rate{Region: region, Class: class, BasisPoints: int(math.Round(f * 100))})
+	}
+	return rates, nil
+}
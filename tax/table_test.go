@@ -0,0 +1,77 @@
+package tax
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+func TestTableCalculate(t *testing.T) {
+	rates := []Rate{
+		{Region: "US", BasisPoints: 500},
+		{Region: "US-CA", BasisPoints: 725},
+		{Region: "US-CA", Class: "food", BasisPoints: 0},
+		{Region: "GB", BasisPoints: 2000},
+	}
+	lines := []Line{
+		{ProductID: 1, TaxClass: "standard", Quantity: 2, UnitPrice: 1000},
+		{ProductID: 2, TaxClass: "food", Quantity: 1, UnitPrice: 500},
+	}
+	tests := []struct {
+		name     string
+		mode     Mode
+		region   string
+		discount models.Money
+		want     []models.Money
+	}{
+		{"region with class override", ModeExclusive, "US-CA", 0, []models.Money{145, 0}},
+		{"falls back to country", ModeExclusive, "US-NY", 0, []models.Money{100, 25}},
+		{"untaxed region", ModeExclusive, "FR", 0, []models.Money{0, 0}},
+		{"inclusive", ModeInclusive, "GB", 0, []models.Money{333, 83}},
+		{"discount spread over lines", ModeExclusive, "US", 500, []models.Money{80, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &Table{Mode: tt.mode, Rates: rates}
+			res, err := table.Calculate(context.Background(), Request{Region: tt.region, Lines: lines, Discount: tt.discount})
+			if err != nil {
+				t.Fatalf("Calculate: %v", err)
+			}
+			if !reflect.DeepEqual(res.Lines, tt.want) || res.Total != tt.want[0]+tt.want[1] {
+				t.Errorf("got %+v; want lines %v", res, tt.want)
+			}
+			if res.Inclusive != (tt.mode == ModeInclusive) {
+				t.Errorf("Inclusive = %v; want %v", res.Inclusive, tt.mode == ModeInclusive)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	got := Allocate(100, []models.Money{1, 1, 1, 0})
+	if want := []models.Money{33, 33, 34, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Allocate = %v; want %v", got, want)
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates("US-CA=7.25, GB=20,GB/books=0")
+	if err != nil {
+		t.Fatalf("ParseRates: %v", err)
+	}
+	want := []Rate{
+		{Region: "US-CA", BasisPoints: 725},
+		{Region: "GB", BasisPoints: 2000},
+		{Region: "GB", Class: "books", BasisPoints: 0},
+	}
+	if !reflect.DeepEqual(rates, want) {
+		t.Errorf("got %+v; want %+v", rates, want)
+	}
+	for _, bad := range []string{"GB", "=5", "GB=abc", "GB=150"} {
+		if _, err := ParseRates(bad); err == nil {
+			t.Errorf("ParseRates(%q) succeeded; want error", bad)
+		}
+	}
+}
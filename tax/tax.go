@@ -0,0 +1,83 @@
+// Package tax works out the sales tax owed on an order. Handlers talk to a
+// Calculator; the built-in Table applies configured rates by region and
+// product tax class.
+package tax
+
+import (
+	"context"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+)
+
+// Mode says whether catalog prices already include tax.
+type Mode string
+
+// Pricing modes. Exclusive prices have tax added on top; inclusive prices
+// contain it, so the tax is carved out of them and the total is unchanged.
+const (
+	ModeExclusive Mode = "exclusive"
+	ModeInclusive Mode = "inclusive"
+)
+
+// Valid reports whether m is a known mode.
+func (m Mode) Valid() bool {
+	return m == ModeExclusive || m == ModeInclusive
+}
+
+// Line is one order line to be taxed.
+type Line struct {
+	ProductID int
+	TaxClass  string
+	Quantity  int
+	UnitPrice models.Money
+}
+
+// Request asks for the tax on an order shipped to Region, such as "US-CA"
+// or "GB". Discount is an order-level reduction, spread over the lines in
+// proportion to their value before tax is worked out.
+type Request struct {
+	Region   string
+	Currency string
+	Lines    []Line
+	Discount models.Money
+}
+
+// Result is the tax on each line of a Request, in the same order, and
+// their sum. Inclusive reports whether the amounts are already part of the
+// line prices.
+type Result struct {
+	Lines     []models.Money
+	Total     models.Money
+	Inclusive bool
+}
+
+// Calculator works out tax. Implementations backed by a remote service
+// should honour ctx.
+type Calculator interface {
+	Calculate(ctx context.Context, req Request) (Result, error)
+}
+
+// Allocate splits amount over weights in proportion to each weight,
+// rounding down and giving the remainder to the last non-zero weight so
+// the parts sum to amount.
+func Allocate(amount models.Money, weights []models.Money) []models.Money {
+	parts := make([]models.Money, len(weights))
+	var total models.Money
+	last := -1
+	for i, w := range weights {
+		total += w
+		if w > 0 {
+			last = i
+		}
+	}
+	if amount == 0 || last < 0 {
+		return parts
+	}
+	var given models.Money
+	for i, w := range weights {
+		parts[i] = amount * w / total
+		given += parts[i]
+	}
+	parts[last] += amount - given
+	return parts
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/payments"
+)
+
+// runWebhook implements `server webhook sign`, which reads an event body on
+// stdin and prints the signature header a gateway would send with it, so
+// fake events can be posted with curl:
+//
+//	sig=$(./server webhook sign < event.json)
+//	curl -H "X-Webhook-Signature: $sig" --data @event.json .../webhooks/payments
+func runWebhook(args []string) {
+	if len(args) != 1 || args[0] != "sign" {
+		fmt.Fprintln(os.Stderr, "usage: server webhook sign < event.json")
+		os.Exit(2)
+	}
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		log.Fatal("PAYMENT_WEBHOOK_SECRET is not set")
+	}
+	body, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Fatalf("Failed to read event: %v", err)
+	}
+	fmt.Println(payments.SignWebhook(secret, body, time.Now()))
+}
@@ -0,0 +1,115 @@
+// Package webhooks delivers domain events to the URLs integrators have
+// subscribed. It runs as an events.Dispatcher subscriber, so delivery is
+// at least once and failed deliveries are retried with the dispatcher's
+// exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+// Headers sent with every delivery besides payments.SignatureHeader, which
+// is computed exactly as for inbound payment webhooks.
+const (
+	EventTypeHeader = "X-Webhook-Event"
+	EventIDHeader   = "X-Webhook-Event-ID"
+)
+
+// Payload is the JSON body POSTed to subscribers.
+type Payload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Notifier posts events to matching subscriptions and logs every attempt.
+type Notifier struct {
+	Store  store.SubscriptionStore
+	Client *http.Client
+}
+
+// NewNotifier returns a Notifier whose requests time out after 10 seconds.
+func NewNotifier(s store.SubscriptionStore) *Notifier {
+	return &Notifier{Store: s, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Deliver is an events.Handler. It posts ev to every matching subscription
+// that has not yet accepted it and fails if any of them did not, so that
+// the dispatcher retries just those later.
+func (n *Notifier) Deliver(ctx context.Context, ev models.OutboxEvent) error {
+	subs, err := n.Store.SubscriptionsForEvent(ctx, ev.Type)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(Payload{ID: ev.ID, Type: ev.Type, CreatedAt: ev.CreatedAt, Data: ev.Payload})
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, sub := range subs {
+		done, err := n.Store.WebhookDelivered(ctx, sub.ID, ev.ID)
+		if err != nil {
+			return err
+		}
+		if done {
+			continue
+		}
+		d := n.post(ctx, sub, ev, body)
+		if err := n.Store.RecordWebhookDelivery(ctx, &d); err != nil {
+			return err
+		}
+		if !d.Succeeded {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("webhook delivery failed for %d subscription(s)", failed)
+	}
+	return nil
+}
+
+// post makes one delivery attempt. Any 2xx response counts as accepted.
+func (n *Notifier) post(ctx context.Context, sub models.WebhookSubscription, ev models.OutboxEvent, body []byte) (d models.WebhookDelivery) {
+	d = models.WebhookDelivery{
+		SubscriptionID: sub.ID,
+		EventID:        ev.ID,
+		EventType:      ev.Type,
+		Attempt:        ev.Attempts + 1,
+	}
+	start := time.Now()
+	defer func() { d.DurationMS = int(time.Since(start).Milliseconds()) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventTypeHeader, ev.Type)
+	req.Header.Set(EventIDHeader, strconv.FormatInt(ev.ID, 10))
+	req.Header.Set(payments.SignatureHeader, payments.SignWebhook(sub.Secret, body, time.Now()))
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+	resp.Body.Close()
+	d.StatusCode = &resp.StatusCode
+	d.Succeeded = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !d.Succeeded {
+		d.Error = resp.Status
+	}
+	return d
+}
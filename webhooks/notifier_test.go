@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Heisenberg270/ecommerce-go/models"
+	"github.com/Heisenberg270/ecommerce-go/payments"
+	"github.com/Heisenberg270/ecommerce-go/store"
+)
+
+func TestNotifierDeliver(t *testing.T) {
+	ctx := context.Background()
+	var got []Payload
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sig := r.Header.Get(payments.SignatureHeader)
+		if err := payments.VerifyWebhook("erp-secret", sig, body, time.Now(), time.Minute); err != nil {
+			t.Errorf("receiver: %v", err)
+		}
+		var p Payload
+		json.Unmarshal(body, &p)
+		got = append(got, p)
+	}))
+	defer up.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	st := store.NewMemory()
+	ok := models.WebhookSubscription{URL: up.URL, EventTypes: []string{models.EventOrderPlaced}, Secret: "erp-secret", Active: true}
+	bad := models.WebhookSubscription{URL: down.URL, EventTypes: []string{"*"}, Secret: "x", Active: true}
+	other := models.WebhookSubscription{URL: up.URL, EventTypes: []string{models.EventUserSignedUp}, Secret: "x", Active: true}
+	for _, s := range []*models.WebhookSubscription{&ok, &bad, &other} {
+		st.CreateWebhookSubscription(ctx, s)
+	}
+
+	n := NewNotifier(st)
+	ev := models.OutboxEvent{ID: 9, Type: models.EventOrderPlaced, Payload: json.RawMessage(`{"order_id":1}`)}
+	if err := n.Deliver(ctx, ev); err == nil {
+		t.Fatal("Deliver succeeded although one receiver is down")
+	}
+	if len(got) != 1 || got[0].ID != 9 || string(got[0].Data) != `{"order_id":1}` {
+		t.Fatalf("receiver got %+v; want event 9 once", got)
+	}
+
+	// the retry only goes to the subscription that failed
+	ev.Attempts = 1
+	n.Deliver(ctx, ev)
+	if len(got) != 1 {
+		t.Errorf("healthy receiver got %d deliveries; want 1", len(got))
+	}
+	log, _ := st.ListWebhookDeliveries(ctx, bad.ID)
+	if len(log) != 2 || log[0].Attempt != 2 || log[0].Succeeded || *log[0].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("failing subscription log = %+v; want two failed attempts, newest first", log)
+	}
+	if log, _ := st.ListWebhookDeliveries(ctx, other.ID); len(log) != 0 {
+		t.Errorf("unsubscribed event was delivered: %+v", log)
+	}
+}